@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// maxParallelActivations bounds concurrent activation requests so large bulk
+// activations don't trip ARM throttling
+const maxParallelActivations = 4
+
+// Requests that are accepted but not yet provisioned are polled for a short
+// while so the status list can show the final state
+const (
+	provisionPollInterval = 2 * time.Second
+	provisionPollTimeout  = 30 * time.Second
+)
+
+// primeRequestKeys lets activations repeated within a few minutes, even
+// across runs, reuse the earlier request
+func primeRequestKeys() {
+	if keys, err := state.RequestKeys(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load recent request IDs: %v\n", err)
+	} else {
+		azure.SetRequestKeys(keys)
+	}
+	azure.OnRequestKeys = func(keys map[string]azure.RequestKey) {
+		if err := state.SaveRequestKeys(keys); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save recent request IDs: %v\n", err)
+		}
+	}
+}
+
+// activationOutcome is the result of submitting one activation request
+type activationOutcome struct {
+	role   azure.RoleAssignment
+	result *azure.ActivationResult
+	err    error
+}
+
+// submitActivations sends activation requests concurrently while showing a
+// live status per role, and returns the outcomes in the order of roles
+func submitActivations(roles []azure.RoleAssignment, justification string) ([]activationOutcome, error) {
+	labels := make([]string, len(roles))
+	for i, r := range roles {
+		labels[i] = fmt.Sprintf("%s on %s", r.RoleName, r.ScopeName)
+	}
+
+	outcomes := make([]activationOutcome, len(roles))
+	err := ui.TrackActivations(labels, func(update func(ui.ActivationUpdate)) {
+		sem := make(chan struct{}, maxParallelActivations)
+		var wg sync.WaitGroup
+		for i, role := range roles {
+			wg.Add(1)
+			go func() {
+				defer handlePanic()
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				update(ui.ActivationUpdate{Index: i, State: ui.ActivationSubmitted})
+				result, err := azure.ActivateRole(activationRequest(role, justification))
+				if err == nil {
+					result = awaitProvisioning(role, result)
+				}
+				outcomes[i] = activationOutcome{role: role, result: result, err: err}
+				update(outcomeUpdate(i, outcomes[i]))
+			}()
+		}
+		wg.Wait()
+	}, nonInteractive)
+
+	return outcomes, err
+}
+
+// awaitProvisioning polls a request that was accepted but not yet provisioned.
+// Requests pending approval are returned as they are.
+func awaitProvisioning(role azure.RoleAssignment, result *azure.ActivationResult) *azure.ActivationResult {
+	deadline := time.Now().Add(provisionPollTimeout)
+	for !result.IsProvisioned() && !result.IsPendingApproval() && !result.IsFailed() && !result.IsDryRun() && time.Now().Before(deadline) {
+		time.Sleep(provisionPollInterval)
+		latest, err := azure.GetScheduleRequest(role.Scope, result.RequestID)
+		if err != nil {
+			break
+		}
+		result = latest
+	}
+	return result
+}
+
+// outcomeUpdate converts the final outcome of a request into a status update
+func outcomeUpdate(index int, o activationOutcome) ui.ActivationUpdate {
+	switch {
+	case o.err != nil:
+		return ui.ActivationUpdate{Index: index, State: ui.ActivationFailed, Detail: o.err.Error()}
+	case o.result.IsPendingApproval():
+		return ui.ActivationUpdate{Index: index, State: ui.ActivationPending, Detail: "request " + o.result.RequestID}
+	case o.result.IsFailed():
+		return ui.ActivationUpdate{Index: index, State: ui.ActivationFailed, Detail: o.result.Status}
+	case o.result.IsProvisioned():
+		return ui.ActivationUpdate{Index: index, State: ui.ActivationProvisioned, Detail: fmt.Sprintf("%d minutes", duration)}
+	default:
+		return ui.ActivationUpdate{Index: index, State: ui.ActivationSubmitted, Detail: o.result.Status}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var force bool
+
+// findActive returns the active assignment of role on the same scope, if any
+func findActive(role azure.RoleAssignment, active []azure.RoleAssignment) *azure.RoleAssignment {
+	for i, a := range active {
+		if strings.EqualFold(path.Base(a.RoleDefinitionID), path.Base(role.RoleDefinitionID)) &&
+			strings.EqualFold(a.Scope, role.Scope) {
+			return &active[i]
+		}
+	}
+	return nil
+}
+
+// formatRemaining renders the time left until t, e.g. 1h20m
+func formatRemaining(t time.Time) string {
+	d := time.Until(t).Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh%dm", h, m)
+}
+
+// skipAlreadyActive drops roles that are already active so no duplicate
+// request is submitted. For each one it reports the remaining time and, when
+// interactive, offers to extend it instead. It returns the roles still to
+// activate and those to extend. --force keeps every role.
+func skipAlreadyActive(roles []azure.RoleAssignment) (activate, extend []azure.RoleAssignment, err error) {
+	if force {
+		return roles, nil, nil
+	}
+
+	active, err := ui.SpinWithResult("Checking active roles", func() ([]azure.RoleAssignment, error) {
+		return azure.GetActiveRoleAssignments()
+	}, nonInteractive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not check for already active roles: %v\n", err)
+		return roles, nil, nil
+	}
+	saveActiveCache(active)
+
+	for _, role := range roles {
+		current := findActive(role, active)
+		if current == nil {
+			activate = append(activate, role)
+			continue
+		}
+
+		if current.EndDateTime == nil {
+			infof("%s\n", ui.SubtleStyle.Render(fmt.Sprintf("%s on %s is permanently assigned, skipping", role.RoleName, role.ScopeName)))
+			continue
+		}
+
+		remaining := formatRemaining(*current.EndDateTime)
+		infof("%s\n", ui.TitleStyle.Render(fmt.Sprintf("%s on %s is already active (%s remaining, until %s)",
+			role.RoleName, role.ScopeName, remaining, ui.FormatTime(*current.EndDateTime))))
+
+		if nonInteractive || quiet {
+			continue
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Extend it by %d minutes instead?", duration))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			extend = append(extend, role)
+		}
+	}
+
+	return activate, extend, nil
+}
+
+// extendRoles requests an extension of each role by minutes
+func extendRoles(roles []azure.RoleAssignment, minutes int, justification string) error {
+	pending := false
+	for _, role := range roles {
+		result, err := ui.SpinWithResult(
+			fmt.Sprintf("Extending %s on %s", role.RoleName, role.ScopeName),
+			func() (*azure.ActivationResult, error) { return azure.ExtendRole(role, minutes, justification) },
+			nonInteractive,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to extend %s on %s: %w", role.RoleName, role.ScopeName, err)
+		}
+		if result.IsDryRun() {
+			continue
+		}
+		if result.IsPendingApproval() {
+			pending = true
+			infof("%s\n", ui.TitleStyle.Render(fmt.Sprintf("Extension of %s submitted and pending approval", role.RoleName)))
+			continue
+		}
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Extended %s by %d minutes", role.RoleName, minutes)))
+	}
+
+	if pending {
+		return withExitCode(exitPendingApproval, nil)
+	}
+	return nil
+}
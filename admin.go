@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	adminPrincipal string
+	adminRole      string
+	adminScope     string
+	adminDuration  string
+	adminMinutes   int
+)
+
+func adminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Manage other principals' PIM assignments (for PIM administrators)",
+		Long: `Commands for PIM administrators. They act on other users' and groups'
+assignments, so the signed-in user needs permission to manage role
+assignments at the scope, e.g. User Access Administrator or Owner.`,
+	}
+
+	assign := &cobra.Command{
+		Use:   "assign-eligible",
+		Short: "Make a user or group eligible for a role at a scope",
+		Long: `Submits an AdminAssign roleEligibilityScheduleRequest making the principal
+eligible for the role at the scope. --duration is an ISO 8601 duration such
+as P180D, or a number of days like 180d; without it the eligibility doesn't
+expire, which the role management policy may not allow.
+
+Without --principal, you're asked to search for a user or group.`,
+		Args: cobra.NoArgs,
+		RunE: runAdminAssignEligible,
+	}
+	addAdminFlags(assign)
+	assign.Flags().StringVar(&adminDuration, "duration", "", "How long the eligibility lasts, e.g. P180D or 180d (default no expiration)")
+	cmd.AddCommand(assign)
+
+	remove := &cobra.Command{
+		Use:   "remove-eligible",
+		Short: "Remove a user's or group's eligibility for a role at a scope",
+		Long: `Submits an AdminRemove roleEligibilityScheduleRequest ending the principal's
+eligibility for the role at the scope.
+
+Without --principal, you're asked to search for a user or group.`,
+		Args: cobra.NoArgs,
+		RunE: runAdminRemoveEligible,
+	}
+	addAdminFlags(remove)
+	cmd.AddCommand(remove)
+
+	assignActive := &cobra.Command{
+		Use:   "assign",
+		Short: "Grant a user or group a role for a limited time",
+		Long: `Submits an AdminAssign roleAssignmentScheduleRequest giving the principal an
+active assignment of the role at the scope for --duration, e.g. to grant a
+teammate temporary access during an incident. The assignment ends on its own;
+the role management policy caps how long it may last.
+
+Without --principal, you're asked to search for a user or group.`,
+		Args: cobra.NoArgs,
+		RunE: runAdminAssign,
+	}
+	addAdminFlags(assignActive)
+	assignActive.Flags().VarP(newMinutesValue(60, &adminMinutes), "duration", "d", "How long the assignment lasts, in minutes or like 2h")
+	assignActive.Flags().BoolVar(&overrideGuardrail, "override-guardrail", false, "Grant even if it breaks a guardrail from the config file; recorded in the audit log")
+	assignActive.Flags().StringVar(&breakGlass, "break-glass", "", "Confirm granting this break-glass role without typing its name")
+	cmd.AddCommand(assignActive)
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List every principal's eligible and active assignments at a scope",
+		Long: `Lists the eligible and active PIM assignments of every user, group and
+service principal at the scope, including those inherited from parent scopes,
+for access reviews. Filter with --principal and --role, which take glob
+patterns matched against names, UPNs and IDs.`,
+		Args: cobra.NoArgs,
+		RunE: runAdminList,
+	}
+	list.Flags().StringVar(&adminScope, "scope", "", "Scope, e.g. /subscriptions/<id>")
+	list.Flags().StringVar(&adminPrincipal, "principal", "", "Only list principals whose name, UPN or ID matches this glob pattern")
+	list.Flags().StringVar(&adminRole, "role", "", "Only list roles whose name matches this glob pattern")
+	cmd.AddCommand(list)
+
+	cmd.AddCommand(policyReportCmd())
+
+	return cmd
+}
+
+func runAdminList(cmd *cobra.Command, args []string) error {
+	if adminScope == "" || !strings.HasPrefix(adminScope, "/") {
+		return fmt.Errorf("--scope is required, e.g. /subscriptions/<id>")
+	}
+	for _, pattern := range []string{adminPrincipal, adminRole} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	assignments, err := ui.SpinWithResult("Fetching assignments at "+adminScope, func() ([]azure.RoleAssignment, error) {
+		return azure.GetAssignmentsAtScope(adminScope)
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	var matched []azure.RoleAssignment
+	for _, a := range assignments {
+		if adminRole != "" && !globMatch(adminRole, a.RoleName) {
+			continue
+		}
+		if adminPrincipal != "" && !principalMatches(adminPrincipal, a) {
+			continue
+		}
+		matched = append(matched, a)
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return strings.ToLower(ui.PrincipalName(matched[i])) < strings.ToLower(ui.PrincipalName(matched[j]))
+	})
+
+	if outputFormat == "json" {
+		out, err := ui.RenderRolesJSON(matched)
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Print(out)
+		return nil
+	}
+	if len(matched) == 0 {
+		infof("No assignments found.\n")
+		return nil
+	}
+	infof("Found %d assignment(s):\n\n", len(matched))
+	fmt.Print(ui.RenderAssignmentsTable(matched))
+	return nil
+}
+
+// principalMatches reports whether the assignment's principal name, email or ID matches pattern
+func principalMatches(pattern string, a azure.RoleAssignment) bool {
+	if globMatch(pattern, a.PrincipalID) {
+		return true
+	}
+	if p := a.ExpandedProperties; p != nil {
+		return globMatch(pattern, p.Principal.DisplayName) || globMatch(pattern, p.Principal.Email)
+	}
+	return false
+}
+
+// addAdminFlags registers the flags naming an assignment for admin commands
+func addAdminFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&adminPrincipal, "principal", "", "User UPN, object ID or exact group name")
+	cmd.Flags().StringVar(&adminRole, "role", "", "Role name, e.g. Contributor")
+	cmd.Flags().StringVar(&adminScope, "scope", "", "Scope, e.g. /subscriptions/<id>")
+	cmd.Flags().StringVarP(&reason, "reason", "r", "", "Justification for the request")
+	cmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Fail if user input is required")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation")
+}
+
+func runAdminAssignEligible(cmd *cobra.Command, args []string) error {
+	isoDuration, err := eligibilityDuration(adminDuration)
+	if err != nil {
+		return err
+	}
+	req, err := adminRequest()
+	if err != nil {
+		return err
+	}
+	req.Duration = isoDuration
+
+	lasting := "with no expiration"
+	if isoDuration != "" {
+		lasting = "for " + isoDuration
+	}
+	question := fmt.Sprintf("Make %s eligible for %s on %s %s?", req.Principal.Label(), req.RoleName, req.Scope, lasting)
+	return submitAdminRequest(question, req, azure.AssignEligible,
+		fmt.Sprintf("%s is now eligible for %s on %s", req.Principal.Name, req.RoleName, req.Scope))
+}
+
+func runAdminAssign(cmd *cobra.Command, args []string) error {
+	req, err := adminRequest()
+	if err != nil {
+		return err
+	}
+	req.Duration = fmt.Sprintf("PT%dM", adminMinutes)
+	if err := authorizeAdminAssign(req); err != nil {
+		return err
+	}
+
+	question := fmt.Sprintf("Grant %s %s on %s for %s?", req.Principal.Label(), req.RoleName, req.Scope, ui.FormatMinutes(adminMinutes))
+	return submitAdminRequest(question, req, azure.AssignActive,
+		fmt.Sprintf("Granted %s %s on %s for %s", req.Principal.Name, req.RoleName, req.Scope, ui.FormatMinutes(adminMinutes)))
+}
+
+func runAdminRemoveEligible(cmd *cobra.Command, args []string) error {
+	req, err := adminRequest()
+	if err != nil {
+		return err
+	}
+	question := fmt.Sprintf("Remove %s's eligibility for %s on %s?", req.Principal.Label(), req.RoleName, req.Scope)
+	return submitAdminRequest(question, req, azure.RemoveEligible,
+		fmt.Sprintf("Removed %s's eligibility for %s on %s", req.Principal.Name, req.RoleName, req.Scope))
+}
+
+// adminRequest resolves the principal, role and scope flags, asking for the
+// principal when it wasn't given
+func adminRequest() (azure.AdminRequest, error) {
+	if adminScope == "" || !strings.HasPrefix(adminScope, "/") {
+		return azure.AdminRequest{}, fmt.Errorf("--scope is required, e.g. /subscriptions/<id>")
+	}
+	if adminRole == "" {
+		return azure.AdminRequest{}, fmt.Errorf("--role is required")
+	}
+
+	var principal *azure.Principal
+	var err error
+	if adminPrincipal != "" {
+		principal, err = ui.SpinWithResult("Looking up "+adminPrincipal, func() (*azure.Principal, error) {
+			return azure.ResolvePrincipal(adminPrincipal)
+		}, nonInteractive)
+	} else {
+		principal, err = pickPrincipal()
+	}
+	if err != nil {
+		return azure.AdminRequest{}, err
+	}
+
+	roleDefinitionID, err := ui.SpinWithResult("Looking up role "+adminRole, func() (string, error) {
+		return azure.ResolveRoleDefinition(adminScope, adminRole)
+	}, nonInteractive)
+	if err != nil {
+		return azure.AdminRequest{}, err
+	}
+
+	justification := reason
+	if justification == "" && !nonInteractive {
+		if justification, err = ui.PromptForJustification("", nil, nil); err != nil {
+			return azure.AdminRequest{}, fmt.Errorf("failed to get justification: %w", err)
+		}
+	}
+
+	return azure.AdminRequest{
+		Principal:        *principal,
+		RoleDefinitionID: roleDefinitionID,
+		RoleName:         adminRole,
+		Scope:            adminScope,
+		Justification:    justification,
+	}, nil
+}
+
+// authorizeAdminAssign runs the checks of authorizeRequests that apply to
+// granting another principal an active role: the max_duration guardrail, the
+// justification requirements and the confirmation of high-risk roles
+func authorizeAdminAssign(req azure.AdminRequest) error {
+	if err := checkGuardrails([]guardedRequest{{role: req.Role(), minutes: adminMinutes, admin: true}}, nil); err != nil {
+		return err
+	}
+	if err := cfg.Requirements.CheckReason(req.Justification); err != nil {
+		return err
+	}
+	return confirmHighRisk([]azure.RoleAssignment{req.Role()}, req.Justification)
+}
+
+// pickPrincipal searches Graph for users and groups and lets the user pick one
+func pickPrincipal() (*azure.Principal, error) {
+	if nonInteractive {
+		return nil, fmt.Errorf("--principal is required with --non-interactive")
+	}
+	query, err := ui.PromptForPrincipalSearch()
+	if err != nil {
+		return nil, err
+	}
+	principals, err := ui.SpinWithResult("Searching users and groups", func() ([]azure.Principal, error) {
+		return azure.SearchPrincipals(query)
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(principals) == 0 {
+		return nil, fmt.Errorf("no users or groups start with %q", query)
+	}
+
+	items := make([]ui.PickItem, len(principals))
+	for i, p := range principals {
+		items[i] = ui.PickItem{Title: p.Name, Description: strings.TrimSpace(p.Type + " " + p.UPN)}
+	}
+	i, err := ui.Pick("Select a principal", items)
+	if errors.Is(err, ui.ErrPickSkipped) {
+		return nil, fmt.Errorf("no principal selected")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &principals[i], nil
+}
+
+// submitAdminRequest confirms and submits an admin request
+func submitAdminRequest(question string, req azure.AdminRequest, submit func(azure.AdminRequest) (*azure.ActivationResult, error), done string) error {
+	if !assumeYes && !nonInteractive {
+		ok, err := ui.Confirm(question)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	result, err := ui.SpinWithResult("Submitting request", func() (*azure.ActivationResult, error) {
+		return submit(req)
+	}, nonInteractive)
+	if err != nil {
+		return err
+	}
+	if result.IsDryRun() {
+		return nil
+	}
+	if result.IsPendingApproval() {
+		infof("%s\n", ui.TitleStyle.Render("Request submitted and pending approval"))
+		return withExitCode(exitPendingApproval, nil)
+	}
+	infof("%s\n", ui.SuccessStyle.Render(done))
+	return nil
+}
+
+// eligibilityDuration converts --duration to ISO 8601, accepting P180D as is
+// or a number of days like 180d
+func eligibilityDuration(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(strings.ToUpper(s), "P") {
+		return strings.ToUpper(s), nil
+	}
+	d, err := parseDays(s)
+	if err != nil || d%(24*time.Hour) != 0 {
+		return "", fmt.Errorf("invalid --duration %q, use an ISO 8601 duration like P180D or days like 180d", s)
+	}
+	return fmt.Sprintf("P%dD", int(d.Hours()/24)), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+func aliasCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "a <alias>",
+		Short: "Activate a role alias from the config file",
+		Long: `Activates the role named by an alias in the config file without discovering
+all eligible roles or prompting. Only the alias's scope is queried.
+
+The justification comes from --reason, --template or the alias's reason.`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			c, err := config.Load()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+			return c.AliasNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: runAlias,
+	}
+	addActivationFlags(cmd)
+	return cmd
+}
+
+func runAlias(cmd *cobra.Command, args []string) error {
+	alias, ok := cfg.Aliases[args[0]]
+	if !ok {
+		if names := cfg.AliasNames(); len(names) > 0 {
+			return fmt.Errorf("alias %q not found in config (available: %s)", args[0], strings.Join(names, ", "))
+		}
+		return fmt.Errorf("alias %q not found, define aliases in the config file", args[0])
+	}
+
+	if reason == "" && reasonTmpl == "" {
+		reason = alias.Reason
+	}
+	// An alias is a complete activation request; never stop to ask
+	nonInteractive = true
+
+	return activate(func() ([]azure.RoleAssignment, error) {
+		return resolveAlias(args[0], alias)
+	})
+}
+
+// resolveAlias finds the eligibility matching an alias by querying only its scope
+func resolveAlias(name string, alias config.Alias) ([]azure.RoleAssignment, error) {
+	roles, err := ui.SpinWithResult(fmt.Sprintf("Resolving %s", name), func() ([]azure.RoleAssignment, error) {
+		return azure.GetEligibleRolesAtScope(alias.Scope)
+	}, nonInteractive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get eligible roles at %s: %w", alias.Scope, err)
+	}
+
+	var matches []azure.RoleAssignment
+	for _, r := range findRoles(roles, alias.Role, "") {
+		if sameScope(r.Scope, alias.Scope) {
+			matches = append(matches, r)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, withExitCode(exitNothingEligible,
+			fmt.Errorf("alias %s: not eligible for %s at %s", name, alias.Role, alias.Scope))
+	}
+	return matches[:1], nil
+}
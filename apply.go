@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var manifestFile string
+
+func applyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply -f <manifest>",
+		Short: "Converge active roles to a YAML manifest",
+		Long: `Reads a manifest declaring the activations that should be in effect and
+converges to it: missing roles are activated, roles that would expire before
+their declared duration are extended, and roles you activated that the
+manifest doesn't list are deactivated. Permanent assignments are never touched.
+
+The plan is printed and confirmed before anything is changed.
+
+  reason: On-call week
+  activations:
+    - role: Contributor
+      scope: /subscriptions/<id>
+      duration: 4h
+    - role: Reader
+      scope: /subscriptions/<id>/resourceGroups/logs
+      reason: Reading incident logs`,
+		Args: cobra.NoArgs,
+		RunE: runApply,
+	}
+	cmd.Flags().StringVarP(&manifestFile, "file", "f", "", "Manifest file to apply")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Apply the plan without confirmation")
+	addActivationFlags(cmd)
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+type applyAction int
+
+const (
+	applyKeep applyAction = iota
+	applyActivate
+	applyExtend
+	applyDeactivate
+)
+
+// applyStep is one entry of the plan computed from a manifest
+type applyStep struct {
+	action  applyAction
+	role    azure.RoleAssignment
+	minutes int
+	reason  string
+	note    string
+}
+
+func (s applyStep) String() string {
+	target := fmt.Sprintf("%s on %s", s.role.RoleName, s.role.ScopeName)
+	switch s.action {
+	case applyActivate:
+		return fmt.Sprintf("+ activate   %s for %dm", target, s.minutes)
+	case applyExtend:
+		return fmt.Sprintf("~ extend     %s by %dm (%s)", target, s.minutes, s.note)
+	case applyDeactivate:
+		return fmt.Sprintf("- deactivate %s (%s)", target, s.note)
+	}
+	return fmt.Sprintf("  keep       %s (%s)", target, s.note)
+}
+
+// sameScope compares scope IDs ignoring case and a trailing slash
+func sameScope(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
+
+// planManifest diffs the manifest against the current active assignments
+func planManifest(m *config.Manifest) ([]applyStep, error) {
+	type desired struct {
+		config.Activate
+		minutes int
+	}
+	wanted := make([]desired, len(m.Activations))
+	scopes := map[string]bool{}
+	for i, a := range m.Activations {
+		wanted[i] = desired{Activate: a, minutes: duration}
+		if a.Duration != "" {
+			minutes, err := parseMinutes(a.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("manifest: %s on %s: %w", a.Role, a.Scope, err)
+			}
+			wanted[i].minutes = minutes
+		}
+		if wanted[i].Reason == "" {
+			wanted[i].Reason = m.Reason
+		}
+		scopes[strings.TrimSuffix(a.Scope, "/")] = true
+	}
+
+	type snapshot struct {
+		eligible, active []azure.RoleAssignment
+	}
+	snap, err := ui.SpinWithResult("Comparing manifest with active roles", func() (snapshot, error) {
+		var s snapshot
+		for scope := range scopes {
+			roles, err := azure.GetEligibleRolesAtScope(scope)
+			if err != nil {
+				return s, fmt.Errorf("failed to get eligible roles at %s: %w", scope, err)
+			}
+			s.eligible = append(s.eligible, roles...)
+		}
+		active, err := azure.GetActiveRoleAssignments()
+		if err != nil {
+			return s, fmt.Errorf("failed to get active roles: %w", err)
+		}
+		s.active = active
+		return s, nil
+	}, nonInteractive)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []applyStep
+	declared := make([]azure.RoleAssignment, 0, len(wanted))
+	for _, w := range wanted {
+		var role *azure.RoleAssignment
+		for _, r := range findRoles(snap.eligible, w.Role, "") {
+			if sameScope(r.Scope, w.Scope) {
+				role = &r
+				break
+			}
+		}
+		if role == nil {
+			return nil, withExitCode(exitNothingEligible,
+				fmt.Errorf("manifest: not eligible for %s at %s", w.Role, w.Scope))
+		}
+		declared = append(declared, *role)
+
+		step := applyStep{action: applyActivate, role: *role, minutes: w.minutes, reason: w.Reason}
+		if current := findActive(*role, snap.active); current != nil {
+			switch {
+			case current.EndDateTime == nil:
+				step.action, step.note = applyKeep, "permanent"
+			case time.Until(*current.EndDateTime) < time.Duration(w.minutes)*time.Minute:
+				step.action, step.note = applyExtend, formatRemaining(*current.EndDateTime)+" remaining"
+			default:
+				step.action, step.note = applyKeep, formatRemaining(*current.EndDateTime)+" remaining"
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	for _, a := range snap.active {
+		if a.AssignmentType != "Activated" || findActive(a, declared) != nil {
+			continue
+		}
+		note := "not in manifest"
+		if a.EndDateTime != nil {
+			note += ", " + formatRemaining(*a.EndDateTime) + " remaining"
+		}
+		steps = append(steps, applyStep{action: applyDeactivate, role: a, note: note})
+	}
+
+	return steps, nil
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	if autoDeactivate > 0 {
+		return fmt.Errorf("--auto-deactivate can't be used with apply, set the duration in the manifest instead")
+	}
+	manifest, err := config.LoadManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+
+	user, err := fetchCurrentUser(nonInteractive)
+	if err != nil {
+		return err
+	}
+
+	steps, err := planManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	changes := 0
+	for _, s := range steps {
+		if s.action != applyKeep {
+			changes++
+		}
+	}
+	if !quiet {
+		fmt.Println("\nPlan:")
+		for _, s := range steps {
+			style := ui.SubtleStyle
+			if s.action != applyKeep {
+				style = ui.TitleStyle
+			}
+			fmt.Println("  " + style.Render(s.String()))
+		}
+		fmt.Println()
+	}
+	if changes == 0 {
+		infof("Nothing to change.\n")
+		return nil
+	}
+
+	var guarded []guardedRequest
+	var releasing []azure.RoleAssignment
+	for _, s := range steps {
+		switch s.action {
+		case applyActivate, applyExtend:
+			guarded = append(guarded, guardedRequest{role: s.role, minutes: s.minutes, extend: s.action == applyExtend})
+		case applyDeactivate:
+			releasing = append(releasing, s.role)
+		}
+	}
+	if err := checkGuardrails(guarded, releasing); err != nil {
+		return err
+	}
+
+	if !assumeYes {
+		if nonInteractive {
+			return fmt.Errorf("applying a manifest needs confirmation, pass --yes in non-interactive mode")
+		}
+		ok, err := ui.Confirm(fmt.Sprintf("Apply %d change(s)?", changes))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("apply cancelled")
+		}
+	}
+
+	if err := resolveJustifications(steps, user); err != nil {
+		return err
+	}
+
+	err = applySteps(steps)
+	invalidateActiveCache()
+	return err
+}
+
+// resolveJustifications fills in a justification for every activation and
+// extension the manifest leaves without one, then checks the requirements
+func resolveJustifications(steps []applyStep, user *azure.UserInfo) error {
+	var needed []azure.RoleAssignment
+	for _, s := range steps {
+		if (s.action == applyActivate || s.action == applyExtend) && s.reason == "" {
+			needed = append(needed, s.role)
+		}
+	}
+
+	if err := resolveTicket(); err != nil {
+		return err
+	}
+	if err := cfg.Requirements.CheckTicket(ticketNum); err != nil {
+		return err
+	}
+
+	fallback := ""
+	if len(needed) > 0 {
+		var err error
+		if fallback, err = resolveJustification(needed, user); err != nil {
+			return err
+		}
+	}
+
+	for i := range steps {
+		s := &steps[i]
+		if s.action != applyActivate && s.action != applyExtend {
+			continue
+		}
+		if s.reason == "" {
+			s.reason = fallback
+		}
+		if err := cfg.Requirements.CheckReason(s.reason); err != nil {
+			return fmt.Errorf("%s on %s: %w", s.role.RoleName, s.role.ScopeName, err)
+		}
+		if err := confirmHighRisk([]azure.RoleAssignment{s.role}, s.reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySteps carries out the plan. Failures don't stop the remaining steps;
+// the first error is returned at the end.
+func applySteps(steps []applyStep) error {
+	var firstErr error
+	failed, pending := 0, false
+	for _, s := range steps {
+		target := fmt.Sprintf("%s on %s", s.role.RoleName, s.role.ScopeName)
+
+		var label, done string
+		var run func() (*azure.ActivationResult, error)
+		switch s.action {
+		case applyActivate:
+			label, done = "Activating "+target, fmt.Sprintf("Activated %s for %d minutes", target, s.minutes)
+			run = func() (*azure.ActivationResult, error) {
+				return azure.ActivateRole(azure.ActivationRequest{
+					Role:          s.role,
+					Duration:      s.minutes,
+					Justification: s.reason,
+					TicketNumber:  ticketNum,
+					TicketSystem:  ticketSys,
+				})
+			}
+		case applyExtend:
+			label, done = "Extending "+target, fmt.Sprintf("Extended %s by %d minutes", target, s.minutes)
+			run = func() (*azure.ActivationResult, error) { return azure.ExtendRole(s.role, s.minutes, s.reason) }
+		case applyDeactivate:
+			label, done = "Deactivating "+target, "Deactivated "+target
+			run = func() (*azure.ActivationResult, error) { return azure.DeactivateRole(s.role) }
+		default:
+			continue
+		}
+
+		result, err := ui.SpinWithResult(label, run, nonInteractive)
+		if err == nil && result.IsFailed() {
+			err = fmt.Errorf("request %s ended with status %s", result.RequestID, result.Status)
+		}
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s failed: %w", label, err)
+			}
+			continue
+		}
+		if result.IsDryRun() {
+			continue
+		}
+
+		if s.action == applyActivate && !result.Existing {
+			recordActivation(s.role)
+		}
+		if result.IsPendingApproval() {
+			pending = true
+			infof("%s\n", ui.TitleStyle.Render(fmt.Sprintf("%s submitted and pending approval (request %s)", label, result.RequestID)))
+			continue
+		}
+		infof("%s\n", ui.SuccessStyle.Render(done))
+	}
+
+	if firstErr != nil {
+		if failed > 1 {
+			return fmt.Errorf("%d changes failed, first error: %w", failed, firstErr)
+		}
+		return firstErr
+	}
+	if pending {
+		return withExitCode(exitPendingApproval, nil)
+	}
+	return nil
+}
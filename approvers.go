@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var approversPending bool
+
+func approversCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "approvers",
+		Short: "Show who can approve activating a role",
+		Long: `Shows the approvers the role management policy names for activating an
+eligible role, so you know whom to ping while a request is pending. Approver
+groups are expanded to their members, including nested groups.
+
+Pick the role from the selector, narrow it down with --role and
+--subscription, or use --pending for the roles of your requests that are
+waiting for approval.`,
+		Args: cobra.NoArgs,
+		RunE: runApprovers,
+	}
+	cmd.Flags().StringVar(&rolePattern, "role", "", "Only offer roles whose name matches this glob pattern, or regular expression with re:")
+	cmd.Flags().BoolVar(&approversPending, "pending", false, "Show the approvers of your pending requests")
+	cmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Fail if user input is required")
+	cmd.Flags().BoolVar(&takeFirst, "first", false, "When several roles match in non-interactive mode, use the first instead of failing")
+	return cmd
+}
+
+// roleApprovers is the approval policy of one role for the approvers command
+type roleApprovers struct {
+	RoleName         string         `json:"roleName"`
+	ScopeName        string         `json:"scopeName"`
+	Scope            string         `json:"scope"`
+	ApprovalRequired bool           `json:"approvalRequired"`
+	Approvers        []approverJSON `json:"approvers"`
+}
+
+type approverJSON struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+func runApprovers(cmd *cobra.Command, args []string) error {
+	roles, err := fetchEligibleRoles()
+	if err != nil {
+		return err
+	}
+	roles = filterRoles(roles)
+
+	if approversPending {
+		pending, err := state.PendingRequests()
+		if err != nil {
+			return err
+		}
+		var waiting []azure.RoleAssignment
+		for _, r := range roles {
+			for _, p := range pending {
+				if p.Matches(r) {
+					waiting = append(waiting, r)
+					break
+				}
+			}
+		}
+		if len(waiting) == 0 {
+			infof("No requests are waiting for approval.\n")
+			return nil
+		}
+		roles = waiting
+	} else {
+		if len(roles) == 0 {
+			return withExitCode(exitNothingEligible, fmt.Errorf("no eligible role assignments found"))
+		}
+		if nonInteractive && len(roles) > 1 {
+			roles, err = pickNonInteractive(roles)
+		} else if len(roles) > 1 {
+			roles, err = ui.SelectRoles(roles, "Select role to show approvers for", nonInteractive)
+		}
+		if err != nil {
+			return fmt.Errorf("role selection failed: %w", err)
+		}
+	}
+
+	var results []roleApprovers
+	for _, role := range roles {
+		result, err := ui.SpinWithResult("Looking up approvers of "+role.RoleName, func() (roleApprovers, error) {
+			required, approvers, err := azure.GetApprovalPolicy(role)
+			r := roleApprovers{RoleName: role.RoleName, ScopeName: role.ScopeName, Scope: role.Scope,
+				ApprovalRequired: required, Approvers: []approverJSON{}}
+			for _, a := range approvers {
+				r.Approvers = append(r.Approvers, approverJSON{ID: a.ID, Name: a.Name, Email: a.Email, Group: a.Group})
+			}
+			return r, err
+		}, false)
+		if err != nil {
+			return fmt.Errorf("failed to look up approvers of %s on %s: %w", role.RoleName, role.ScopeName, err)
+		}
+		results = append(results, result)
+	}
+
+	if outputFormat == "json" {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for i, r := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		label := fmt.Sprintf("%s on %s", r.RoleName, ui.ScopeLabel(roles[i]))
+		switch {
+		case !r.ApprovalRequired:
+			fmt.Printf("%s doesn't need approval.\n", label)
+			continue
+		case len(r.Approvers) == 0:
+			fmt.Printf("%s needs approval, but the policy names no approvers, so it falls to the\n"+
+				"tenant's Privileged Role Administrators and Global Administrators.\n", label)
+			continue
+		}
+		fmt.Printf("%s\n\n", ui.TitleStyle.Render(fmt.Sprintf("Approvers of %s:", label)))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tEMAIL\tVIA")
+		for _, a := range r.Approvers {
+			via := "named directly"
+			if a.Group != "" {
+				via = "group " + a.Group
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", a.Name, a.Email, via)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	auditSince  string
+	auditFormat string
+	auditFile   string
+	auditScope  string
+)
+
+func auditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report on your PIM activation history",
+	}
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export your activation requests for compliance reviews",
+		Long: `Exports the role assignment requests you made, with justifications, durations,
+approvers and scopes, as CSV, TSV, JSON or a Markdown table. Without --format,
+--output csv, tsv, json or markdown picks the report format.`,
+		Args: cobra.NoArgs,
+		RunE: runAuditExport,
+	}
+	export.Flags().StringVar(&auditSince, "since", "30d", "Only include requests newer than this, e.g. 7d, 12h or 2024-01-31")
+	export.Flags().StringVar(&auditFormat, "format", "csv", "Report format: csv, tsv, json or md")
+	export.Flags().StringVarP(&auditFile, "file", "f", "", "Write the report to this file instead of stdout")
+	cmd.AddCommand(export)
+
+	unused := &cobra.Command{
+		Use:   "unused",
+		Short: "List eligible roles you haven't activated recently",
+		Long: `Compares your eligibilities against your activation history and lists the
+roles you haven't activated since --since, so unneeded eligibilities can be
+given up. PIM only keeps request history for a limited time, so a role shown
+as never activated may have been used before that.
+
+With --scope, PIM administrators review every principal's eligibilities at the
+scope instead. Eligibilities granted to a group count as used when anyone
+activated that role at that scope.`,
+		Args: cobra.NoArgs,
+		RunE: runAuditUnused,
+	}
+	unused.Flags().StringVar(&auditSince, "since", "90d", "Report roles not activated since this, e.g. 90d, 12w or 2024-01-31")
+	unused.Flags().StringVar(&auditScope, "scope", "", "Review every principal's eligibilities at this scope (admin)")
+	cmd.AddCommand(unused)
+
+	return cmd
+}
+
+// parseSince turns a relative age like 30d, 2w or 12h, or a date, into a cutoff time
+func parseSince(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if n := len(s); n > 1 {
+		if u, ok := unit[s[n-1]]; ok {
+			if v, err := strconv.Atoi(s[:n-1]); err == nil && v >= 0 {
+				return time.Now().Add(-time.Duration(v) * u), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, use a value like 30d, 2w, 12h or 2024-01-31", s)
+}
+
+func runAuditExport(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("format") && outputFormat != "table" {
+		auditFormat = outputFormat
+		if outputFormat == "markdown" {
+			auditFormat = "md"
+		}
+	}
+	switch auditFormat {
+	case "csv", "tsv", "json", "md":
+	default:
+		return fmt.Errorf("unsupported report format %q (supported: csv, tsv, json, md)", auditFormat)
+	}
+	since, err := parseSince(auditSince)
+	if err != nil {
+		return err
+	}
+
+	if auditFile == "" {
+		// Keep the report on stdout parseable
+		ui.Quiet = true
+	}
+
+	requests, err := ui.SpinWithResult("Fetching activation history", func() ([]azure.ScheduleRequest, error) {
+		all, err := azure.ListScheduleRequests()
+		if err != nil {
+			return nil, err
+		}
+		var recent []azure.ScheduleRequest
+		for _, r := range all {
+			if r.RequestedAt.Before(since) {
+				continue
+			}
+			r.ResolveApprover()
+			recent = append(recent, r)
+		}
+		return recent, nil
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if auditFile != "" {
+		f, err := os.Create(auditFile)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch auditFormat {
+	case "json":
+		err = writeAuditJSON(out, requests)
+	case "md":
+		err = writeAuditMarkdown(out, requests, since)
+	case "tsv":
+		err = writeAuditCSV(out, requests, '\t')
+	default:
+		err = writeAuditCSV(out, requests, ',')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if auditFile != "" {
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Wrote %d request(s) to %s", len(requests), auditFile)))
+	}
+	return nil
+}
+
+func runAuditUnused(cmd *cobra.Command, args []string) error {
+	since, err := parseSince(auditSince)
+	if err != nil {
+		return err
+	}
+	if auditScope != "" && !strings.HasPrefix(auditScope, "/") {
+		return fmt.Errorf("invalid --scope %q, e.g. /subscriptions/<id>", auditScope)
+	}
+
+	var eligible []azure.RoleAssignment
+	var requests []azure.ScheduleRequest
+	err = ui.SpinWithAction("Comparing eligibilities with activation history", func() error {
+		var err error
+		if auditScope != "" {
+			all, err := azure.GetAssignmentsAtScope(auditScope)
+			if err != nil {
+				return err
+			}
+			for _, a := range all {
+				if a.EligibilityID != "" {
+					eligible = append(eligible, a)
+				}
+			}
+			requests, err = azure.ListScheduleRequestsAtScope(auditScope)
+			return err
+		}
+		if eligible, err = azure.GetEligibleRoleAssignments(); err != nil {
+			return err
+		}
+		requests, err = azure.ListScheduleRequests()
+		return err
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	unused := unusedEligibilities(eligible, requests, since, auditScope != "")
+	if outputFormat != "table" {
+		return printRoles(unused, false)
+	}
+	if len(unused) == 0 {
+		infof("%s\n", ui.SuccessStyle.Render("Every eligible role was activated since "+since.Format("2006-01-02")))
+		return nil
+	}
+	infof("%d eligible role(s) not activated since %s:\n\n", len(unused), since.Format("2006-01-02"))
+	fmt.Print(ui.RenderUsageTable(unused, auditScope != ""))
+	return nil
+}
+
+// unusedEligibilities returns the eligibilities without an activation since
+// the cutoff. Each keeps its most recent activation, if any, in Activation.
+// Across principals, direct eligibilities only count their own principal's
+// requests, while group ones count anyone's.
+func unusedEligibilities(eligible []azure.RoleAssignment, requests []azure.ScheduleRequest, since time.Time, byPrincipal bool) []azure.RoleAssignment {
+	var unused []azure.RoleAssignment
+	for _, role := range eligible {
+		var last *azure.ScheduleRequest
+		for i := range requests {
+			r := &requests[i]
+			if r.RequestType != "SelfActivate" || !strings.EqualFold(r.Scope, role.Scope) ||
+				!strings.EqualFold(path.Base(r.RoleDefinitionID), path.Base(role.RoleDefinitionID)) {
+				continue
+			}
+			if byPrincipal && role.MemberType != "Group" && !strings.EqualFold(r.PrincipalID, role.PrincipalID) {
+				continue
+			}
+			if (&azure.ActivationResult{Status: r.Status}).IsFailed() {
+				continue
+			}
+			// Requests are sorted newest first
+			last = r
+			break
+		}
+		if last != nil && !last.RequestedAt.Before(since) {
+			continue
+		}
+		if last != nil {
+			details := last.ActivationDetails
+			role.Activation = &details
+		}
+		unused = append(unused, role)
+	}
+	return unused
+}
+
+// auditRecord is one row of an audit report
+type auditRecord struct {
+	RequestedAt   time.Time `json:"requestedAt"`
+	RequestID     string    `json:"requestId"`
+	RequestType   string    `json:"requestType"`
+	Status        string    `json:"status"`
+	RoleName      string    `json:"roleName"`
+	ScopeName     string    `json:"scopeName"`
+	Scope         string    `json:"scope"`
+	Duration      int       `json:"durationMinutes,omitempty"`
+	Justification string    `json:"justification,omitempty"`
+	TicketNumber  string    `json:"ticketNumber,omitempty"`
+	TicketSystem  string    `json:"ticketSystem,omitempty"`
+	Requester     string    `json:"requester,omitempty"`
+	Approver      string    `json:"approver,omitempty"`
+}
+
+func toAuditRecord(r azure.ScheduleRequest) auditRecord {
+	return auditRecord{
+		RequestedAt:   r.RequestedAt,
+		RequestID:     r.RequestID,
+		RequestType:   r.RequestType,
+		Status:        r.Status,
+		RoleName:      r.RoleName,
+		ScopeName:     r.ScopeName,
+		Scope:         r.Scope,
+		Duration:      r.Duration,
+		Justification: r.Justification,
+		TicketNumber:  r.TicketNumber,
+		TicketSystem:  r.TicketSystem,
+		Requester:     r.Requester,
+		Approver:      r.Approver,
+	}
+}
+
+var auditHeaders = []string{
+	"Requested At", "Request ID", "Type", "Status", "Role", "Scope Name", "Scope",
+	"Duration (min)", "Justification", "Ticket", "Ticket System", "Requester", "Approver",
+}
+
+func (a auditRecord) fields() []string {
+	duration := ""
+	if a.Duration > 0 {
+		duration = strconv.Itoa(a.Duration)
+	}
+	return []string{
+		a.RequestedAt.UTC().Format(time.RFC3339), a.RequestID, a.RequestType, a.Status, a.RoleName,
+		a.ScopeName, a.Scope, duration, a.Justification, a.TicketNumber, a.TicketSystem, a.Requester, a.Approver,
+	}
+}
+
+// writeAuditCSV writes the report as CSV, or as TSV when comma is a tab
+func writeAuditCSV(w io.Writer, requests []azure.ScheduleRequest, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write(auditHeaders); err != nil {
+		return err
+	}
+	for _, r := range requests {
+		if err := cw.Write(toAuditRecord(r).fields()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeAuditJSON(w io.Writer, requests []azure.ScheduleRequest) error {
+	records := make([]auditRecord, len(requests))
+	for i, r := range requests {
+		records[i] = toAuditRecord(r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func writeAuditMarkdown(w io.Writer, requests []azure.ScheduleRequest, since time.Time) error {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# PIM activation report\n\n")
+	fmt.Fprintf(&b, "Requests since %s, generated %s.\n\n",
+		since.Format("2006-01-02"), time.Now().Format("2006-01-02 15:04 MST"))
+
+	b.WriteString("| " + strings.Join(auditHeaders, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(auditHeaders)) + "\n")
+	for _, r := range requests {
+		fields := toAuditRecord(r).fields()
+		for i, f := range fields {
+			fields[i] = escape.Replace(f)
+		}
+		b.WriteString("| " + strings.Join(fields, " | ") + " |\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
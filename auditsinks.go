@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/auditlog"
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+)
+
+// auditActions names schedule request types in audit records
+var auditActions = map[string]string{
+	"SelfActivate":   "activate",
+	"SelfExtend":     "extend",
+	"SelfDeactivate": "deactivate",
+	"SelfRenew":      "renew",
+	"AdminAssign":    "assign",
+	"AdminRemove":    "remove",
+}
+
+// auditSinks receive every schedule request made through hacktivator
+var (
+	auditSinks     []auditlog.Sink
+	auditSinkTypes []string
+)
+
+// configureAuditSinks sets up the sinks in the config
+func configureAuditSinks(configured []config.AuditSink) error {
+	for i, c := range configured {
+		sink, err := auditlog.New(c)
+		if err != nil {
+			return fmt.Errorf("audit_sinks[%d]: %w", i, err)
+		}
+		auditSinks = append(auditSinks, sink)
+		auditSinkTypes = append(auditSinkTypes, c.Type)
+	}
+	auditlog.ProductVersion = version
+	return nil
+}
+
+// writeAuditEvent records a request in every audit sink
+func writeAuditEvent(e azure.RequestEvent) {
+	if len(auditSinks) == 0 {
+		return
+	}
+	event := auditlog.Event{
+		Time:             time.Now().UTC(),
+		Action:           auditActions[e.RequestType],
+		User:             signedInUser(),
+		RoleName:         e.Role.RoleName,
+		RoleDefinitionID: e.Role.RoleDefinitionID,
+		Scope:            e.Role.Scope,
+		ScopeName:        e.Role.ScopeName,
+		Duration:         e.Duration,
+		Justification:    e.Justification,
+		TicketNumber:     e.TicketNumber,
+		TicketSystem:     e.TicketSystem,
+		Principal:        e.Principal,
+		AssignmentType:   "active",
+	}
+	if e.Eligibility {
+		event.AssignmentType = "eligible"
+	}
+	if e.RequestType == "SelfActivate" || e.RequestType == "SelfExtend" || e.RequestType == "AdminAssign" && !e.Eligibility {
+		event.GuardrailOverride = guardrailOverride(e.Role)
+	}
+	if e.Result != nil {
+		event.RequestID = e.Result.RequestID
+		event.Status = e.Result.Status
+	}
+	if e.Err != nil {
+		event.Error = e.Err.Error()
+	}
+	for i, sink := range auditSinks {
+		if err := sink.Write(event); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to write audit event to %s sink: %v\n", auditSinkTypes[i], err)
+		}
+	}
+}
+
+var (
+	signedInUserOnce sync.Once
+	signedInUserName string
+)
+
+// signedInUser names the user in audit records and notifications, looked up once
+func signedInUser() string {
+	signedInUserOnce.Do(func() {
+		user, err := azure.GetCurrentUser()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to identify the signed-in user: %v\n", err)
+			return
+		}
+		signedInUserName = user.UPN
+		if signedInUserName == "" {
+			signedInUserName = user.DisplayName
+		}
+	})
+	return signedInUserName
+}
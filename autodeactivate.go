@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// autoDeactivate is how many minutes after activating a role it is
+// deactivated again, 0 to let it run for the whole duration
+var autoDeactivate int
+
+// minAutoDeactivate is the shortest --auto-deactivate; PIM refuses to
+// deactivate roles that have been active for less than five minutes
+const minAutoDeactivate = 5
+
+// overdueGrace is how long past its time a scheduled deactivation is left to
+// its timer before the daemon or the next command that activates roles
+// takes it over
+const overdueGrace = 2 * time.Minute
+
+// checkAutoDeactivate validates --auto-deactivate against the duration
+func checkAutoDeactivate() error {
+	if autoDeactivate == 0 {
+		return nil
+	}
+	if autoDeactivate < minAutoDeactivate {
+		return fmt.Errorf("--auto-deactivate must be at least %d minutes, PIM can't deactivate a role sooner", minAutoDeactivate)
+	}
+	if autoDeactivate >= duration {
+		return fmt.Errorf("--auto-deactivate %s must be shorter than the activation duration of %s",
+			ui.FormatMinutes(autoDeactivate), ui.FormatMinutes(duration))
+	}
+	return nil
+}
+
+// scheduleDeactivations schedules deactivating the newly activated roles
+// after --auto-deactivate and starts a detached timer to do it. Without the
+// flag, schedules left from earlier activations of the roles are dropped.
+func scheduleDeactivations(roles []azure.RoleAssignment) {
+	if autoDeactivate == 0 {
+		for _, role := range roles {
+			if err := state.UnscheduleDeactivation(role); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to update scheduled deactivations: %v\n", err)
+			}
+		}
+		return
+	}
+
+	now := time.Now()
+	at := now.Add(time.Duration(autoDeactivate) * time.Minute)
+	expires := now.Add(time.Duration(duration) * time.Minute)
+	var ids []string
+	for _, role := range roles {
+		d, err := state.ScheduleDeactivation(role, at, expires)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to schedule the deactivation of %s: %v\n", role.RoleName, err)
+			continue
+		}
+		ids = append(ids, d.ID)
+		infof("%s\n", ui.SubtleStyle.Render(fmt.Sprintf("%s on %s will be deactivated at %s",
+			role.RoleName, role.ScopeName, ui.FormatTime(at))))
+	}
+	if len(ids) > 0 {
+		startDeactivationTimer(ids)
+	}
+}
+
+// startDeactivationTimer starts a detached 'deactivate-scheduled' for the
+// given scheduled deactivations. If it can't, a running daemon or the next
+// command that activates roles deactivates them once they are overdue.
+func startDeactivationTimer(ids []string) {
+	exe, err := os.Executable()
+	if err == nil {
+		args := append([]string{"deactivate-scheduled"}, ids...)
+		if activeContext != nil {
+			args = append(args, "--context", activeContext.Name)
+		}
+		timer := exec.Command(exe, args...)
+		if err = timer.Start(); err == nil {
+			_ = timer.Process.Release()
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "warning: failed to start the deactivation timer, the daemon or the next activation will deactivate the role(s): %v\n", err)
+}
+
+// attachScheduledDeactivations sets AutoDeactivateAt on the active roles
+// with a scheduled deactivation
+func attachScheduledDeactivations(roles []azure.RoleAssignment) {
+	scheduled, err := state.ScheduledDeactivations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load scheduled deactivations: %v\n", err)
+		return
+	}
+	for i := range roles {
+		for _, d := range scheduled {
+			if d.Matches(roles[i]) && time.Now().Before(d.Expires) {
+				at := d.At
+				roles[i].AutoDeactivateAt = &at
+			}
+		}
+	}
+}
+
+func deactivateScheduledCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "deactivate-scheduled <id>...",
+		Short:  "Wait for scheduled deactivations and carry them out",
+		Long:   `Started in the background by --auto-deactivate; not meant to be run by hand.`,
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE:   runDeactivateScheduled,
+	}
+}
+
+// runDeactivateScheduled sleeps until each of its scheduled deactivations is
+// due and carries it out. The schedule is reread after every nap, so a role
+// that was activated again in the meantime isn't deactivated early.
+func runDeactivateScheduled(cmd *cobra.Command, args []string) error {
+	// Outlive the terminal that started the activation
+	signal.Ignore(syscall.SIGHUP)
+
+	own := make(map[string]bool)
+	for _, id := range args {
+		own[id] = true
+	}
+	for len(own) > 0 {
+		scheduled, err := state.ScheduledDeactivations()
+		if err != nil {
+			return err
+		}
+		next := time.Time{}
+		for id := range own {
+			found := false
+			for _, d := range scheduled {
+				if d.ID == id {
+					found = true
+					if next.IsZero() || d.At.Before(next) {
+						next = d.At
+					}
+				}
+			}
+			if !found {
+				delete(own, id)
+			}
+		}
+		if len(own) == 0 {
+			return nil
+		}
+
+		if wait := time.Until(next); wait > 0 {
+			// Nap in short steps: the monotonic clock stops while the
+			// computer sleeps, the wall clock doesn't
+			time.Sleep(min(wait, time.Minute))
+			continue
+		}
+		var attempted []string
+		err = runScheduledDeactivations(state.LockWait, func(d state.ScheduledDeactivation) bool {
+			if own[d.ID] && !d.At.After(time.Now()) {
+				attempted = append(attempted, d.ID)
+				return true
+			}
+			return false
+		})
+		// Failures are left to the daemon and the next command
+		for _, id := range attempted {
+			delete(own, id)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// runOverdueDeactivations carries out scheduled deactivations that their
+// timer missed, e.g. because the computer restarted, reporting problems as
+// warnings
+func runOverdueDeactivations() {
+	// Another process carrying them out leaves nothing to do
+	err := runScheduledDeactivations(0, func(d state.ScheduledDeactivation) bool {
+		return time.Since(d.At) > overdueGrace
+	})
+	if err != nil && !errors.Is(err, state.ErrLocked) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// runScheduledDeactivations deactivates the scheduled roles that due selects.
+// Schedules of activations that have ended are dropped; failed ones are
+// kept to be retried. The schedule stays locked meanwhile, so concurrent
+// runs can't deactivate a role twice or lose a new schedule; wait is how
+// long to wait for another run to finish.
+func runScheduledDeactivations(wait time.Duration, due func(state.ScheduledDeactivation) bool) error {
+	return state.UpdateScheduledDeactivations(wait, func(scheduled []state.ScheduledDeactivation) ([]state.ScheduledDeactivation, error) {
+		return deactivateScheduled(scheduled, due)
+	})
+}
+
+// deactivateScheduled carries out the scheduled deactivations that due
+// selects and returns the schedules to keep
+func deactivateScheduled(scheduled []state.ScheduledDeactivation, due func(state.ScheduledDeactivation) bool) ([]state.ScheduledDeactivation, error) {
+	var kept []state.ScheduledDeactivation
+	var firstErr error
+	for _, d := range scheduled {
+		if time.Now().After(d.Expires) {
+			continue
+		}
+		if !due(d) {
+			kept = append(kept, d)
+			continue
+		}
+
+		label := fmt.Sprintf("%s on %s", d.RoleName, d.ScopeName)
+		result, err := azure.DeactivateRole(d.Role())
+		switch {
+		case errors.Is(err, azure.ErrRoleAssignmentNotFound):
+			// Deactivated by hand already
+		case err != nil:
+			d.LastError = err.Error()
+			kept = append(kept, d)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("scheduled deactivation of %s failed: %w", label, err)
+			}
+		case result.IsDryRun():
+			kept = append(kept, d)
+		default:
+			invalidateActiveCache()
+			infof("%s\n", ui.SuccessStyle.Render("Deactivated "+label+" as scheduled"))
+		}
+	}
+	return kept, firstErr
+}
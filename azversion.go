@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/cassette"
+	"github.com/ica-js/hacktivator/internal/state"
+)
+
+// azVersionCacheTTL is how long a detected az version is trusted. The az
+// launcher often stays the same across upgrades, so its timestamp alone
+// can't tell when to check again.
+const azVersionCacheTTL = 24 * time.Hour
+
+// checkAzVersion fails if the installed Azure CLI is older than the minimum
+// supported version. If the version can't be determined the check is skipped.
+func checkAzVersion() error {
+	version, err := detectAzVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not determine the Azure CLI version: %v\n", err)
+		return nil
+	}
+
+	azure.SetCLIVersion(version)
+	if azure.CompareVersions(version, azure.MinCLIVersion) < 0 {
+		return fmt.Errorf("azure CLI %s is too old, hacktivator needs %s or newer; run 'az upgrade'", version, azure.MinCLIVersion)
+	}
+	return nil
+}
+
+// detectAzVersion returns the az version, from the cache when it is recent
+// and the az executable hasn't changed
+func detectAzVersion() (string, error) {
+	path, err := exec.LookPath("az")
+	if err != nil && !cassette.Replaying() {
+		return "", err
+	}
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	cached, err := state.CachedCLIVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load cached az version: %v\n", err)
+	}
+	if cached != nil && cached.Path == path && cached.ModTime.Equal(modTime) &&
+		time.Since(cached.CheckedAt) < azVersionCacheTTL && cached.Version != "" {
+		return cached.Version, nil
+	}
+
+	version, err := azure.GetCLIVersion()
+	if err != nil {
+		return "", err
+	}
+	if err := state.SaveCLIVersion(state.CLIVersion{Path: path, ModTime: modTime, Version: version, CheckedAt: time.Now()}); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache az version: %v\n", err)
+	}
+	return version, nil
+}
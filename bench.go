@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	benchRuns  int
+	benchModes []string
+	benchSave  bool
+)
+
+func benchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure how fast hacktivator works in your tenant",
+	}
+
+	discover := &cobra.Command{
+		Use:   "discover",
+		Short: "Compare the discovery modes and recommend a discovery_mode",
+		Long: `Finds your eligible roles with each discovery mode in turn, bypassing the
+cache, and reports how long each took and how many roles it found:
+
+  scan       the tenant, then one subscription at a time (the default)
+  parallel   several subscriptions at a time
+  batch      up to 20 subscriptions per request with the ARM batch API
+  aggregate  everything at once from the PIM API the Azure portal uses
+
+The fastest mode that found every role without failing on a subscription,
+e.g. because it was throttled, is recommended; --save writes it to
+the config file as discovery_mode. scan_subscriptions applies as usual, so
+run with --rescan-all to measure every subscription.`,
+		Args: cobra.NoArgs,
+		RunE: runBenchDiscover,
+	}
+	discover.Flags().IntVar(&benchRuns, "runs", 3, "Times to run each mode; the median is compared")
+	discover.Flags().StringSliceVar(&benchModes, "modes", azure.DiscoveryModes, "Discovery modes to compare (comma-separated)")
+	discover.Flags().BoolVar(&benchSave, "save", false, "Save the recommended mode as discovery_mode in the config file")
+	cmd.AddCommand(discover)
+
+	return cmd
+}
+
+// benchResult is the outcome of one discovery mode for bench discover
+type benchResult struct {
+	Mode   string          `json:"mode"`
+	Runs   []time.Duration `json:"runsNs"`
+	Median time.Duration   `json:"medianNs,omitempty"`
+	Roles  int             `json:"roles"`
+	Failed int             `json:"failedSubscriptions"` // subscriptions that couldn't be queried, e.g. when throttled
+	Error  string          `json:"error,omitempty"`
+}
+
+func runBenchDiscover(cmd *cobra.Command, args []string) error {
+	if benchRuns < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+	for _, mode := range benchModes {
+		if !slices.Contains(azure.DiscoveryModes, mode) {
+			return fmt.Errorf("unknown discovery mode %q (available: %s)", mode, strings.Join(azure.DiscoveryModes, ", "))
+		}
+	}
+
+	// Sign in and list subscriptions first, so the first mode isn't slowed by it
+	if _, err := azure.GetAccessToken(); err != nil {
+		return withExitCode(exitAuthError, err)
+	}
+	subs, err := ui.SpinWithResult("Listing subscriptions", azure.ListSubscriptions, nonInteractive)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	infof("Discovering eligible roles in %d subscription(s) with each mode, %d time(s)...\n", len(subs), benchRuns)
+
+	var results []benchResult
+	for _, mode := range benchModes {
+		result := benchResult{Mode: mode}
+		for run := 1; run <= benchRuns; run++ {
+			start := time.Now()
+			scan, err := ui.SpinWithResult(fmt.Sprintf("%s (%d/%d)", mode, run, benchRuns), func() (*azure.EligibleScan, error) {
+				return azure.ScanEligibleRolesWith(mode)
+			}, nonInteractive)
+			if err != nil {
+				result.Error = firstLine(err.Error())
+				break
+			}
+			result.Runs = append(result.Runs, time.Since(start))
+			result.Roles = len(scan.Roles)
+			result.Failed = 0
+			for _, sub := range scan.Subscriptions {
+				if sub.Failed {
+					result.Failed++
+				}
+			}
+		}
+		if result.Error == "" {
+			sorted := slices.Clone(result.Runs)
+			slices.Sort(sorted)
+			result.Median = sorted[len(sorted)/2]
+		}
+		results = append(results, result)
+	}
+	best := recommendDiscoveryMode(results)
+
+	if outputFormat == "json" {
+		out, err := json.MarshalIndent(struct {
+			Subscriptions int           `json:"subscriptions"`
+			Results       []benchResult `json:"results"`
+			Recommended   string        `json:"recommended,omitempty"`
+		}{len(subs), results, best}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODE\tMEDIAN\tFASTEST\tSLOWEST\tROLES\tRESULT")
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Fprintf(w, "%s\t-\t-\t-\t-\t%s\n", r.Mode, r.Error)
+				continue
+			}
+			var notes []string
+			if r.Failed > 0 {
+				notes = append(notes, fmt.Sprintf("%d subscription(s) failed", r.Failed))
+			}
+			if r.Mode == best {
+				notes = append(notes, "recommended")
+			}
+			note := strings.Join(notes, ", ")
+			if note == "" {
+				note = "ok"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.Mode, formatBenchTime(r.Median),
+				formatBenchTime(slices.Min(r.Runs)), formatBenchTime(slices.Max(r.Runs)), r.Roles, note)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if best == "" {
+		return fmt.Errorf("no discovery mode succeeded")
+	}
+	if benchSave {
+		if err := config.SetValue("discovery_mode", best); err != nil {
+			return fmt.Errorf("failed to save discovery_mode: %w", err)
+		}
+		path, _ := config.Path()
+		infof("Saved discovery_mode: %s to %s.\n", best, path)
+	} else if outputFormat != "json" {
+		infof("\nTo use it, set discovery_mode: %s in the config file, or run again with --save.\n", best)
+	}
+	return nil
+}
+
+// recommendDiscoveryMode picks the fastest mode among those that found the
+// most roles with the fewest failed subscriptions, since a fast mode that
+// misses eligibilities or gets throttled is no use
+func recommendDiscoveryMode(results []benchResult) string {
+	var best *benchResult
+	for i := range results {
+		r := &results[i]
+		switch {
+		case r.Error != "":
+		case best == nil,
+			r.Roles > best.Roles,
+			r.Roles == best.Roles && r.Failed < best.Failed,
+			r.Roles == best.Roles && r.Failed == best.Failed && r.Median < best.Median:
+			best = r
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Mode
+}
+
+// formatBenchTime shows a duration to the millisecond, or the tenth of a
+// second above ten seconds
+func formatBenchTime(d time.Duration) string {
+	if d >= 10*time.Second {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// defaultHighRiskRoles are the break-glass roles when the config names none
+var defaultHighRiskRoles = []string{"Owner", "User Access Administrator"}
+
+// highRiskReasonLength is the shortest justification accepted for a
+// break-glass role, or the configured minimum if that is longer
+const highRiskReasonLength = 20
+
+// breakGlass names the break-glass role to activate without being asked
+// to type its name
+var breakGlass string
+
+// isHighRisk reports whether role is a break-glass role
+func isHighRisk(role azure.RoleAssignment) bool {
+	patterns := defaultHighRiskRoles
+	if cfg != nil && cfg.HighRiskRoles != nil {
+		patterns = cfg.HighRiskRoles
+	}
+	for _, pattern := range patterns {
+		if globMatch(pattern, role.RoleName) {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseHighRisk keeps break-glass roles out of the HTTP API and MCP server,
+// where no one can type the role's name
+func refuseHighRisk(role azure.RoleAssignment) error {
+	if isHighRisk(role) {
+		return fmt.Errorf("%s is a break-glass role; activate it with the hacktivator CLI", role.RoleName)
+	}
+	return nil
+}
+
+// confirmHighRisk checks the break-glass requirements for the roles about to
+// be activated or extended: a justification of at least
+// highRiskReasonLength characters, and the role's name typed on the terminal
+// or given with --break-glass
+func confirmHighRisk(roles []azure.RoleAssignment, justification string) error {
+	for _, role := range roles {
+		if !isHighRisk(role) {
+			continue
+		}
+		target := fmt.Sprintf("%s on %s", role.RoleName, role.ScopeName)
+
+		minLength := max(highRiskReasonLength, cfg.Requirements.MinReasonLength)
+		if n := len([]rune(strings.TrimSpace(justification))); n < minLength {
+			return withExitCode(exitPolicyViolation, fmt.Errorf(
+				"%s is a break-glass role: the justification must be at least %d characters (got %d)", target, minLength, n))
+		}
+		if len(notifiers) == 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s is a break-glass role, but no notifications are configured to announce it\n", target)
+		}
+
+		switch {
+		case breakGlass != "":
+			if !strings.EqualFold(strings.TrimSpace(breakGlass), role.RoleName) {
+				return withExitCode(exitPolicyViolation, fmt.Errorf(
+					"%s is a break-glass role, but --break-glass names %q", target, breakGlass))
+			}
+		case nonInteractive || !term.IsTerminal(os.Stdin.Fd()):
+			return withExitCode(exitPolicyViolation, fmt.Errorf(
+				"%s is a break-glass role; pass --break-glass %q to activate it without a prompt", target, role.RoleName))
+		default:
+			fmt.Println(ui.ErrorStyle.Render(fmt.Sprintf("%s is a break-glass role. Every notification channel will be told.", target)))
+			ok, err := ui.ConfirmByTyping(fmt.Sprintf("Type %q to activate it", role.RoleName), role.RoleName)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("activation of %s cancelled", target)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	activateAll         bool
+	rolePattern         string
+	subscriptionPattern string
+	assumeYes           bool
+
+	// directScope and roleDefinitionID name one role to activate without a
+	// scan; a directScope with a pattern prefix filters like --role instead
+	directScope      string
+	roleDefinitionID string
+
+	// takeFirst activates the top match when several roles match without a prompt
+	takeFirst bool
+
+	// Compiled by validatePatterns
+	roleMatcher, subscriptionMatcher, scopeMatcher *matcher
+)
+
+// globMatch reports whether value matches a case-insensitive glob pattern
+func globMatch(pattern, value string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && ok
+}
+
+// matcher is a selection flag value: a case-insensitive glob, or with the
+// re: prefix a case-insensitive regular expression. glob: makes the default
+// explicit.
+type matcher struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// isPattern reports whether a flag value has a re: or glob: prefix
+func isPattern(value string) bool {
+	return strings.HasPrefix(value, "re:") || strings.HasPrefix(value, "glob:")
+}
+
+func parseMatcher(flag, value string) (*matcher, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if expr, ok := strings.CutPrefix(value, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s regular expression %q: %w", flag, expr, err)
+		}
+		return &matcher{re: re}, nil
+	}
+	glob := strings.TrimPrefix(value, "glob:")
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid --%s pattern %q: %w", flag, glob, err)
+	}
+	return &matcher{glob: glob}, nil
+}
+
+// match reports whether any of values matches. A nil matcher matches anything.
+func (m *matcher) match(values ...string) bool {
+	if m == nil {
+		return true
+	}
+	for _, v := range values {
+		if m.re != nil && m.re.MatchString(v) || m.re == nil && globMatch(m.glob, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// memberTypes limits list and status to assignments with these member types
+var memberTypes []string
+
+// validateMemberTypes checks --member-type against the types Azure reports
+func validateMemberTypes() error {
+	for _, t := range memberTypes {
+		switch strings.ToLower(t) {
+		case "direct", "group", "inherited":
+		default:
+			return fmt.Errorf("unsupported --member-type %q (supported: direct, group, inherited)", t)
+		}
+	}
+	return nil
+}
+
+// filterMemberTypes keeps the roles matching --member-type
+func filterMemberTypes(roles []azure.RoleAssignment) []azure.RoleAssignment {
+	if len(memberTypes) == 0 {
+		return roles
+	}
+	var matched []azure.RoleAssignment
+	for _, r := range roles {
+		for _, t := range memberTypes {
+			if strings.EqualFold(r.MemberType, t) {
+				matched = append(matched, r)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// filterRoles keeps the roles matching --role, --subscription and a --scope
+// pattern. The subscription pattern matches either the subscription name or
+// its ID, and the scope pattern the scope name or its full ID.
+func filterRoles(roles []azure.RoleAssignment) []azure.RoleAssignment {
+	if roleMatcher == nil && subscriptionMatcher == nil && scopeMatcher == nil {
+		return roles
+	}
+
+	var matched []azure.RoleAssignment
+	for _, r := range roles {
+		if roleMatcher.match(r.RoleName) && subscriptionMatcher.match(r.SubscriptionName, r.SubscriptionID) &&
+			scopeMatcher.match(r.ScopeName, r.Scope) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// filterSubscription keeps the roles in subscriptions matching --subscription,
+// for commands that don't take --role
+func filterSubscription(roles []azure.RoleAssignment) []azure.RoleAssignment {
+	if subscriptionMatcher == nil {
+		return roles
+	}
+	var matched []azure.RoleAssignment
+	for _, r := range roles {
+		if subscriptionMatcher.match(r.SubscriptionName, r.SubscriptionID) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// validatePatterns compiles the selection flags, rejecting malformed
+// patterns before anything is fetched
+func validatePatterns() error {
+	var err error
+	if roleMatcher, err = parseMatcher("role", rolePattern); err != nil {
+		return err
+	}
+	if subscriptionMatcher, err = parseMatcher("subscription", subscriptionPattern); err != nil {
+		return err
+	}
+	scopeMatcher = nil
+	if isPattern(directScope) {
+		if scopeMatcher, err = parseMatcher("scope", directScope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickNonInteractive settles on a role when several match and there is no
+// prompt: --first takes the one the selector would list first, otherwise
+// the matches are listed so the flags can be narrowed down
+func pickNonInteractive(roles []azure.RoleAssignment) ([]azure.RoleAssignment, error) {
+	ranked := ui.RankRoles(roles)
+	if takeFirst {
+		infof("Taking the first of %d matching roles: %s on %s\n", len(ranked), ranked[0].RoleName, ranked[0].ScopeName)
+		return ranked[:1], nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d roles match, narrow them down with --role, --subscription or --scope, or pass --first:", len(ranked))
+	for _, r := range ranked {
+		fmt.Fprintf(&b, "\n  %s on %s (%s)", r.RoleName, r.ScopeName, r.Scope)
+	}
+	return nil, errors.New(b.String())
+}
+
+// confirmBulk prints the roles about to be activated and asks to go ahead
+func confirmBulk(roles []azure.RoleAssignment) error {
+	if !quiet {
+		fmt.Printf("\nAbout to activate %d role(s) for %d minutes:\n\n", len(roles), duration)
+		fmt.Print(ui.RenderRolesTable(roles, false))
+		fmt.Println()
+	}
+
+	if assumeYes {
+		return nil
+	}
+	if nonInteractive {
+		return fmt.Errorf("bulk activation needs confirmation, pass --yes in non-interactive mode")
+	}
+
+	ok, err := ui.Confirm(fmt.Sprintf("Activate %d role(s)?", len(roles)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("activation cancelled")
+	}
+	return nil
+}
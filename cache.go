@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// defaultEligibleCacheTTL is how long a scan of eligible roles is reused
+// unless the config says otherwise. Short, so revoked and new eligibilities
+// show up within minutes; rescans are incremental, so they are cheap.
+const defaultEligibleCacheTTL = 15 * time.Minute
+
+// eligibleCacheTTL is how long a scan of eligible roles is reused
+var eligibleCacheTTL = defaultEligibleCacheTTL
+
+// setEligibleCacheTTL sets eligibleCacheTTL from config. An empty value keeps the default.
+func setEligibleCacheTTL(value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid eligible_cache_ttl %q, use a duration like 15m or 2h", value)
+	}
+	eligibleCacheTTL = d
+	return nil
+}
+
+// fullScanInterval is how often every subscription is queried again. In
+// between, later scans only query subscriptions that are new or failed,
+// so eligibilities added in other subscriptions only show up after a full
+// scan (or in the tenant-wide listing).
+const fullScanInterval = 7 * 24 * time.Hour
+
+// fullRefresh queries every subscription instead of reusing cached results
+var fullRefresh bool
+
+// cacheNames are the caches cache clear accepts
+var cacheNames = []string{"subscriptions", "eligibilities", "policies", "role-definitions"}
+
+// primeCaches hands the cached subscription list of the current tenant and
+// the remembered policy and role definition lookups to the azure package,
+// and keeps the caches up to date with every fresh answer. Without a
+// tenant the subscription cache is not used.
+func primeCaches() {
+	if lookups, err := state.CachedLookups(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load cached lookups: %v\n", err)
+	} else {
+		azure.SetLookups(lookups)
+	}
+	azure.OnLookup = func(l azure.Lookups) {
+		if err := state.SaveLookups(l); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to cache lookups: %v\n", err)
+		}
+	}
+
+	tenant, err := azure.DefaultTenant()
+	if err != nil {
+		return
+	}
+	azure.OnSubscriptionsListed = func(subs []azure.Subscription) {
+		if err := state.SaveSubscriptions(tenant, subs); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to cache subscriptions: %v\n", err)
+		}
+	}
+
+	caches, err := state.CachedSubscriptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load cached subscriptions: %v\n", err)
+		return
+	}
+	if cached, ok := caches[tenant]; ok && cached.Subscriptions != nil {
+		azure.SetSubscriptions(cached.Subscriptions, cached.FetchedAt)
+	}
+}
+
+// scanKey identifies the subscriptions a scan covers, so a cached scan is
+// only reused for the same selection
+func scanKey() string {
+	if rescanAll || len(cfg.ScanSubscriptions) == 0 {
+		return "*"
+	}
+	patterns := append([]string(nil), cfg.ScanSubscriptions...)
+	sort.Strings(patterns)
+	return strings.Join(patterns, ",")
+}
+
+// scanEligibleRoles returns the eligible roles from the cache when the same
+// subscriptions were scanned recently, and scans and caches them otherwise.
+// When --subscription names one subscription, only that one is queried.
+// cached reports whether the roles came from the cache.
+func scanEligibleRoles(nonInteractive bool) (roles []azure.RoleAssignment, cached bool, err error) {
+	sub, single, err := singleSubscription()
+	if err != nil {
+		return nil, false, err
+	}
+	if single {
+		name := sub.Name
+		if name == "" {
+			name = sub.ID
+		}
+		roles, err := ui.SpinWithResult("Fetching eligible roles in "+name, func() ([]azure.RoleAssignment, error) {
+			return azure.GetEligibleRolesInSubscription(sub)
+		}, nonInteractive)
+		return roles, false, err
+	}
+
+	if err := offerSubscriptionScan(nonInteractive); err != nil {
+		return nil, false, err
+	}
+
+	tenant, _ := azure.DefaultTenant()
+	c, ok := cachedScan(tenant)
+	if ok && !rescanAll && c.ScanKey == scanKey() && time.Since(c.ScannedAt) < eligibleCacheTTL {
+		return c.Roles, true, nil
+	}
+
+	scan, err := ui.SpinWithResult("Fetching eligible roles", func() (*azure.EligibleScan, error) {
+		return azure.ScanEligibleRoles(previousScan(c, ok))
+	}, nonInteractive)
+	if err != nil {
+		return nil, false, err
+	}
+	cacheEligible(tenant, scan)
+	return scan.Roles, false, nil
+}
+
+// rescanEligibleRoles scans for eligible roles again, like the cache was
+// stale, and caches the scan. Only the subscriptions that are new or failed
+// are queried until a full scan is due. The daemon refreshes with it.
+func rescanEligibleRoles() ([]azure.RoleAssignment, error) {
+	tenant, _ := azure.DefaultTenant()
+	scan, err := azure.ScanEligibleRoles(previousScan(cachedScan(tenant)))
+	if err != nil {
+		return nil, err
+	}
+	cacheEligible(tenant, scan)
+	return scan.Roles, nil
+}
+
+// cachedScan returns the cached scan of the tenant, unless --full-refresh
+// asks to query Azure again
+func cachedScan(tenant string) (state.EligibleCache, bool) {
+	if tenant == "" || fullRefresh {
+		return state.EligibleCache{}, false
+	}
+	caches, err := state.CachedEligible()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load cached eligible roles: %v\n", err)
+	}
+	c, ok := caches[tenant]
+	return c, ok
+}
+
+// previousScan returns the cached scan to reuse the results of its
+// subscriptions from, unless a full scan is due. Results per subscription
+// don't depend on which subscriptions were selected, so they can be reused
+// even if that changed.
+func previousScan(c state.EligibleCache, ok bool) *azure.EligibleScan {
+	if !ok || time.Since(c.FullScanAt) >= fullScanInterval {
+		return nil
+	}
+	return &c.EligibleScan
+}
+
+// cacheEligible saves a fresh scan for the tenant
+func cacheEligible(tenant string, scan *azure.EligibleScan) {
+	if tenant == "" {
+		return
+	}
+	if err := state.SaveEligible(tenant, scanKey(), scan); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache eligible roles: %v\n", err)
+	}
+}
+
+// localOnly skips the az checks for subcommands that only touch local files
+func localOnly(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	if err := applyEnvDefaults(cmd); err != nil {
+		return err
+	}
+	return applyContext()
+}
+
+func cacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and manage the local caches",
+		Long: `Hacktivator caches the subscription list and eligible roles of each tenant,
+and the activation policies and role definitions it has looked up, so
+startup doesn't have to query Azure every time.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show what is cached and how old it is",
+		Long: `Shows each cache, how many entries it holds and how old it is. Stale
+entries are refreshed the next time they are used.`,
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: localOnly,
+		RunE:              runCacheStatus,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear [cache...]",
+		Short: "Delete cached data",
+		Long: `Deletes the named caches, or all of them: subscriptions, eligibilities,
+policies and role-definitions.`,
+		ValidArgs:         cacheNames,
+		Args:              cobra.OnlyValidArgs,
+		PersistentPreRunE: localOnly,
+		RunE:              runCacheClear,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "warm",
+		Short: "Refresh every cache now",
+		Long: `Lists the subscriptions, scans for eligible roles and looks up the
+activation policy of each one, replacing the cached data. Run it from a
+login script so the first interactive use of the day is instant.`,
+		Args: cobra.NoArgs,
+		RunE: runCacheWarm,
+	})
+
+	return cmd
+}
+
+// cacheEntry describes one cache for cache status
+type cacheEntry struct {
+	Cache     string    `json:"cache"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Current   bool      `json:"current,omitempty"` // the tenant az is signed in to
+	Entries   int       `json:"entries"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Stale     bool      `json:"stale"` // some or all entries will be refreshed on next use
+}
+
+func runCacheStatus(cmd *cobra.Command, args []string) error {
+	// localOnly doesn't load the config, which may set the TTL
+	if c, err := config.Load(); err == nil {
+		if err := setEligibleCacheTTL(c.EligibleCacheTTL); err != nil {
+			return err
+		}
+	}
+	entries, err := cacheEntries()
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if len(entries) == 0 {
+		infof("Nothing is cached yet.\n")
+		return nil
+	}
+	return writeCacheEntries(os.Stdout, entries)
+}
+
+// cacheEntries describes every cache, ordered by cache and tenant
+func cacheEntries() ([]cacheEntry, error) {
+	current, _ := azure.DefaultTenant()
+
+	subs, err := state.CachedSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	eligible, err := state.CachedEligible()
+	if err != nil {
+		return nil, err
+	}
+	lookups, err := state.CachedLookups()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []cacheEntry{}
+	for tenant, c := range subs {
+		entries = append(entries, cacheEntry{
+			Cache: "subscriptions", Tenant: tenant, Current: tenant == current,
+			Entries: len(c.Subscriptions), UpdatedAt: c.FetchedAt,
+			Stale: time.Since(c.FetchedAt) >= azure.SubscriptionsTTL,
+		})
+	}
+	for tenant, c := range eligible {
+		entries = append(entries, cacheEntry{
+			Cache: "eligibilities", Tenant: tenant, Current: tenant == current,
+			Entries: len(c.Roles), UpdatedAt: c.ScannedAt,
+			Stale: time.Since(c.ScannedAt) >= eligibleCacheTTL,
+		})
+	}
+	if len(lookups.Policies) > 0 {
+		e := cacheEntry{Cache: "policies", Entries: len(lookups.Policies)}
+		for _, p := range lookups.Policies {
+			e.addLookup(p.FetchedAt)
+		}
+		entries = append(entries, e)
+	}
+	if len(lookups.RoleDefinitions) > 0 {
+		e := cacheEntry{Cache: "role-definitions", Entries: len(lookups.RoleDefinitions)}
+		for _, d := range lookups.RoleDefinitions {
+			e.addLookup(d.FetchedAt)
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Cache != entries[j].Cache {
+			return cacheOrder(entries[i].Cache) < cacheOrder(entries[j].Cache)
+		}
+		return entries[i].Tenant < entries[j].Tenant
+	})
+	return entries, nil
+}
+
+// writeCacheEntries prints cache entries as a table
+func writeCacheEntries(out io.Writer, entries []cacheEntry) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CACHE\tTENANT\tENTRIES\tUPDATED\tSTATE")
+	for _, e := range entries {
+		tenant := e.Tenant
+		switch {
+		case tenant == "":
+			tenant = "-"
+		case e.Current:
+			tenant += " (current)"
+		}
+		status := "fresh"
+		if e.Stale {
+			status = "stale, refreshed on next use"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s ago\t%s\n", e.Cache, tenant, e.Entries, formatAge(e.UpdatedAt), status)
+	}
+	return w.Flush()
+}
+
+// addLookup folds one lookup's fetch time into a lookup cache's entry,
+// which shows the newest update and is stale if any lookup is
+func (e *cacheEntry) addLookup(fetchedAt time.Time) {
+	if fetchedAt.After(e.UpdatedAt) {
+		e.UpdatedAt = fetchedAt
+	}
+	if time.Since(fetchedAt) >= azure.LookupsTTL {
+		e.Stale = true
+	}
+}
+
+func cacheOrder(name string) int {
+	for i, n := range cacheNames {
+		if n == name {
+			return i
+		}
+	}
+	return len(cacheNames)
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	names := args
+	if len(names) == 0 {
+		names = cacheNames
+	}
+
+	for _, name := range names {
+		var err error
+		switch name {
+		case "subscriptions":
+			err = state.ClearSubscriptions()
+		case "eligibilities":
+			err = state.ClearEligible()
+		case "policies", "role-definitions":
+			var lookups azure.Lookups
+			if lookups, err = state.CachedLookups(); err == nil {
+				if name == "policies" {
+					lookups.Policies = nil
+				} else {
+					lookups.RoleDefinitions = nil
+				}
+				err = state.SaveLookups(lookups)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clear %s: %w", name, err)
+		}
+		infof("Cleared %s\n", name)
+	}
+	return nil
+}
+
+func runCacheWarm(cmd *cobra.Command, args []string) error {
+	subs, err := ui.SpinWithResult("Listing subscriptions", azure.FetchSubscriptions, false)
+	if err != nil {
+		return fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	scan, err := ui.SpinWithResult("Scanning eligible roles", func() (*azure.EligibleScan, error) {
+		return azure.ScanEligibleRoles(nil)
+	}, false)
+	if err != nil {
+		return fmt.Errorf("failed to get eligible roles: %w", err)
+	}
+	tenant, _ := azure.DefaultTenant()
+	cacheEligible(tenant, scan)
+	roles := scan.Roles
+
+	// Look everything up again, and save once at the end rather than after
+	// every lookup
+	azure.OnLookup = nil
+	azure.SetLookups(azure.Lookups{})
+	failed := 0
+	err = ui.SpinWithAction("Fetching activation policies", func() error {
+		for _, r := range roles {
+			azure.RememberRoleDefinition(r.Scope, r.RoleName, r.RoleDefinitionID)
+			if _, err := azure.GetMaxActivationDuration(r); err != nil {
+				failed++
+			}
+		}
+		return nil
+	}, false)
+	if err != nil {
+		return err
+	}
+	lookups := azure.CurrentLookups()
+	if err := state.SaveLookups(lookups); err != nil {
+		return fmt.Errorf("failed to cache lookups: %w", err)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "warning: failed to fetch %d activation policies; they will be fetched on use\n", failed)
+	}
+
+	infof("Cached %d subscriptions, %d eligible roles and %d activation policies\n",
+		len(subs), len(roles), len(lookups.Policies))
+	return nil
+}
+
+// formatAge renders the time since t, e.g. 3h or 2d
+func formatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ica-js/hacktivator/internal/cassette"
+)
+
+// openCassette records the run's az commands and REST requests to the file
+// named by HACKTIVATOR_RECORD, or replays them from HACKTIVATOR_REPLAY
+// instead of calling Azure. The variables are cleared so background
+// processes the run starts, like deactivation timers, don't overwrite the
+// recording or run against it.
+func openCassette() error {
+	record, replay := os.Getenv("HACKTIVATOR_RECORD"), os.Getenv("HACKTIVATOR_REPLAY")
+	os.Unsetenv("HACKTIVATOR_RECORD")
+	os.Unsetenv("HACKTIVATOR_REPLAY")
+	switch {
+	case record != "" && replay != "":
+		return fmt.Errorf("set only one of HACKTIVATOR_RECORD and HACKTIVATOR_REPLAY")
+	case record != "":
+		return cassette.Open(record, false)
+	case replay != "":
+		return cassette.Open(replay, true)
+	}
+	return nil
+}
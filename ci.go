@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// ciMode runs without prompts or full-screen interfaces for pipelines
+var ciMode bool
+
+// applyCI sets up --ci: nothing may wait for input, results go to stdout as
+// JSON unless -o says otherwise, and progress and errors are logged to
+// stderr in the CI system's format, e.g. as ::group:: and ::error:: on
+// GitHub Actions
+func applyCI(cmd *cobra.Command) {
+	ui.CI = ui.DetectCI()
+	nonInteractive = true
+	plain = true
+	noColor = true
+	reducedMotion = true
+	if f := cmd.Flags().Lookup("output"); f != nil && !f.Changed {
+		outputFormat = "json"
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/browser"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	// contextName selects a context for one run instead of the current one
+	contextName string
+	// activeContext is the context this run signs in with, nil for the
+	// Azure CLI's own sign-in
+	activeContext *state.Context
+
+	contextTenant         string
+	contextAuth           string
+	contextAzureConfigDir string
+)
+
+// contextNamePattern keeps context names usable as directory names
+var contextNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// applyContext points the Azure CLI at the sign-in of the context chosen with
+// --context, or the current one. Every az command hacktivator runs inherits
+// AZURE_CONFIG_DIR, so they all use that context's account and tenant.
+func applyContext() error {
+	name := contextName
+	if name == "" {
+		_, current, err := state.Contexts()
+		if err != nil {
+			return err
+		}
+		name = current
+	}
+	if name == "" {
+		return nil
+	}
+
+	ctx, err := state.FindContext(name)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		if contextName != "" {
+			return fmt.Errorf("no context named %q, see 'hacktivator context list'", name)
+		}
+		fmt.Fprintf(os.Stderr, "warning: current context %q no longer exists, using the Azure CLI's sign-in\n", name)
+		return nil
+	}
+	dir, err := ctx.AzureDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv("AZURE_CONFIG_DIR", dir); err != nil {
+		return fmt.Errorf("failed to select context %s: %w", name, err)
+	}
+	activeContext = ctx
+	state.SetContext(ctx.Name)
+	return nil
+}
+
+// signInTenant returns the tenant to sign in to again: the context's, or the
+// Azure CLI's default. Empty if neither is known, which lets az choose.
+func signInTenant() string {
+	if activeContext != nil {
+		return activeContext.Tenant
+	}
+	tenant, _ := azure.DefaultTenant()
+	return tenant
+}
+
+func contextCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "Manage named sign-in contexts for several tenants",
+		Long: `A context names a tenant and keeps its own Azure CLI sign-in, like kubectl
+contexts, so you can switch between customer tenants without running az login
+with different flags each time:
+
+  hacktivator context add contoso --tenant contoso.onmicrosoft.com
+  hacktivator context use contoso
+
+Every command then signs in with the current context; --context (or
+HACKTIVATOR_CONTEXT) picks another for one run. With no current context,
+hacktivator uses the Azure CLI's own sign-in.`,
+	}
+
+	add := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Define a context and sign in to it",
+		Long: `Defines a context for --tenant and, on a terminal, signs in to it. Adding
+an existing name replaces it. The context keeps its sign-in in its own Azure
+CLI config directory unless --azure-config-dir shares an existing one.`,
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: localOnly,
+		RunE:              runContextAdd,
+	}
+	add.Flags().StringVar(&contextTenant, "tenant", "", "Tenant ID or domain, e.g. contoso.onmicrosoft.com")
+	add.Flags().StringVar(&contextAuth, "auth", "az", "How to sign in; only az (the Azure CLI) is supported")
+	add.Flags().StringVar(&contextAzureConfigDir, "azure-config-dir", "", "Use this Azure CLI config directory, e.g. ~/.azure, instead of one of the context's own")
+	add.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Don't sign in after adding the context")
+	_ = add.MarkFlagRequired("tenant")
+	cmd.AddCommand(add)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "use <name>",
+		Short:             "Make a context current",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeContexts,
+		PersistentPreRunE: localOnly,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := state.UseContext(args[0]); err != nil {
+				return err
+			}
+			infof("Switched to context %s.\n", args[0])
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "current",
+		Short:             "Print the current context",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: localOnly,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, current, err := state.Contexts()
+			if err != nil {
+				return err
+			}
+			if current == "" {
+				return fmt.Errorf("no current context; the Azure CLI's own sign-in is used")
+			}
+			fmt.Println(current)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "list",
+		Aliases:           []string{"ls"},
+		Short:             "List the contexts",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: localOnly,
+		RunE:              runContextList,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "login [name]",
+		Short:             "Sign in to a context again",
+		Long:              `Runs az login for the named context, or the current one.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeContexts,
+		PersistentPreRunE: localOnly,
+		RunE:              runContextLogin,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "remove <name>",
+		Aliases:           []string{"rm"},
+		Short:             "Delete a context and its sign-in",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeContexts,
+		PersistentPreRunE: localOnly,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := state.RemoveContext(args[0])
+			if err != nil {
+				return err
+			}
+			if !removed {
+				return fmt.Errorf("no context named %q", args[0])
+			}
+			infof("Removed context %s.\n", args[0])
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	all, _, err := state.Contexts()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, c := range all {
+		names = append(names, c.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func runContextAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !contextNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid context name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	if contextAuth != "az" {
+		return fmt.Errorf("unsupported auth %q (supported: az)", contextAuth)
+	}
+	ctx := state.Context{Name: name, Tenant: contextTenant, Auth: contextAuth, AzureConfigDir: contextAzureConfigDir}
+	if err := state.AddContext(ctx); err != nil {
+		return err
+	}
+	infof("Added context %s for tenant %s.\n", name, contextTenant)
+
+	if nonInteractive || !term.IsTerminal(os.Stdin.Fd()) {
+		infof("Sign in with 'hacktivator context login %s', switch with 'hacktivator context use %s'.\n", name, name)
+		return nil
+	}
+	if err := contextLogin(ctx); err != nil {
+		return err
+	}
+	infof("Switch to it with 'hacktivator context use %s'.\n", name)
+	return nil
+}
+
+func runContextLogin(cmd *cobra.Command, args []string) error {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		var err error
+		if _, name, err = state.Contexts(); err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("no current context; name the context to sign in to")
+		}
+	}
+	ctx, err := state.FindContext(name)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		return fmt.Errorf("no context named %q", name)
+	}
+	return contextLogin(*ctx)
+}
+
+// contextLogin runs az login for a context in its Azure CLI config directory
+func contextLogin(ctx state.Context) error {
+	dir, err := ctx.AzureDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.Setenv("AZURE_CONFIG_DIR", dir); err != nil {
+		return err
+	}
+	infof("Signing in to %s for context %s...\n", ctx.Tenant, ctx.Name)
+	if err := azure.Login(ctx.Tenant, !browser.Available()); err != nil {
+		return err
+	}
+	infof("%s\n", ui.SuccessStyle.Render("Signed in to context "+ctx.Name))
+	return nil
+}
+
+// contextJSON is a context in the output of context list
+type contextJSON struct {
+	Name           string `json:"name"`
+	Tenant         string `json:"tenant"`
+	Auth           string `json:"auth"`
+	AzureConfigDir string `json:"azureConfigDir"`
+	Current        bool   `json:"current"`
+}
+
+func runContextList(cmd *cobra.Command, args []string) error {
+	all, current, err := state.Contexts()
+	if err != nil {
+		return err
+	}
+
+	out := []contextJSON{}
+	for _, c := range all {
+		dir, err := c.AzureDir()
+		if err != nil {
+			return err
+		}
+		out = append(out, contextJSON{Name: c.Name, Tenant: c.Tenant, Auth: c.Auth, AzureConfigDir: dir, Current: c.Name == current})
+	}
+	if outputFormat == "json" {
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(out) == 0 {
+		infof("No contexts defined; add one with 'hacktivator context add <name> --tenant <tenant>'.\n")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  CURRENT\tNAME\tTENANT\tAUTH\tAZURE CONFIG DIR")
+	for _, c := range out {
+		marker := ""
+		if c.Current {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", marker, c.Name, c.Tenant, c.Auth, c.AzureConfigDir)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/daemon"
+	"github.com/ica-js/hacktivator/internal/lockwatch"
+)
+
+var (
+	daemonInterval   time.Duration
+	deactivateOnLock bool
+)
+
+// daemonMaxAge is the oldest daemon snapshot of eligible roles the CLI uses
+// instead of querying Azure
+const daemonMaxAge = 15 * time.Minute
+
+func daemonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep role caches fresh in the background for instant lookups",
+		Long: `Runs in the foreground, refreshing your eligible and active role assignments
+every --interval and serving them over a local socket in the config directory.
+
+While it runs, role discovery, the prompt command and status bar segments use
+its data instead of querying Azure, and activations ask it to refresh. It also
+sends the configured notifications when a request pending approval is approved
+or denied, retries activations queued while Azure was unreachable, and
+carries out --auto-deactivate schedules whose timer didn't. When
+it isn't running, everything falls back to direct calls. Run it from your
+service manager (systemd, launchd) or a login script.
+
+With --deactivate-on-lock it also deactivates every role you activated when
+the screen locks or the computer goes to sleep, so walking away doesn't leave
+privileged sessions running.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Service managers stop the daemon with SIGTERM
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			path, err := daemon.SocketPath()
+			if err != nil {
+				return err
+			}
+			infof("Serving role caches on %s, refreshing every %s\n", path, daemonInterval)
+			if deactivateOnLock {
+				infof("Deactivating roles when the screen locks or the computer sleeps\n")
+				go watchLock(ctx)
+			}
+
+			server := &daemon.Server{
+				Interval:     daemonInterval,
+				ScanEligible: rescanEligibleRoles,
+				OnRefresh: func(active []azure.RoleAssignment) {
+					saveActiveCache(active)
+					checkPendingApprovals()
+					retryQueuedActivations()
+					runOverdueDeactivations()
+				},
+			}
+			return server.Serve(ctx)
+		},
+	}
+	cmd.Flags().DurationVar(&daemonInterval, "interval", 2*time.Minute, "How often to refresh role assignments")
+	cmd.Flags().BoolVar(&deactivateOnLock, "deactivate-on-lock", false, "Deactivate all activated roles when the screen locks or the computer sleeps")
+	return cmd
+}
+
+// watchLock deactivates the activated roles on every lock or sleep
+func watchLock(ctx context.Context) {
+	events := make(chan lockwatch.Event)
+	go lockwatch.Watch(ctx, events)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-events:
+			deactivateAll(e)
+		}
+	}
+}
+
+// deactivateAll deactivates every role the user activated. Permanent
+// assignments can't be deactivated and are left alone.
+func deactivateAll(why lockwatch.Event) {
+	active, err := azure.GetActiveRoleAssignments()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s, but failed to list active roles: %v\n", why, err)
+		return
+	}
+	deactivated := 0
+	for _, role := range active {
+		if role.AssignmentType != "Activated" {
+			continue
+		}
+		result, err := azure.DeactivateRole(role)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s, but failed to deactivate %s on %s: %v\n", why, role.RoleName, role.ScopeName, err)
+			continue
+		}
+		if !result.IsDryRun() {
+			deactivated++
+			infof("%s %s: deactivated %s on %s\n", time.Now().Format("15:04:05"), why, role.RoleName, role.ScopeName)
+		}
+	}
+	if deactivated > 0 {
+		invalidateActiveCache()
+	}
+}
+
+// daemonEligibleRoles returns eligible roles from a running daemon if its
+// snapshot is recent enough. --full-refresh and --rescan-all ask to scan
+// again, so they skip the daemon.
+func daemonEligibleRoles() ([]azure.RoleAssignment, bool) {
+	if fullRefresh || rescanAll {
+		return nil, false
+	}
+	snap, err := daemon.Fetch()
+	if err != nil || snap.EligibleAt.IsZero() || time.Since(snap.EligibleAt) > daemonMaxAge {
+		return nil, false
+	}
+	return snap.Eligible, true
+}
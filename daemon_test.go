@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/daemon"
+)
+
+func TestDaemonEligibleRolesSkippedByRefreshFlags(t *testing.T) {
+	t.Setenv("HACKTIVATOR_CONFIG_DIR", t.TempDir())
+	path, err := daemon.SocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(daemon.Snapshot{
+			Eligible:   []azure.RoleAssignment{{RoleName: "Reader", Scope: "/subscriptions/1"}},
+			EligibleAt: time.Now(),
+		})
+	}))
+
+	if roles, ok := daemonEligibleRoles(); !ok || len(roles) != 1 {
+		t.Fatalf("got %v, %v from the daemon", roles, ok)
+	}
+	for _, flag := range []*bool{&fullRefresh, &rescanAll} {
+		*flag = true
+		if _, ok := daemonEligibleRoles(); ok {
+			t.Error("used the daemon's snapshot despite --full-refresh or --rescan-all")
+		}
+		*flag = false
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// durationSet records whether -d was given, on the command line or through
+// the environment; otherwise the duration picker asks
+var durationSet bool
+
+// until is an end time for the activation, instead of a duration
+var until string
+
+// defaultWorkdayEnd is used by --until eod when the config sets no workday_end
+const defaultWorkdayEnd = "18:00"
+
+// applyUntil turns --until into a duration counted from now
+func applyUntil() error {
+	if until == "" {
+		return nil
+	}
+	if durationSet {
+		return fmt.Errorf("--until and --duration can't be combined")
+	}
+
+	end, err := untilTime(until, time.Now())
+	if err != nil {
+		return err
+	}
+	// Round up so the role doesn't lapse a minute early
+	duration = int((time.Until(end) + time.Minute - 1) / time.Minute)
+	durationSet = true
+	return nil
+}
+
+// untilTime resolves "HH:MM" or "eod" to that local time today. A time that
+// has passed is rejected rather than rolled over to tomorrow, since
+// activating overnight is rarely what was meant and would likely exceed the
+// policy's maximum duration.
+func untilTime(value string, now time.Time) (time.Time, error) {
+	clock, eod := value, strings.EqualFold(value, "eod")
+	if eod {
+		clock = cfg.WorkdayEnd
+		if clock == "" {
+			clock = defaultWorkdayEnd
+		}
+	}
+
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		if eod {
+			return time.Time{}, fmt.Errorf("invalid workday_end %q in config, use HH:MM", clock)
+		}
+		return time.Time{}, fmt.Errorf("invalid --until %q, use a local time like 18:00 or eod", value)
+	}
+	end := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !end.After(now) {
+		if eod {
+			return time.Time{}, fmt.Errorf("the workday ended at %s, pass --duration or a later --until", clock)
+		}
+		return time.Time{}, fmt.Errorf("%s has already passed, pass --duration or a later --until", clock)
+	}
+	return end, nil
+}
+
+// durationPresets are offered by the duration picker, in minutes
+var durationPresets = []int{30, 60, 120, 240, 480}
+
+// minutesValue is a flag holding a number of minutes. It accepts a bare
+// number of minutes or a Go duration such as 2h or 1h30m.
+type minutesValue int
+
+func newMinutesValue(val int, p *int) *minutesValue {
+	*p = val
+	return (*minutesValue)(p)
+}
+
+func (m *minutesValue) Set(s string) error {
+	minutes, err := parseMinutes(s)
+	if err != nil {
+		return err
+	}
+	*m = minutesValue(minutes)
+	return nil
+}
+
+func (m *minutesValue) String() string { return strconv.Itoa(int(*m)) }
+
+func (m *minutesValue) Type() string { return "duration" }
+
+// parseMinutes converts "90", "90m" or "1h30m" into whole minutes
+func parseMinutes(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 {
+			return 0, fmt.Errorf("duration must be positive")
+		}
+		return n, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, use minutes or a value like 2h or 90m", s)
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("duration must be at least one minute")
+	}
+	return int(d.Round(time.Minute) / time.Minute), nil
+}
+
+// chooseDuration asks how long to activate for when -d wasn't given. The
+// presets stop at the shortest maximum that the roles' policies allow, so
+// it's hard to ask for more time than needed or permitted.
+func chooseDuration(roles []azure.RoleAssignment) error {
+	// Piped input is meant for the role prompt, and --yes means unattended
+	if durationSet || nonInteractive || assumeYes || !term.IsTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+
+	var lookupErr error
+	maxMinutes, _ := ui.SpinWithResult("Checking activation policy", func() (int, error) {
+		limit := 0
+		for _, role := range roles {
+			m, err := azure.GetMaxActivationDuration(role)
+			if err != nil {
+				lookupErr = err
+				continue
+			}
+			if m > 0 && (limit == 0 || m < limit) {
+				limit = m
+			}
+		}
+		return limit, nil
+	}, nonInteractive)
+	if lookupErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to read the activation policy: %v\n", lookupErr)
+	}
+
+	var items []ui.PickItem
+	var choices []int
+	for _, m := range durationPresets {
+		if maxMinutes > 0 && m > maxMinutes {
+			break
+		}
+		items = append(items, ui.PickItem{Title: ui.FormatMinutes(m)})
+		choices = append(choices, m)
+	}
+	if maxMinutes > 0 && (len(choices) == 0 || choices[len(choices)-1] != maxMinutes) {
+		items = append(items, ui.PickItem{Title: ui.FormatMinutes(maxMinutes)})
+		choices = append(choices, maxMinutes)
+	}
+	if maxMinutes > 0 {
+		items[len(items)-1].Description = "policy maximum"
+	}
+	items = append(items, ui.PickItem{Title: "Custom...", Description: "enter a duration like 90m or 3h"})
+
+	index, err := ui.Pick("Activate for how long?", items)
+	switch {
+	case errors.Is(err, ui.ErrPickSkipped):
+		// Keep the default, within the policy limit
+		if maxMinutes > 0 && duration > maxMinutes {
+			duration = maxMinutes
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("duration selection failed: %w", err)
+	case index < len(choices):
+		duration = choices[index]
+		return nil
+	}
+
+	answer, err := ui.PromptForDuration(func(s string) error {
+		m, err := parseMinutes(s)
+		if err != nil {
+			return err
+		}
+		if maxMinutes > 0 && m > maxMinutes {
+			return fmt.Errorf("the policy allows at most %s", ui.FormatMinutes(maxMinutes))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	duration, err = parseMinutes(answer)
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// defaultEligibilityWarningDays is used when the config sets no eligibility_warning_days
+const defaultEligibilityWarningDays = 14
+
+// expiring limits list to eligibilities ending within this window, e.g. 30d
+var expiring string
+
+// parseDays converts "30d", "30" (days) or a duration like 72h
+func parseDays(s string) (time.Duration, error) {
+	days := strings.TrimSuffix(s, "d")
+	if n, err := strconv.Atoi(days); err == nil && n > 0 {
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid --expiring %q, use a number of days like 30d", s)
+}
+
+// expiringWithin returns the roles whose eligibility ends within d, soonest first
+func expiringWithin(roles []azure.RoleAssignment, d time.Duration) []azure.RoleAssignment {
+	var matched []azure.RoleAssignment
+	for _, r := range roles {
+		if r.EndDateTime != nil && time.Until(*r.EndDateTime) < d {
+			matched = append(matched, r)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].EndDateTime.Before(*matched[j].EndDateTime)
+	})
+	return matched
+}
+
+// warnExpiringEligibility points out eligibilities that end soon, so a
+// renewal can be requested before access is lost
+func warnExpiringEligibility(roles []azure.RoleAssignment) {
+	days := cfg.EligibilityWarningDays
+	if days == 0 {
+		days = defaultEligibilityWarningDays
+	}
+	if days < 0 {
+		return
+	}
+	soon := expiringWithin(roles, time.Duration(days)*24*time.Hour)
+	for _, r := range soon {
+		infof("%s\n", ui.ErrorStyle.Render(fmt.Sprintf("Your eligibility for %s on %s ends %s (%s)",
+			r.RoleName, r.ScopeName, ui.FormatTime(*r.EndDateTime), formatDaysLeft(*r.EndDateTime))))
+	}
+	if len(soon) > 0 {
+		infof("%s\n", ui.SubtleStyle.Render("Run 'hacktivator renew-eligibility' to request a renewal"))
+	}
+}
+
+// formatDaysLeft renders the time until t in days, or hours on the last day
+func formatDaysLeft(t time.Time) string {
+	d := time.Until(t)
+	switch {
+	case d < time.Hour:
+		return "within the hour"
+	case d < 24*time.Hour:
+		return fmt.Sprintf("in %dh", int(d.Hours()))
+	case d < 48*time.Hour:
+		return "in 1 day"
+	}
+	return fmt.Sprintf("in %d days", int(d.Hours()/24))
+}
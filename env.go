@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const envPrefix = "HACKTIVATOR_"
+
+// envName returns the environment variable for a flag, e.g. ticket-system
+// becomes HACKTIVATOR_TICKET_SYSTEM
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// commandLineOnly are the flags that confirm or override a safety check.
+// They only count when given on the command line, so a variable left in a
+// dotfile can't skip the check for every later run.
+var commandLineOnly = map[string]bool{
+	"break-glass":          true,
+	"override-guardrail":   true,
+	"yes":                  true,
+	"insecure-skip-verify": true,
+}
+
+// envAnnotation marks the flags of subcommands that are set from the
+// environment, see addActivationFlags
+const envAnnotation = "hacktivator_env"
+
+// envBound reports whether f is set from its environment variable: the root
+// command's flags, and the activation flags other commands share with it.
+// Other subcommand flags may share a name but not a meaning, like status
+// --duration, so they aren't.
+func envBound(cmd *cobra.Command, f *pflag.Flag) bool {
+	if !cmd.HasParent() || cmd.Root().PersistentFlags().Lookup(f.Name) == f {
+		return true
+	}
+	_, ok := f.Annotations[envAnnotation]
+	return ok
+}
+
+// applyEnvDefaults sets any flag not given on the command line from its
+// HACKTIVATOR_* environment variable. Explicit flags always win.
+func applyEnvDefaults(cmd *cobra.Command) error {
+	var firstErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || firstErr != nil || f.Name == "help" || commandLineOnly[f.Name] || !envBound(cmd, f) {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if err := cmd.Flags().Set(f.Name, value); err != nil {
+			firstErr = fmt.Errorf("invalid value %q for %s: %w", value, envName(f.Name), err)
+		}
+	})
+	return firstErr
+}
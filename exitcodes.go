@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// Process exit codes, documented in the README for wrapper scripts
+const (
+	exitOK              = 0
+	exitFailure         = 1
+	exitPendingApproval = 2
+	exitPolicyViolation = 3
+	exitAuthError       = 4
+	exitNothingEligible = 5
+)
+
+// codedError attaches a specific exit code to an error. A nil err means the
+// command finished without anything to report, only a non-zero status.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// exitCode maps an error returned by a command to the process exit code
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+
+	var policy *azure.ErrPolicyViolation
+	switch {
+	case errors.As(err, &policy):
+		return exitPolicyViolation
+	case errors.Is(err, azure.ErrNotAuthenticated):
+		return exitAuthError
+	case errors.Is(err, azure.ErrPendingApproval):
+		return exitPendingApproval
+	}
+	return exitFailure
+}
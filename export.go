@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	icsFile  string
+	icsServe string
+)
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export your role assignments to other tools",
+	}
+
+	ics := &cobra.Command{
+		Use:   "ics",
+		Short: "Write an iCalendar file of active and scheduled assignments",
+		Long: `Writes an iCalendar (.ics) file with an event for each active or scheduled
+role assignment window, so your calendar shows when privileged access is in
+effect. Permanent assignments have no window and are left out.
+
+With --serve the calendar is served over HTTP instead and regenerated on every
+request, so a calendar app can subscribe to it.`,
+		Args: cobra.NoArgs,
+		RunE: runExportICS,
+	}
+	ics.Flags().StringVarP(&icsFile, "file", "f", "", "Write the calendar to this file instead of stdout")
+	ics.Flags().StringVar(&icsServe, "serve", "", "Serve the calendar over HTTP on this address, e.g. localhost:8765")
+	cmd.AddCommand(ics)
+
+	return cmd
+}
+
+// assignmentWindow is a period during which a role is, or will be, active
+type assignmentWindow struct {
+	uid           string
+	role          string
+	scope         string
+	start, end    time.Time
+	justification string
+	scheduled     bool
+}
+
+// scheduledStatuses are request statuses for activations that will start later
+var scheduledStatuses = map[string]bool{
+	"Granted":                 true,
+	"PendingScheduleCreation": true,
+	"ScheduleCreated":         true,
+	"PendingProvisioning":     true,
+}
+
+// fetchAssignmentWindows collects active assignments and requests scheduled to start in the future
+func fetchAssignmentWindows() ([]assignmentWindow, error) {
+	active, err := azure.GetActiveRoleAssignments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active roles: %w", err)
+	}
+	if err := azure.AttachActivationDetails(active); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load activation details: %v\n", err)
+	}
+
+	var windows []assignmentWindow
+	for _, r := range active {
+		if r.EndDateTime == nil || r.StartDateTime.IsZero() {
+			continue
+		}
+		w := assignmentWindow{
+			uid:   lastSegment(r.ID),
+			role:  r.RoleName,
+			scope: r.ScopeName,
+			start: r.StartDateTime,
+			end:   *r.EndDateTime,
+		}
+		if r.Activation != nil {
+			w.justification = r.Activation.Justification
+		}
+		windows = append(windows, w)
+	}
+
+	requests, err := azure.ListScheduleRequests()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, r := range requests {
+		if !scheduledStatuses[r.Status] || !r.StartDateTime.After(now) || r.Duration == 0 {
+			continue
+		}
+		windows = append(windows, assignmentWindow{
+			uid:           r.RequestID,
+			role:          r.RoleName,
+			scope:         r.ScopeName,
+			start:         r.StartDateTime,
+			end:           r.StartDateTime.Add(time.Duration(r.Duration) * time.Minute),
+			justification: r.Justification,
+			scheduled:     true,
+		})
+	}
+
+	return windows, nil
+}
+
+func lastSegment(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// icsEscape escapes text values as required by RFC 5545
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+
+// icsFold splits content lines longer than 75 octets
+func icsFold(line string) string {
+	var b strings.Builder
+	for len(line) > 75 {
+		cut := 75
+		// Don't split a multi-byte character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// renderICS builds an iCalendar document with one event per window
+func renderICS(windows []assignmentWindow) string {
+	const stamp = "20060102T150405Z"
+	now := time.Now().UTC().Format(stamp)
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//hacktivator//PIM assignments//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:PIM role assignments",
+	}
+	for _, w := range windows {
+		summary := fmt.Sprintf("PIM: %s on %s", w.role, w.scope)
+		if w.scheduled {
+			summary += " (scheduled)"
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+w.uid+"@hacktivator",
+			"DTSTAMP:"+now,
+			"DTSTART:"+w.start.UTC().Format(stamp),
+			"DTEND:"+w.end.UTC().Format(stamp),
+			"SUMMARY:"+icsEscape.Replace(summary),
+		)
+		if w.justification != "" {
+			lines = append(lines, "DESCRIPTION:"+icsEscape.Replace(w.justification))
+		}
+		lines = append(lines, "TRANSP:TRANSPARENT", "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icsFold(line) + "\r\n")
+	}
+	return b.String()
+}
+
+func runExportICS(cmd *cobra.Command, args []string) error {
+	if icsServe != "" {
+		return serveICS(icsServe)
+	}
+
+	if icsFile == "" {
+		// Keep the calendar on stdout parseable
+		ui.Quiet = true
+	}
+
+	windows, err := ui.SpinWithResult("Fetching assignments", fetchAssignmentWindows, false)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if icsFile != "" {
+		f, err := os.Create(icsFile)
+		if err != nil {
+			return fmt.Errorf("failed to create calendar: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if _, err := io.WriteString(out, renderICS(windows)); err != nil {
+		return fmt.Errorf("failed to write calendar: %w", err)
+	}
+	if icsFile != "" {
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Wrote %d event(s) to %s", len(windows), icsFile)))
+	}
+	return nil
+}
+
+// serveICS serves a freshly generated calendar on every request
+func serveICS(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		windows, err := fetchAssignmentWindows()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		io.WriteString(w, renderICS(windows)) //nolint: errcheck
+	})
+
+	infof("Serving calendar on http://%s/ (Ctrl+C to stop)\n", addr)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
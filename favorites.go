@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+func favoriteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "favorite",
+		Short: "Manage favorite roles",
+		Long: `Manage the roles pinned to the top of the selector.
+
+Roles can also be starred with the f key in the selector.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add [role] [scope]",
+		Short: "Add eligible roles to your favorites",
+		Long: `Adds an eligible role to your favorites. The scope may be a scope name or
+a full scope ID and is only needed when the role is eligible on several
+scopes. Without arguments the roles are chosen interactively.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: runFavoriteAdd,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove [role] [scope]",
+		Short: "Remove roles from your favorites",
+		Args:  cobra.MaximumNArgs(2),
+		RunE:  runFavoriteRemove,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List your favorite roles",
+		Args:  cobra.NoArgs,
+		RunE:  runFavoriteList,
+	})
+
+	return cmd
+}
+
+func favoritesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "favorites",
+		Short: "Activate one of your favorite roles",
+		Long: `Shows only your favorite roles in the selector and activates the chosen
+one. Accepts the same flags as activating from the root command.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return activate(func() ([]azure.RoleAssignment, error) {
+				roles, err := fetchEligibleRoles()
+				if err != nil {
+					return nil, err
+				}
+				return favoriteRoles(roles)
+			})
+		},
+	}
+	addActivationFlags(cmd)
+	addSchemaFlag(cmd)
+	return cmd
+}
+
+// favoriteRoles narrows eligible roles down to the favorites
+func favoriteRoles(roles []azure.RoleAssignment) ([]azure.RoleAssignment, error) {
+	refs, err := state.Favorites()
+	if err != nil {
+		return nil, err
+	}
+
+	var favorites []azure.RoleAssignment
+	for _, role := range roles {
+		for _, ref := range refs {
+			if ref.Matches(role) {
+				favorites = append(favorites, role)
+				break
+			}
+		}
+	}
+
+	if len(favorites) == 0 {
+		return nil, withExitCode(exitNothingEligible,
+			fmt.Errorf("none of your favorite roles are eligible, add some with 'hacktivator favorite add'"))
+	}
+	return favorites, nil
+}
+
+// findRoles returns the roles named roleName, optionally limited to a scope
+// given by name or ID
+func findRoles(roles []azure.RoleAssignment, roleName, scope string) []azure.RoleAssignment {
+	var matches []azure.RoleAssignment
+	for _, r := range roles {
+		if !strings.EqualFold(r.RoleName, roleName) {
+			continue
+		}
+		if scope != "" && !strings.EqualFold(r.ScopeName, scope) && !strings.EqualFold(r.Scope, scope) {
+			continue
+		}
+		matches = append(matches, r)
+	}
+	return matches
+}
+
+func runFavoriteAdd(cmd *cobra.Command, args []string) error {
+	eligibleRoles, err := ui.SpinWithResult("Fetching eligible roles", func() ([]azure.RoleAssignment, error) {
+		return azure.GetEligibleRoleAssignments()
+	}, false)
+	if err != nil {
+		return fmt.Errorf("failed to get eligible roles: %w", err)
+	}
+	if len(eligibleRoles) == 0 {
+		return withExitCode(exitNothingEligible, fmt.Errorf("no eligible role assignments found"))
+	}
+
+	var roles []azure.RoleAssignment
+	if len(args) > 0 {
+		scope := ""
+		if len(args) == 2 {
+			scope = args[1]
+		}
+		roles = findRoles(eligibleRoles, args[0], scope)
+		switch {
+		case len(roles) == 0:
+			return fmt.Errorf("no eligible role %q found", strings.Join(args, " on "))
+		case len(roles) > 1 && scope == "":
+			return fmt.Errorf("%s is eligible on %d scopes, pass the scope as a second argument", args[0], len(roles))
+		}
+	} else {
+		roles, err = ui.SelectRoles(eligibleRoles, "Select roles to add to favorites", false)
+		if err != nil {
+			return fmt.Errorf("role selection failed: %w", err)
+		}
+	}
+
+	for _, role := range roles {
+		if err := state.AddFavorite(state.Ref(role)); err != nil {
+			return fmt.Errorf("failed to save favorites: %w", err)
+		}
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Added %s on %s to favorites", role.RoleName, role.ScopeName)))
+	}
+	return nil
+}
+
+func runFavoriteRemove(cmd *cobra.Command, args []string) error {
+	refs, err := state.Favorites()
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		infof("You have no favorite roles.\n")
+		return nil
+	}
+
+	var remove []state.RoleRef
+	if len(args) > 0 {
+		for _, ref := range refs {
+			if !strings.EqualFold(ref.RoleName, args[0]) {
+				continue
+			}
+			if len(args) == 2 && !strings.EqualFold(ref.ScopeName, args[1]) && !strings.EqualFold(ref.Scope, args[1]) {
+				continue
+			}
+			remove = append(remove, ref)
+		}
+		if len(remove) == 0 {
+			return fmt.Errorf("no favorite %q found", strings.Join(args, " on "))
+		}
+	} else {
+		items := make([]ui.PickItem, len(refs))
+		for i, ref := range refs {
+			items[i] = ui.PickItem{Title: ref.RoleName, Description: ref.ScopeName}
+		}
+		idx, err := ui.Pick("Select favorite to remove", items)
+		if err != nil {
+			return fmt.Errorf("selection failed: %w", err)
+		}
+		remove = append(remove, refs[idx])
+	}
+
+	for _, ref := range remove {
+		if _, err := state.RemoveFavorite(ref); err != nil {
+			return fmt.Errorf("failed to save favorites: %w", err)
+		}
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Removed %s on %s from favorites", ref.RoleName, ref.ScopeName)))
+	}
+	return nil
+}
+
+func runFavoriteList(cmd *cobra.Command, args []string) error {
+	refs, err := state.Favorites()
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		if refs == nil {
+			refs = []state.RoleRef{}
+		}
+		out, err := json.MarshalIndent(refs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if len(refs) == 0 {
+		infof("You have no favorite roles.\n")
+		return nil
+	}
+	for _, ref := range refs {
+		fmt.Printf("★ %s on %s\n", ref.RoleName, ref.ScopeName)
+		if !quiet {
+			fmt.Println(ui.SubtleStyle.Render("  " + ref.Scope))
+		}
+	}
+	return nil
+}
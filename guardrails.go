@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// overrideGuardrail activates despite the config's guardrails
+var overrideGuardrail bool
+
+// guardedRequest is an activation or extension checked against the guardrails
+type guardedRequest struct {
+	role    azure.RoleAssignment
+	minutes int
+	extend  bool
+	// admin grants the role to another principal, whose active roles
+	// max_active_roles doesn't count
+	admin bool
+}
+
+// overriddenGuardrails holds the guardrails broken with --override-guardrail
+// for each role, so its audit records can name them
+var (
+	overriddenGuardrailsMu sync.Mutex
+	overriddenGuardrails   = map[string][]string{}
+)
+
+func guardrailKey(role azure.RoleAssignment) string {
+	return strings.ToLower(path.Base(role.RoleDefinitionID) + "|" + role.Scope)
+}
+
+// guardrailOverride returns the guardrails broken for role, if any
+func guardrailOverride(role azure.RoleAssignment) string {
+	overriddenGuardrailsMu.Lock()
+	defer overriddenGuardrailsMu.Unlock()
+	return strings.Join(overriddenGuardrails[guardrailKey(role)], "; ")
+}
+
+// maxGuardedDuration returns the shortest max_duration guardrail of the
+// patterns matching role's name, in minutes, and the pattern; 0 if none
+func maxGuardedDuration(role azure.RoleAssignment) (int, string, error) {
+	limit, limitPattern := 0, ""
+	for pattern, value := range cfg.Guardrails.MaxDuration {
+		if !globMatch(pattern, role.RoleName) {
+			continue
+		}
+		m, err := parseMinutes(value)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid guardrails.max_duration for %q in config: %w", pattern, err)
+		}
+		if limit == 0 || m < limit {
+			limit, limitPattern = m, pattern
+		}
+	}
+	return limit, limitPattern, nil
+}
+
+// checkGuardrails enforces the config's guardrails on requests, counting
+// releasing as deactivated first. With --override-guardrail each violation is
+// printed loudly and recorded in the audit log instead.
+func checkGuardrails(requests []guardedRequest, releasing []azure.RoleAssignment) error {
+	type violation struct {
+		role    azure.RoleAssignment
+		message string
+	}
+	var violations []violation
+
+	for _, r := range requests {
+		limit, pattern, err := maxGuardedDuration(r.role)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && r.minutes > limit {
+			violations = append(violations, violation{r.role, fmt.Sprintf(
+				"%s on %s is limited to %s by the max_duration guardrail for %q, not %s",
+				r.role.RoleName, r.role.ScopeName, ui.FormatMinutes(limit), pattern, ui.FormatMinutes(r.minutes))})
+		}
+	}
+
+	if maxActive := cfg.Guardrails.MaxActiveRoles; maxActive > 0 {
+		var activating []azure.RoleAssignment
+		for _, r := range requests {
+			if !r.extend && !r.admin {
+				activating = append(activating, r.role)
+			}
+		}
+		if len(activating) > 0 {
+			count, err := countActivatedAfter(activating, releasing)
+			if err != nil {
+				return fmt.Errorf("failed to check the max_active_roles guardrail: %w", err)
+			}
+			if count > maxActive {
+				message := fmt.Sprintf("activating %d role(s) would leave %d active, more than the max_active_roles guardrail of %d",
+					len(activating), count, maxActive)
+				for _, role := range activating {
+					violations = append(violations, violation{role, message})
+				}
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	var messages []string
+	for _, v := range violations {
+		messages = appendUnique(messages, v.message)
+	}
+	if !overrideGuardrail {
+		return withExitCode(exitPolicyViolation, fmt.Errorf(
+			"%s; pass --override-guardrail to go ahead anyway", strings.Join(messages, "; ")))
+	}
+
+	for _, message := range messages {
+		fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render("GUARDRAIL OVERRIDDEN: "+message))
+	}
+	overriddenGuardrailsMu.Lock()
+	defer overriddenGuardrailsMu.Unlock()
+	for _, v := range violations {
+		key := guardrailKey(v.role)
+		overriddenGuardrails[key] = appendUnique(overriddenGuardrails[key], v.message)
+	}
+	return nil
+}
+
+// countActivatedAfter returns how many roles will be activated once
+// activating are, and releasing deactivated. Permanent assignments don't
+// count, as they aren't activations.
+func countActivatedAfter(activating, releasing []azure.RoleAssignment) (int, error) {
+	active, err := azure.GetActiveRoleAssignments()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, a := range active {
+		if a.EndDateTime != nil && findActive(a, releasing) == nil {
+			count++
+		}
+	}
+	for _, role := range activating {
+		if findActive(role, active) == nil {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	statusHistory bool
+	historySince  string
+)
+
+// Timeline states, in the Status of the assignments built by historyTimeline
+const (
+	timelineActive      = "Active"
+	timelineScheduled   = "Scheduled"
+	timelineEnded       = "Ended"
+	timelineDeactivated = "Deactivated"
+)
+
+// runStatusHistory prints past, active and scheduled assignments as one
+// chronological timeline
+func runStatusHistory() error {
+	since, err := parseSince(historySince)
+	if err != nil {
+		return err
+	}
+
+	var requests []azure.ScheduleRequest
+	active, err := ui.SpinWithResult("Fetching assignment history", func() ([]azure.RoleAssignment, error) {
+		var err error
+		if requests, err = azure.ListScheduleRequests(); err != nil {
+			return nil, err
+		}
+		return azure.GetActiveRoleAssignments()
+	}, false)
+	if err != nil {
+		return fmt.Errorf("failed to get assignment history: %w", err)
+	}
+	saveActiveCache(active)
+
+	timeline := historyTimeline(requests, active, time.Now())
+	names := make(map[string]string)
+	if subs, err := azure.ListSubscriptions(); err == nil {
+		for _, sub := range subs {
+			names[strings.ToLower(sub.ID)] = sub.Name
+		}
+	}
+	var shown []azure.RoleAssignment
+	for _, a := range timeline {
+		if a.StartDateTime.Before(since) && a.EndDateTime != nil && a.EndDateTime.Before(since) {
+			continue
+		}
+		if a.SubscriptionName == "" {
+			a.SubscriptionName = names[strings.ToLower(a.SubscriptionID)]
+		}
+		shown = append(shown, a)
+	}
+	shown = filterRoles(shown)
+
+	if outputFormat != "table" {
+		return printRoles(shown, true)
+	}
+	if len(shown) == 0 {
+		infof("No assignments since %s.\n", since.Format("2006-01-02"))
+		return nil
+	}
+	infof("%d assignment(s) since %s, oldest first:\n\n", len(shown), since.Format("2006-01-02"))
+	fmt.Print(ui.RenderTimelineTable(shown))
+	return nil
+}
+
+// historyTimeline merges the user's schedule requests with the active
+// assignments into one assignment per activation, oldest first. Extensions
+// move the end of the activation they extend, and deactivations end it
+// early. Active assignments without a request, e.g. ones older than PIM's
+// request history or assigned by an administrator, are added as they are.
+func historyTimeline(requests []azure.ScheduleRequest, active []azure.RoleAssignment, now time.Time) []azure.RoleAssignment {
+	key := func(roleDefinitionID, scope string) string {
+		return strings.ToLower(path.Base(roleDefinitionID) + "|" + scope)
+	}
+
+	ordered := append([]azure.ScheduleRequest(nil), requests...)
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return ordered[a].RequestedAt.Before(ordered[b].RequestedAt)
+	})
+
+	var timeline []azure.RoleAssignment
+	// latest finds the most recent granted activation of a role that started by t
+	latest := func(k string, t time.Time) *azure.RoleAssignment {
+		for i := len(timeline) - 1; i >= 0; i-- {
+			a := &timeline[i]
+			if key(a.RoleDefinitionID, a.Scope) == k && !a.StartDateTime.After(t) &&
+				!(&azure.ActivationResult{Status: a.Status}).IsFailed() {
+				return a
+			}
+		}
+		return nil
+	}
+
+	for _, r := range ordered {
+		k := key(r.RoleDefinitionID, r.Scope)
+		failed := (&azure.ActivationResult{Status: r.Status}).IsFailed()
+		switch r.RequestType {
+		case "SelfDeactivate", "AdminRemove":
+			if a := latest(k, r.RequestedAt); !failed && a != nil && (a.EndDateTime == nil || a.EndDateTime.After(r.RequestedAt)) {
+				end := r.RequestedAt
+				a.EndDateTime = &end
+				a.Status = timelineDeactivated
+			}
+			continue
+		case "SelfExtend", "AdminExtend":
+			if a := latest(k, r.RequestedAt); !failed && a != nil {
+				if ext := r.Assignment(); ext.EndDateTime != nil {
+					a.EndDateTime = ext.EndDateTime
+				}
+				continue
+			}
+		}
+		timeline = append(timeline, r.Assignment())
+	}
+
+	for _, role := range active {
+		a := latest(key(role.RoleDefinitionID, role.Scope), now)
+		if a == nil || a.Status == timelineDeactivated || (a.EndDateTime != nil && a.EndDateTime.Before(now)) {
+			timeline = append(timeline, role)
+			a = &timeline[len(timeline)-1]
+		} else {
+			activation := a.Activation
+			*a = role
+			if a.Activation == nil {
+				a.Activation = activation
+			}
+		}
+		a.Status = timelineActive
+		if a.StartDateTime.After(now) {
+			a.Status = timelineScheduled
+		}
+	}
+
+	for i := range timeline {
+		a := &timeline[i]
+		result := azure.ActivationResult{Status: a.Status}
+		switch {
+		case a.Status == timelineActive || a.Status == timelineScheduled || a.Status == timelineDeactivated ||
+			result.IsFailed() || result.IsPendingApproval():
+		case a.StartDateTime.After(now):
+			a.Status = timelineScheduled
+		default:
+			// Not among the active assignments, so it ended, possibly
+			// before its requested end
+			a.Status = timelineEnded
+		}
+	}
+
+	sort.SliceStable(timeline, func(a, b int) bool {
+		return timeline[a].StartDateTime.Before(timeline[b].StartDateTime)
+	})
+	return timeline
+}
@@ -0,0 +1,209 @@
+// Package auditlog forwards a record of every activation, extension and
+// deactivation made through hacktivator to syslog, a file or an HTTP
+// collector, as JSON or ArcSight CEF for SIEMs such as Sentinel and Splunk.
+package auditlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/config"
+)
+
+// ProductVersion is reported as the device version in CEF records
+var ProductVersion = "dev"
+
+// Event is one activation, extension or deactivation request
+type Event struct {
+	Time              time.Time `json:"time"`
+	Action            string    `json:"action"` // activate, extend, deactivate, renew, assign or remove
+	User              string    `json:"user"`
+	Principal         string    `json:"principal,omitempty"` // who an admin request is for
+	AssignmentType    string    `json:"assignmentType"`      // active or eligible
+	RoleName          string    `json:"roleName"`
+	RoleDefinitionID  string    `json:"roleDefinitionId"`
+	Scope             string    `json:"scope"`
+	ScopeName         string    `json:"scopeName,omitempty"`
+	Duration          int       `json:"durationMinutes,omitempty"`
+	Justification     string    `json:"justification,omitempty"`
+	TicketNumber      string    `json:"ticketNumber,omitempty"`
+	TicketSystem      string    `json:"ticketSystem,omitempty"`
+	RequestID         string    `json:"requestId,omitempty"`
+	Status            string    `json:"status,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	GuardrailOverride string    `json:"guardrailOverride,omitempty"` // guardrails broken with --override-guardrail
+}
+
+// Sink receives audit events
+type Sink interface {
+	Write(e Event) error
+}
+
+// New returns the sink a config entry describes
+func New(c config.AuditSink) (Sink, error) {
+	var format func(Event) ([]byte, error)
+	switch c.Format {
+	case "", "json":
+		format = formatJSON
+	case "cef":
+		format = formatCEF
+	default:
+		return nil, fmt.Errorf("unsupported audit sink format %q (supported: json, cef)", c.Format)
+	}
+
+	switch c.Type {
+	case "syslog":
+		return newSyslogSink(c.Address, format)
+	case "file":
+		if c.Path == "" {
+			return nil, fmt.Errorf("file audit sink needs a path")
+		}
+		return &fileSink{path: c.Path, format: format}, nil
+	case "http":
+		if c.URL == "" {
+			return nil, fmt.Errorf("http audit sink needs a url")
+		}
+		// HTTP collectors take structured payloads; CEF is sent as text
+		return &httpSink{url: c.URL, headers: c.Headers, format: format, json: c.Format != "cef"}, nil
+	}
+	return nil, fmt.Errorf("unsupported audit sink type %q (supported: syslog, file, http)", c.Type)
+}
+
+func formatJSON(e Event) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// formatCEF renders an event as a CEF:0 record
+func formatCEF(e Event) ([]byte, error) {
+	severity, outcome := 3, e.Status
+	if e.Error != "" {
+		severity, outcome = 7, "failure"
+	}
+	if e.GuardrailOverride != "" {
+		severity = 8
+	}
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(e.Time.UnixMilli(), 10),
+		"suser=" + cefValue(e.User),
+		"act=" + cefValue(e.Action),
+		"outcome=" + cefValue(outcome),
+		"cs1Label=role", "cs1=" + cefValue(e.RoleName),
+		"cs2Label=scope", "cs2=" + cefValue(e.Scope),
+		"cs4Label=assignmentType", "cs4=" + cefValue(e.AssignmentType),
+	}
+	if e.Principal != "" {
+		ext = append(ext, "duser="+cefValue(e.Principal))
+	}
+	if e.Duration > 0 {
+		ext = append(ext, "cn1Label=durationMinutes", "cn1="+strconv.Itoa(e.Duration))
+	}
+	if e.TicketNumber != "" {
+		ext = append(ext, "cs3Label=ticket", "cs3="+cefValue(strings.TrimSpace(e.TicketSystem+" "+e.TicketNumber)))
+	}
+	if e.RequestID != "" {
+		ext = append(ext, "externalId="+cefValue(e.RequestID))
+	}
+	if e.Justification != "" {
+		ext = append(ext, "msg="+cefValue(e.Justification))
+	}
+	if e.Error != "" {
+		ext = append(ext, "reason="+cefValue(e.Error))
+	}
+	if e.GuardrailOverride != "" {
+		ext = append(ext, "cs5Label=guardrailOverride", "cs5="+cefValue(e.GuardrailOverride))
+	}
+
+	header := []string{"CEF:0", "ica-js", "hacktivator", cefHeader(ProductVersion),
+		cefHeader(e.Action), cefHeader(cefNames[e.Action]), strconv.Itoa(severity)}
+	return []byte(strings.Join(header, "|") + "|" + strings.Join(ext, " ")), nil
+}
+
+// cefNames are the human-readable event names for each action
+var cefNames = map[string]string{
+	"activate":   "PIM role activation",
+	"extend":     "PIM role extension",
+	"deactivate": "PIM role deactivation",
+	"renew":      "PIM eligibility renewal",
+	"assign":     "PIM role assignment",
+	"remove":     "PIM role assignment removal",
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+func cefValue(s string) string  { return cefValueEscaper.Replace(s) }
+
+// fileSink appends one record per line
+type fileSink struct {
+	path   string
+	format func(Event) ([]byte, error)
+	mu     sync.Mutex
+}
+
+func (s *fileSink) Write(e Event) error {
+	line, err := s.format(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// httpSink POSTs each record to a collector
+type httpSink struct {
+	url     string
+	headers map[string]string
+	format  func(Event) ([]byte, error)
+	json    bool
+}
+
+func (s *httpSink) Write(e Event) error {
+	body, err := s.format(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if s.json {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", s.url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package auditlog
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+)
+
+// priority is facility authpriv (10) with severity notice (5)
+const priority = 10*8 + 5
+
+// localSyslogPaths are the usual local syslog sockets on Linux, macOS and BSD
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogSink sends RFC 5424 messages to a local or remote syslog daemon.
+// log/syslog isn't available on Windows, so messages are framed here.
+type syslogSink struct {
+	network, address string
+	format           func(Event) ([]byte, error)
+}
+
+func newSyslogSink(address string, format func(Event) ([]byte, error)) (*syslogSink, error) {
+	if address == "" {
+		if runtime.GOOS == "windows" {
+			return nil, fmt.Errorf("there is no local syslog on Windows, set the syslog audit sink address")
+		}
+		return &syslogSink{format: format}, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" || (u.Scheme != "udp" && u.Scheme != "tcp") {
+		return nil, fmt.Errorf("invalid syslog address %q, expected udp://host:port or tcp://host:port", address)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "514")
+	}
+	return &syslogSink{network: u.Scheme, address: host, format: format}, nil
+}
+
+func (s *syslogSink) Write(e Event) error {
+	msg, err := s.format(e)
+	if err != nil {
+		return err
+	}
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	line := fmt.Sprintf("<%d>1 %s %s hacktivator %d - - %s",
+		priority, e.Time.UTC().Format(time.RFC3339Nano), hostname, os.Getpid(), msg)
+	if s.network == "tcp" {
+		// Non-transparent framing: TCP receivers split messages on newlines
+		line += "\n"
+	}
+	_, err = conn.Write([]byte(line))
+	return err
+}
+
+func (s *syslogSink) dial() (net.Conn, error) {
+	if s.network != "" {
+		return net.DialTimeout(s.network, s.address, 5*time.Second)
+	}
+	var lastErr error
+	for _, path := range localSyslogPaths {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, fmt.Errorf("failed to connect to local syslog: %w", lastErr)
+}
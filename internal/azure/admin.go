@@ -0,0 +1,238 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/telemetry"
+)
+
+// AdminRequest assigns or removes a role for another principal
+type AdminRequest struct {
+	Principal        Principal
+	RoleDefinitionID string // full role definition resource ID
+	RoleName         string
+	Scope            string
+	Duration         string // ISO 8601, e.g. P180D or PT2H; empty for no expiration
+	Justification    string
+}
+
+// Role is the role the request is for, as an assignment of the principal
+func (r AdminRequest) Role() RoleAssignment {
+	return RoleAssignment{
+		RoleDefinitionID: r.RoleDefinitionID,
+		RoleName:         r.RoleName,
+		Scope:            r.Scope,
+		ScopeName:        r.Scope,
+		PrincipalID:      r.Principal.ID,
+	}
+}
+
+// ResolveRoleDefinition finds the ID of a role definition by name at scope
+func ResolveRoleDefinition(scope, name string) (string, error) {
+	if id, ok := cachedRoleDefinition(scope, name); ok {
+		return id, nil
+	}
+
+	url := fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleDefinitions?api-version=2022-04-01&$filter=roleName eq '%s'",
+		scope, odataString(name))
+	output, err := rest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up role %s: %w", name, err)
+	}
+
+	var response struct {
+		Value []struct {
+			ID string `json:"id"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return "", fmt.Errorf("failed to parse role definitions: %w", err)
+	}
+	if len(response.Value) == 0 {
+		return "", fmt.Errorf("no role named %q at %s", name, scope)
+	}
+	RememberRoleDefinition(scope, name, response.Value[0].ID)
+	return response.Value[0].ID, nil
+}
+
+// AssignEligible makes a principal eligible for a role at a scope
+// (AdminAssign). The signed-in user needs permission to manage PIM
+// assignments there, e.g. User Access Administrator.
+func AssignEligible(req AdminRequest) (*ActivationResult, error) {
+	expiration := map[string]interface{}{"type": "NoExpiration"}
+	if req.Duration != "" {
+		expiration = map[string]interface{}{"type": "AfterDuration", "duration": req.Duration}
+	}
+	requestBody := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"principalId":      req.Principal.ID,
+			"roleDefinitionId": req.RoleDefinitionID,
+			"requestType":      "AdminAssign",
+			"justification":    req.Justification,
+			"scheduleInfo": map[string]interface{}{
+				"startDateTime": time.Now().UTC().Format(time.RFC3339),
+				"expiration":    expiration,
+			},
+		},
+	}
+
+	result, err := submitRequest(req.Scope, "roleEligibilityScheduleRequests", requestBody)
+	notifyScheduleRequest(RequestEvent{
+		RequestType:   "AdminAssign",
+		Role:          req.Role(),
+		Principal:     req.Principal.Label(),
+		Eligibility:   true,
+		Duration:      parseISODurationMinutes(req.Duration),
+		Justification: req.Justification,
+		Result:        result,
+		Err:           err,
+	})
+	return result, err
+}
+
+// AssignActive grants a principal a time-bound active assignment of a role
+// at a scope (AdminAssign), e.g. to give a teammate temporary access in an
+// emergency. The duration is required; the policy caps how long it may be.
+func AssignActive(req AdminRequest) (*ActivationResult, error) {
+	requestBody := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"principalId":      req.Principal.ID,
+			"roleDefinitionId": req.RoleDefinitionID,
+			"requestType":      "AdminAssign",
+			"justification":    req.Justification,
+			"scheduleInfo": map[string]interface{}{
+				"startDateTime": time.Now().UTC().Format(time.RFC3339),
+				"expiration": map[string]interface{}{
+					"type":     "AfterDuration",
+					"duration": req.Duration,
+				},
+			},
+		},
+	}
+
+	result, err := submitScheduleRequest(req.Scope, requestBody)
+	notifyScheduleRequest(RequestEvent{
+		RequestType:   "AdminAssign",
+		Role:          req.Role(),
+		Principal:     req.Principal.Label(),
+		Duration:      parseISODurationMinutes(req.Duration),
+		Justification: req.Justification,
+		Result:        result,
+		Err:           err,
+	})
+	return result, err
+}
+
+// RemoveEligible ends a principal's eligibility for a role at a scope (AdminRemove)
+func RemoveEligible(req AdminRequest) (*ActivationResult, error) {
+	requestBody := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"principalId":      req.Principal.ID,
+			"roleDefinitionId": req.RoleDefinitionID,
+			"requestType":      "AdminRemove",
+			"justification":    req.Justification,
+		},
+	}
+
+	result, err := submitRequest(req.Scope, "roleEligibilityScheduleRequests", requestBody)
+	notifyScheduleRequest(RequestEvent{
+		RequestType:   "AdminRemove",
+		Role:          req.Role(),
+		Principal:     req.Principal.Label(),
+		Eligibility:   true,
+		Justification: req.Justification,
+		Result:        result,
+		Err:           err,
+	})
+	return result, err
+}
+
+// GetAssignmentsAtScope lists every principal's eligible and active PIM
+// assignments at scope, including those inherited from the scopes above it.
+// Eligible assignments have EligibilityID set; active ones AssignmentType.
+func GetAssignmentsAtScope(scope string) ([]RoleAssignment, error) {
+	span := telemetry.Start("scan assignments at scope", telemetry.String("azure.scope", scope))
+	var all []RoleAssignment
+	for _, resourceType := range []string{"roleEligibilityScheduleInstances", "roleAssignmentScheduleInstances"} {
+		url := fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/%s?api-version=2020-10-01&$filter=atScope()&$expand=roleDefinition,principal",
+			scope, resourceType)
+		eligible := resourceType == "roleEligibilityScheduleInstances"
+		roles, err := listScheduleInstances(url, eligible)
+		if err != nil {
+			err = fmt.Errorf("failed to list %s: %w", resourceType, err)
+			span.End(err)
+			return nil, err
+		}
+		if eligible {
+			roles = dedupeEligibilities(roles)
+		}
+		all = append(all, roles...)
+	}
+	span.SetAttr(telemetry.Int("assignments", len(all)))
+	span.End(nil)
+	return all, nil
+}
+
+// listScheduleInstances follows the pages of a schedule instance listing
+func listScheduleInstances(url string, eligible bool) ([]RoleAssignment, error) {
+	var roles []RoleAssignment
+	for url != "" {
+		output, err := rest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		page, next, err := parseScheduleInstances([]byte(output), eligible)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, page...)
+		url = next
+	}
+	return roles, nil
+}
+
+// parseScheduleInstances reads a page of schedule instances and returns the
+// link to the next page, if any
+func parseScheduleInstances(data []byte, eligible bool) ([]RoleAssignment, string, error) {
+	var response roleEligibilityScheduleInstancesResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var roles []RoleAssignment
+	for _, item := range response.Value {
+		role := RoleAssignment{
+			ID:                 item.ID,
+			RoleDefinitionID:   item.Properties.RoleDefinitionID,
+			Scope:              item.Properties.Scope,
+			SubscriptionID:     SubscriptionID(item.Properties.Scope),
+			PrincipalID:        item.Properties.PrincipalID,
+			Status:             item.Properties.Status,
+			MemberType:         item.Properties.MemberType,
+			AssignmentType:     item.Properties.AssignmentType,
+			ScheduleID:         item.Properties.RoleAssignmentScheduleID,
+			ExpandedProperties: item.Properties.ExpandedProperties,
+		}
+		if eligible {
+			role.EligibilityID = item.ID
+			role.EligibilityScheduleID = item.Properties.RoleEligibilityScheduleID
+		}
+		if t, err := time.Parse(time.RFC3339, item.Properties.StartDateTime); err == nil {
+			role.StartDateTime = t
+		}
+		if item.Properties.EndDateTime != nil {
+			if t, err := time.Parse(time.RFC3339, *item.Properties.EndDateTime); err == nil {
+				role.EndDateTime = &t
+			}
+		}
+		if role.ExpandedProperties != nil {
+			role.RoleName = role.ExpandedProperties.RoleDefinition.DisplayName
+			role.ScopeName = role.ExpandedProperties.Scope.DisplayName
+			role.ScopeType = role.ExpandedProperties.Scope.Type
+		}
+		roles = append(roles, role)
+	}
+	return roles, response.NextLink, nil
+}
@@ -0,0 +1,176 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/telemetry"
+)
+
+// pimResource is the audience of access tokens for the PIM aggregation API
+const pimResource = "https://api.azrbac.mspim.azure.com"
+
+// aggregateRoleAssignmentsURL lists role assignments across every Azure
+// resource PIM manages, with the scope and role definition expanded
+const aggregateRoleAssignmentsURL = pimResource + "/api/v2/privilegedAccess/azureResources/roleAssignments" +
+	"?$expand=linkedEligibleRoleAssignment,subject,scopedResource,roleDefinition($expand=resource)" +
+	"&$filter=(subject/id eq '%s') and (assignmentState eq 'Eligible')"
+
+// aggregateResource is a resource as the aggregation API describes it
+type aggregateResource struct {
+	ID          string `json:"id"`
+	ExternalID  string `json:"externalId"` // the ARM ID
+	Type        string `json:"type"`       // e.g. subscription, resourcegroup
+	DisplayName string `json:"displayName"`
+}
+
+// aggregateRoleAssignmentsResponse is a page of aggregation API role assignments
+type aggregateRoleAssignmentsResponse struct {
+	Value []struct {
+		ID             string             `json:"id"`
+		SubjectID      string             `json:"subjectId"`
+		MemberType     string             `json:"memberType"` // Direct, Group or Inherited
+		Status         string             `json:"status"`
+		StartDateTime  *string            `json:"startDateTime"`
+		EndDateTime    *string            `json:"endDateTime"`
+		ScopedResource *aggregateResource `json:"scopedResource"`
+		RoleDefinition *struct {
+			ExternalID  string `json:"externalId"` // the ARM ID
+			DisplayName string `json:"displayName"`
+		} `json:"roleDefinition"`
+		LinkedEligibleRoleAssignment *struct {
+			SubjectID string `json:"subjectId"`
+		} `json:"linkedEligibleRoleAssignment"`
+	} `json:"value"`
+	NextLink string `json:"@odata.nextLink,omitempty"`
+}
+
+// aggregateScopeTypes maps aggregation API resource types to ScopeType
+var aggregateScopeTypes = map[string]string{
+	"managementgroup": "managementGroup",
+	"subscription":    "subscription",
+	"resourcegroup":   "resourceGroup",
+}
+
+// scanAggregated lists every eligibility of the current user with the PIM
+// aggregation API, in one request plus one per extra page, and arranges the
+// result like a full scan. It fails when the API isn't reachable or won't
+// accept the Azure CLI's sign-in, so the caller can scan instead.
+func scanAggregated() (*EligibleScan, error) {
+	span := telemetry.Start("scan eligible roles (aggregate)")
+
+	roles, err := GetAggregatedEligibleRoles()
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+
+	now := time.Now()
+	scan := &EligibleScan{
+		Subscriptions: make(map[string]SubscriptionScan),
+		ScannedAt:     now,
+		FullScanAt:    now,
+	}
+	for _, role := range roles {
+		if role.SubscriptionID == "" {
+			scan.TenantRoles = append(scan.TenantRoles, role)
+			continue
+		}
+		key := strings.ToLower(role.SubscriptionID)
+		sub := scan.Subscriptions[key]
+		sub.Roles = append(sub.Roles, role)
+		sub.ScannedAt = now
+		scan.Subscriptions[key] = sub
+	}
+
+	scan.Roles = dedupeEligibilities(roles)
+	if subscriptions, err := getSubscriptions(); err == nil {
+		names := make(map[string]string, len(subscriptions))
+		for _, sub := range subscriptions {
+			names[strings.ToLower(sub.ID)] = sub.Name
+		}
+		for i := range scan.Roles {
+			scan.Roles[i].SubscriptionName = names[strings.ToLower(scan.Roles[i].SubscriptionID)]
+		}
+	}
+
+	span.SetAttr(telemetry.Int("roles", len(scan.Roles)))
+	span.End(nil)
+	return scan, nil
+}
+
+// GetAggregatedEligibleRoles lists every eligibility of the current user
+// across all scopes with the PIM aggregation API
+func GetAggregatedEligibleRoles() ([]RoleAssignment, error) {
+	principalID, err := GetCurrentUserPrincipalID()
+	if err != nil {
+		return nil, err
+	}
+	token, err := fetchAccessToken(pimResource)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []RoleAssignment
+	url := fmt.Sprintf(aggregateRoleAssignmentsURL, principalID)
+	for url != "" {
+		output, status, err := armRequest("GET", url, nil, token.value)
+		if err != nil {
+			return nil, err
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("GET %s failed with status %d: %s", url, status, output)
+		}
+
+		var response aggregateRoleAssignmentsResponse
+		if err := json.Unmarshal([]byte(output), &response); err != nil {
+			return nil, fmt.Errorf("failed to parse PIM aggregation API response: %w", err)
+		}
+		for _, item := range response.Value {
+			if item.RoleDefinition == nil || item.ScopedResource == nil || item.ScopedResource.ExternalID == "" {
+				continue
+			}
+			scope := item.ScopedResource.ExternalID
+			role := RoleAssignment{
+				ID:               item.ID,
+				EligibilityID:    item.ID,
+				RoleDefinitionID: item.RoleDefinition.ExternalID,
+				RoleName:         item.RoleDefinition.DisplayName,
+				Scope:            scope,
+				ScopeName:        item.ScopedResource.DisplayName,
+				ScopeType:        aggregateScopeTypes[strings.ToLower(item.ScopedResource.Type)],
+				SubscriptionID:   SubscriptionID(scope),
+				PrincipalID:      item.SubjectID,
+				Status:           item.Status,
+				MemberType:       item.MemberType,
+				MaxDuration:      480, // Default 8 hours, can be overridden by policy
+			}
+			if role.ScopeType == "" {
+				role.ScopeType = detectScopeType(scope)
+			}
+			if role.MemberType == "Group" && item.LinkedEligibleRoleAssignment != nil {
+				// The eligibility is the group's; activations link to its schedule
+				role.PrincipalID = item.LinkedEligibleRoleAssignment.SubjectID
+			}
+			if item.StartDateTime != nil {
+				if t, err := time.Parse(time.RFC3339, *item.StartDateTime); err == nil {
+					role.StartDateTime = t
+				}
+			}
+			if item.EndDateTime != nil && *item.EndDateTime != "" {
+				if t, err := time.Parse(time.RFC3339, *item.EndDateTime); err == nil {
+					role.EndDateTime = &t
+				}
+			}
+			roles = append(roles, role)
+		}
+		url = response.NextLink
+	}
+
+	resolveGroupNames(roles)
+	debugf("PIM aggregation API listed %d eligible role(s)", len(roles))
+	return roles, nil
+}
@@ -0,0 +1,80 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// WorkItem represents an Azure DevOps work item
+type WorkItem struct {
+	ID    int
+	Title string
+	State string
+	Type  string
+}
+
+type workItemResponse struct {
+	ID     int `json:"id"`
+	Fields struct {
+		Title string `json:"System.Title"`
+		State string `json:"System.State"`
+		Type  string `json:"System.WorkItemType"`
+	} `json:"fields"`
+}
+
+func (w workItemResponse) toWorkItem() WorkItem {
+	return WorkItem{
+		ID:    w.ID,
+		Title: w.Fields.Title,
+		State: w.Fields.State,
+		Type:  w.Fields.Type,
+	}
+}
+
+// GetWorkItem fetches a single Azure DevOps work item using the azure-devops CLI extension
+func GetWorkItem(organization string, id int) (*WorkItem, error) {
+	output, err := runAzCommand("boards", "work-item", "show",
+		"--id", strconv.Itoa(id),
+		"--org", organization,
+		"--output", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get work item %d: %w", id, err)
+	}
+
+	var response workItemResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse work item: %w", err)
+	}
+
+	item := response.toWorkItem()
+	return &item, nil
+}
+
+// GetMyActiveWorkItems returns work items assigned to the signed-in user that are not closed
+func GetMyActiveWorkItems(organization, project string) ([]WorkItem, error) {
+	wiql := "SELECT [System.Id], [System.Title], [System.State], [System.WorkItemType] FROM workitems " +
+		"WHERE [System.AssignedTo] = @Me AND [System.StateCategory] NOT IN ('Completed', 'Removed') " +
+		"ORDER BY [System.ChangedDate] DESC"
+
+	args := []string{"boards", "query", "--wiql", wiql, "--org", organization, "--output", "json"}
+	if project != "" {
+		args = append(args, "--project", project)
+	}
+
+	output, err := runAzCommand(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query work items: %w", err)
+	}
+
+	var response []workItemResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse work items: %w", err)
+	}
+
+	items := make([]WorkItem, 0, len(response))
+	for _, r := range response {
+		items = append(items, r.toWorkItem())
+	}
+	return items, nil
+}
@@ -0,0 +1,118 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// BreadcrumbSeparator joins the levels of a scope path
+const BreadcrumbSeparator = " › "
+
+// scopeEntity is a management group or subscription with its ancestors
+type scopeEntity struct {
+	name    string
+	parents []string // display names, from the root down
+}
+
+var (
+	hierarchyOnce sync.Once
+	hierarchyErr  error
+	hierarchyMu   sync.RWMutex
+	// hierarchy maps lowercase management group and subscription IDs (the
+	// last scope segment) to their names and ancestors
+	hierarchy map[string]scopeEntity
+)
+
+// LoadScopeHierarchy fetches the management group tree once per process, so
+// ScopeBreadcrumb can include the management groups above a subscription.
+// Only entities the user can see are returned.
+func LoadScopeHierarchy() error {
+	hierarchyOnce.Do(func() {
+		entities := make(map[string]scopeEntity)
+		url := "https://management.azure.com/providers/Microsoft.Management/getEntities?api-version=2020-05-01"
+		for url != "" {
+			output, err := rest("POST", url, nil)
+			if err != nil {
+				hierarchyErr = fmt.Errorf("failed to get management groups: %w", err)
+				return
+			}
+			var page struct {
+				Value []struct {
+					Name       string `json:"name"`
+					Properties struct {
+						DisplayName            string   `json:"displayName"`
+						ParentDisplayNameChain []string `json:"parentDisplayNameChain"`
+					} `json:"properties"`
+				} `json:"value"`
+				NextLink string `json:"nextLink"`
+			}
+			if err := json.Unmarshal([]byte(output), &page); err != nil {
+				hierarchyErr = fmt.Errorf("failed to parse management groups: %w", err)
+				return
+			}
+			for _, e := range page.Value {
+				entities[strings.ToLower(e.Name)] = scopeEntity{name: e.Properties.DisplayName, parents: e.Properties.ParentDisplayNameChain}
+			}
+			url = page.NextLink
+		}
+		hierarchyMu.Lock()
+		hierarchy = entities
+		hierarchyMu.Unlock()
+	})
+	return hierarchyErr
+}
+
+// ScopeBreadcrumb renders a role's scope as its hierarchy, e.g.
+// "Platform › Prod › app-rg › web01", so identically named resource groups
+// in different subscriptions can be told apart. Management groups are only
+// included once LoadScopeHierarchy has run.
+func ScopeBreadcrumb(r RoleAssignment) string {
+	hierarchyMu.RLock()
+	defer hierarchyMu.RUnlock()
+
+	var crumbs []string
+	parts := strings.Split(strings.Trim(r.Scope, "/"), "/")
+	for i := 0; i+1 < len(parts); {
+		switch {
+		case strings.EqualFold(parts[i], "subscriptions"):
+			name := r.SubscriptionName
+			if name == "" {
+				name = parts[i+1]
+			}
+			crumbs = append(crumbs, entityPath(parts[i+1], name)...)
+			i += 2
+		case strings.EqualFold(parts[i], "providers"):
+			if strings.EqualFold(parts[i+1], "Microsoft.Management") && i+3 < len(parts) && strings.EqualFold(parts[i+2], "managementGroups") {
+				crumbs = append(crumbs, entityPath(parts[i+3], parts[i+3])...)
+				i += 4
+				continue
+			}
+			// A resource provider namespace, followed by type/name pairs
+			i += 2
+		default:
+			// resourceGroups/<name> or a resource type/name pair
+			crumbs = append(crumbs, parts[i+1])
+			i += 2
+		}
+	}
+	if len(crumbs) == 0 {
+		return r.ScopeName
+	}
+	return strings.Join(crumbs, BreadcrumbSeparator)
+}
+
+// entityPath returns the ancestors and name of a management group or
+// subscription, or just fallback when the hierarchy isn't loaded
+func entityPath(id, fallback string) []string {
+	e, ok := hierarchy[strings.ToLower(id)]
+	if !ok {
+		return []string{fallback}
+	}
+	name := e.name
+	if name == "" {
+		name = fallback
+	}
+	return append(append([]string{}, e.parents...), name)
+}
@@ -3,9 +3,15 @@ package azure
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/cassette"
+	"github.com/ica-js/hacktivator/internal/telemetry"
+	"github.com/ica-js/hacktivator/internal/tracelog"
 )
 
 // UserInfo represents the current Azure CLI user
@@ -18,6 +24,9 @@ type UserInfo struct {
 
 // IsAzCliInstalled checks if the Azure CLI is installed
 func IsAzCliInstalled() bool {
+	if cassette.Replaying() {
+		return true
+	}
 	_, err := exec.LookPath("az")
 	return err == nil
 }
@@ -30,30 +39,51 @@ func IsAuthenticated() bool {
 
 // GetCurrentUser returns information about the currently logged-in user
 func GetCurrentUser() (*UserInfo, error) {
-	output, err := runAzCommand("ad", "signed-in-user", "show", "--output", "json")
+	user, err := SignedInUser()
 	if err != nil {
 		return getCurrentUserFromAccount()
 	}
+	return user, nil
+}
+
+// SignedInUser reads the signed-in user from Microsoft Graph, without
+// falling back to the Azure CLI's account like GetCurrentUser
+func SignedInUser() (*UserInfo, error) {
+	output, err := runAzCommand("ad", "signed-in-user", "show", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
 
 	var user UserInfo
 	if err := json.Unmarshal([]byte(output), &user); err != nil {
-		return getCurrentUserFromAccount()
+		return nil, fmt.Errorf("failed to parse signed-in user: %w", err)
 	}
-
 	return &user, nil
 }
 
-// GetCurrentUserPrincipalID returns the object ID of the currently signed-in user
+// GetCurrentUserPrincipalID returns the object ID of the currently signed-in
+// user, or of the service principal the Azure CLI is signed in as. It comes
+// from the oid claim of the ARM access token, the principal ARM authorizes,
+// so it needs no Microsoft Graph access, which some accounts lack or
+// Conditional Access blocks. Graph is only asked if the token can't be read.
 func GetCurrentUserPrincipalID() (string, error) {
+	claims, err := accessTokenClaims()
+	if err == nil && claims.ObjectID != "" {
+		return claims.ObjectID, nil
+	}
+	if err != nil {
+		debugf("Could not read the principal ID from the access token: %v", err)
+	}
+
 	output, err := runAzCommand("ad", "signed-in-user", "show", "--query", "id", "--output", "tsv")
 	if err != nil {
 		return "", fmt.Errorf("failed to get current user principal ID: %w", err)
 	}
-
 	return strings.TrimSpace(output), nil
 }
 
-// getCurrentUserFromAccount gets user info from az account show as fallback
+// getCurrentUserFromAccount gets user info from az account show and the
+// access token, for accounts that can't read themselves from Microsoft Graph
 func getCurrentUserFromAccount() (*UserInfo, error) {
 	output, err := runAzCommand("account", "show", "--output", "json")
 	if err != nil {
@@ -69,22 +99,57 @@ func getCurrentUserFromAccount() (*UserInfo, error) {
 		return nil, err
 	}
 
-	return &UserInfo{
+	user := &UserInfo{
 		DisplayName: account.User.Name,
 		UPN:         account.User.Name,
-	}, nil
+	}
+	if claims, err := accessTokenClaims(); err == nil {
+		user.ObjectID = claims.ObjectID
+	}
+	return user, nil
 }
 
-// runAzCommand executes an Azure CLI command and returns the output
+// runAzCommand executes an Azure CLI command and returns the output. If the
+// session has expired, the user is offered to sign in again and the command
+// is retried once.
 func runAzCommand(args ...string) (string, error) {
+	start := time.Now()
+	output, err := runAz(args...)
+	if err != nil && args[0] != "login" && errors.Is(err, ErrSessionExpired) && reauthenticate(start) {
+		return runAz(args...)
+	}
+	return output, err
+}
+
+// runAz executes an Azure CLI command once
+func runAz(args ...string) (string, error) {
 	cmd := exec.Command("az", args...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("az command failed: %w\nstderr: %s", err, stderr.String())
+	span := telemetry.StartClient("az "+args[0], telemetry.String("process.command_args", "az "+strings.Join(tracelog.RedactArgs(args), " ")))
+	start := time.Now()
+	var err error
+	if cassette.Replaying() {
+		err = cassette.ReplayAz(args, &stdout, &stderr)
+	} else {
+		err = cmd.Run()
+		cassette.RecordAz(args, stdout.String(), stderr.String(), err)
+	}
+	if err != nil {
+		exitCode := -1
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+		tracelog.Az(args, stdout.String(), stderr.String(), exitCode, start, err)
+		err = classifyError(fmt.Errorf("az command failed: %w\nstderr: %s", err, stderr.String()))
+		span.End(err)
+		return "", err
 	}
+	tracelog.Az(args, stdout.String(), stderr.String(), 0, start, nil)
+	span.End(nil)
 
 	return stdout.String(), nil
 }
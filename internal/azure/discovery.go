@@ -0,0 +1,210 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discovery modes, chosen with SetDiscoveryMode
+const (
+	// DiscoverScan queries the tenant and then one subscription at a time
+	DiscoverScan = "scan"
+	// DiscoverParallel queries several subscriptions at a time
+	DiscoverParallel = "parallel"
+	// DiscoverBatch queries up to maxBatchRequests subscriptions per request
+	// with the ARM batch API
+	DiscoverBatch = "batch"
+	// DiscoverAggregate lists every eligibility at once from the PIM
+	// aggregation API the Azure portal uses, falling back to DiscoverScan
+	DiscoverAggregate = "aggregate"
+)
+
+// DiscoveryModes lists the discovery modes, the default first
+var DiscoveryModes = []string{DiscoverScan, DiscoverParallel, DiscoverBatch, DiscoverAggregate}
+
+// DiscoveryMode is how ScanEligibleRoles finds eligible roles, and
+// GetActiveRoleAssignments active ones
+var DiscoveryMode = DiscoverScan
+
+// SetDiscoveryMode sets DiscoveryMode from config. An empty name keeps the default.
+func SetDiscoveryMode(name string) error {
+	if name == "" {
+		return nil
+	}
+	for _, mode := range DiscoveryModes {
+		if strings.EqualFold(name, mode) {
+			DiscoveryMode = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown discovery_mode %q (available: %s)", name, strings.Join(DiscoveryModes, ", "))
+}
+
+// maxParallelScans bounds the subscriptions queried at once in the parallel
+// mode, well below the ARM read limit per principal
+const maxParallelScans = 8
+
+// maxBatchRequests is how many requests ARM accepts in one batch
+const maxBatchRequests = 20
+
+// roleListing is a listing of roles that is queried in every subscription:
+// the eligible roles, or the active ones
+type roleListing struct {
+	name  string                    // e.g. "eligible roles", for messages
+	path  func(scope string) string // see eligibleRolesPath
+	parse func(data []byte) ([]RoleAssignment, string, error)
+}
+
+var (
+	eligibleListing = roleListing{"eligible roles", eligibleRolesPath, parseEligibleRoles}
+	activeListing   = roleListing{"active roles", activeRolesPath, func(data []byte) ([]RoleAssignment, string, error) {
+		return parseScheduleInstances(data, false)
+	}}
+)
+
+// fetch lists the roles from url, following the pages
+func (l roleListing) fetch(url string) ([]RoleAssignment, error) {
+	var all []RoleAssignment
+	for url != "" {
+		output, err := rest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		roles, next, err := l.parse([]byte(output))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, roles...)
+		url = next
+	}
+	return all, nil
+}
+
+// querySubscriptions queries the listing in each subscription as mode says,
+// returning the results in the order of subs
+func querySubscriptions(mode string, l roleListing, subs []Subscription) []SubscriptionScan {
+	switch mode {
+	case DiscoverParallel:
+		return scanSubscriptionsParallel(l, subs)
+	case DiscoverBatch:
+		return scanSubscriptionsBatched(l, subs)
+	}
+	results := make([]SubscriptionScan, len(subs))
+	for i, sub := range subs {
+		results[i] = scanSubscription(l, sub)
+	}
+	return results
+}
+
+// scanSubscription queries the listing in one subscription. A failure is
+// logged and recorded so the next scan queries the subscription again; the
+// user might not have access to all subscriptions.
+func scanSubscription(l roleListing, sub Subscription) SubscriptionScan {
+	roles, err := l.fetch("https://management.azure.com" + l.path(fmt.Sprintf("/subscriptions/%s", sub.ID)))
+	if err != nil {
+		debugf("Could not list %s in %s: %v", l.name, sub.Name, err)
+		return SubscriptionScan{Failed: true, ScannedAt: time.Now()}
+	}
+	return SubscriptionScan{Roles: roles, ScannedAt: time.Now()}
+}
+
+// scanSubscriptionsParallel scans up to maxParallelScans subscriptions at a
+// time, returning the results in the order of subs
+func scanSubscriptionsParallel(l roleListing, subs []Subscription) []SubscriptionScan {
+	results := make([]SubscriptionScan, len(subs))
+	sem := make(chan struct{}, maxParallelScans)
+	var wg sync.WaitGroup
+	for i, sub := range subs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, sub Subscription) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = scanSubscription(l, sub)
+		}(i, sub)
+	}
+	wg.Wait()
+	return results
+}
+
+// batchResponse is the reply of the ARM batch API
+type batchResponse struct {
+	Responses []struct {
+		Name           string          `json:"name"`
+		HTTPStatusCode int             `json:"httpStatusCode"`
+		Content        json.RawMessage `json:"content"`
+	} `json:"responses"`
+}
+
+// scanSubscriptionsBatched sends the subscription queries through the ARM
+// batch API, maxBatchRequests at a time, returning the results in the order
+// of subs. Further pages are fetched one by one, and subscriptions missing
+// from a batch reply, or the whole batch if it fails, are scanned on their
+// own.
+func scanSubscriptionsBatched(l roleListing, subs []Subscription) []SubscriptionScan {
+	results := make([]SubscriptionScan, len(subs))
+	for start := 0; start < len(subs); start += maxBatchRequests {
+		chunk := subs[start:min(start+maxBatchRequests, len(subs))]
+
+		type batchRequest struct {
+			Name       string `json:"name"`
+			HTTPMethod string `json:"httpMethod"`
+			URL        string `json:"url"`
+		}
+		requests := make([]batchRequest, len(chunk))
+		for i, sub := range chunk {
+			requests[i] = batchRequest{
+				Name:       fmt.Sprint(start + i),
+				HTTPMethod: http.MethodGet,
+				URL:        l.path(fmt.Sprintf("/subscriptions/%s", sub.ID)),
+			}
+		}
+		body, _ := json.Marshal(map[string]any{"requests": requests})
+
+		done := make(map[int]bool, len(chunk))
+		output, err := rest("POST", "https://management.azure.com/batch?api-version=2020-06-01", body)
+		if err != nil {
+			debugf("Batch of %d subscription queries failed: %v", len(chunk), err)
+		} else {
+			var response batchResponse
+			if err := json.Unmarshal([]byte(output), &response); err != nil {
+				debugf("Failed to parse batch response: %v", err)
+			}
+			for _, r := range response.Responses {
+				var i int
+				if _, err := fmt.Sscan(r.Name, &i); err != nil || i < start || i >= start+len(chunk) {
+					continue
+				}
+				if r.HTTPStatusCode != http.StatusOK {
+					debugf("Could not list %s in %s: status %d: %s", l.name, subs[i].Name, r.HTTPStatusCode, r.Content)
+					results[i] = SubscriptionScan{Failed: true, ScannedAt: time.Now()}
+					done[i] = true
+					continue
+				}
+				roles, next, err := l.parse(r.Content)
+				if err == nil && next != "" {
+					var more []RoleAssignment
+					more, err = l.fetch(next)
+					roles = append(roles, more...)
+				}
+				if err != nil {
+					debugf("Could not list %s in %s: %v", l.name, subs[i].Name, err)
+					continue
+				}
+				results[i] = SubscriptionScan{Roles: roles, ScannedAt: time.Now()}
+				done[i] = true
+			}
+		}
+
+		for i := start; i < start+len(chunk); i++ {
+			if !done[i] {
+				results[i] = scanSubscription(l, subs[i])
+			}
+		}
+	}
+	return results
+}
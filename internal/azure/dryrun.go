@@ -0,0 +1,79 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DryRun prints mutating requests instead of sending them. Read-only
+// requests still go out so the plan reflects the real state.
+var DryRun bool
+
+// DryRunOutput receives the requests printed in dry-run mode
+var DryRunOutput io.Writer = os.Stdout
+
+// dryRunStatus is the status reported for requests that were not sent
+const dryRunStatus = "DryRun"
+
+var dryRunMu sync.Mutex
+
+// IsDryRun reports whether the request was only printed, not sent
+func (r *ActivationResult) IsDryRun() bool {
+	return r.Status == dryRunStatus
+}
+
+// redactedFields are replaced in printed request bodies. Justifications keep
+// their length, which is what most policy checks look at.
+var redactedFields = map[string]bool{
+	"principalId":  true,
+	"ticketNumber": true,
+}
+
+// redact returns a copy of a request body with identifying values masked
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = redactField(k, val)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = redactField(k, val)
+		}
+		return out
+	}
+	return v
+}
+
+func redactField(key string, val interface{}) interface{} {
+	s, isString := val.(string)
+	switch {
+	case redactedFields[key] && isString && s != "":
+		return "<redacted>"
+	case key == "justification" && isString:
+		return fmt.Sprintf("<redacted, %d characters>", len([]rune(s)))
+	}
+	return redact(val)
+}
+
+// printDryRun writes the request that would have been made
+func printDryRun(method, url string, body map[string]interface{}) {
+	var out strings.Builder
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(redact(body)); err != nil {
+		fmt.Fprintf(&out, "<unprintable body: %v>\n", err)
+	}
+
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+	fmt.Fprintf(DryRunOutput, "# dry run, not sent\n%s %s\n%s\n", method, url, out.String())
+}
@@ -0,0 +1,164 @@
+package azure
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Kinds of errors callers can test for with errors.Is, instead of matching
+// Azure's messages. The errors returned keep Azure's message.
+var (
+	// ErrNotAuthenticated means the Azure CLI isn't signed in, or Azure
+	// rejected its token
+	ErrNotAuthenticated = errors.New("not authenticated")
+	// ErrThrottled means Azure Resource Manager refused the request with
+	// 429 Too Many Requests
+	ErrThrottled = errors.New("throttled")
+	// ErrPendingApproval means the request can't be made while an earlier
+	// one for the role is waiting for an approver
+	ErrPendingApproval = errors.New("pending approval")
+	// ErrMFARequired means the request was rejected because the session
+	// wasn't signed in with MFA
+	ErrMFARequired = errors.New("MFA required")
+	// ErrSessionExpired means the Azure CLI's sign-in expired or was revoked
+	// and only a new `az login` helps. Such errors are also ErrNotAuthenticated.
+	ErrSessionExpired = errors.New("session expired")
+	// ErrRoleAssignmentExists means the role is already active, e.g. because
+	// an earlier activation went through
+	ErrRoleAssignmentExists = errors.New("role assignment exists")
+	// ErrRoleAssignmentNotFound means the role isn't active, e.g. because it
+	// was deactivated already
+	ErrRoleAssignmentNotFound = errors.New("role assignment not found")
+)
+
+// ErrPolicyViolation means a role management policy rejected a request;
+// test for it with errors.As
+type ErrPolicyViolation struct {
+	// Rule is the policy rule that failed, e.g. JustificationRule, or empty
+	// if Azure didn't say
+	Rule string
+	Err  error
+}
+
+func (e *ErrPolicyViolation) Error() string { return e.Err.Error() }
+
+func (e *ErrPolicyViolation) Unwrap() error { return e.Err }
+
+// kindError attaches error kinds to an error, keeping its message
+type kindError struct {
+	kinds []error
+	err   error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+
+func (e *kindError) Unwrap() []error { return append(e.kinds[:len(e.kinds):len(e.kinds)], e.err) }
+
+// policyMarkers appear in ARM error responses when a PIM policy rejects a request
+var policyMarkers = []string{
+	"RoleAssignmentRequestPolicyValidationFailed",
+	"PolicyViolation",
+	"JustificationRule",
+	"TicketingRule",
+	"ExpirationRule",
+	"MfaRule",
+}
+
+// failedRule finds the name of the failed rule, e.g. "ExpirationRule"
+var failedRule = regexp.MustCompile(`\b[A-Z][A-Za-z]*Rule\b`)
+
+// authMarkers appear in az output and ARM responses when the session is
+// missing, expired or rejected
+var authMarkers = []string{
+	"AADSTS",
+	"az login",
+	"InvalidAuthenticationToken",
+	"ExpiredAuthenticationToken",
+}
+
+// throttleMarkers appear in az output when ARM throttled the request
+var throttleMarkers = []string{
+	"TooManyRequests",
+	"Too Many Requests",
+}
+
+// pendingMarkers appear in ARM responses when a request for the role is
+// already waiting for approval
+var pendingMarkers = []string{
+	"PendingRoleAssignmentRequest",
+}
+
+// mfaMarkers appear in ARM and az errors when the token lacks the MFA claim a
+// role management policy or Conditional Access policy demands
+var mfaMarkers = []string{
+	"MfaRule",
+	"RoleAssignmentRequestAcrsValidationFailed",
+	"AADSTS50076",
+	"AADSTS50079",
+}
+
+// sessionExpiredMarkers appear in az output when the CLI's sign-in has
+// expired or been revoked and only a new `az login` helps
+var sessionExpiredMarkers = []string{
+	"AADSTS50133", // password expired
+	"AADSTS50173", // grant revoked, e.g. by a password change
+	"AADSTS70008", // refresh token expired
+	"AADSTS70043", // sign-in frequency of a Conditional Access policy
+	"AADSTS700082",
+	"AADSTS700084",
+	"ExpiredAuthenticationToken",
+	"To re-authenticate, please run",
+}
+
+// errorKinds lists the markers of each error kind. An error can be of
+// several kinds, e.g. an expired session is also not authenticated.
+var errorKinds = []struct {
+	kind    error
+	markers []string
+}{
+	{ErrPendingApproval, pendingMarkers},
+	{ErrThrottled, throttleMarkers},
+	{ErrMFARequired, mfaMarkers},
+	{ErrSessionExpired, sessionExpiredMarkers},
+	{ErrNotAuthenticated, append(authMarkers, sessionExpiredMarkers...)},
+	{ErrRoleAssignmentExists, []string{"RoleAssignmentExists"}},
+	{ErrRoleAssignmentNotFound, []string{"RoleAssignmentDoesNotExist"}},
+}
+
+// classifyError attaches the kinds of err, told by Azure's error codes, so
+// callers can branch on them. Errors of no known kind are returned as they are.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var policy *ErrPolicyViolation
+	var kind *kindError
+	if errors.As(err, &policy) || errors.As(err, &kind) {
+		return err
+	}
+
+	msg := err.Error()
+	var kinds []error
+	for _, k := range errorKinds {
+		if containsAny(msg, k.markers) {
+			kinds = append(kinds, k.kind)
+		}
+	}
+	if containsAny(msg, policyMarkers) {
+		err = &ErrPolicyViolation{Rule: failedRule.FindString(msg), Err: err}
+	}
+	if len(kinds) == 0 {
+		return err
+	}
+	return &kindError{kinds, err}
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		msg   string
+		kinds []error
+		rule  string // of a policy violation
+	}{
+		{msg: `{"error":{"code":"RoleAssignmentExists","message":"The Role assignment already exists."}}`,
+			kinds: []error{ErrRoleAssignmentExists}},
+		{msg: `{"error":{"code":"RoleAssignmentDoesNotExist","message":"The Role assignment does not exist."}}`,
+			kinds: []error{ErrRoleAssignmentNotFound}},
+		{msg: `{"error":{"code":"RoleAssignmentRequestPolicyValidationFailed","message":"The following policy rules failed: [\"MfaRule\"]"}}`,
+			kinds: []error{ErrMFARequired}, rule: "MfaRule"},
+		{msg: "AADSTS70043: The refresh token has expired due to sign-in frequency checks. To re-authenticate, please run `az login`.",
+			kinds: []error{ErrSessionExpired, ErrNotAuthenticated}},
+		{msg: "Please run 'az login' to setup account.",
+			kinds: []error{ErrNotAuthenticated}},
+		{msg: `{"error":{"code":"PendingRoleAssignmentRequest"}}`,
+			kinds: []error{ErrPendingApproval}},
+		{msg: "connection reset by peer"},
+	}
+	all := []error{ErrNotAuthenticated, ErrThrottled, ErrPendingApproval, ErrMFARequired,
+		ErrSessionExpired, ErrRoleAssignmentExists, ErrRoleAssignmentNotFound}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("request failed: %w", classifyError(errors.New(tt.msg)))
+		for _, kind := range all {
+			want := false
+			for _, k := range tt.kinds {
+				want = want || k == kind
+			}
+			if errors.Is(err, kind) != want {
+				t.Errorf("%s: errors.Is(%v) = %v, want %v", tt.msg, kind, !want, want)
+			}
+		}
+
+		var policy *ErrPolicyViolation
+		if errors.As(err, &policy) != (tt.rule != "") || tt.rule != "" && policy.Rule != tt.rule {
+			t.Errorf("%s: policy violation %+v, want rule %q", tt.msg, policy, tt.rule)
+		}
+		if err.Error() != "request failed: "+tt.msg {
+			t.Errorf("message changed to %q", err.Error())
+		}
+	}
+}
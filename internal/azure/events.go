@@ -0,0 +1,28 @@
+package azure
+
+// RequestEvent describes an activate, extend, deactivate, renew or admin
+// request after it was sent to Azure
+type RequestEvent struct {
+	RequestType string // SelfActivate, SelfExtend, SelfDeactivate, SelfRenew, AdminAssign or AdminRemove
+	Role        RoleAssignment
+	// Principal names who an admin request is for; empty for self requests
+	Principal string
+	// Eligibility is set for requests on eligibilities rather than active assignments
+	Eligibility   bool
+	Duration      int // minutes; zero for deactivation
+	Justification string
+	TicketNumber  string
+	TicketSystem  string
+	Result        *ActivationResult // nil if the request failed
+	Err           error
+}
+
+// OnScheduleRequest, when set, is called after every schedule request,
+// including failed ones and dry runs
+var OnScheduleRequest func(RequestEvent)
+
+func notifyScheduleRequest(e RequestEvent) {
+	if OnScheduleRequest != nil {
+		OnScheduleRequest(e)
+	}
+}
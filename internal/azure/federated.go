@@ -0,0 +1,161 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// federatedAudience is the audience Microsoft Entra ID expects in tokens it
+// exchanges for a federated credential
+const federatedAudience = "api://AzureADTokenExchange"
+
+// FederatedIdentity is a service principal that trusts the OIDC tokens of a
+// pipeline or workload instead of holding a secret
+type FederatedIdentity struct {
+	ClientID string
+	Tenant   string
+}
+
+// FederatedIdentityFromEnv reads the service principal from AZURE_CLIENT_ID
+// and AZURE_TENANT_ID, or from the variables the Azure Pipelines AzureCLI
+// task sets for its service connection
+func FederatedIdentityFromEnv() (FederatedIdentity, error) {
+	id := FederatedIdentity{
+		ClientID: firstEnv("AZURE_CLIENT_ID", "AZURESUBSCRIPTION_CLIENT_ID"),
+		Tenant:   firstEnv("AZURE_TENANT_ID", "AZURESUBSCRIPTION_TENANT_ID"),
+	}
+	if id.ClientID == "" || id.Tenant == "" {
+		return id, errors.New("set AZURE_CLIENT_ID and AZURE_TENANT_ID to the federated service principal's application ID and tenant")
+	}
+	return id, nil
+}
+
+// FederatedToken returns an OIDC token of the environment for exchange with
+// Microsoft Entra ID: from AZURE_FEDERATED_TOKEN_FILE (e.g. AKS workload
+// identity), GitHub Actions, or an Azure Pipelines service connection. source
+// names where it came from.
+func FederatedToken() (token, source string, err error) {
+	if path := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read federated token: %w", err)
+		}
+		return strings.TrimSpace(string(data)), "AZURE_FEDERATED_TOKEN_FILE", nil
+	}
+	if requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"); requestURL != "" {
+		token, err := gitHubOIDCToken(requestURL, os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"))
+		return token, "GitHub Actions", err
+	}
+	if requestURI := os.Getenv("SYSTEM_OIDCREQUESTURI"); requestURI != "" {
+		token, err := azurePipelinesOIDCToken(requestURI, os.Getenv("SYSTEM_ACCESSTOKEN"),
+			os.Getenv("AZURESUBSCRIPTION_SERVICE_CONNECTION_ID"))
+		return token, "Azure Pipelines", err
+	}
+	return "", "", errors.New("no OIDC token available: run in GitHub Actions with 'id-token: write' permission, " +
+		"in Azure Pipelines with a workload identity service connection, or set AZURE_FEDERATED_TOKEN_FILE")
+}
+
+// gitHubOIDCToken requests the job's ID token for Azure
+func gitHubOIDCToken(requestURL, requestToken string) (string, error) {
+	if requestToken == "" {
+		return "", errors.New("ACTIONS_ID_TOKEN_REQUEST_TOKEN is not set; grant the job 'id-token: write' permission")
+	}
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("audience", federatedAudience)
+	u.RawQuery = q.Encode()
+
+	var response struct {
+		Value string `json:"value"`
+	}
+	if err := oidcRequest("GET", u.String(), requestToken, &response); err != nil {
+		return "", fmt.Errorf("failed to get the GitHub Actions OIDC token: %w", err)
+	}
+	return response.Value, nil
+}
+
+// azurePipelinesOIDCToken requests an ID token for a service connection
+func azurePipelinesOIDCToken(requestURI, accessToken, serviceConnectionID string) (string, error) {
+	if accessToken == "" {
+		return "", errors.New("SYSTEM_ACCESSTOKEN is not set; map it into the step's environment")
+	}
+	if serviceConnectionID == "" {
+		return "", errors.New("AZURESUBSCRIPTION_SERVICE_CONNECTION_ID is not set; run in an AzureCLI task or set it to the service connection's ID")
+	}
+	u, err := url.Parse(requestURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid SYSTEM_OIDCREQUESTURI: %w", err)
+	}
+	q := u.Query()
+	q.Set("api-version", "7.1")
+	q.Set("serviceConnectionId", serviceConnectionID)
+	u.RawQuery = q.Encode()
+
+	var response struct {
+		OIDCToken string `json:"oidcToken"`
+	}
+	if err := oidcRequest("POST", u.String(), accessToken, &response); err != nil {
+		return "", fmt.Errorf("failed to get the Azure Pipelines OIDC token: %w", err)
+	}
+	return response.OIDCToken, nil
+}
+
+// oidcRequest calls a CI system's token endpoint and decodes the response
+func oidcRequest(method, url, bearer string, v any) error {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// LoginFederated signs the Azure CLI in as the service principal with an
+// OIDC token. The principal may have no subscriptions until its roles are
+// activated.
+func LoginFederated(id FederatedIdentity, token string) error {
+	if token == "" {
+		return errors.New("the OIDC token is empty")
+	}
+	_, err := runAzCommand("login", "--service-principal", "--username", id.ClientID, "--tenant", id.Tenant,
+		"--federated-token", token, "--allow-no-subscriptions", "--output", "none")
+	if err != nil {
+		return fmt.Errorf("failed to sign in as %s: %w", id.ClientID, err)
+	}
+	invalidateToken()
+	return nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
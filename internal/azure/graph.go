@@ -0,0 +1,34 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const graphBaseURL = "https://graph.microsoft.com/v1.0"
+
+// SendMail sends an HTML email as the signed-in user through Microsoft Graph.
+// It goes through `az rest`, which requests a Graph token for the Azure CLI;
+// the tenant must allow the Azure CLI the delegated Mail.Send permission.
+func SendMail(to []string, subject, html string) error {
+	recipients := make([]map[string]any, len(to))
+	for i, addr := range to {
+		recipients[i] = map[string]any{"emailAddress": map[string]string{"address": addr}}
+	}
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"subject":      subject,
+			"body":         map[string]string{"contentType": "HTML", "content": html},
+			"toRecipients": recipients,
+		},
+		"saveToSentItems": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal email: %w", err)
+	}
+
+	if _, err := azRest("POST", graphBaseURL+"/me/sendMail", body); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package azure
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// LookupsTTL is how long a remembered policy or role definition lookup is
+// trusted before ARM is asked again
+const LookupsTTL = 24 * time.Hour
+
+// PolicyLookup is the activation limit of a role's policy at a scope
+type PolicyLookup struct {
+	MaxMinutes int       `json:"maxMinutes"` // 0 if the policy sets no limit
+	FetchedAt  time.Time `json:"fetchedAt"`
+}
+
+// RoleDefinitionLookup is the ID a role name resolved to at a scope
+type RoleDefinitionLookup struct {
+	ID        string    `json:"id"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Lookups are remembered answers to policy and role definition queries,
+// keyed by role definition ID and scope, and by scope and role name
+type Lookups struct {
+	Policies        map[string]PolicyLookup         `json:"policies"`
+	RoleDefinitions map[string]RoleDefinitionLookup `json:"roleDefinitions"`
+}
+
+var (
+	lookupsMu sync.Mutex
+	lookups   = Lookups{
+		Policies:        make(map[string]PolicyLookup),
+		RoleDefinitions: make(map[string]RoleDefinitionLookup),
+	}
+
+	// OnLookup is called with all remembered lookups whenever a query adds
+	// one, e.g. to cache them between runs
+	OnLookup func(Lookups)
+)
+
+// SetLookups provides lookups remembered from earlier runs
+func SetLookups(l Lookups) {
+	lookupsMu.Lock()
+	defer lookupsMu.Unlock()
+	lookups = l.clone()
+}
+
+// CurrentLookups returns the remembered lookups
+func CurrentLookups() Lookups {
+	lookupsMu.Lock()
+	defer lookupsMu.Unlock()
+	return lookups.clone()
+}
+
+// clone copies the maps, so callers never share them with queries in flight
+func (l Lookups) clone() Lookups {
+	c := Lookups{
+		Policies:        make(map[string]PolicyLookup, len(l.Policies)),
+		RoleDefinitions: make(map[string]RoleDefinitionLookup, len(l.RoleDefinitions)),
+	}
+	for k, v := range l.Policies {
+		c.Policies[k] = v
+	}
+	for k, v := range l.RoleDefinitions {
+		c.RoleDefinitions[k] = v
+	}
+	return c
+}
+
+// RememberRoleDefinition records the ID of a role at a scope, e.g. from an
+// eligibility, so resolving the role by name needs no query
+func RememberRoleDefinition(scope, name, id string) {
+	lookupsMu.Lock()
+	lookups.RoleDefinitions[lookupKey(scope, name)] = RoleDefinitionLookup{ID: id, FetchedAt: time.Now()}
+	lookupsMu.Unlock()
+	lookupAdded()
+}
+
+func lookupKey(parts ...string) string {
+	return strings.ToLower(strings.Join(parts, "|"))
+}
+
+func cachedPolicy(role RoleAssignment) (int, bool) {
+	lookupsMu.Lock()
+	defer lookupsMu.Unlock()
+	p, ok := lookups.Policies[lookupKey(role.RoleDefinitionID, role.Scope)]
+	if !ok || time.Since(p.FetchedAt) >= LookupsTTL {
+		return 0, false
+	}
+	return p.MaxMinutes, true
+}
+
+func rememberPolicy(role RoleAssignment, maxMinutes int) {
+	lookupsMu.Lock()
+	lookups.Policies[lookupKey(role.RoleDefinitionID, role.Scope)] = PolicyLookup{MaxMinutes: maxMinutes, FetchedAt: time.Now()}
+	lookupsMu.Unlock()
+	lookupAdded()
+}
+
+func cachedRoleDefinition(scope, name string) (string, bool) {
+	lookupsMu.Lock()
+	defer lookupsMu.Unlock()
+	d, ok := lookups.RoleDefinitions[lookupKey(scope, name)]
+	if !ok || time.Since(d.FetchedAt) >= LookupsTTL {
+		return "", false
+	}
+	return d.ID, true
+}
+
+// lookupAdded passes a snapshot of the lookups to OnLookup
+func lookupAdded() {
+	if OnLookup == nil {
+		return
+	}
+	OnLookup(CurrentLookups())
+}
@@ -0,0 +1,83 @@
+package azure
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HTTPMetrics sums up the direct HTTP requests of a run, to tell a slow
+// tenant from a throttled or flaky one. Requests sent through `az rest`
+// aren't counted.
+type HTTPMetrics struct {
+	Requests  int           `json:"requests"`
+	Retries   int           `json:"retries"`   // requests sent again, e.g. after a rejected token
+	Throttles int           `json:"throttles"` // 429 Too Many Requests responses
+	Errors    int           `json:"errors"`    // requests that got no response
+	Bytes     int64         `json:"bytes"`     // sent and received bodies
+	Total     time.Duration `json:"totalNs"`
+	P95       time.Duration `json:"p95Ns"`
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   HTTPMetrics
+	latencies []time.Duration
+)
+
+// recordRequest counts one request. status is 0 when there was no response.
+func recordRequest(status int, bytes int, elapsed time.Duration) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics.Requests++
+	metrics.Bytes += int64(bytes)
+	metrics.Total += elapsed
+	switch status {
+	case 0:
+		metrics.Errors++
+	case http.StatusTooManyRequests:
+		metrics.Throttles++
+	}
+	latencies = append(latencies, elapsed)
+}
+
+// recordRetry counts a request that is sent again
+func recordRetry() {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics.Retries++
+}
+
+// RunHTTPMetrics returns the metrics of the requests so far
+func RunHTTPMetrics() HTTPMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	m := metrics
+	if len(latencies) > 0 {
+		sorted := slices.Clone(latencies)
+		slices.Sort(sorted)
+		// Nearest rank
+		m.P95 = sorted[(len(sorted)*95+99)/100-1]
+	}
+	return m
+}
+
+// String summarizes the metrics on one line
+func (m HTTPMetrics) String() string {
+	return fmt.Sprintf("%d request(s), %d retried, %d throttled, %d without response, %s, %s total, p95 %s",
+		m.Requests, m.Retries, m.Throttles, m.Errors, formatBytes(m.Bytes),
+		m.Total.Round(time.Millisecond), m.P95.Round(time.Millisecond))
+}
+
+// formatBytes shows a byte count in B, KiB or MiB
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/ica-js/hacktivator/internal/telemetry"
 )
 
 // Verbose enables debug output when set to true
@@ -15,20 +17,32 @@ var Verbose bool
 
 // RoleAssignment represents a PIM role assignment (eligible or active)
 type RoleAssignment struct {
-	ID                     string
-	RoleDefinitionID       string
-	RoleName               string
-	Scope                  string
-	ScopeName              string
-	ScopeType              string // subscription, resourceGroup, managementGroup
-	PrincipalID            string
-	Status                 string
-	MemberType             string
-	StartDateTime          time.Time
-	EndDateTime            *time.Time
-	MaxDuration            int // maximum activation duration in minutes
-	EligibilityID          string
-	ExpandedProperties     *ExpandedProperties
+	ID               string
+	RoleDefinitionID string
+	RoleName         string
+	Scope            string
+	ScopeName        string
+	ScopeType        string // subscription, resourceGroup, managementGroup
+	SubscriptionID   string // empty for management group and tenant scopes
+	SubscriptionName string
+	PrincipalID      string
+	Status           string
+	MemberType       string
+	GroupName        string // the group granting the role, when MemberType is Group
+	AssignmentType   string // Activated or Assigned, for active roles
+	StartDateTime    time.Time
+	EndDateTime      *time.Time
+	MaxDuration      int // maximum activation duration in minutes
+	EligibilityID    string
+	// EligibilityScheduleID is the roleEligibilitySchedule behind an eligible
+	// role's instance, when the listing includes it
+	EligibilityScheduleID string
+	ScheduleID            string             // roleAssignmentSchedule of an active role
+	Activation            *ActivationDetails // set by AttachActivationDetails
+	// AutoDeactivateAt is when hacktivator deactivates the role before it
+	// ends, see --auto-deactivate
+	AutoDeactivateAt   *time.Time
+	ExpandedProperties *ExpandedProperties
 }
 
 // ExpandedProperties contains detailed role and scope information
@@ -63,10 +77,17 @@ type PrincipalInfo struct {
 // ActivationRequest contains parameters for role activation
 type ActivationRequest struct {
 	Role          RoleAssignment
-	Duration      int    // in minutes
+	Duration      int // in minutes
 	Justification string
 	TicketNumber  string
 	TicketSystem  string
+	// RequestID resubmits a request made earlier under this ID, e.g. when
+	// retrying after a network failure; the existing request is reused if
+	// it reached Azure
+	RequestID string
+	// Fresh submits a new request even if the role was activated within
+	// RequestKeyWindow
+	Fresh bool
 }
 
 func debugf(format string, args ...interface{}) {
@@ -82,163 +103,407 @@ type roleEligibilityScheduleInstancesResponse struct {
 		Name       string `json:"name"`
 		Type       string `json:"type"`
 		Properties struct {
-			RoleDefinitionID       string              `json:"roleDefinitionId"`
-			Scope                  string              `json:"scope"`
-			PrincipalID            string              `json:"principalId"`
-			Status                 string              `json:"status"`
-			MemberType             string              `json:"memberType"`
-			StartDateTime          string              `json:"startDateTime"`
-			EndDateTime            *string             `json:"endDateTime"`
-			ExpandedProperties     *ExpandedProperties `json:"expandedProperties"`
+			RoleDefinitionID          string              `json:"roleDefinitionId"`
+			Scope                     string              `json:"scope"`
+			PrincipalID               string              `json:"principalId"`
+			Status                    string              `json:"status"`
+			MemberType                string              `json:"memberType"`
+			AssignmentType            string              `json:"assignmentType"`
+			RoleAssignmentScheduleID  string              `json:"roleAssignmentScheduleId"`
+			RoleEligibilityScheduleID string              `json:"roleEligibilityScheduleId"`
+			StartDateTime             string              `json:"startDateTime"`
+			EndDateTime               *string             `json:"endDateTime"`
+			ExpandedProperties        *ExpandedProperties `json:"expandedProperties"`
 		} `json:"properties"`
 	} `json:"value"`
 	NextLink string `json:"nextLink,omitempty"`
 }
 
+// EligibleScan is the result of an eligibility scan. Results are kept per
+// subscription so a later scan can reuse the subscriptions that haven't
+// changed.
+type EligibleScan struct {
+	Roles         []RoleAssignment            `json:"roles"`         // merged and deduplicated
+	TenantRoles   []RoleAssignment            `json:"tenantRoles"`   // from the tenant-wide listing
+	Subscriptions map[string]SubscriptionScan `json:"subscriptions"` // by lower-case subscription ID
+	ScannedAt     time.Time                   `json:"scannedAt"`
+	FullScanAt    time.Time                   `json:"fullScanAt"` // when every subscription was last queried
+}
+
+// SubscriptionScan is the eligibility listing of one subscription
+type SubscriptionScan struct {
+	Roles     []RoleAssignment `json:"roles"`
+	Failed    bool             `json:"failed,omitempty"` // queried again by the next scan
+	ScannedAt time.Time        `json:"scannedAt"`
+}
+
 // GetEligibleRoleAssignments fetches all eligible PIM role assignments for the current user
 func GetEligibleRoleAssignments() ([]RoleAssignment, error) {
-	var allRoles []RoleAssignment
+	scan, err := ScanEligibleRoles(nil)
+	if err != nil {
+		return nil, err
+	}
+	return scan.Roles, nil
+}
+
+// ScanEligibleRoles fetches all eligible role assignments for the current
+// user. With a previous scan, only subscriptions that are new or whose
+// listing failed are queried, and the rest are taken from previous. The
+// tenant-wide listing is always fetched again. DiscoveryMode decides how
+// the subscriptions are queried; in the aggregate mode everything is listed
+// at once instead, and subscriptions are only scanned if that fails.
+func ScanEligibleRoles(previous *EligibleScan) (*EligibleScan, error) {
+	mode := DiscoveryMode
+	if mode == DiscoverAggregate {
+		scan, err := scanAggregated()
+		if err == nil {
+			return scan, nil
+		}
+		debugf("Falling back to scanning subscriptions: %v", err)
+		mode = DiscoverScan
+	}
+	return scanSubscriptions(mode, previous)
+}
+
+// ScanEligibleRolesWith runs a full scan in the given discovery mode,
+// without reusing an earlier scan or falling back to another mode, e.g. to
+// compare the modes
+func ScanEligibleRolesWith(mode string) (*EligibleScan, error) {
+	if mode == DiscoverAggregate {
+		return scanAggregated()
+	}
+	return scanSubscriptions(mode, nil)
+}
+
+// scanSubscriptions lists the tenant-wide eligibilities and then those of
+// each subscription, queried as mode says
+func scanSubscriptions(mode string, previous *EligibleScan) (*EligibleScan, error) {
+	span := telemetry.Start("scan eligible roles", telemetry.String("discovery.mode", mode))
 
 	// Get all subscriptions first
 	subscriptions, err := getSubscriptions()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+		err = fmt.Errorf("failed to get subscriptions: %w", err)
+		span.End(err)
+		return nil, err
+	}
+	span.SetAttr(telemetry.Int("subscriptions", len(subscriptions)))
+
+	now := time.Now()
+	scan := &EligibleScan{
+		Subscriptions: make(map[string]SubscriptionScan),
+		ScannedAt:     now,
+		FullScanAt:    now,
+	}
+	if previous != nil {
+		scan.FullScanAt = previous.FullScanAt
 	}
 
 	// Also check at tenant level using the management API
 	// This covers management groups and other scopes
-	roles, err := getEligibleRolesAtScope("")
-	if err == nil {
-		allRoles = append(allRoles, roles...)
+	if roles, err := GetEligibleRolesAtScope(""); err == nil {
+		resolveGroupNames(roles)
+		scan.TenantRoles = roles
 	}
 
 	// Fetch eligible roles for each subscription
-	for _, sub := range subscriptions {
-		scope := fmt.Sprintf("/subscriptions/%s", sub.ID)
-		roles, err := getEligibleRolesAtScope(scope)
-		if err != nil {
-			// Log but continue - user might not have access to all subscriptions
-			continue
+	scanned := scannedSubscriptions(subscriptions)
+	reused := 0
+	var pending []Subscription
+	for _, sub := range scanned {
+		key := strings.ToLower(sub.ID)
+		if previous != nil {
+			if prev, ok := previous.Subscriptions[key]; ok && !prev.Failed {
+				scan.Subscriptions[key] = prev
+				reused++
+				continue
+			}
 		}
-		allRoles = append(allRoles, roles...)
+		pending = append(pending, sub)
+	}
+	results := querySubscriptions(mode, eligibleListing, pending)
+	for i, sub := range pending {
+		resolveGroupNames(results[i].Roles)
+		scan.Subscriptions[strings.ToLower(sub.ID)] = results[i]
+	}
+	if previous != nil {
+		debugf("Reused the previous scan of %d subscriptions", reused)
 	}
 
-	// Deduplicate roles based on ID
-	seen := make(map[string]bool)
-	uniqueRoles := make([]RoleAssignment, 0)
-	for _, role := range allRoles {
-		if !seen[role.ID] {
-			seen[role.ID] = true
-			uniqueRoles = append(uniqueRoles, role)
-		}
+	allRoles := append([]RoleAssignment(nil), scan.TenantRoles...)
+	for _, sub := range scanned {
+		allRoles = append(allRoles, scan.Subscriptions[strings.ToLower(sub.ID)].Roles...)
 	}
 
-	return uniqueRoles, nil
-}
+	subscriptionNames := make(map[string]string, len(subscriptions))
+	for _, sub := range subscriptions {
+		subscriptionNames[strings.ToLower(sub.ID)] = sub.Name
+	}
 
-// subscription represents an Azure subscription
-type subscription struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	scan.Roles = dedupeEligibilities(allRoles)
+	for i := range scan.Roles {
+		scan.Roles[i].SubscriptionName = subscriptionNames[strings.ToLower(scan.Roles[i].SubscriptionID)]
+	}
+
+	span.SetAttr(telemetry.Int("roles", len(scan.Roles)), telemetry.Int("subscriptions.reused", reused))
+	span.End(nil)
+	return scan, nil
 }
 
-func getSubscriptions() ([]subscription, error) {
-	output, err := runAzCommand("account", "list", "--query", "[].{id:id, name:name}", "-o", "json")
+// GetEligibleRolesInSubscription fetches the eligible role assignments that
+// apply to one subscription, without listing the others or the tenant
+func GetEligibleRolesInSubscription(sub Subscription) ([]RoleAssignment, error) {
+	roles, err := GetEligibleRolesAtScope(fmt.Sprintf("/subscriptions/%s", sub.ID))
 	if err != nil {
 		return nil, err
 	}
+	resolveGroupNames(roles)
+	roles = dedupeEligibilities(roles)
+	for i := range roles {
+		if strings.EqualFold(roles[i].SubscriptionID, sub.ID) {
+			roles[i].SubscriptionName = sub.Name
+		}
+	}
+	return roles, nil
+}
 
-	var subs []subscription
-	if err := json.Unmarshal([]byte(output), &subs); err != nil {
-		return nil, fmt.Errorf("failed to parse subscriptions: %w", err)
+// FindEligibility returns the eligibility for a role definition at exactly
+// scope, for activating without a scan. roleDefinitionID may be the full
+// resource ID or only its GUID.
+func FindEligibility(scope, roleDefinitionID string) (RoleAssignment, error) {
+	scope = strings.TrimSuffix(scope, "/")
+	roles, err := GetEligibleRolesAtScope(scope)
+	if err != nil {
+		return RoleAssignment{}, fmt.Errorf("failed to list eligibilities at %s: %w", scope, err)
 	}
+	resolveGroupNames(roles)
 
-	return subs, nil
+	var elsewhere []string
+	for _, role := range dedupeEligibilities(roles) {
+		if !strings.EqualFold(extractLastSegment(role.RoleDefinitionID), extractLastSegment(roleDefinitionID)) {
+			continue
+		}
+		if strings.EqualFold(role.Scope, scope) {
+			return role, nil
+		}
+		elsewhere = append(elsewhere, role.Scope)
+	}
+	if len(elsewhere) > 0 {
+		return RoleAssignment{}, fmt.Errorf("role definition %s is eligible at %s, not at %s",
+			extractLastSegment(roleDefinitionID), strings.Join(elsewhere, ", "), scope)
+	}
+	return RoleAssignment{}, fmt.Errorf("no eligibility for role definition %s at %s", extractLastSegment(roleDefinitionID), scope)
 }
 
-func getEligibleRolesAtScope(scope string) ([]RoleAssignment, error) {
-	var url string
-	if scope == "" {
-		// Use the Azure management API for all eligible roles
-		url = "https://management.azure.com/providers/Microsoft.Authorization/roleEligibilityScheduleInstances?api-version=2020-10-01&$filter=asTarget()&$expand=roleDefinition,principal"
-	} else {
-		url = fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleEligibilityScheduleInstances?api-version=2020-10-01&$filter=asTarget()&$expand=roleDefinition,principal", scope)
+// dedupeEligibilities keeps one entry per logical eligibility. The same
+// eligibility is returned by several scope queries, sometimes as instances
+// with different IDs, so entries are keyed by their schedule, or by role,
+// principal and scope when the schedule isn't known.
+func dedupeEligibilities(roles []RoleAssignment) []RoleAssignment {
+	seen := make(map[string]bool)
+	unique := make([]RoleAssignment, 0, len(roles))
+	for _, role := range roles {
+		key := role.EligibilityScheduleID
+		if key == "" {
+			key = role.RoleDefinitionID + "|" + role.PrincipalID + "|" + role.Scope
+		}
+		key = strings.ToLower(key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, role)
 	}
-
-	return fetchEligibleRoles(url)
+	return unique
 }
 
-func fetchEligibleRoles(url string) ([]RoleAssignment, error) {
-	var allRoles []RoleAssignment
+// ScanFilter, when set, limits the per-subscription role scans to the
+// subscriptions it accepts. The tenant-wide listing is always included.
+var ScanFilter func(Subscription) bool
 
-	for url != "" {
-		output, err := runAzCommand("rest", "--method", "GET", "--url", url)
-		if err != nil {
-			return nil, err
+// scannedSubscriptions applies ScanFilter
+func scannedSubscriptions(subs []Subscription) []Subscription {
+	if ScanFilter == nil {
+		return subs
+	}
+	var scanned []Subscription
+	for _, sub := range subs {
+		if ScanFilter(sub) {
+			scanned = append(scanned, sub)
 		}
+	}
+	debugf("Scanning %d of %d subscriptions", len(scanned), len(subs))
+	return scanned
+}
+
+// eligibleRolesPath is the ARM path, without the host, that lists the
+// eligible roles that apply at scope, or across the tenant when scope is empty
+func eligibleRolesPath(scope string) string {
+	return scope + "/providers/Microsoft.Authorization/roleEligibilityScheduleInstances?api-version=2020-10-01&$filter=asTarget()&$expand=roleDefinition,principal"
+}
 
-		var response roleEligibilityScheduleInstancesResponse
-		if err := json.Unmarshal([]byte(output), &response); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
+// GetEligibleRolesAtScope fetches the eligible roles that apply at a single
+// scope, or across the tenant when scope is empty
+func GetEligibleRolesAtScope(scope string) ([]RoleAssignment, error) {
+	span := telemetry.Start("eligible roles at scope", telemetry.String("azure.scope", scope))
+	roles, err := eligibleListing.fetch("https://management.azure.com" + eligibleRolesPath(scope))
+	span.End(err)
+	return roles, err
+}
+
+// parseEligibleRoles reads a page of roleEligibilityScheduleInstances and
+// returns the link to the next page, if any
+func parseEligibleRoles(data []byte) ([]RoleAssignment, string, error) {
+	var response roleEligibilityScheduleInstancesResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var roles []RoleAssignment
+	for _, item := range response.Value {
+		role := RoleAssignment{
+			ID:                    item.ID,
+			EligibilityID:         item.ID,
+			EligibilityScheduleID: item.Properties.RoleEligibilityScheduleID,
+			RoleDefinitionID:      item.Properties.RoleDefinitionID,
+			Scope:                 item.Properties.Scope,
+			SubscriptionID:        SubscriptionID(item.Properties.Scope),
+			PrincipalID:           item.Properties.PrincipalID,
+			Status:                item.Properties.Status,
+			MemberType:            item.Properties.MemberType,
+			MaxDuration:           480, // Default 8 hours, can be overridden by policy
+			ExpandedProperties:    item.Properties.ExpandedProperties,
 		}
 
-		for _, item := range response.Value {
-			role := RoleAssignment{
-				ID:               item.ID,
-				EligibilityID:    item.ID,
-				RoleDefinitionID: item.Properties.RoleDefinitionID,
-				Scope:            item.Properties.Scope,
-				PrincipalID:      item.Properties.PrincipalID,
-				Status:           item.Properties.Status,
-				MemberType:       item.Properties.MemberType,
-				MaxDuration:      480, // Default 8 hours, can be overridden by policy
-				ExpandedProperties: item.Properties.ExpandedProperties,
+		// Parse start time
+		if item.Properties.StartDateTime != "" {
+			if t, err := time.Parse(time.RFC3339, item.Properties.StartDateTime); err == nil {
+				role.StartDateTime = t
 			}
+		}
 
-			// Parse start time
-			if item.Properties.StartDateTime != "" {
-				if t, err := time.Parse(time.RFC3339, item.Properties.StartDateTime); err == nil {
-					role.StartDateTime = t
-				}
+		// Parse end time
+		if item.Properties.EndDateTime != nil && *item.Properties.EndDateTime != "" {
+			if t, err := time.Parse(time.RFC3339, *item.Properties.EndDateTime); err == nil {
+				role.EndDateTime = &t
 			}
+		}
 
-			// Parse end time
-			if item.Properties.EndDateTime != nil && *item.Properties.EndDateTime != "" {
-				if t, err := time.Parse(time.RFC3339, *item.Properties.EndDateTime); err == nil {
-					role.EndDateTime = &t
-				}
+		// Extract role name and scope info from expanded properties
+		if role.ExpandedProperties != nil {
+			role.RoleName = role.ExpandedProperties.RoleDefinition.DisplayName
+			role.ScopeName = role.ExpandedProperties.Scope.DisplayName
+			role.ScopeType = role.ExpandedProperties.Scope.Type
+			if role.MemberType == "Group" {
+				role.GroupName = role.ExpandedProperties.Principal.DisplayName
 			}
+		} else {
+			// Fallback: extract role name from role definition ID
+			role.RoleName = extractLastSegment(role.RoleDefinitionID)
+			role.ScopeName = extractScopeName(role.Scope)
+			role.ScopeType = detectScopeType(role.Scope)
+		}
 
-			// Extract role name and scope info from expanded properties
-			if role.ExpandedProperties != nil {
-				role.RoleName = role.ExpandedProperties.RoleDefinition.DisplayName
-				role.ScopeName = role.ExpandedProperties.Scope.DisplayName
-				role.ScopeType = role.ExpandedProperties.Scope.Type
-			} else {
-				// Fallback: extract role name from role definition ID
-				role.RoleName = extractLastSegment(role.RoleDefinitionID)
-				role.ScopeName = extractScopeName(role.Scope)
-				role.ScopeType = detectScopeType(role.Scope)
-			}
+		roles = append(roles, role)
+	}
+	return roles, response.NextLink, nil
+}
 
-			allRoles = append(allRoles, role)
-		}
+// ActivationResult describes the submitted roleAssignmentScheduleRequest
+type ActivationResult struct {
+	ID         string // full resource ID of the request
+	RequestID  string
+	Status     string // e.g. Provisioned, Granted, PendingApproval
+	ScheduleID string // roleAssignmentSchedule created by the request, once known
+	Existing   bool   // an earlier request for the same activation was reused
+}
 
-		url = response.NextLink
+// scheduleRequestResponse is a single roleAssignmentScheduleRequest
+type scheduleRequestResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Status                         string `json:"status"`
+		TargetRoleAssignmentScheduleID string `json:"targetRoleAssignmentScheduleId"`
+	} `json:"properties"`
+}
+
+func (r scheduleRequestResponse) toResult() *ActivationResult {
+	return &ActivationResult{
+		ID:         r.ID,
+		RequestID:  r.Name,
+		Status:     r.Properties.Status,
+		ScheduleID: r.Properties.TargetRoleAssignmentScheduleID,
 	}
+}
 
-	return allRoles, nil
+// IsPendingApproval reports whether the request is waiting for an approver
+func (r *ActivationResult) IsPendingApproval() bool {
+	return strings.HasPrefix(r.Status, "PendingApproval") || r.Status == "PendingAdminDecision"
 }
 
-// ActivateRole activates an eligible PIM role
-func ActivateRole(req ActivationRequest) error {
-	requestID := uuid.New().String()
+// IsProvisioned reports whether the role assignment is in effect
+func (r *ActivationResult) IsProvisioned() bool {
+	return r.Status == "Provisioned"
+}
+
+// failedStatuses are terminal request statuses that mean the request did not go through
+var failedStatuses = map[string]bool{
+	"Failed":             true,
+	"Denied":             true,
+	"AdminDenied":        true,
+	"Canceled":           true,
+	"Revoked":            true,
+	"TimedOut":           true,
+	"ProvisioningFailed": true,
+	"Invalid":            true,
+}
+
+// IsFailed reports whether the request ended without granting the role
+func (r *ActivationResult) IsFailed() bool {
+	return failedStatuses[r.Status]
+}
+
+// ActivateRole activates an eligible PIM role. Activating the same role
+// again within RequestKeyWindow returns the earlier request while it is
+// pending, or if it was provisioned and the role is still active.
+func ActivateRole(req ActivationRequest) (*ActivationResult, error) {
+	requestID, reused := req.RequestID, req.RequestID != ""
+	switch {
+	case DryRun:
+		requestID = uuid.New().String()
+	case !reused:
+		requestID, reused = requestKeyFor(req.Role, req.Fresh)
+	}
+	if reused && !DryRun {
+		existing, err := GetScheduleRequest(req.Role.Scope, requestID)
+		found := err == nil
+		reuse := found && !existing.IsFailed()
+		if reuse && existing.IsProvisioned() {
+			// The role may have been deactivated since, e.g. in the portal
+			reuse, err = isActive(req.Role)
+			if err == nil && !reuse {
+				debugf("Request %s was provisioned, but the role is no longer active", requestID)
+			}
+		}
+		switch {
+		case reuse:
+			debugf("Reusing request %s with status %s", requestID, existing.Status)
+			existing.Existing = true
+			return existing, nil
+		case IsNetworkError(err):
+			return nil, err
+		case found:
+			requestID, _ = requestKeyFor(req.Role, true)
+		}
+		// Otherwise the request never reached Azure; submit it under the same ID
+	}
 
 	// Get the current user's principal ID - this is who is activating the role
 	// This may differ from the eligibility's principal ID if the role is assigned via a group
 	currentUserPrincipalID, err := GetCurrentUserPrincipalID()
 	if err != nil {
-		return fmt.Errorf("failed to get current user principal ID: %w", err)
+		return nil, fmt.Errorf("failed to get current user principal ID: %w", err)
 	}
 
 	debugf("Role ID: %s", req.Role.ID)
@@ -251,15 +516,15 @@ func ActivateRole(req ActivationRequest) error {
 	// The instance ID contains the schedule info we need
 	// Format: .../roleEligibilityScheduleInstances/{instanceName}
 	// We need to find the corresponding roleEligibilitySchedule
-	
+
 	// Get the eligibility schedule by querying for it
-	eligibilityScheduleID, err := getEligibilityScheduleID(req.Role.Scope, req.Role.RoleDefinitionID, req.Role.PrincipalID)
+	eligibilityScheduleID, err := getEligibilityScheduleID(req.Role)
 	if err != nil {
 		debugf("Could not find eligibility schedule, using instance ID as fallback: %v", err)
 		// Fallback: use the instance name
 		eligibilityScheduleID = extractLastSegment(req.Role.ID)
 	}
-	
+
 	debugf("Using eligibility schedule ID: %s", eligibilityScheduleID)
 
 	// Build the activation request body
@@ -293,101 +558,343 @@ func ActivateRole(req ActivationRequest) error {
 		requestBody["properties"].(map[string]interface{})["ticketInfo"] = ticketInfo
 	}
 
+	result, err := submitRequestWithID(req.Role.Scope, "roleAssignmentScheduleRequests", requestID, requestBody)
+	notifyScheduleRequest(RequestEvent{
+		RequestType:   "SelfActivate",
+		Role:          req.Role,
+		Duration:      req.Duration,
+		Justification: req.Justification,
+		TicketNumber:  req.TicketNumber,
+		TicketSystem:  req.TicketSystem,
+		Result:        result,
+		Err:           err,
+	})
+	return result, err
+}
+
+// DeactivateRole ends an active PIM role assignment before it expires
+func DeactivateRole(role RoleAssignment) (*ActivationResult, error) {
+	currentUserPrincipalID, err := GetCurrentUserPrincipalID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user principal ID: %w", err)
+	}
+
+	requestBody := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"principalId":      currentUserPrincipalID,
+			"roleDefinitionId": role.RoleDefinitionID,
+			"requestType":      "SelfDeactivate",
+		},
+	}
+
+	result, err := submitScheduleRequest(role.Scope, requestBody)
+	if err == nil && !DryRun {
+		forgetRequestKey(role)
+	}
+	notifyScheduleRequest(RequestEvent{RequestType: "SelfDeactivate", Role: role, Result: result, Err: err})
+	return result, err
+}
+
+// ExtendRole requests an extension of an active PIM role assignment.
+// Whether SelfExtend is accepted depends on the role management policy.
+func ExtendRole(role RoleAssignment, duration int, justification string) (*ActivationResult, error) {
+	currentUserPrincipalID, err := GetCurrentUserPrincipalID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user principal ID: %w", err)
+	}
+
+	requestBody := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"principalId":      currentUserPrincipalID,
+			"roleDefinitionId": role.RoleDefinitionID,
+			"requestType":      "SelfExtend",
+			"justification":    justification,
+			"scheduleInfo": map[string]interface{}{
+				"startDateTime": time.Now().UTC().Format(time.RFC3339),
+				"expiration": map[string]interface{}{
+					"type":     "AfterDuration",
+					"duration": fmt.Sprintf("PT%dM", duration),
+				},
+			},
+		},
+	}
+
+	result, err := submitScheduleRequest(role.Scope, requestBody)
+	notifyScheduleRequest(RequestEvent{
+		RequestType:   "SelfExtend",
+		Role:          role,
+		Duration:      duration,
+		Justification: justification,
+		Result:        result,
+		Err:           err,
+	})
+	return result, err
+}
+
+// RenewEligibility asks for an eligibility that is about to end to be
+// extended by days. Whether SelfRenew is accepted depends on the role
+// management policy; renewals usually wait for an administrator.
+func RenewEligibility(role RoleAssignment, days int, justification string) (*ActivationResult, error) {
+	currentUserPrincipalID, err := GetCurrentUserPrincipalID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user principal ID: %w", err)
+	}
+
+	requestBody := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"principalId":      currentUserPrincipalID,
+			"roleDefinitionId": role.RoleDefinitionID,
+			"requestType":      "SelfRenew",
+			"justification":    justification,
+			"scheduleInfo": map[string]interface{}{
+				"startDateTime": time.Now().UTC().Format(time.RFC3339),
+				"expiration": map[string]interface{}{
+					"type":     "AfterDuration",
+					"duration": fmt.Sprintf("P%dD", days),
+				},
+			},
+		},
+	}
+
+	result, err := submitRequest(role.Scope, "roleEligibilityScheduleRequests", requestBody)
+	notifyScheduleRequest(RequestEvent{
+		RequestType:   "SelfRenew",
+		Role:          role,
+		Eligibility:   true,
+		Duration:      days * 24 * 60,
+		Justification: justification,
+		Result:        result,
+		Err:           err,
+	})
+	return result, err
+}
+
+// submitScheduleRequest PUTs a roleAssignmentScheduleRequest at scope and returns its status
+func submitScheduleRequest(scope string, requestBody map[string]interface{}) (*ActivationResult, error) {
+	return submitRequest(scope, "roleAssignmentScheduleRequests", requestBody)
+}
+
+// submitRequest PUTs a schedule request of the given resource type at scope
+// and returns its status
+func submitRequest(scope, resourceType string, requestBody map[string]interface{}) (*ActivationResult, error) {
+	return submitRequestWithID(scope, resourceType, uuid.New().String(), requestBody)
+}
+
+// submitRequestWithID PUTs a schedule request under a chosen request ID, so
+// sending it again can't create a second request
+func submitRequestWithID(scope, resourceType, requestID string, requestBody map[string]interface{}) (*ActivationResult, error) {
 	bodyJSON, err := json.Marshal(requestBody)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request body: %w", err)
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
 	debugf("Request body: %s", string(bodyJSON))
 
-	// Build the URL for the activation request
-	url := fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleAssignmentScheduleRequests/%s?api-version=2020-10-01",
-		req.Role.Scope, requestID)
+	// Build the URL for the schedule request
+	id := fmt.Sprintf("%s/providers/Microsoft.Authorization/%s/%s", scope, resourceType, requestID)
+	url := fmt.Sprintf("https://management.azure.com%s?api-version=2020-10-01", id)
 
 	debugf("Request URL: %s", url)
 
-	output, err := runAzCommand("rest", "--method", "PUT", "--url", url, "--body", string(bodyJSON))
+	if DryRun {
+		printDryRun("PUT", url, requestBody)
+		return &ActivationResult{ID: id, RequestID: requestID, Status: dryRunStatus}, nil
+	}
+
+	props, _ := requestBody["properties"].(map[string]interface{})
+	span := telemetry.Start("schedule request",
+		telemetry.String("azure.scope", scope),
+		telemetry.String("pim.request_type", fmt.Sprint(props["requestType"])),
+		telemetry.String("pim.request_id", requestID))
+	output, err := rest("PUT", url, bodyJSON)
 	if err != nil {
-		return fmt.Errorf("activation request failed: %w", err)
+		err = fmt.Errorf("schedule request failed: %w", err)
+		span.End(err)
+		return nil, err
 	}
-	
+
 	debugf("Response: %s", output)
 
-	return nil
+	var response scheduleRequestResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		err = fmt.Errorf("failed to parse activation response: %w", err)
+		span.End(err)
+		return nil, err
+	}
+
+	result := response.toResult()
+	span.SetAttr(telemetry.String("pim.status", result.Status))
+	span.End(nil)
+	return result, nil
 }
 
-// getEligibilityScheduleID finds the roleEligibilitySchedule ID for linking
-func getEligibilityScheduleID(scope, roleDefinitionID, principalID string) (string, error) {
-	// Query roleEligibilitySchedules for this scope, role, and principal
+// GetScheduleRequest fetches the current status of a roleAssignmentScheduleRequest
+func GetScheduleRequest(scope, requestID string) (*ActivationResult, error) {
+	url := fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleAssignmentScheduleRequests/%s?api-version=2020-10-01",
+		scope, requestID)
+
+	output, err := rest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule request: %w", err)
+	}
+
+	var response scheduleRequestResponse
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule request: %w", err)
+	}
+
+	return response.toResult(), nil
+}
+
+// eligibilitySchedule is a roleEligibilitySchedule that may back an eligibility
+type eligibilitySchedule struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Scope       string `json:"scope"`
+		PrincipalID string `json:"principalId"`
+		MemberType  string `json:"memberType"`
+		Status      string `json:"status"`
+	} `json:"properties"`
+}
+
+// getEligibilityScheduleID finds the roleEligibilitySchedule to link an
+// activation to. The instance names its schedule when Azure includes it;
+// otherwise every schedule for the role and principal at the scope is
+// listed, since a user can be eligible directly and through groups at once,
+// and one is chosen with pickEligibilitySchedule.
+func getEligibilityScheduleID(role RoleAssignment) (string, error) {
+	if role.EligibilityScheduleID != "" {
+		return extractLastSegment(role.EligibilityScheduleID), nil
+	}
+
 	url := fmt.Sprintf(
 		"https://management.azure.com%s/providers/Microsoft.Authorization/roleEligibilitySchedules?api-version=2020-10-01&$filter=principalId eq '%s' and roleDefinitionId eq '%s'",
-		scope, principalID, roleDefinitionID,
+		role.Scope, role.PrincipalID, role.RoleDefinitionID,
 	)
 
 	debugf("Querying eligibility schedules: %s", url)
 
-	output, err := runAzCommand("rest", "--method", "GET", "--url", url)
+	output, err := rest("GET", url, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to query eligibility schedules: %w", err)
 	}
 
 	var response struct {
-		Value []struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		} `json:"value"`
+		Value []eligibilitySchedule `json:"value"`
 	}
-
 	if err := json.Unmarshal([]byte(output), &response); err != nil {
 		return "", fmt.Errorf("failed to parse eligibility schedules: %w", err)
 	}
 
-	if len(response.Value) == 0 {
+	schedule := pickEligibilitySchedule(response.Value, role)
+	if schedule == nil {
 		return "", fmt.Errorf("no eligibility schedule found")
 	}
+	debugf("Found %d eligibility schedule(s), using %s", len(response.Value), schedule.ID)
+	return schedule.Name, nil
+}
 
-	debugf("Found eligibility schedule: %s (name: %s)", response.Value[0].ID, response.Value[0].Name)
-	
-	// Return just the name (GUID) part
-	return response.Value[0].Name, nil
+// pickEligibilitySchedule chooses the schedule an activation links to. It
+// keeps schedules for the eligibility's principal and member type, then
+// prefers one granted at exactly the eligibility's scope, then one that is
+// Provisioned, and finally the lowest schedule name so the choice is the
+// same on every run.
+func pickEligibilitySchedule(schedules []eligibilitySchedule, role RoleAssignment) *eligibilitySchedule {
+	rank := func(s eligibilitySchedule) int {
+		r := 0
+		if !strings.EqualFold(s.Properties.Scope, role.Scope) {
+			r += 4
+		}
+		if role.MemberType != "" && !strings.EqualFold(s.Properties.MemberType, role.MemberType) {
+			r += 2
+		}
+		if s.Properties.Status != "" && s.Properties.Status != "Provisioned" {
+			r++
+		}
+		return r
+	}
+
+	var best *eligibilitySchedule
+	for i := range schedules {
+		s := &schedules[i]
+		if s.Properties.PrincipalID != "" && !strings.EqualFold(s.Properties.PrincipalID, role.PrincipalID) {
+			continue
+		}
+		if best == nil || rank(*s) < rank(*best) || (rank(*s) == rank(*best) && s.Name < best.Name) {
+			best = s
+		}
+	}
+	return best
 }
 
-// GetActiveRoleAssignments fetches currently active PIM role assignments
+// GetActiveRoleAssignments fetches currently active PIM role assignments.
+// Like the eligibility scan, it merges the tenant-wide listing with one per
+// subscription, since assignments at narrower scopes don't always appear in
+// the tenant-wide one, and drops the duplicates. The subscriptions are
+// queried as DiscoveryMode says; the aggregate mode, which only lists
+// eligibilities, queries them in parallel.
 func GetActiveRoleAssignments() ([]RoleAssignment, error) {
-	url := "https://management.azure.com/providers/Microsoft.Authorization/roleAssignmentScheduleInstances?api-version=2020-10-01&$filter=asTarget()&$expand=roleDefinition,principal"
+	mode := DiscoveryMode
+	if mode == DiscoverAggregate {
+		mode = DiscoverParallel
+	}
 
-	output, err := runAzCommand("rest", "--method", "GET", "--url", url)
+	span := telemetry.Start("scan active roles", telemetry.String("discovery.mode", mode))
+	roles, err := activeListing.fetch("https://management.azure.com" + activeRolesPath(""))
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
 
-	var response roleEligibilityScheduleInstancesResponse
-	if err := json.Unmarshal([]byte(output), &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	subscriptions, err := getSubscriptions()
+	if err != nil {
+		debugf("Could not list subscriptions, using the tenant-wide listing only: %v", err)
+	}
+	subscriptionNames := make(map[string]string, len(subscriptions))
+	for _, sub := range subscriptions {
+		subscriptionNames[strings.ToLower(sub.ID)] = sub.Name
+	}
+	for _, result := range querySubscriptions(mode, activeListing, scannedSubscriptions(subscriptions)) {
+		roles = append(roles, result.Roles...)
 	}
 
-	var roles []RoleAssignment
-	for _, item := range response.Value {
-		role := RoleAssignment{
-			ID:               item.ID,
-			RoleDefinitionID: item.Properties.RoleDefinitionID,
-			Scope:            item.Properties.Scope,
-			PrincipalID:      item.Properties.PrincipalID,
-			Status:           item.Properties.Status,
-			MemberType:       item.Properties.MemberType,
-			ExpandedProperties: item.Properties.ExpandedProperties,
+	seen := make(map[string]bool)
+	unique := make([]RoleAssignment, 0, len(roles))
+	for _, role := range roles {
+		key := strings.ToLower(role.ID)
+		if seen[key] {
+			continue
 		}
+		seen[key] = true
+		role.SubscriptionName = subscriptionNames[strings.ToLower(role.SubscriptionID)]
+		unique = append(unique, role)
+	}
 
-		if role.ExpandedProperties != nil {
-			role.RoleName = role.ExpandedProperties.RoleDefinition.DisplayName
-			role.ScopeName = role.ExpandedProperties.Scope.DisplayName
-			role.ScopeType = role.ExpandedProperties.Scope.Type
-		}
+	span.SetAttr(telemetry.Int("roles", len(unique)))
+	span.End(nil)
+	return unique, nil
+}
 
-		roles = append(roles, role)
-	}
+// activeRolesPath is the ARM path, without the host, that lists the active
+// roles that apply at scope, or across the tenant when scope is empty
+func activeRolesPath(scope string) string {
+	return scope + "/providers/Microsoft.Authorization/roleAssignmentScheduleInstances?api-version=2020-10-01&$filter=asTarget()&$expand=roleDefinition,principal"
+}
 
-	return roles, nil
+// isActive reports whether role is active at its scope
+func isActive(role RoleAssignment) (bool, error) {
+	active, err := activeListing.fetch("https://management.azure.com" + activeRolesPath(role.Scope))
+	if err != nil {
+		return false, err
+	}
+	for _, a := range active {
+		if strings.EqualFold(a.Scope, role.Scope) &&
+			strings.EqualFold(extractLastSegment(a.RoleDefinitionID), extractLastSegment(role.RoleDefinitionID)) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // extractLastSegment extracts the last segment from a path-like string
@@ -417,6 +924,17 @@ func extractScopeName(scope string) string {
 	return scope
 }
 
+// SubscriptionID extracts the subscription GUID from a scope path, if any
+func SubscriptionID(scope string) string {
+	parts := strings.Split(scope, "/")
+	for i, part := range parts {
+		if strings.EqualFold(part, "subscriptions") && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
 // detectScopeType detects the type of scope from the scope path
 func detectScopeType(scope string) string {
 	if strings.Contains(scope, "/resourceGroups/") {
@@ -429,4 +947,4 @@ func detectScopeType(scope string) string {
 		return "subscription"
 	}
 	return "unknown"
-}
\ No newline at end of file
+}
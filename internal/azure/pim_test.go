@@ -0,0 +1,129 @@
+package azure
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ica-js/hacktivator/internal/cassette"
+)
+
+// replay serves az and ARM from a cassette in testdata for the test
+func replay(t *testing.T, name string) {
+	t.Helper()
+	if err := cassette.Open(filepath.Join("testdata", name), true); err != nil {
+		t.Fatal(err)
+	}
+	invalidateToken()
+}
+
+func TestEligibleRolesFollowsNextLink(t *testing.T) {
+	replay(t, "eligible-roles.json")
+
+	roles, err := GetEligibleRolesAtScope("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 2 {
+		t.Fatalf("got %d roles from both pages, want 2: %+v", len(roles), roles)
+	}
+
+	owner, reader := roles[0], roles[1]
+	if owner.RoleName != "Owner" || owner.ScopeName != "Scope 5" || owner.ScopeType != "subscription" {
+		t.Errorf("first page: got %s on %s (%s)", owner.RoleName, owner.ScopeName, owner.ScopeType)
+	}
+	if owner.SubscriptionID != "00000000-0000-0000-0000-000000000003" {
+		t.Errorf("SubscriptionID = %q", owner.SubscriptionID)
+	}
+	if owner.EndDateTime == nil || owner.EndDateTime.Year() != 2027 {
+		t.Errorf("EndDateTime = %v, want 2027", owner.EndDateTime)
+	}
+	if owner.GroupName != "" {
+		t.Errorf("direct eligibility has GroupName %q", owner.GroupName)
+	}
+
+	if reader.RoleName != "Reader" || reader.ScopeType != "resourcegroup" {
+		t.Errorf("second page: got %s (%s)", reader.RoleName, reader.ScopeType)
+	}
+	if reader.GroupName != "Principal 10" {
+		t.Errorf("GroupName = %q, want the group granting the role", reader.GroupName)
+	}
+	if reader.EndDateTime != nil {
+		t.Errorf("permanent eligibility has EndDateTime %v", reader.EndDateTime)
+	}
+}
+
+func TestDedupeEligibilitiesAcrossScopes(t *testing.T) {
+	replay(t, "eligible-roles.json")
+
+	tenant, err := GetEligibleRolesAtScope("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := GetEligibleRolesAtScope("/subscriptions/00000000-0000-0000-0000-000000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant[0].ID == sub[0].ID {
+		t.Fatal("cassette should list the owner eligibility as two instances")
+	}
+
+	roles := dedupeEligibilities(append(tenant, sub...))
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.RoleName)
+	}
+	if len(roles) != 3 {
+		t.Fatalf("got %v, want Owner, Reader and Role 18 once each", names)
+	}
+	if roles[0].ID != tenant[0].ID {
+		t.Errorf("kept %s, want the first instance %s", roles[0].ID, tenant[0].ID)
+	}
+}
+
+func TestDedupeEligibilitiesWithoutSchedule(t *testing.T) {
+	role := RoleAssignment{RoleDefinitionID: "/providers/Microsoft.Authorization/roleDefinitions/r", PrincipalID: "p", Scope: "/subscriptions/s"}
+	other := role
+	other.Scope = "/subscriptions/S" // scopes compare case-insensitively
+	elsewhere := role
+	elsewhere.Scope = "/subscriptions/t"
+
+	if got := dedupeEligibilities([]RoleAssignment{role, other, elsewhere}); len(got) != 2 {
+		t.Errorf("got %d roles, want 2", len(got))
+	}
+}
+
+func TestParseEligibleRoles(t *testing.T) {
+	roles, next, err := parseEligibleRoles([]byte(`{
+		"value": [{
+			"id": "/subscriptions/s/providers/Microsoft.Authorization/roleEligibilityScheduleInstances/i",
+			"properties": {
+				"roleDefinitionId": "/subscriptions/s/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7",
+				"scope": "/subscriptions/s",
+				"memberType": "Direct",
+				"startDateTime": "2026-01-12T09:30:00Z"
+			}
+		}],
+		"nextLink": "https://management.azure.com/next"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "https://management.azure.com/next" {
+		t.Errorf("next = %q", next)
+	}
+	if len(roles) != 1 {
+		t.Fatalf("got %d roles, want 1", len(roles))
+	}
+	// Without expanded properties, names come from the IDs
+	role := roles[0]
+	if role.RoleName != "acdd72a7-3385-48ef-bd42-f606fba81ae7" || role.ScopeName != "s" || role.ScopeType != "subscription" {
+		t.Errorf("got %s on %s (%s)", role.RoleName, role.ScopeName, role.ScopeType)
+	}
+	if role.MaxDuration != 480 {
+		t.Errorf("MaxDuration = %d, want the 8 hour default", role.MaxDuration)
+	}
+
+	if _, _, err := parseEligibleRoles([]byte("<html>")); err == nil {
+		t.Error("expected an error for a response that isn't JSON")
+	}
+}
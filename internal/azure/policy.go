@@ -0,0 +1,232 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/telemetry"
+)
+
+// Approver is someone who can approve activations of a role
+type Approver struct {
+	ID    string // Entra object ID
+	Name  string
+	Email string // mail, or the UPN if the user has no mailbox address
+	Group string // the approver group the user is in, empty if named directly
+}
+
+// policyApprover is an approver as listed in a role management policy
+type policyApprover struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	UserType    string `json:"userType"` // User or Group
+	IsBackup    bool   `json:"isBackup"`
+}
+
+// GetApprovers returns the approvers the role management policy names for
+// activating a role at its scope, with groups expanded to their members. An
+// empty list means approval isn't required or falls to the tenant's
+// Privileged Role Administrators.
+func GetApprovers(role RoleAssignment) ([]Approver, error) {
+	_, approvers, err := GetApprovalPolicy(role)
+	return approvers, err
+}
+
+// GetApprovalPolicy reports whether activating a role at its scope needs
+// approval, and by whom, like GetApprovers
+func GetApprovalPolicy(role RoleAssignment) (required bool, approvers []Approver, err error) {
+	span := telemetry.Start("policy fetch", telemetry.String("azure.scope", role.Scope))
+	required, listed, err := policyApprovers(role)
+	span.End(err)
+	if err != nil {
+		return false, nil, err
+	}
+
+	seen := make(map[string]bool)
+	add := func(a Approver) {
+		if !seen[strings.ToLower(a.ID)] {
+			seen[strings.ToLower(a.ID)] = true
+			approvers = append(approvers, a)
+		}
+	}
+	for _, p := range listed {
+		if p.UserType == "Group" {
+			members, err := groupMembers(p.ID)
+			if err != nil {
+				debugf("Could not expand approver group %s: %v", p.Description, err)
+				continue
+			}
+			for _, m := range members {
+				m.Group = p.Description
+				add(m)
+			}
+			continue
+		}
+		user, err := graphUser(p.ID)
+		if err != nil {
+			debugf("Could not look up approver %s: %v", p.Description, err)
+			user = &Approver{ID: p.ID, Name: p.Description}
+		}
+		add(*user)
+	}
+	return required, approvers, nil
+}
+
+// policyRule is one effective rule of a role management policy. Only the
+// fields of the rule types we read are declared.
+type policyRule struct {
+	ID string `json:"id"`
+
+	// Expiration rules
+	IsExpirationRequired bool   `json:"isExpirationRequired"`
+	MaximumDuration      string `json:"maximumDuration"`
+
+	// Approval rules
+	Setting *struct {
+		IsApprovalRequired bool `json:"isApprovalRequired"`
+		ApprovalStages     []struct {
+			PrimaryApprovers []policyApprover `json:"primaryApprovers"`
+		} `json:"approvalStages"`
+	} `json:"setting"`
+}
+
+// Rule IDs for activations by the user, as opposed to admin assignments
+const (
+	approvalRuleID   = "Approval_EndUser_Assignment"
+	expirationRuleID = "Expiration_EndUser_Assignment"
+)
+
+// policyRules returns the effective rules of the policy assigned to a role at its scope
+func policyRules(role RoleAssignment) ([]policyRule, error) {
+	url := fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleManagementPolicyAssignments?api-version=2020-10-01&$filter=roleDefinitionId eq '%s'",
+		role.Scope, role.RoleDefinitionID)
+	output, err := rest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role management policy: %w", err)
+	}
+
+	var response struct {
+		Value []struct {
+			Properties struct {
+				EffectiveRules []policyRule `json:"effectiveRules"`
+			} `json:"properties"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse role management policy: %w", err)
+	}
+
+	var rules []policyRule
+	for _, assignment := range response.Value {
+		rules = append(rules, assignment.Properties.EffectiveRules...)
+	}
+	return rules, nil
+}
+
+// policyApprovers reads whether approval is required and the primary
+// approvers from the policy assigned to a role at its scope
+func policyApprovers(role RoleAssignment) (bool, []policyApprover, error) {
+	rules, err := policyRules(role)
+	if err != nil {
+		return false, nil, err
+	}
+
+	required := false
+	var approvers []policyApprover
+	for _, rule := range rules {
+		if rule.ID != approvalRuleID || rule.Setting == nil || !rule.Setting.IsApprovalRequired {
+			continue
+		}
+		required = true
+		for _, stage := range rule.Setting.ApprovalStages {
+			for _, a := range stage.PrimaryApprovers {
+				if !a.IsBackup {
+					approvers = append(approvers, a)
+				}
+			}
+		}
+	}
+	return required, approvers, nil
+}
+
+// GetMaxActivationDuration returns the longest activation in minutes the
+// role's policy allows, or 0 if it sets no limit
+func GetMaxActivationDuration(role RoleAssignment) (int, error) {
+	if minutes, ok := cachedPolicy(role); ok {
+		return minutes, nil
+	}
+
+	span := telemetry.Start("policy fetch", telemetry.String("azure.scope", role.Scope))
+	rules, err := policyRules(role)
+	span.End(err)
+	if err != nil {
+		return 0, err
+	}
+
+	minutes := 0
+	for _, rule := range rules {
+		if rule.ID == expirationRuleID && rule.IsExpirationRequired {
+			minutes = parseISODurationMinutes(rule.MaximumDuration)
+			break
+		}
+	}
+	rememberPolicy(role, minutes)
+	return minutes, nil
+}
+
+// graphUserResponse is the subset of a Graph user we need
+type graphUserResponse struct {
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	Mail              string `json:"mail"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+func (u graphUserResponse) toApprover() Approver {
+	email := u.Mail
+	if email == "" {
+		email = u.UserPrincipalName
+	}
+	return Approver{ID: u.ID, Name: u.DisplayName, Email: email}
+}
+
+const graphUserFields = "$select=id,displayName,mail,userPrincipalName"
+
+func graphUser(id string) (*Approver, error) {
+	output, err := azRest("GET", graphBaseURL+"/users/"+url.PathEscape(id)+"?"+graphUserFields, nil)
+	if err != nil {
+		return nil, err
+	}
+	var user graphUserResponse
+	if err := json.Unmarshal([]byte(output), &user); err != nil {
+		return nil, fmt.Errorf("failed to parse user: %w", err)
+	}
+	approver := user.toApprover()
+	return &approver, nil
+}
+
+// groupMembers lists the users in a group, including nested groups
+func groupMembers(id string) ([]Approver, error) {
+	var members []Approver
+	next := graphBaseURL + "/groups/" + url.PathEscape(id) + "/transitiveMembers/microsoft.graph.user?" + graphUserFields
+	for next != "" {
+		output, err := azRest("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Value    []graphUserResponse `json:"value"`
+			NextLink string              `json:"@odata.nextLink"`
+		}
+		if err := json.Unmarshal([]byte(output), &page); err != nil {
+			return nil, fmt.Errorf("failed to parse group members: %w", err)
+		}
+		for _, u := range page.Value {
+			members = append(members, u.toApprover())
+		}
+		next = page.NextLink
+	}
+	return members, nil
+}
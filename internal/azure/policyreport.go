@@ -0,0 +1,188 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/telemetry"
+)
+
+// PolicySettings summarizes the activation and eligibility rules of the role
+// management policy of one role at one scope
+type PolicySettings struct {
+	RoleDefinitionID string
+	RoleName         string
+	Scope            string
+	ScopeName        string
+	// MaxActivationMinutes is the longest activation allowed, 0 if unlimited
+	MaxActivationMinutes  int
+	ApprovalRequired      bool
+	Approvers             int // primary approvers configured, groups counted once
+	MFARequired           bool
+	JustificationRequired bool
+	TicketRequired        bool
+	// PermanentEligibility reports whether eligibilities may be assigned
+	// without an end date
+	PermanentEligibility bool
+}
+
+// Rule IDs read for the policy report, besides approvalRuleID and expirationRuleID
+const (
+	enablementRuleID            = "Enablement_EndUser_Assignment"
+	eligibilityExpirationRuleID = "Expiration_Admin_Eligibility"
+)
+
+// ListPolicySettings returns the policies of every role at scope and, for a
+// management group, at the management groups and subscriptions below it.
+// Scopes that can't be read are skipped.
+func ListPolicySettings(scope string) ([]PolicySettings, error) {
+	span := telemetry.Start("policy report", telemetry.String("azure.scope", scope))
+	scopes := []string{scope}
+	if strings.Contains(strings.ToLower(scope), "/managementgroups/") {
+		below, err := descendantScopes(scope)
+		if err != nil {
+			span.End(err)
+			return nil, err
+		}
+		scopes = append(scopes, below...)
+	}
+
+	var settings []PolicySettings
+	for i, s := range scopes {
+		found, err := policySettingsAt(s)
+		if err != nil {
+			if i == 0 {
+				span.End(err)
+				return nil, err
+			}
+			debugf("Could not list role management policies at %s: %v", s, err)
+			continue
+		}
+		settings = append(settings, found...)
+	}
+	span.SetAttr(telemetry.Int("policies", len(settings)))
+	span.End(nil)
+	return settings, nil
+}
+
+// descendantScopes lists the management groups and subscriptions below a
+// management group
+func descendantScopes(scope string) ([]string, error) {
+	var scopes []string
+	url := "https://management.azure.com" + scope + "/descendants?api-version=2020-05-01"
+	for url != "" {
+		output, err := rest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list scopes below %s: %w", scope, err)
+		}
+		var page struct {
+			Value []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+				Type string `json:"type"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := json.Unmarshal([]byte(output), &page); err != nil {
+			return nil, fmt.Errorf("failed to parse scopes below %s: %w", scope, err)
+		}
+		for _, d := range page.Value {
+			if strings.EqualFold(d.Type, "Microsoft.Management/managementGroups/subscriptions") ||
+				strings.EqualFold(d.Type, "/subscriptions") {
+				scopes = append(scopes, "/subscriptions/"+d.Name)
+				continue
+			}
+			scopes = append(scopes, d.ID)
+		}
+		url = page.NextLink
+	}
+	return scopes, nil
+}
+
+// policySettingsAt reads the policy of every role assigned at exactly scope
+func policySettingsAt(scope string) ([]PolicySettings, error) {
+	var settings []PolicySettings
+	url := fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleManagementPolicyAssignments?api-version=2020-10-01", scope)
+	for url != "" {
+		output, err := rest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role management policies: %w", err)
+		}
+		var page struct {
+			Value []struct {
+				Properties struct {
+					Scope                      string             `json:"scope"`
+					RoleDefinitionID           string             `json:"roleDefinitionId"`
+					EffectiveRules             []policyReportRule `json:"effectiveRules"`
+					PolicyAssignmentProperties struct {
+						RoleDefinition RoleDefinitionInfo `json:"roleDefinition"`
+						Scope          ScopeInfo          `json:"scope"`
+					} `json:"policyAssignmentProperties"`
+				} `json:"properties"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := json.Unmarshal([]byte(output), &page); err != nil {
+			return nil, fmt.Errorf("failed to parse role management policies: %w", err)
+		}
+		for _, v := range page.Value {
+			p := v.Properties
+			s := PolicySettings{
+				RoleDefinitionID: p.RoleDefinitionID,
+				RoleName:         p.PolicyAssignmentProperties.RoleDefinition.DisplayName,
+				Scope:            p.Scope,
+				ScopeName:        p.PolicyAssignmentProperties.Scope.DisplayName,
+			}
+			if s.Scope == "" {
+				s.Scope = scope
+			}
+			if s.RoleName == "" {
+				s.RoleName = extractLastSegment(p.RoleDefinitionID)
+			}
+			if s.ScopeName == "" {
+				s.ScopeName = extractScopeName(s.Scope)
+			}
+			for _, rule := range p.EffectiveRules {
+				rule.apply(&s)
+			}
+			settings = append(settings, s)
+		}
+		url = page.NextLink
+	}
+	return settings, nil
+}
+
+// policyReportRule is a policyRule with the enablement rule's fields
+type policyReportRule struct {
+	policyRule
+	EnabledRules []string `json:"enabledRules"`
+}
+
+func (rule policyReportRule) apply(s *PolicySettings) {
+	switch rule.ID {
+	case expirationRuleID:
+		if rule.IsExpirationRequired {
+			s.MaxActivationMinutes = parseISODurationMinutes(rule.MaximumDuration)
+		}
+	case eligibilityExpirationRuleID:
+		s.PermanentEligibility = !rule.IsExpirationRequired
+	case approvalRuleID:
+		if rule.Setting == nil || !rule.Setting.IsApprovalRequired {
+			return
+		}
+		s.ApprovalRequired = true
+		for _, stage := range rule.Setting.ApprovalStages {
+			for _, a := range stage.PrimaryApprovers {
+				if !a.IsBackup {
+					s.Approvers++
+				}
+			}
+		}
+	case enablementRuleID:
+		s.MFARequired = slices.Contains(rule.EnabledRules, "MultiFactorAuthentication")
+		s.JustificationRequired = slices.Contains(rule.EnabledRules, "Justification")
+		s.TicketRequired = slices.Contains(rule.EnabledRules, "Ticketing")
+	}
+}
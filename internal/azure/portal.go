@@ -0,0 +1,19 @@
+package azure
+
+import "strings"
+
+const portalBaseURL = "https://portal.azure.com"
+
+// PortalURL returns the Azure portal page for a role's scope. Management
+// groups and unknown scopes fall back to the PIM "My roles" blade.
+func PortalURL(role RoleAssignment) string {
+	switch detectScopeType(role.Scope) {
+	case "subscription", "resourceGroup":
+		return portalBaseURL + "/#@/resource" + strings.TrimRight(role.Scope, "/") + "/overview"
+	default:
+		return portalBaseURL + "/#view/Microsoft_Azure_PIMCommon/ActivationMenuBlade/~/azurerbac"
+	}
+}
+
+// ApprovalsURL is the PIM blade where approvers review pending Azure resource requests
+const ApprovalsURL = portalBaseURL + "/#view/Microsoft_Azure_PIMCommon/ApproveRequestMenuBlade/~/azurerbac"
@@ -0,0 +1,157 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Principal is a user, group or service principal in Entra ID
+type Principal struct {
+	ID   string
+	Name string
+	Type string // User, Group or ServicePrincipal
+	UPN  string // users only
+}
+
+// Label names the principal for display
+func (p Principal) Label() string {
+	if p.UPN != "" {
+		return fmt.Sprintf("%s (%s)", p.Name, p.UPN)
+	}
+	return p.Name
+}
+
+// graphPrincipal is the subset of a Graph directory object we need
+type graphPrincipal struct {
+	ODataType         string `json:"@odata.type"`
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+func (g graphPrincipal) toPrincipal(principalType string) Principal {
+	if principalType == "" {
+		switch g.ODataType {
+		case "#microsoft.graph.user":
+			principalType = "User"
+		case "#microsoft.graph.group":
+			principalType = "Group"
+		case "#microsoft.graph.servicePrincipal":
+			principalType = "ServicePrincipal"
+		}
+	}
+	return Principal{ID: g.ID, Name: g.DisplayName, Type: principalType, UPN: g.UserPrincipalName}
+}
+
+const graphPrincipalFields = "id,displayName,userPrincipalName"
+
+// ResolvePrincipal looks up a principal by object ID, UPN or exact group name
+func ResolvePrincipal(s string) (*Principal, error) {
+	if _, err := uuid.Parse(s); err == nil {
+		var obj graphPrincipal
+		if err := graphGet("/directoryObjects/"+s, &obj); err != nil {
+			return nil, fmt.Errorf("failed to look up principal %s: %w", s, err)
+		}
+		p := obj.toPrincipal("")
+		return &p, nil
+	}
+
+	if strings.Contains(s, "@") {
+		var user graphPrincipal
+		if err := graphGet("/users/"+url.PathEscape(s)+"?$select="+graphPrincipalFields, &user); err != nil {
+			return nil, fmt.Errorf("failed to look up user %s: %w", s, err)
+		}
+		p := user.toPrincipal("User")
+		return &p, nil
+	}
+
+	groups, err := graphList("/groups", "displayName eq '"+odataString(s)+"'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up group %s: %w", s, err)
+	}
+	switch len(groups) {
+	case 0:
+		return nil, fmt.Errorf("no user or group found for %q, use a UPN, object ID or exact group name", s)
+	case 1:
+		p := groups[0].toPrincipal("Group")
+		return &p, nil
+	}
+	return nil, fmt.Errorf("%d groups are named %q, use the object ID instead", len(groups), s)
+}
+
+// SearchPrincipals finds users and groups whose name or UPN starts with query
+func SearchPrincipals(query string) ([]Principal, error) {
+	q := odataString(query)
+	users, err := graphList("/users", fmt.Sprintf("startswith(displayName,'%s') or startswith(userPrincipalName,'%s')", q, q))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	groups, err := graphList("/groups", fmt.Sprintf("startswith(displayName,'%s')", q))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search groups: %w", err)
+	}
+
+	principals := make([]Principal, 0, len(users)+len(groups))
+	for _, u := range users {
+		principals = append(principals, u.toPrincipal("User"))
+	}
+	for _, g := range groups {
+		principals = append(principals, g.toPrincipal("Group"))
+	}
+	return principals, nil
+}
+
+// graphGet fetches a Graph resource into v
+func graphGet(path string, v any) error {
+	output, err := azRest("GET", graphBaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(output), v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// graphList returns the first page of a filtered Graph collection
+func graphList(path, filter string) ([]graphPrincipal, error) {
+	query := url.Values{"$filter": {filter}, "$select": {graphPrincipalFields}, "$top": {"25"}}
+	var page struct {
+		Value []graphPrincipal `json:"value"`
+	}
+	if err := graphGet(path+"?"+query.Encode(), &page); err != nil {
+		return nil, err
+	}
+	return page.Value, nil
+}
+
+// odataString escapes s for use inside a quoted OData literal
+func odataString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// resolveGroupNames looks up the names of groups granting roles where the
+// listing didn't include them. Lookups that fail leave the name empty.
+func resolveGroupNames(roles []RoleAssignment) {
+	names := make(map[string]string)
+	for i := range roles {
+		r := &roles[i]
+		if r.MemberType != "Group" || r.GroupName != "" || r.PrincipalID == "" {
+			continue
+		}
+		name, ok := names[r.PrincipalID]
+		if !ok {
+			var group graphPrincipal
+			if err := graphGet("/groups/"+url.PathEscape(r.PrincipalID)+"?$select=id,displayName", &group); err != nil {
+				debugf("Could not look up group %s: %v", r.PrincipalID, err)
+			}
+			name = group.DisplayName
+			names[r.PrincipalID] = name
+		}
+		r.GroupName = name
+	}
+}
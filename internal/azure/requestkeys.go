@@ -0,0 +1,104 @@
+package azure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestKeyWindow is how long activating the same role again reuses the
+// earlier request instead of submitting a new one, which would send the
+// approvers a second email
+const RequestKeyWindow = 10 * time.Minute
+
+// RequestKey is the ID an activation request was submitted under
+type RequestKey struct {
+	RequestID string    `json:"requestId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var (
+	requestKeysMu sync.Mutex
+	requestKeys   = make(map[string]RequestKey)
+	// requestKeysSaveMu keeps concurrent activations from saving at the same time
+	requestKeysSaveMu sync.Mutex
+
+	// OnRequestKeys is called with the current request keys whenever they
+	// change, e.g. to keep them between runs
+	OnRequestKeys func(map[string]RequestKey)
+)
+
+// SetRequestKeys provides request keys remembered from earlier runs, keyed
+// by role definition ID and scope
+func SetRequestKeys(keys map[string]RequestKey) {
+	requestKeysMu.Lock()
+	defer requestKeysMu.Unlock()
+	requestKeys = make(map[string]RequestKey, len(keys))
+	for k, v := range keys {
+		requestKeys[k] = v
+	}
+}
+
+// requestKeyFor returns the request ID to activate role under. reused
+// reports whether it was handed out within RequestKeyWindow, in which case
+// the request may already exist. fresh always starts a new request.
+func requestKeyFor(role RoleAssignment, fresh bool) (id string, reused bool) {
+	key := lookupKey(role.RoleDefinitionID, role.Scope)
+	requestKeysMu.Lock()
+	if k, ok := requestKeys[key]; ok && !fresh && time.Since(k.CreatedAt) < RequestKeyWindow {
+		requestKeysMu.Unlock()
+		return k.RequestID, true
+	}
+	id = uuid.New().String()
+	requestKeys[key] = RequestKey{RequestID: id, CreatedAt: time.Now()}
+	for k, v := range requestKeys {
+		if time.Since(v.CreatedAt) >= RequestKeyWindow {
+			delete(requestKeys, k)
+		}
+	}
+	requestKeysMu.Unlock()
+	requestKeysChanged()
+	return id, false
+}
+
+// forgetRequestKey makes the next activation of role a new request, e.g.
+// after it was deactivated
+func forgetRequestKey(role RoleAssignment) {
+	key := lookupKey(role.RoleDefinitionID, role.Scope)
+	requestKeysMu.Lock()
+	_, ok := requestKeys[key]
+	delete(requestKeys, key)
+	requestKeysMu.Unlock()
+	if ok {
+		requestKeysChanged()
+	}
+}
+
+// requestKeysChanged passes a snapshot of the request keys to OnRequestKeys
+func requestKeysChanged() {
+	if OnRequestKeys == nil {
+		return
+	}
+	requestKeysSaveMu.Lock()
+	defer requestKeysSaveMu.Unlock()
+	requestKeysMu.Lock()
+	snapshot := make(map[string]RequestKey, len(requestKeys))
+	for k, v := range requestKeys {
+		snapshot[k] = v
+	}
+	requestKeysMu.Unlock()
+	OnRequestKeys(snapshot)
+}
+
+// RecentRequestID returns the ID the last activation of role was submitted
+// under, if it was within RequestKeyWindow
+func RecentRequestID(role RoleAssignment) string {
+	requestKeysMu.Lock()
+	defer requestKeysMu.Unlock()
+	k, ok := requestKeys[lookupKey(role.RoleDefinitionID, role.Scope)]
+	if !ok || time.Since(k.CreatedAt) >= RequestKeyWindow {
+		return ""
+	}
+	return k.RequestID
+}
@@ -0,0 +1,274 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActivationDetails is the metadata of the request that activated a role
+type ActivationDetails struct {
+	RequestID     string
+	RequestType   string // SelfActivate, SelfExtend, ...
+	Justification string
+	TicketNumber  string
+	TicketSystem  string
+	RequestedAt   time.Time
+	Requester     string
+	Approver      string // empty unless the request went through approval
+}
+
+// ScheduleRequest is a roleAssignmentScheduleRequest made by the current user
+type ScheduleRequest struct {
+	ActivationDetails
+	RoleDefinitionID string
+	RoleName         string
+	Scope            string
+	ScopeName        string
+	PrincipalID      string // who the request is for
+	Status           string
+	StartDateTime    time.Time
+	Duration         int // requested duration in minutes, 0 if not time-bound
+	ApprovalID       string
+	TargetScheduleID string
+}
+
+// scheduleRequestsResponse is a page of roleAssignmentScheduleRequests
+type scheduleRequestsResponse struct {
+	Value []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			RoleDefinitionID               string `json:"roleDefinitionId"`
+			Scope                          string `json:"scope"`
+			PrincipalID                    string `json:"principalId"`
+			RequestType                    string `json:"requestType"`
+			Status                         string `json:"status"`
+			Justification                  string `json:"justification"`
+			CreatedOn                      string `json:"createdOn"`
+			ApprovalID                     string `json:"approvalId"`
+			TargetRoleAssignmentScheduleID string `json:"targetRoleAssignmentScheduleId"`
+			TicketInfo                     struct {
+				TicketNumber string `json:"ticketNumber"`
+				TicketSystem string `json:"ticketSystem"`
+			} `json:"ticketInfo"`
+			ScheduleInfo struct {
+				StartDateTime string `json:"startDateTime"`
+				Expiration    struct {
+					Duration string `json:"duration"`
+				} `json:"expiration"`
+			} `json:"scheduleInfo"`
+			ExpandedProperties *struct {
+				Principal struct {
+					DisplayName string `json:"displayName"`
+				} `json:"principal"`
+				RoleDefinition RoleDefinitionInfo `json:"roleDefinition"`
+				Scope          ScopeInfo          `json:"scope"`
+			} `json:"expandedProperties"`
+		} `json:"properties"`
+	} `json:"value"`
+	NextLink string `json:"nextLink,omitempty"`
+}
+
+// ListScheduleRequests fetches every role assignment request the current user
+// has made, newest first. Approvers are not resolved; see ResolveApprover.
+func ListScheduleRequests() ([]ScheduleRequest, error) {
+	return listScheduleRequests("https://management.azure.com/providers/Microsoft.Authorization/roleAssignmentScheduleRequests?api-version=2020-10-01&$filter=asRequestor()")
+}
+
+// ListScheduleRequestsAtScope fetches every principal's role assignment
+// requests at a scope and below, newest first. Reading them requires
+// permission to read role assignments at the scope.
+func ListScheduleRequestsAtScope(scope string) ([]ScheduleRequest, error) {
+	return listScheduleRequests(fmt.Sprintf("https://management.azure.com%s/providers/Microsoft.Authorization/roleAssignmentScheduleRequests?api-version=2020-10-01&$expand=principal",
+		scope))
+}
+
+func listScheduleRequests(url string) ([]ScheduleRequest, error) {
+	var requests []ScheduleRequest
+	for url != "" {
+		output, err := rest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list schedule requests: %w", err)
+		}
+
+		var response scheduleRequestsResponse
+		if err := json.Unmarshal([]byte(output), &response); err != nil {
+			return nil, fmt.Errorf("failed to parse schedule requests: %w", err)
+		}
+
+		for _, item := range response.Value {
+			p := item.Properties
+			r := ScheduleRequest{
+				ActivationDetails: ActivationDetails{
+					RequestID:     item.Name,
+					RequestType:   p.RequestType,
+					Justification: p.Justification,
+					TicketNumber:  p.TicketInfo.TicketNumber,
+					TicketSystem:  p.TicketInfo.TicketSystem,
+				},
+				RoleDefinitionID: p.RoleDefinitionID,
+				RoleName:         extractLastSegment(p.RoleDefinitionID),
+				Scope:            p.Scope,
+				ScopeName:        extractScopeName(p.Scope),
+				PrincipalID:      p.PrincipalID,
+				Status:           p.Status,
+				Duration:         parseISODurationMinutes(p.ScheduleInfo.Expiration.Duration),
+				ApprovalID:       p.ApprovalID,
+				TargetScheduleID: p.TargetRoleAssignmentScheduleID,
+			}
+			if t, err := time.Parse(time.RFC3339, p.CreatedOn); err == nil {
+				r.RequestedAt = t
+			}
+			if t, err := time.Parse(time.RFC3339, p.ScheduleInfo.StartDateTime); err == nil {
+				r.StartDateTime = t
+			}
+			if e := p.ExpandedProperties; e != nil {
+				r.Requester = e.Principal.DisplayName
+				if e.RoleDefinition.DisplayName != "" {
+					r.RoleName = e.RoleDefinition.DisplayName
+				}
+				if e.Scope.DisplayName != "" {
+					r.ScopeName = e.Scope.DisplayName
+				}
+			}
+			requests = append(requests, r)
+		}
+
+		url = response.NextLink
+	}
+
+	sort.SliceStable(requests, func(a, b int) bool {
+		return requests[a].RequestedAt.After(requests[b].RequestedAt)
+	})
+	return requests, nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseISODurationMinutes converts an ISO 8601 duration like PT8H or P1DT30M
+// into minutes, returning 0 if it can't be parsed
+func parseISODurationMinutes(s string) int {
+	m := isoDurationPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	n := func(i int) int {
+		v, _ := strconv.Atoi(m[i])
+		return v
+	}
+	return n(1)*24*60 + n(2)*60 + n(3) + n(4)/60
+}
+
+// Assignment returns the assignment the request asked for, starting when it
+// was scheduled to and lasting the requested duration. Status is the
+// request's status.
+func (r ScheduleRequest) Assignment() RoleAssignment {
+	a := RoleAssignment{
+		RoleDefinitionID: r.RoleDefinitionID,
+		RoleName:         r.RoleName,
+		Scope:            r.Scope,
+		ScopeName:        r.ScopeName,
+		ScopeType:        detectScopeType(r.Scope),
+		SubscriptionID:   SubscriptionID(r.Scope),
+		PrincipalID:      r.PrincipalID,
+		Status:           r.Status,
+		StartDateTime:    r.StartDateTime,
+		ScheduleID:       r.TargetScheduleID,
+	}
+	if a.StartDateTime.IsZero() {
+		a.StartDateTime = r.RequestedAt
+	}
+	if r.Duration > 0 {
+		end := a.StartDateTime.Add(time.Duration(r.Duration) * time.Minute)
+		a.EndDateTime = &end
+	}
+	details := r.ActivationDetails
+	a.Activation = &details
+	return a
+}
+
+// ResolveApprover fills in Approver for a request that went through approval
+func (r *ScheduleRequest) ResolveApprover() {
+	if r.ApprovalID != "" && r.Approver == "" {
+		r.Approver = getApprover(r.ApprovalID)
+	}
+}
+
+// AttachActivationDetails joins active assignments against the current user's
+// roleAssignmentScheduleRequests and fills in Activation on every role whose
+// request can be found. Roles without a matching request are left untouched.
+func AttachActivationDetails(roles []RoleAssignment) error {
+	requests, err := ListScheduleRequests()
+	if err != nil {
+		return err
+	}
+
+	for i := range roles {
+		role := &roles[i]
+
+		// Prefer requests targeting the role's schedule, then fall back to the
+		// same role and scope. Within either, take the most recent request,
+		// e.g. an extension over the original activation. Requests are sorted
+		// newest first, so the first match of each kind wins.
+		var best *ScheduleRequest
+		bestBySchedule := false
+		for j := range requests {
+			r := &requests[j]
+			if r.RequestType == "SelfDeactivate" || r.RequestType == "AdminRemove" {
+				continue
+			}
+			bySchedule := role.ScheduleID != "" && strings.EqualFold(path.Base(r.TargetScheduleID), path.Base(role.ScheduleID))
+			byRole := strings.EqualFold(path.Base(r.RoleDefinitionID), path.Base(role.RoleDefinitionID)) &&
+				strings.EqualFold(r.Scope, role.Scope)
+			if (bySchedule && !bestBySchedule) || (byRole && best == nil) {
+				best, bestBySchedule = r, bySchedule
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		best.ResolveApprover()
+		details := best.ActivationDetails
+		role.Activation = &details
+	}
+
+	return nil
+}
+
+// getApprover returns who reviewed a PIM approval, or an empty string if it
+// can't be determined
+func getApprover(approvalID string) string {
+	url := fmt.Sprintf("https://management.azure.com%s?api-version=2021-01-01-preview", approvalID)
+	output, err := rest("GET", url, nil)
+	if err != nil {
+		debugf("Could not fetch approval %s: %v", approvalID, err)
+		return ""
+	}
+
+	var response struct {
+		Properties struct {
+			Stages []struct {
+				ReviewedBy *struct {
+					DisplayName string `json:"displayName"`
+				} `json:"reviewedBy"`
+			} `json:"stages"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return ""
+	}
+
+	var approvers []string
+	for _, stage := range response.Properties.Stages {
+		if stage.ReviewedBy != nil && stage.ReviewedBy.DisplayName != "" {
+			approvers = append(approvers, stage.ReviewedBy.DisplayName)
+		}
+	}
+	return strings.Join(approvers, ", ")
+}
@@ -0,0 +1,165 @@
+package azure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/cassette"
+	"github.com/ica-js/hacktivator/internal/telemetry"
+	"github.com/ica-js/hacktivator/internal/tracelog"
+)
+
+// httpClient sends ARM requests directly instead of through `az rest`
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// rest calls an ARM endpoint and returns the response body. Requests go
+// straight to ARM with the cached access token; if no token can be obtained
+// the call falls back to `az rest`.
+func rest(method, url string, body []byte) (string, error) {
+	token, err := GetAccessToken()
+	if err != nil {
+		debugf("Falling back to az rest: %v", err)
+		return azRest(method, url, body)
+	}
+
+	output, status, err := armRequest(method, url, body, token)
+	if err == nil && status == http.StatusUnauthorized {
+		// The token may have been revoked or the session refreshed; retry once
+		invalidateToken()
+		recordRetry()
+		if token, err = GetAccessToken(); err != nil {
+			return "", err
+		}
+		output, status, err = armRequest(method, url, body, token)
+	}
+	if err != nil {
+		return "", err
+	}
+	if status < 200 || status > 299 {
+		err := fmt.Errorf("%s %s failed with status %d: %s", method, url, status, output)
+		if status == http.StatusTooManyRequests {
+			return "", &kindError{[]error{ErrThrottled}, err}
+		}
+		return "", classifyError(err)
+	}
+	return output, nil
+}
+
+// armRequest performs a single authenticated request
+func armRequest(method, url string, body []byte, token string) (string, int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	// Filters are written readably, e.g. $filter=principalId eq '...'; az rest
+	// encodes the spaces itself, so do the same here
+	req, err := http.NewRequest(method, strings.ReplaceAll(url, " ", "%20"), reader)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	span := telemetry.StartClient(method,
+		telemetry.String("http.request.method", method),
+		telemetry.String("url.full", req.URL.String()))
+	start := time.Now()
+	var resp *http.Response
+	if cassette.Replaying() {
+		resp, err = cassette.ReplayHTTP(req)
+	} else {
+		resp, err = httpClient.Do(req)
+	}
+	if err != nil {
+		cassette.RecordHTTP(method, req.URL.String(), body, 0, "", err)
+		err = fmt.Errorf("%s %s failed: %w", method, url, err)
+		recordRequest(0, len(body), time.Since(start))
+		tracelog.HTTP(method, req.URL.String(), body, 0, "", start, err)
+		span.End(err)
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	span.SetAttr(telemetry.Int("http.response.status_code", resp.StatusCode))
+	var spanErr error
+	if resp.StatusCode >= 400 {
+		spanErr = errors.New(resp.Status)
+	}
+	span.End(spanErr)
+
+	data, err := io.ReadAll(resp.Body)
+	recordRequest(resp.StatusCode, len(body)+len(data), time.Since(start))
+	cassette.RecordHTTP(method, req.URL.String(), body, resp.StatusCode, string(data), err)
+	if err != nil {
+		err = fmt.Errorf("failed to read response: %w", err)
+		tracelog.HTTP(method, req.URL.String(), body, resp.StatusCode, "", start, err)
+		return "", resp.StatusCode, err
+	}
+	tracelog.HTTP(method, req.URL.String(), body, resp.StatusCode, string(data), start, nil)
+	debugf("%s %s: %s", method, url, resp.Status)
+	return string(data), resp.StatusCode, nil
+}
+
+// azRest sends a request through `az rest`. The body is passed as @file
+// because cmd and PowerShell mangle the quotes in inline JSON on Windows.
+func azRest(method, url string, body []byte) (string, error) {
+	args := []string{"rest", "--method", method, "--url", url}
+	if body != nil {
+		f, err := os.CreateTemp("", "hacktivator-body-*.json")
+		if err != nil {
+			return "", fmt.Errorf("failed to create request body file: %w", err)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(body)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write request body file: %w", err)
+		}
+		args = append(args, "--body", "@"+f.Name())
+	}
+	return runAzCommand(args...)
+}
+
+// networkMarkers appear in az output when Azure can't be reached
+var networkMarkers = []string{
+	"Failed to establish a new connection",
+	"Max retries exceeded",
+	"NameResolutionError",
+	"Temporary failure in name resolution",
+	"getaddrinfo failed",
+	"Network is unreachable",
+	"Connection aborted",
+	"ConnectionResetError",
+}
+
+// IsNetworkError reports whether err means Azure couldn't be reached, as
+// opposed to Azure rejecting the request
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || (errors.As(err, &urlErr) && urlErr.Timeout()) {
+		return true
+	}
+	msg := err.Error()
+	for _, marker := range networkMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package azure
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OnSessionExpired, when set, is called when an az command fails because the
+// session expired. It returns whether the user signed in again, in which case
+// the command is retried.
+var OnSessionExpired func() bool
+
+var (
+	// reauthMu lets one caller at a time sign in again; concurrent requests
+	// that failed meanwhile retry with the new session
+	reauthMu      sync.Mutex
+	reauthLastRun time.Time
+)
+
+// reauthenticate handles a command that failed with an expired session at
+// failedAt and reports whether to retry it
+func reauthenticate(failedAt time.Time) bool {
+	reauthMu.Lock()
+	defer reauthMu.Unlock()
+	if reauthLastRun.After(failedAt) {
+		return true
+	}
+	if OnSessionExpired == nil || !OnSessionExpired() {
+		return false
+	}
+	reauthLastRun = time.Now()
+	return true
+}
+
+// Login runs `az login` attached to the terminal. With deviceCode, az prints
+// a code to enter at microsoft.com/devicelogin and waits for the sign-in
+// instead of opening a browser.
+func Login(tenant string, deviceCode bool) error {
+	return interactiveLogin(loginArgs(tenant, deviceCode))
+}
+
+func loginArgs(tenant string, deviceCode bool) []string {
+	args := []string{"login"}
+	if tenant != "" {
+		args = append(args, "--tenant", tenant)
+	}
+	if deviceCode {
+		args = append(args, "--use-device-code")
+	}
+	return args
+}
+
+// interactiveLogin runs az with args, letting the user complete the sign-in
+// on the terminal. Its output goes to stderr to keep stdout for results.
+func interactiveLogin(args []string) error {
+	debugf("Running az %s", strings.Join(args, " "))
+	cmd := exec.Command("az", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("az login failed: %w", err)
+	}
+	return nil
+}
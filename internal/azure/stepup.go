@@ -0,0 +1,29 @@
+package azure
+
+import "strings"
+
+// armScope is the scope requested when signing in again for ARM
+const armScope = "https://management.core.windows.net//.default"
+
+// stepUpArgs are the az arguments that sign in again for ARM, prompting for
+// MFA when the tenant requires it
+func stepUpArgs(tenant string, deviceCode bool) []string {
+	return append(loginArgs(tenant, deviceCode), "--scope", armScope)
+}
+
+// StepUpCommand returns the az command that signs in again with MFA, for
+// users who can't be prompted
+func StepUpCommand(tenant string, deviceCode bool) string {
+	return "az " + strings.Join(stepUpArgs(tenant, deviceCode), " ")
+}
+
+// StepUpLogin runs `az login` attached to the terminal so the user can
+// complete MFA, then drops the cached token so the next request uses the new
+// session. deviceCode is as for Login.
+func StepUpLogin(tenant string, deviceCode bool) error {
+	if err := interactiveLogin(stepUpArgs(tenant, deviceCode)); err != nil {
+		return err
+	}
+	invalidateToken()
+	return nil
+}
@@ -0,0 +1,124 @@
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SubscriptionsTTL is how long a subscription list is used before it is
+// refreshed in the background. Subscriptions are rarely added or removed.
+const SubscriptionsTTL = 24 * time.Hour
+
+// Subscription represents an Azure subscription
+type Subscription struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+var (
+	subsMu         sync.Mutex
+	subsList       []Subscription
+	subsListedAt   time.Time
+	subsRefreshing bool
+
+	// OnSubscriptionsListed is called with every list fetched from the
+	// Azure CLI, e.g. to cache it between runs
+	OnSubscriptionsListed func(subs []Subscription)
+)
+
+// SetSubscriptions provides a subscription list fetched earlier, so scans
+// don't have to run `az account list`. Once the list is older than a day it
+// is refreshed in the background while the old one is still used.
+func SetSubscriptions(subs []Subscription, listedAt time.Time) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	subsList, subsListedAt = subs, listedAt
+}
+
+// ListSubscriptions returns every subscription the signed-in account can see
+func ListSubscriptions() ([]Subscription, error) {
+	return getSubscriptions()
+}
+
+func getSubscriptions() ([]Subscription, error) {
+	subsMu.Lock()
+	if subsList == nil {
+		subsMu.Unlock()
+		return FetchSubscriptions()
+	}
+	subs := append([]Subscription(nil), subsList...)
+	if time.Since(subsListedAt) >= SubscriptionsTTL && !subsRefreshing {
+		subsRefreshing = true
+		go func() {
+			if _, err := FetchSubscriptions(); err != nil {
+				debugf("Could not refresh subscriptions: %v", err)
+			}
+			subsMu.Lock()
+			subsRefreshing = false
+			subsMu.Unlock()
+		}()
+	}
+	subsMu.Unlock()
+	return subs, nil
+}
+
+// FetchSubscriptions lists the subscriptions with the Azure CLI, ignoring
+// any list given to SetSubscriptions, and keeps the result for later scans
+func FetchSubscriptions() ([]Subscription, error) {
+	output, err := runAzCommand("account", "list", "--query", "[].{id:id, name:name}", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	subs := []Subscription{}
+	if err := json.Unmarshal([]byte(output), &subs); err != nil {
+		return nil, fmt.Errorf("failed to parse subscriptions: %w", err)
+	}
+
+	SetSubscriptions(subs, time.Now())
+	if OnSubscriptionsListed != nil {
+		OnSubscriptionsListed(subs)
+	}
+	return subs, nil
+}
+
+// DefaultTenant returns the tenant of the Azure CLI's default subscription.
+// It is read from the CLI's profile rather than by running az, so it is
+// cheap enough to key caches on.
+func DefaultTenant() (string, error) {
+	dir := os.Getenv("AZURE_CONFIG_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".azure")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "azureProfile.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Azure CLI profile: %w", err)
+	}
+	var profile struct {
+		Subscriptions []struct {
+			TenantID  string `json:"tenantId"`
+			IsDefault bool   `json:"isDefault"`
+		} `json:"subscriptions"`
+	}
+	// The CLI writes the profile with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return "", fmt.Errorf("failed to parse Azure CLI profile: %w", err)
+	}
+	for _, sub := range profile.Subscriptions {
+		if sub.IsDefault {
+			return sub.TenantID, nil
+		}
+	}
+	return "", fmt.Errorf("no default subscription in the Azure CLI profile")
+}
@@ -0,0 +1,120 @@
+package azure
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// armResource is the audience of access tokens for Azure Resource Manager
+const armResource = "https://management.azure.com/"
+
+// tokenRefreshMargin renews a cached token this long before it expires so it
+// can't lapse in the middle of a request
+const tokenRefreshMargin = 5 * time.Minute
+
+type accessToken struct {
+	value     string
+	expiresOn time.Time
+}
+
+var (
+	tokenMu     sync.Mutex
+	cachedToken *accessToken
+)
+
+// GetAccessToken returns an ARM access token from the Azure CLI. The token is
+// fetched once per process and reused until it is about to expire.
+func GetAccessToken() (string, error) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if cachedToken != nil && time.Until(cachedToken.expiresOn) > tokenRefreshMargin {
+		return cachedToken.value, nil
+	}
+
+	token, err := fetchAccessToken(armResource)
+	if err != nil {
+		return "", err
+	}
+	cachedToken = token
+	return token.value, nil
+}
+
+// fetchAccessToken gets a token for resource from the Azure CLI, uncached
+func fetchAccessToken(resource string) (*accessToken, error) {
+	output, err := runAzCommand("account", "get-access-token", "--resource", resource, "--output", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var response struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresOn   string `json:"expiresOn"`  // local time, e.g. 2024-01-31 12:00:00.000000
+		ExpiresUnix int64  `json:"expires_on"` // added in az 2.54
+	}
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return nil, fmt.Errorf("failed to parse access token: %w", err)
+	}
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("az returned an empty access token")
+	}
+
+	token := &accessToken{value: response.AccessToken}
+	switch {
+	case response.ExpiresUnix > 0:
+		token.expiresOn = time.Unix(response.ExpiresUnix, 0)
+	default:
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", response.ExpiresOn, time.Local); err == nil {
+			token.expiresOn = t
+		} else if secs, err := strconv.ParseInt(response.ExpiresOn, 10, 64); err == nil {
+			token.expiresOn = time.Unix(secs, 0)
+		} else {
+			// Unknown format; assume the usual minimum lifetime
+			token.expiresOn = time.Now().Add(tokenRefreshMargin + 10*time.Minute)
+		}
+	}
+	debugf("Fetched access token for %s, expires %s", resource, token.expiresOn.Format(time.RFC3339))
+	return token, nil
+}
+
+// invalidateToken drops the cached token, e.g. after ARM rejected it
+func invalidateToken() {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	cachedToken = nil
+}
+
+// tokenClaims are the access token claims hacktivator reads
+type tokenClaims struct {
+	ObjectID string `json:"oid"`
+	TenantID string `json:"tid"`
+	IDType   string `json:"idtyp"` // "app" for service principals, "user" for users
+}
+
+// accessTokenClaims decodes the claims of the ARM access token. The token
+// isn't verified; it came from the Azure CLI and is only read for
+// information Azure checks itself.
+func accessTokenClaims() (*tokenClaims, error) {
+	token, err := GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode access token: %w", err)
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse access token claims: %w", err)
+	}
+	return &claims, nil
+}
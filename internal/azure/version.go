@@ -0,0 +1,63 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinCLIVersion is the oldest supported Azure CLI. 2.37.0 moved `az ad` to
+// Microsoft Graph, whose output has the id field used to find the signed-in
+// user's object ID.
+const MinCLIVersion = "2.37.0"
+
+// cliVersion is the detected Azure CLI version, empty if unknown
+var cliVersion string
+
+// GetCLIVersion asks the Azure CLI for its version, e.g. 2.61.0
+func GetCLIVersion() (string, error) {
+	output, err := runAzCommand("version", "--output", "json")
+	if err != nil {
+		return "", fmt.Errorf("failed to get az version: %w", err)
+	}
+
+	var response struct {
+		CLI string `json:"azure-cli"`
+	}
+	if err := json.Unmarshal([]byte(output), &response); err != nil {
+		return "", fmt.Errorf("failed to parse az version: %w", err)
+	}
+	if response.CLI == "" {
+		return "", fmt.Errorf("az version did not report azure-cli")
+	}
+	return response.CLI, nil
+}
+
+// SetCLIVersion records the detected version so requests can adapt to it
+func SetCLIVersion(v string) {
+	cliVersion = v
+	debugf("Azure CLI version %s", v)
+}
+
+// CompareVersions compares dotted versions numerically, returning -1, 0 or 1.
+// Missing or non-numeric parts count as 0, so 2.61 equals 2.61.0.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
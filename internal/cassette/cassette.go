@@ -0,0 +1,422 @@
+// Package cassette records the az commands and REST requests of a run with
+// their responses, and replays them later instead of calling Azure, so
+// parsing, pagination and deduplication can be exercised in CI against data
+// from a real tenant. Recordings are sanitized as they are made: tokens and
+// secrets are redacted, and GUIDs, email addresses and the names of people,
+// groups, scopes and custom roles are replaced with stable fakes, so the same
+// ID gets the same fake throughout a cassette.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/tracelog"
+)
+
+// Interaction is one recorded az command or HTTP request
+type Interaction struct {
+	Type     string   `json:"type"` // az or http
+	Args     []string `json:"args,omitempty"`
+	Method   string   `json:"method,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Request  string   `json:"request,omitempty"` // request body, for reference only
+	Status   int      `json:"status,omitempty"`
+	ExitCode int      `json:"exitCode,omitempty"`
+	Response string   `json:"response,omitempty"` // response body, or stdout of az
+	Stderr   string   `json:"stderr,omitempty"`
+	Error    string   `json:"error,omitempty"` // the request got no response
+}
+
+// Cassette is the file format, a list of interactions in the order they happened
+type Cassette struct {
+	RecordedAt   time.Time     `json:"recordedAt"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+var (
+	mu        sync.Mutex
+	recording string // path to save to on Close
+	replaying bool
+	tape      Cassette
+	played    map[string]int // next interaction to replay, by match key
+	fakes     map[string]string
+)
+
+// Open starts recording to path, or replaying from it with replay set.
+// Recordings are saved by Close.
+func Open(path string, replay bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !replay {
+		recording = path
+		tape = Cassette{RecordedAt: time.Now().UTC()}
+		fakes = make(map[string]string)
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &tape); err != nil {
+		return fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	replaying = true
+	played = make(map[string]int)
+	return nil
+}
+
+// Close saves the recording, if one is being made
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if recording == "" {
+		return nil
+	}
+	path := recording
+	recording = ""
+	data, err := json.MarshalIndent(tape, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return nil
+}
+
+// Replaying reports whether responses come from a cassette instead of Azure
+func Replaying() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return replaying
+}
+
+// exitError is the error of a replayed az command that failed
+type exitError struct{ code int }
+
+func (e *exitError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// ExitCode matches exec.ExitError
+func (e *exitError) ExitCode() int { return e.code }
+
+// ReplayAz writes the recorded output of `az args` to stdout and stderr. The
+// error reports a non-zero exit code like exec.ExitError, or that the
+// command wasn't recorded.
+func ReplayAz(args []string, stdout, stderr io.Writer) error {
+	it, ok := next(azKey(args, false), azKey(args, true))
+	if !ok {
+		return fmt.Errorf("no recorded response for az %s", strings.Join(args, " "))
+	}
+	io.WriteString(stdout, it.Response)
+	io.WriteString(stderr, it.Stderr)
+	if it.ExitCode != 0 {
+		return &exitError{it.ExitCode}
+	}
+	return nil
+}
+
+// ReplayHTTP returns the recorded response to req
+func ReplayHTTP(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	it, ok := next(httpKey(req.Method, url, false), httpKey(req.Method, url, true))
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, url)
+	}
+	if it.Error != "" {
+		return nil, fmt.Errorf("%s", it.Error)
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", it.Status, http.StatusText(it.Status)),
+		StatusCode: it.Status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(it.Response)),
+		Request:    req,
+	}, nil
+}
+
+// next finds the next interaction recorded for key, or else for the looser
+// key. Requests repeated more often than recorded, like polling, get the
+// last recorded response again.
+func next(key, loose string) (Interaction, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, k := range []string{key, loose} {
+		var matches []Interaction
+		for _, it := range tape.Interactions {
+			if interactionKey(it, k == loose) == k {
+				matches = append(matches, it)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		i := min(played[k], len(matches)-1)
+		played[k]++
+		return matches[i], true
+	}
+	return Interaction{}, false
+}
+
+func interactionKey(it Interaction, loose bool) string {
+	if it.Type == "az" {
+		return azKey(it.Args, loose)
+	}
+	return httpKey(it.Method, it.URL, loose)
+}
+
+var (
+	guid      = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	email     = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	timestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?`)
+	// groupName is a resource group or management group named in a scope
+	groupName = regexp.MustCompile(`(?i)(/resourceGroups/|/managementGroups/)([^/?&#"\s\\]+)`)
+)
+
+// azKey matches az commands by their arguments. Request body files have a
+// new name on every run; the loose key also ignores GUIDs and times, which
+// differ for requests made under new IDs, like activations.
+func azKey(args []string, loose bool) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "@") {
+			arg = "@file"
+		}
+		parts[i] = arg
+	}
+	key := "az " + strings.Join(parts, " ")
+	if loose {
+		key = loosen(key)
+	}
+	return key
+}
+
+func httpKey(method, url string, loose bool) string {
+	key := method + " " + url
+	if loose {
+		key = loosen(key)
+	}
+	return key
+}
+
+func loosen(key string) string {
+	key = guid.ReplaceAllString(key, "{id}")
+	return timestamp.ReplaceAllString(key, "{time}")
+}
+
+// RecordAz adds an az command to the recording, if one is being made. err is
+// the error of running it, if any.
+func RecordAz(args []string, stdout, stderr string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if recording == "" {
+		return
+	}
+	it := Interaction{Type: "az", Stderr: sanitize(stderr)}
+	if len(args) > 0 && args[0] == "account" && len(args) > 1 && args[1] == "get-access-token" {
+		it.Response = fakeAccessToken(stdout)
+	} else {
+		it.Response = sanitize(stdout)
+	}
+	for _, arg := range tracelog.RedactArgs(args) {
+		it.Args = append(it.Args, sanitize(arg))
+	}
+	var exit interface{ ExitCode() int }
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		it.ExitCode = exit.ExitCode()
+	default:
+		it.ExitCode = -1
+	}
+	tape.Interactions = append(tape.Interactions, it)
+}
+
+// RecordHTTP adds a request and its response to the recording, if one is
+// being made. err is set when the request got no response.
+func RecordHTTP(method, url string, body []byte, status int, response string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if recording == "" {
+		return
+	}
+	it := Interaction{
+		Type:     "http",
+		Method:   method,
+		URL:      sanitize(url),
+		Request:  sanitize(string(body)),
+		Status:   status,
+		Response: sanitize(response),
+	}
+	if err != nil {
+		it.Error = sanitize(err.Error())
+	}
+	tape.Interactions = append(tape.Interactions, it)
+}
+
+// sanitize redacts credentials and replaces GUIDs, email addresses and
+// display names with fakes. Must be called with mu held.
+func sanitize(s string) string {
+	s = tracelog.Redact(s)
+	s = scrubNames(s)
+	s = groupName.ReplaceAllStringFunc(s, func(m string) string {
+		parts := groupName.FindStringSubmatch(m)
+		if guid.MatchString(parts[2]) {
+			return m
+		}
+		format := "resource-group-%d"
+		if strings.EqualFold(parts[1], "/managementGroups/") {
+			format = "management-group-%d"
+		}
+		return parts[1] + fake("group:"+strings.ToLower(parts[2]), format)
+	})
+	s = guid.ReplaceAllStringFunc(s, fakeGUID)
+	return email.ReplaceAllStringFunc(s, func(addr string) string {
+		return fake("mail:"+strings.ToLower(addr), "user%d@example.com")
+	})
+}
+
+// fake returns the stand-in for the value under key, the same one every
+// time. New ones are made from format and a number.
+func fake(key, format string) string {
+	if f, ok := fakes[key]; ok {
+		return f
+	}
+	f := fmt.Sprintf(format, len(fakes)+1)
+	fakes[key] = f
+	return f
+}
+
+// fakeGUID returns the stand-in for id
+func fakeGUID(id string) string {
+	return fake(strings.ToLower(id), "00000000-0000-0000-0000-%012d")
+}
+
+// nameLabels names the fakes for display names by the object they describe
+var nameLabels = map[string]string{
+	"principal":      "Principal",
+	"subject":        "Principal",
+	"scope":          "Scope",
+	"resource":       "Scope",
+	"roleDefinition": "Role",
+}
+
+// scrubNames replaces the display names in a JSON document, e.g. of
+// principals, scopes and custom roles, and the names of subscriptions listed
+// by `az account list`. The names of built-in roles are kept: they are the
+// same in every tenant, and hacktivator treats some of them specially.
+// Anything that isn't a JSON object or array is returned as it is.
+func scrubNames(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return s
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || !scrubValue(doc, "") {
+		return s
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return s
+	}
+	return out.String()
+}
+
+// scrubValue replaces the names in v, found under key, and reports whether it
+// changed anything
+func scrubValue(v any, key string) bool {
+	changed := false
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			changed = scrubValue(item, key) || changed
+		}
+	case map[string]any:
+		// In order, so fakes are numbered the same on every recording
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			changed = scrubValue(v[k], k) || changed
+		}
+		if name, ok := v["displayName"].(string); ok && name != "" && v["type"] != "BuiltInRole" {
+			label, ok := nameLabels[key]
+			if !ok {
+				label = "Name"
+			}
+			v["displayName"] = fake("name:"+label+":"+name, label+" %d")
+			changed = true
+		}
+		if _, ok := v["isDefault"]; ok {
+			if name, ok := v["name"].(string); ok && name != "" {
+				v["name"] = fake("name:Subscription:"+name, "Subscription %d")
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// fakeAccessToken rewrites `az account get-access-token` output with an
+// unsigned token carrying only the sanitized oid, tid and idtyp claims, which
+// hacktivator reads, and an expiry far in the future so replays don't ask
+// for a new one.
+func fakeAccessToken(output string) string {
+	var token map[string]any
+	if err := json.Unmarshal([]byte(output), &token); err != nil {
+		return sanitize(output)
+	}
+	claims := map[string]string{}
+	if real, ok := token["accessToken"].(string); ok {
+		if parts := strings.Split(real, "."); len(parts) == 3 {
+			if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+				var all map[string]any
+				if json.Unmarshal(payload, &all) == nil {
+					for _, name := range []string{"oid", "tid", "idtyp"} {
+						if v, ok := all[name].(string); ok {
+							claims[name] = guid.ReplaceAllStringFunc(v, fakeGUID)
+						}
+					}
+				}
+			}
+		}
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	payload, _ := json.Marshal(claims)
+	token["accessToken"] = header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	token["expiresOn"] = "2099-12-31 00:00:00.000000"
+	token["expires_on"] = 4102358400
+	for k, v := range token {
+		if s, ok := v.(string); ok && k != "accessToken" {
+			token[k] = guid.ReplaceAllStringFunc(s, fakeGUID)
+		}
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(token); err != nil {
+		return sanitize(output)
+	}
+	return out.String()
+}
@@ -0,0 +1,103 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRecordingIsSanitized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := Open(path, false); err != nil {
+		t.Fatal(err)
+	}
+	url := "https://management.azure.com/subscriptions/b3e1f6a2-5c8d-4e9f-a7b1-2d3c4e5f6a7b/resourceGroups/Payments-Prod/providers/Microsoft.Authorization/roleEligibilityScheduleInstances?api-version=2020-10-01"
+	RecordHTTP("GET", url, nil, 200, `{"value": [{"properties": {
+		"scope": "/subscriptions/b3e1f6a2-5c8d-4e9f-a7b1-2d3c4e5f6a7b/resourceGroups/payments-prod",
+		"expandedProperties": {
+			"principal": {"displayName": "Jane Doe", "email": "jane.doe@contoso.com", "type": "User"},
+			"roleDefinition": {"displayName": "Contoso Network Operator", "type": "CustomRole"},
+			"scope": {"displayName": "payments-prod", "type": "resourcegroup"}
+		}
+	}}, {"properties": {
+		"expandedProperties": {
+			"principal": {"displayName": "Jane Doe", "type": "User"},
+			"roleDefinition": {"displayName": "Owner", "type": "BuiltInRole"}
+		}
+	}}]}`, nil)
+	RecordAz([]string{"account", "list", "--output", "json"},
+		`[{"id": "b3e1f6a2-5c8d-4e9f-a7b1-2d3c4e5f6a7b", "isDefault": true, "name": "Contoso Production", "user": {"name": "jane.doe@contoso.com"}}]`, "", nil)
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"b3e1f6a2", "Payments-Prod", "payments-prod", "Jane", "contoso", "Contoso"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("recording contains %q:\n%s", secret, data)
+		}
+	}
+	for _, kept := range []string{`\"Owner\"`, "resource-group-", "Principal ", "Scope ", "Role ", "Subscription ", "@example.com"} {
+		if !bytes.Contains(data, []byte(kept)) {
+			t.Errorf("recording lacks %q:\n%s", kept, data)
+		}
+	}
+
+	// The same principal gets the same fake throughout
+	names := regexp.MustCompile(`Principal \d+`).FindAllString(string(data), -1)
+	if len(names) != 2 || names[0] != names[1] {
+		t.Errorf("got principal names %v, want the same one twice", names)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := Open(path, false); err != nil {
+		t.Fatal(err)
+	}
+	RecordHTTP("GET", "https://management.azure.com/a", nil, 200, `{"page": 1}`, nil)
+	RecordHTTP("GET", "https://management.azure.com/a", nil, 200, `{"page": 2}`, nil)
+	RecordAz([]string{"rest", "--body", "@/tmp/hacktivator-body-1.json"}, "", "denied", &exitError{1})
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(path, true); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { replaying = false }()
+
+	// Repeated requests get the recorded responses in order, then the last again
+	for _, want := range []string{`{"page": 1}`, `{"page": 2}`, `{"page": 2}`} {
+		req, _ := http.NewRequest("GET", "https://management.azure.com/a", nil)
+		resp, err := ReplayHTTP(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != want {
+			t.Errorf("got %s, want %s", body, want)
+		}
+	}
+
+	// Body files match whatever their name
+	var stdout, stderr strings.Builder
+	err := ReplayAz([]string{"rest", "--body", "@/tmp/hacktivator-body-2.json"}, &stdout, &stderr)
+	var exit interface{ ExitCode() int }
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 || stderr.String() != "denied" {
+		t.Errorf("got %v with stderr %q, want exit status 1 with denied", err, stderr.String())
+	}
+
+	req, _ := http.NewRequest("GET", "https://management.azure.com/b", nil)
+	if _, err := ReplayHTTP(req); err == nil {
+		t.Error("expected an error for a request that wasn't recorded")
+	}
+}
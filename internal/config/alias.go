@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Alias names a role on a scope for one-shot activation
+type Alias struct {
+	Role   string `yaml:"role"`
+	Scope  string `yaml:"scope"`  // full scope ID, e.g. /subscriptions/<id>
+	Reason string `yaml:"reason"` // default justification, may use template placeholders
+}
+
+// UnmarshalYAML accepts either a mapping or the short form "Role @ /scope"
+func (a *Alias) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		role, scope, ok := strings.Cut(node.Value, "@")
+		if !ok {
+			return fmt.Errorf("line %d: alias %q must look like \"Role @ /scope\"", node.Line, node.Value)
+		}
+		a.Role = strings.TrimSpace(role)
+		a.Scope = strings.TrimSpace(scope)
+	} else {
+		type plain Alias
+		if err := node.Decode((*plain)(a)); err != nil {
+			return err
+		}
+	}
+
+	if a.Role == "" || a.Scope == "" {
+		return fmt.Errorf("line %d: alias needs both a role and a scope", node.Line)
+	}
+	return nil
+}
+
+// AliasNames returns the configured alias names in sorted order
+func (c *Config) AliasNames() []string {
+	names := make([]string, 0, len(c.Aliases))
+	for name := range c.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user settings loaded from the config file
+type Config struct {
+	// ReasonTemplates maps a template name to a justification template,
+	// e.g. deploy: "Deploying {{ticket}} to {{scope}}"
+	ReasonTemplates map[string]string `yaml:"reason_templates"`
+
+	// ReasonHistorySize is the number of recent justifications remembered
+	ReasonHistorySize int `yaml:"reason_history_size"`
+
+	// Requirements are enforced before any activation request is submitted
+	Requirements Requirements `yaml:"requirements"`
+
+	// HighRiskRoles are glob patterns for break-glass role names: activating
+	// one takes typing its name, a longer justification, and notifies every
+	// channel. Owner and User Access Administrator when unset; [] for none.
+	HighRiskRoles []string `yaml:"high_risk_roles"`
+
+	// Guardrails are local limits on activations; --override-guardrail
+	// bypasses them, which is recorded in the audit log
+	Guardrails Guardrails `yaml:"guardrails"`
+
+	// Tickets configures an optional Jira or ServiceNow integration
+	Tickets Tickets `yaml:"tickets"`
+
+	// Theme selects and customises the TUI colors
+	Theme Theme `yaml:"theme"`
+
+	// Keybindings remaps keys in the interactive views
+	Keybindings Keybindings `yaml:"keybindings"`
+
+	// Aliases maps a short name to a role and scope, e.g.
+	// prod-owner: "Owner @ /subscriptions/<id>"
+	Aliases map[string]Alias `yaml:"aliases"`
+
+	// SelectorSort is the initial role order in the selector: recent,
+	// frequent or default (the order returned by Azure)
+	SelectorSort string `yaml:"selector_sort"`
+
+	// WorkdayEnd is the local time --until eod activates until, e.g. "18:00"
+	WorkdayEnd string `yaml:"workday_end"`
+
+	// EligibilityWarningDays warns when an eligibility ends within this many
+	// days (default 14, negative to disable)
+	EligibilityWarningDays int `yaml:"eligibility_warning_days"`
+
+	// ScanSubscriptions limits the per-subscription role scans to subscriptions
+	// whose name or ID matches one of these glob patterns; all when unset.
+	// Chosen on the first run in a large tenant.
+	ScanSubscriptions []string `yaml:"scan_subscriptions"`
+
+	// DiscoveryMode is how eligible roles are found: scan (default) queries
+	// the tenant and then one subscription at a time, parallel several at a
+	// time, batch up to 20 per ARM batch request, and aggregate lists them
+	// all at once from the PIM API the Azure portal uses, scanning only if
+	// that fails. 'hacktivator bench discover' compares them.
+	DiscoveryMode string `yaml:"discovery_mode"`
+
+	// EligibleCacheTTL is how long a scan of eligible roles is reused before
+	// rescanning, e.g. "1h" (default 15m)
+	EligibleCacheTTL string `yaml:"eligible_cache_ttl"`
+
+	// ReducedMotion replaces spinners and blinking cursors with static output
+	ReducedMotion bool `yaml:"reduced_motion"`
+
+	// Network configures the proxy and TLS trust for direct HTTP requests
+	Network Network `yaml:"network"`
+
+	// AuditSinks receive a record of every activation, extension and deactivation
+	AuditSinks []AuditSink `yaml:"audit_sinks"`
+
+	// Notifications tell a team when roles are activated or approved
+	Notifications Notifications `yaml:"notifications"`
+}
+
+type Notifications struct {
+	Teams *Webhook `yaml:"teams"` // Microsoft Teams incoming webhook or Workflows URL
+	Slack *Webhook `yaml:"slack"` // Slack incoming webhook URL
+	Email *Email   `yaml:"email"` // email sent through Microsoft Graph
+
+	// Approvers lists the channels that ping a role's approvers when a
+	// request needs approval: email (sent to them directly), teams, slack
+	Approvers []string `yaml:"approvers"`
+
+	// RemindAfter makes the daemon announce a request that is still pending
+	// again after this long, e.g. 30m, and again each time it passes
+	RemindAfter string `yaml:"remind_after"`
+}
+
+// Email sends notifications from the signed-in user's mailbox
+type Email struct {
+	To     []string `yaml:"to"`     // recipients, e.g. a distribution list
+	Scopes []string `yaml:"scopes"` // glob patterns for scope or subscription names; all if empty
+}
+
+// Webhook is a chat webhook, optionally limited to some scopes
+type Webhook struct {
+	URL       string   `yaml:"url"`        // webhook URL; ${VAR} is expanded from the environment
+	URLSecret string   `yaml:"url_secret"` // keyring secret holding the URL, overrides url
+	Scopes    []string `yaml:"scopes"`     // glob patterns for scope or subscription names; all if empty
+}
+
+// AuditSink forwards activation events to a log pipeline or SIEM
+type AuditSink struct {
+	Type    string            `yaml:"type"`    // syslog, file or http
+	Format  string            `yaml:"format"`  // json (default) or cef
+	Address string            `yaml:"address"` // syslog server as udp://host:514 or tcp://host:514, local syslog if empty
+	Path    string            `yaml:"path"`    // file to append events to, one per line
+	URL     string            `yaml:"url"`     // HTTP collector that events are POSTed to
+	Headers map[string]string `yaml:"headers"` // HTTP headers; ${VAR} is expanded from the environment
+}
+
+// Network settings for corporate proxies and TLS-intercepting gateways
+type Network struct {
+	Proxy              string `yaml:"proxy"`                // proxy URL, overrides HTTPS_PROXY
+	CABundle           string `yaml:"ca_bundle"`            // PEM file of extra root CAs to trust
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // disable certificate checks, for debugging only
+}
+
+// Keybindings lists the keys for each TUI action. An empty list keeps the default.
+type Keybindings struct {
+	Select      []string `yaml:"select"`
+	Filter      []string `yaml:"filter"`
+	Quit        []string `yaml:"quit"`
+	MultiSelect []string `yaml:"multi_select"`
+	Copy        []string `yaml:"copy"`
+	OpenPortal  []string `yaml:"open_portal"`
+	Favorite    []string `yaml:"favorite"`
+	Sort        []string `yaml:"sort"`
+	Deactivate  []string `yaml:"deactivate"`
+	Extend      []string `yaml:"extend"`
+	Help        []string `yaml:"help"`
+}
+
+// Tickets configures lookup and validation of ticket numbers
+type Tickets struct {
+	Provider    string `yaml:"provider"` // jira, servicenow or azuredevops
+	URL         string `yaml:"url"`      // base URL, or organization URL for Azure DevOps
+	Project     string `yaml:"project"`  // Azure DevOps project to query
+	Username    string `yaml:"username"`
+	TokenEnv    string `yaml:"token_env"`    // environment variable holding the API token or password
+	TokenSecret string `yaml:"token_secret"` // keyring secret holding the token, overrides token_env
+	Table       string `yaml:"table"`        // ServiceNow table, defaults to incident
+	Validate    bool   `yaml:"validate"`     // reject ticket numbers that don't exist or are closed
+	Pick        bool   `yaml:"pick"`         // offer a picker of assigned tickets when none is given
+}
+
+// Requirements lets organisations mandate justification and ticket details
+type Requirements struct {
+	MinReasonLength int  `yaml:"min_reason_length"`
+	RequireTicket   bool `yaml:"require_ticket"`
+}
+
+// CheckReason reports whether reason satisfies the configured minimum length
+func (r Requirements) CheckReason(reason string) error {
+	if n := len([]rune(strings.TrimSpace(reason))); n < r.MinReasonLength {
+		return fmt.Errorf("justification must be at least %d characters (got %d)", r.MinReasonLength, n)
+	}
+	return nil
+}
+
+// CheckTicket reports whether a ticket number is present when one is required
+func (r Requirements) CheckTicket(ticket string) error {
+	if r.RequireTicket && strings.TrimSpace(ticket) == "" {
+		return fmt.Errorf("a ticket number is required")
+	}
+	return nil
+}
+
+// ReasonRequired reports whether an empty justification would be rejected
+func (r Requirements) ReasonRequired() bool {
+	return r.MinReasonLength > 0
+}
+
+// Guardrails are checked before activations and extensions are requested
+type Guardrails struct {
+	// MaxDuration maps a glob pattern for role names to the longest
+	// activation allowed, e.g. Owner: 2h
+	MaxDuration map[string]string `yaml:"max_duration"`
+	// MaxActiveRoles is the most roles activated at once; 0 for no limit
+	MaxActiveRoles int `yaml:"max_active_roles"`
+}
+
+const defaultReasonHistorySize = 20
+
+// Dir returns the directory holding the config file and local state
+func Dir() (string, error) {
+	if dir := os.Getenv("HACKTIVATOR_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config directory: %w", err)
+	}
+	return filepath.Join(base, "hacktivator"), nil
+}
+
+// Path returns the location of the config file
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// Load reads the config file, returning defaults if it does not exist
+func Load() (*Config, error) {
+	cfg := &Config{
+		ReasonHistorySize: defaultReasonHistorySize,
+	}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// SetValue stores a top-level setting in the config file, creating the file
+// if needed. The rest of the file, including comments, is kept as it is.
+func SetValue(key string, value any) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to update config %s: not a mapping", path)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			root.Content[i+1] = &node
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &node)
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
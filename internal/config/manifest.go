@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest declares the role activations that should be in effect, e.g.
+//
+//	reason: On-call week
+//	activations:
+//	  - role: Contributor
+//	    scope: /subscriptions/<id>
+//	    duration: 4h
+type Manifest struct {
+	Reason      string     `yaml:"reason"` // default justification for every activation
+	Activations []Activate `yaml:"activations"`
+}
+
+// Activate is one desired activation in a manifest
+type Activate struct {
+	Role     string `yaml:"role"`
+	Scope    string `yaml:"scope"`    // full scope ID, e.g. /subscriptions/<id>
+	Duration string `yaml:"duration"` // minutes or a duration like 2h, defaults to --duration
+	Reason   string `yaml:"reason"`   // overrides the manifest reason
+}
+
+// LoadManifest reads and validates an activation manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	for i, a := range m.Activations {
+		if a.Role == "" || a.Scope == "" {
+			return nil, fmt.Errorf("manifest %s: activation %d needs both a role and a scope", path, i+1)
+		}
+	}
+	return &m, nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+)
+
+var templateVarPattern = regexp.MustCompile(`{{\s*([a-zA-Z_]+)\s*}}`)
+
+// ExpandReason substitutes {{name}} placeholders in a reason template.
+// Unknown placeholders are left untouched so the user can spot them.
+func ExpandReason(template string, vars map[string]string) string {
+	return templateVarPattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := templateVarPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// TemplateNames returns the configured reason template names in sorted order
+func (c *Config) TemplateNames() []string {
+	names := make([]string, 0, len(c.ReasonTemplates))
+	for name := range c.ReasonTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
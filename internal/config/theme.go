@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Theme selects a built-in color theme and optionally overrides its colors
+type Theme struct {
+	Name          string `yaml:"name"` // default, solarized or high-contrast
+	Title         Color  `yaml:"title"`
+	Success       Color  `yaml:"success"`
+	Error         Color  `yaml:"error"`
+	Subtle        Color  `yaml:"subtle"`
+	Selection     Color  `yaml:"selection"`
+	PreviewBorder Color  `yaml:"preview_border"`
+}
+
+// Color is a terminal color (ANSI index or hex). It is written either as a
+// single value or as {light: ..., dark: ...} to adapt to the terminal background.
+type Color struct {
+	Light string
+	Dark  string
+}
+
+// IsSet reports whether the color was given in config
+func (c Color) IsSet() bool {
+	return c.Light != "" || c.Dark != ""
+}
+
+// UnmarshalYAML accepts either a scalar color or a light/dark mapping
+func (c *Color) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		c.Light, c.Dark = node.Value, node.Value
+		return nil
+	case yaml.MappingNode:
+		var adaptive struct {
+			Light string `yaml:"light"`
+			Dark  string `yaml:"dark"`
+		}
+		if err := node.Decode(&adaptive); err != nil {
+			return err
+		}
+		if adaptive.Light == "" || adaptive.Dark == "" {
+			return fmt.Errorf("line %d: adaptive colors need both light and dark", node.Line)
+		}
+		c.Light, c.Dark = adaptive.Light, adaptive.Dark
+		return nil
+	default:
+		return fmt.Errorf("line %d: expected a color or {light, dark}", node.Line)
+	}
+}
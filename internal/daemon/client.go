@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// client talks HTTP to the daemon over its socket. Connecting fails fast
+// when no daemon is running so callers can fall back to Azure directly.
+func client(timeout time.Duration) (*http.Client, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 100 * time.Millisecond}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}, nil
+}
+
+// Fetch returns the daemon's latest snapshot, or ErrUnavailable
+func Fetch() (*Snapshot, error) {
+	c, err := client(2 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Get("http://daemon/snapshot")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned %s", resp.Status)
+	}
+
+	var snap Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to parse daemon snapshot: %w", err)
+	}
+	return &snap, nil
+}
+
+// RequestRefresh asks a running daemon to refresh now, e.g. after an
+// activation. It does nothing when no daemon is running.
+func RequestRefresh() {
+	c, err := client(time.Second)
+	if err != nil {
+		return
+	}
+	if resp, err := c.Post("http://daemon/refresh", "", nil); err == nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,181 @@
+// Package daemon keeps eligible and active role assignments fresh in a
+// background process and serves them to the CLI over a local socket, so
+// prompts, status bars and role discovery don't wait on Azure.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+)
+
+// The socket is created in a directory only the user can enter, so it is
+// never reachable by others, even before its own permissions are set
+const (
+	socketDir  = "run"
+	socketName = "daemon.sock"
+)
+
+// ErrUnavailable is returned by the client when no daemon is running
+var ErrUnavailable = errors.New("daemon not running")
+
+// SocketPath is where the daemon listens, one per context. Unix sockets
+// also work on Windows 10 and later.
+func SocketPath() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, socketDir, socketName), nil
+}
+
+// Snapshot is the daemon's latest view of the user's role assignments
+type Snapshot struct {
+	Eligible   []azure.RoleAssignment `json:"eligible"`
+	EligibleAt time.Time              `json:"eligibleAt"`
+	Active     []azure.RoleAssignment `json:"active"`
+	ActiveAt   time.Time              `json:"activeAt"`
+	Error      string                 `json:"error,omitempty"` // last refresh error, if any
+}
+
+// Server refreshes a Snapshot periodically and serves it
+type Server struct {
+	Interval time.Duration
+	// ScanEligible finds the eligible roles on every refresh, e.g. reusing
+	// cached results; a full scan when nil
+	ScanEligible func() ([]azure.RoleAssignment, error)
+	// OnRefresh is called after every successful refresh of active roles
+	OnRefresh func(active []azure.RoleAssignment)
+
+	mu      sync.RWMutex
+	snap    Snapshot
+	trigger chan struct{}
+}
+
+// Serve listens on the daemon socket and refreshes until ctx is cancelled
+func (s *Server) Serve(ctx context.Context) error {
+	path, err := SocketPath()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	// In case it was created with other permissions
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to restrict %s: %w", dir, err)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	// A socket left behind by a daemon that crashed
+	_ = os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+
+	s.trigger = make(chan struct{}, 1)
+	go s.refreshLoop(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /snapshot", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.snap)
+	})
+	mux.HandleFunc("POST /refresh", func(w http.ResponseWriter, r *http.Request) {
+		s.Refresh()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Refresh asks the refresh loop to run now. Requests made while a refresh is
+// already queued are merged.
+func (s *Server) Refresh() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+	}
+}
+
+func (s *Server) refresh() {
+	var errs []error
+
+	active, err := azure.GetActiveRoleAssignments()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("active roles: %w", err))
+	} else {
+		s.mu.Lock()
+		s.snap.Active, s.snap.ActiveAt = active, time.Now()
+		s.mu.Unlock()
+		if s.OnRefresh != nil {
+			s.OnRefresh(active)
+		}
+	}
+
+	scan := s.ScanEligible
+	if scan == nil {
+		scan = azure.GetEligibleRoleAssignments
+	}
+	eligible, err := scan()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("eligible roles: %w", err))
+	} else {
+		s.mu.Lock()
+		s.snap.Eligible, s.snap.EligibleAt = eligible, time.Now()
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.snap.Error = ""
+	if err := errors.Join(errs...); err != nil {
+		s.snap.Error = err.Error()
+		fmt.Fprintf(os.Stderr, "warning: refresh failed: %v\n", err)
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,134 @@
+// Package lockwatch reports when the screen is locked or the computer goes to
+// sleep, using the tools each OS ships with: D-Bus on Linux, ioreg on macOS
+// and tasklist on Windows. Waking from sleep is noticed on every OS by the
+// wall clock jumping ahead of the monotonic clock, which stops while asleep.
+package lockwatch
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Event is why the session should be considered unattended
+type Event string
+
+const (
+	// Locked is sent when the screen is locked
+	Locked Event = "screen locked"
+	// Sleeping is sent just before the computer sleeps, where the OS says so
+	Sleeping Event = "going to sleep"
+	// Woke is sent after the computer slept
+	Woke Event = "woke from sleep"
+)
+
+// pollInterval is how often the lock state and the clocks are checked
+const pollInterval = 5 * time.Second
+
+// sleepThreshold is how far the wall clock must run ahead of the monotonic
+// clock between two polls to count as a sleep
+const sleepThreshold = 30 * time.Second
+
+// Watch sends an event on events each time the screen is locked or the
+// computer goes to sleep or wakes up. It blocks until ctx is cancelled.
+func Watch(ctx context.Context, events chan<- Event) {
+	switch runtime.GOOS {
+	case "linux":
+		go watchDBus(ctx, events, "--system", "org.freedesktop.login1")
+		go watchDBus(ctx, events, "--session", "org.freedesktop.ScreenSaver")
+		go watchDBus(ctx, events, "--session", "org.gnome.ScreenSaver")
+	case "darwin":
+		go pollLocked(ctx, events, macLocked)
+	case "windows":
+		go pollLocked(ctx, events, windowsLocked)
+	}
+	watchSleep(ctx, events)
+}
+
+// watchSleep compares the wall clock with the monotonic clock on every poll
+func watchSleep(ctx context.Context, events chan<- Event) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Round(0) drops the monotonic reading, leaving wall time
+			if now.Round(0).Sub(last.Round(0))-now.Sub(last) > sleepThreshold {
+				send(ctx, events, Woke)
+			}
+			last = now
+		}
+	}
+}
+
+// watchDBus follows the signals of a D-Bus service with gdbus monitor:
+// logind's PrepareForSleep and session Lock, and the screensavers'
+// ActiveChanged. Without gdbus or the service, it watches nothing.
+func watchDBus(ctx context.Context, events chan<- Event, bus, dest string) {
+	cmd := exec.CommandContext(ctx, "gdbus", "monitor", bus, "--dest", dest)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	defer cmd.Wait()
+
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.Contains(line, ".PrepareForSleep (true"):
+			send(ctx, events, Sleeping)
+		case strings.Contains(line, ".ActiveChanged (true"),
+			strings.Contains(line, "org.freedesktop.login1.Session.Lock"):
+			send(ctx, events, Locked)
+		}
+	}
+}
+
+// pollLocked sends Locked whenever locked starts reporting true
+func pollLocked(ctx context.Context, events chan<- Event, locked func() bool) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	was := locked()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			is := locked()
+			if is && !was {
+				send(ctx, events, Locked)
+			}
+			was = is
+		}
+	}
+}
+
+// macLocked reports whether the macOS login window covers the session
+func macLocked() bool {
+	out, err := exec.Command("ioreg", "-n", "Root", "-d1").Output()
+	return err == nil && strings.Contains(string(out), `"IOConsoleLocked" = Yes`)
+}
+
+// windowsLocked reports whether the Windows lock screen is showing, which
+// runs LogonUI.exe
+func windowsLocked() bool {
+	out, err := exec.Command("tasklist", "/FI", "IMAGENAME eq LogonUI.exe", "/NH").Output()
+	return err == nil && strings.Contains(string(out), "LogonUI.exe")
+}
+
+func send(ctx context.Context, events chan<- Event, e Event) {
+	select {
+	case events <- e:
+	case <-ctx.Done():
+	}
+}
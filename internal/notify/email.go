@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// Email sends a summary to a distribution list through Microsoft Graph,
+// from the signed-in user's mailbox
+type Email struct {
+	To []string
+}
+
+func (m *Email) Notify(e Event) error {
+	return azure.SendMail(m.To, "[PIM] "+e.Title(), renderEmail(e, ""))
+}
+
+// ApproverEmail emails the approvers of a pending request directly, as a
+// heads-up ahead of Azure's own, often delayed, approval email
+type ApproverEmail struct{}
+
+func (ApproverEmail) Notify(e Event) error {
+	var to []string
+	for _, a := range e.Approvers {
+		if a.Email != "" {
+			to = append(to, a.Email)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+	intro := fmt.Sprintf("%s is waiting for your approval. Azure will also email you about it.", e.Requester)
+	return azure.SendMail(to, "[PIM] Approval needed: "+e.Title(), renderEmail(e, intro))
+}
+
+func renderEmail(e Event, intro string) string {
+	var b strings.Builder
+	if intro != "" {
+		fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(intro))
+	}
+	fmt.Fprintf(&b, "<p><b>%s</b></p>\n<table>\n", html.EscapeString(e.Title()))
+	for _, f := range e.Facts() {
+		fmt.Fprintf(&b, "<tr><td><b>%s</b></td><td>%s</td></tr>\n", html.EscapeString(f[0]), html.EscapeString(f[1]))
+	}
+	if len(e.Approvers) > 0 {
+		fmt.Fprintf(&b, "<tr><td><b>Approvers</b></td><td>%s</td></tr>\n", html.EscapeString(e.approverNames()))
+	}
+	b.WriteString("</table>\n")
+	if e.Kind == PendingApproval && e.ApprovalURL != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">Review the request</a></p>\n", html.EscapeString(e.ApprovalURL))
+	}
+	if e.PortalURL != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">Open the scope in the Azure portal</a></p>\n", html.EscapeString(e.PortalURL))
+	}
+	b.WriteString("<p><small>Sent by hacktivator</small></p>\n")
+	return b.String()
+}
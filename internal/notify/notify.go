@@ -0,0 +1,164 @@
+// Package notify posts activation notices to chat and email so a team can
+// see when privileged roles are used and approvers can act quickly.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// Kind is the stage of an activation a notice is about
+type Kind string
+
+const (
+	Activated       Kind = "activated" // active right away
+	PendingApproval Kind = "pending"   // submitted and waiting for an approver
+	Approved        Kind = "approved"
+	Denied          Kind = "denied"
+)
+
+// Event describes an activation for notifiers
+type Event struct {
+	Kind          Kind
+	Requester     string
+	Grantee       string // who an admin granted the role to; empty for the requester's own
+	RoleName      string
+	Scope         string
+	ScopeName     string
+	Duration      int       // minutes
+	Expires       time.Time // when the activation ends, in the zone to show it in
+	Justification string
+	TicketNumber  string
+	TicketSystem  string
+	RequestID     string
+	PortalURL     string // the scope in the Azure portal
+	ApprovalURL   string // where approvers review the request
+	// Waiting is how long a pending request has waited, set on reminders
+	Waiting time.Duration
+	// Approvers named in the role's policy, set on pending requests for
+	// channels that ping approvers
+	Approvers []azure.Approver
+	// HighRisk marks a break-glass role, which every channel is told about
+	HighRisk bool
+}
+
+// Notifier delivers events to one channel
+type Notifier interface {
+	Notify(e Event) error
+}
+
+// Title is a one-line summary of the event
+func (e Event) Title() string {
+	target := fmt.Sprintf("%s on %s", e.RoleName, e.scopeLabel())
+	if e.HighRisk {
+		target = "break-glass role " + target
+	}
+	switch e.Kind {
+	case PendingApproval:
+		if e.Waiting > 0 {
+			return fmt.Sprintf("%s's request for %s has waited %s for approval", e.Requester, target,
+				ui.FormatMinutes(int(e.Waiting/time.Minute)))
+		}
+		return fmt.Sprintf("%s requested %s (pending approval)", e.Requester, target)
+	case Approved:
+		return fmt.Sprintf("%s was approved for %s", e.Requester, target)
+	case Denied:
+		return fmt.Sprintf("%s's request for %s was not approved", e.Requester, target)
+	}
+	if e.Grantee != "" {
+		return fmt.Sprintf("%s granted %s %s", e.Requester, e.Grantee, target)
+	}
+	return fmt.Sprintf("%s activated %s", e.Requester, target)
+}
+
+// Facts are the labelled details shown under the title
+func (e Event) Facts() [][2]string {
+	facts := [][2]string{
+		{"Requester", e.Requester},
+		{"Role", e.RoleName},
+		{"Scope", e.scopeLabel()},
+	}
+	if e.Grantee != "" {
+		facts = append(facts, [2]string{"Granted to", e.Grantee})
+	}
+	if e.Duration > 0 {
+		facts = append(facts, [2]string{"Duration", ui.FormatMinutes(e.Duration)})
+	}
+	if !e.Expires.IsZero() {
+		facts = append(facts, [2]string{"Expires", formatExpiry(e.Expires)})
+	}
+	if e.Justification != "" {
+		facts = append(facts, [2]string{"Justification", e.Justification})
+	}
+	if e.TicketNumber != "" {
+		facts = append(facts, [2]string{"Ticket", strings.TrimSpace(e.TicketSystem + " " + e.TicketNumber)})
+	}
+	if e.RequestID != "" {
+		facts = append(facts, [2]string{"Request", e.RequestID})
+	}
+	return facts
+}
+
+// formatExpiry renders an end time in its zone, with UTC alongside so
+// readers elsewhere can convert it
+func formatExpiry(t time.Time) string {
+	s := t.Format("Jan 02 15:04 MST")
+	if _, offset := t.Zone(); offset != 0 {
+		s += " (" + t.UTC().Format("15:04 UTC") + ")"
+	}
+	return s
+}
+
+// approverNames lists the approvers for display
+func (e Event) approverNames() string {
+	names := make([]string, len(e.Approvers))
+	for i, a := range e.Approvers {
+		names[i] = a.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (e Event) scopeLabel() string {
+	if e.ScopeName != "" {
+		return e.ScopeName
+	}
+	return e.Scope
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON sends payload to a webhook and fails on non-2xx responses
+func postJSON(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL embeds the webhook secret; keep it out of error messages
+		return fmt.Errorf("webhook request failed: %w", unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// unwrapURLError drops the *url.Error wrapper, whose message repeats the URL
+func unwrapURLError(err error) error {
+	if u, ok := err.(*url.Error); ok {
+		return u.Err
+	}
+	return err
+}
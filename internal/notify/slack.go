@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Slack posts Block Kit messages to an incoming webhook. The same payload is
+// accepted by a slash command's response_url.
+type Slack struct {
+	URL string
+}
+
+func (s *Slack) Notify(e Event) error {
+	emoji := ":unlock:"
+	switch e.Kind {
+	case PendingApproval:
+		emoji = ":hourglass_flowing_sand:"
+	case Approved:
+		emoji = ":white_check_mark:"
+	case Denied:
+		emoji = ":no_entry:"
+	}
+
+	fields := make([]map[string]string, 0, len(e.Facts()))
+	for _, f := range e.Facts() {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", f[0], slackEscape(f[1]))})
+	}
+
+	var buttons []map[string]any
+	if e.Kind == PendingApproval && e.ApprovalURL != "" {
+		buttons = append(buttons, slackButton("Review request", e.ApprovalURL, "primary"))
+	}
+	if e.PortalURL != "" {
+		buttons = append(buttons, slackButton("Open in portal", e.PortalURL, ""))
+	}
+
+	blocks := []any{
+		map[string]any{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": emoji + " *" + slackEscape(e.Title()) + "*"}},
+		// Slack allows at most 10 fields per section
+		map[string]any{"type": "section", "fields": fields[:min(len(fields), 10)]},
+	}
+	if len(e.Approvers) > 0 {
+		// Slack users can't be looked up from Entra IDs, so approvers are named rather than mentioned
+		blocks = append(blocks, map[string]any{"type": "context", "elements": []map[string]string{
+			{"type": "mrkdwn", "text": "Approvers: " + slackEscape(e.approverNames())},
+		}})
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, map[string]any{"type": "actions", "elements": buttons})
+	}
+
+	return postJSON(s.URL, map[string]any{
+		"text":          e.Title(), // fallback for notifications and old clients
+		"response_type": "in_channel",
+		"blocks":        blocks,
+	})
+}
+
+func slackButton(text, url, style string) map[string]any {
+	b := map[string]any{
+		"type": "button",
+		"text": map[string]string{"type": "plain_text", "text": text},
+		"url":  url,
+	}
+	if style != "" {
+		b["style"] = style
+	}
+	return b
+}
+
+// slackEscape escapes the characters mrkdwn treats as control sequences
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
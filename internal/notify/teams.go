@@ -0,0 +1,67 @@
+package notify
+
+import "strings"
+
+// Teams posts Adaptive Cards to a Microsoft Teams incoming webhook or a
+// Workflows "post to a channel when a webhook request is received" URL
+type Teams struct {
+	URL string
+}
+
+func (t *Teams) Notify(e Event) error {
+	facts := make([]map[string]string, 0, len(e.Facts()))
+	for _, f := range e.Facts() {
+		facts = append(facts, map[string]string{"title": f[0], "value": f[1]})
+	}
+
+	color := "Good"
+	switch e.Kind {
+	case PendingApproval:
+		color = "Warning"
+	case Denied:
+		color = "Attention"
+	}
+
+	var actions []map[string]string
+	if e.Kind == PendingApproval && e.ApprovalURL != "" {
+		actions = append(actions, map[string]string{"type": "Action.OpenUrl", "title": "Review request", "url": e.ApprovalURL})
+	}
+	if e.PortalURL != "" {
+		actions = append(actions, map[string]string{"type": "Action.OpenUrl", "title": "Open in portal", "url": e.PortalURL})
+	}
+
+	body := []any{
+		map[string]any{"type": "TextBlock", "text": e.Title(), "weight": "Bolder", "size": "Medium", "wrap": true, "color": color},
+		map[string]any{"type": "FactSet", "facts": facts},
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"actions": actions,
+	}
+
+	// @mention the approvers so Teams notifies them
+	if len(e.Approvers) > 0 {
+		mentions := make([]string, len(e.Approvers))
+		entities := make([]any, len(e.Approvers))
+		for i, a := range e.Approvers {
+			mentions[i] = "<at>" + a.Name + "</at>"
+			entities[i] = map[string]any{
+				"type":      "mention",
+				"text":      mentions[i],
+				"mentioned": map[string]string{"id": a.ID, "name": a.Name},
+			}
+		}
+		body = append(body, map[string]any{"type": "TextBlock", "text": "Approvers: " + strings.Join(mentions, ", "), "wrap": true})
+		card["msteams"] = map[string]any{"entities": entities}
+	}
+	card["body"] = body
+	return postJSON(t.URL, map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	})
+}
@@ -0,0 +1,25 @@
+// Package schema embeds the JSON schemas of the -o json output. Each output
+// object carries a schemaVersion that is incremented, together with the
+// file name, whenever a field is removed, renamed or changes type; adding
+// fields is not a breaking change.
+package schema
+
+import (
+	"embed"
+	"fmt"
+)
+
+// Version is the current version of every output schema
+const Version = 1
+
+//go:embed *.json
+var files embed.FS
+
+// Get returns the schema for an output, e.g. roles or activation-results
+func Get(name string) ([]byte, error) {
+	data, err := files.ReadFile(fmt.Sprintf("%s.v%d.json", name, Version))
+	if err != nil {
+		return nil, fmt.Errorf("no schema named %s", name)
+	}
+	return data, nil
+}
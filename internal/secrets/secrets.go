@@ -0,0 +1,67 @@
+// Package secrets keeps sensitive values such as API tokens and webhook
+// secrets in the operating system keyring instead of the config file.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// service is the keyring service name every secret is stored under
+const service = "hacktivator"
+
+// ErrNotFound is returned when no secret with the given name is stored
+var ErrNotFound = errors.New("secret not found")
+
+// Store saves and retrieves named secrets
+type Store interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// Default is the system keyring: the macOS Keychain, Windows Credential
+// Manager, or the Secret Service (e.g. GNOME Keyring) on Linux
+var Default Store = keyringStore{}
+
+type keyringStore struct{}
+
+func (keyringStore) Get(name string) (string, error) {
+	value, err := keyring.Get(service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from the keyring: %w", name, err)
+	}
+	return value, nil
+}
+
+func (keyringStore) Set(name, value string) error {
+	if err := keyring.Set(service, name, value); err != nil {
+		return fmt.Errorf("failed to store %s in the keyring: %w", name, err)
+	}
+	return nil
+}
+
+func (keyringStore) Delete(name string) error {
+	err := keyring.Delete(service, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from the keyring: %w", name, err)
+	}
+	return nil
+}
+
+// Resolve returns a secret from the keyring when name is set, otherwise the
+// fallback value, e.g. one read from an environment variable
+func Resolve(name, fallback string) (string, error) {
+	if name == "" {
+		return fallback, nil
+	}
+	return Default.Get(name)
+}
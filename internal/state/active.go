@@ -0,0 +1,88 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+const activeFile = "active.json"
+
+// ActiveRole is a cached active assignment
+type ActiveRole struct {
+	RoleRef
+	EndDateTime *time.Time `json:"endDateTime,omitempty"` // nil for permanent assignments
+}
+
+// ActiveCache is the last known set of active assignments, read by the
+// prompt helper and status integrations without calling Azure
+type ActiveCache struct {
+	FetchedAt time.Time    `json:"fetchedAt"`
+	Roles     []ActiveRole `json:"roles"`
+}
+
+// Current returns the cached roles that haven't expired by now
+func (c *ActiveCache) Current() []ActiveRole {
+	var current []ActiveRole
+	for _, r := range c.Roles {
+		if r.EndDateTime == nil || r.EndDateTime.After(time.Now()) {
+			current = append(current, r)
+		}
+	}
+	return current
+}
+
+// CachedActive returns the cached active assignments. A missing cache is
+// returned as an empty one fetched at the zero time.
+func CachedActive() (*ActiveCache, error) {
+	var cache ActiveCache
+	if err := load(activeFile, &cache); err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// SaveActive replaces the cache with freshly fetched active assignments
+func SaveActive(roles []azure.RoleAssignment) error {
+	cache := ActiveCache{FetchedAt: time.Now(), Roles: make([]ActiveRole, len(roles))}
+	for i, r := range roles {
+		cache.Roles[i] = ActiveRole{RoleRef: Ref(r), EndDateTime: r.EndDateTime}
+	}
+	return save(activeFile, cache)
+}
+
+// InvalidateActive marks the cache stale after activations or deactivations
+// so the next reader refreshes it
+func InvalidateActive() error {
+	cache, err := CachedActive()
+	if err != nil {
+		return err
+	}
+	if cache.FetchedAt.IsZero() {
+		return nil
+	}
+	cache.FetchedAt = time.Time{}
+	return save(activeFile, cache)
+}
+
+const refreshMarker = "active.refresh"
+
+// ClaimRefresh reports whether the caller should refresh the active cache,
+// at most once per interval across processes, so many shells drawing
+// prompts at the same time start a single refresh
+func ClaimRefresh(interval time.Duration) bool {
+	dir, err := Dir()
+	if err != nil {
+		return false
+	}
+	marker := filepath.Join(dir, refreshMarker)
+	if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) < interval {
+		return false
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return false
+	}
+	return os.WriteFile(marker, nil, 0o600) == nil
+}
@@ -0,0 +1,28 @@
+package state
+
+import "time"
+
+const azCLIFile = "azcli.json"
+
+// CLIVersion remembers the detected Azure CLI version so startup doesn't
+// have to run `az version` every time
+type CLIVersion struct {
+	Path      string    `json:"path"`
+	ModTime   time.Time `json:"modTime"`
+	Version   string    `json:"version"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// CachedCLIVersion returns the last detected version, or nil if none was saved
+func CachedCLIVersion() (*CLIVersion, error) {
+	var v *CLIVersion
+	if err := load(azCLIFile, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SaveCLIVersion records the detected version
+func SaveCLIVersion(v CLIVersion) error {
+	return save(azCLIFile, v)
+}
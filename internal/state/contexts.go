@@ -0,0 +1,159 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ica-js/hacktivator/internal/config"
+)
+
+const contextsFile = "contexts.json"
+
+// Context is a named sign-in target, e.g. one customer's tenant
+type Context struct {
+	Name   string `json:"name"`
+	Tenant string `json:"tenant"`
+	Auth   string `json:"auth"` // how to sign in; only az is supported
+	// AzureConfigDir is an Azure CLI config directory shared with other
+	// tools; empty for one of the context's own
+	AzureConfigDir string `json:"azureConfigDir,omitempty"`
+}
+
+// AzureDir returns the Azure CLI config directory the context signs in with
+func (c Context) AzureDir() (string, error) {
+	if c.AzureConfigDir != "" {
+		return c.AzureConfigDir, nil
+	}
+	return c.ownAzureDir()
+}
+
+// ownAzureDir is where a context without AzureConfigDir keeps its sign-in
+func (c Context) ownAzureDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "contexts", c.Name, "azure"), nil
+}
+
+// contexts is the contents of the contexts file
+type contexts struct {
+	Current  string    `json:"current,omitempty"`
+	Contexts []Context `json:"contexts"`
+}
+
+// loadContexts reads the contexts file, which is shared by all contexts
+func loadContexts(c *contexts) error {
+	dir, err := config.Dir()
+	if err != nil {
+		return err
+	}
+	return loadFrom(dir, contextsFile, c)
+}
+
+func saveContexts(c contexts) error {
+	dir, err := config.Dir()
+	if err != nil {
+		return err
+	}
+	return saveTo(dir, contextsFile, c)
+}
+
+// Contexts returns the defined contexts in the order they were added and the
+// name of the current one, if any
+func Contexts() ([]Context, string, error) {
+	var c contexts
+	if err := loadContexts(&c); err != nil {
+		return nil, "", err
+	}
+	return c.Contexts, c.Current, nil
+}
+
+// FindContext returns the named context, or nil if there is none
+func FindContext(name string) (*Context, error) {
+	all, _, err := Contexts()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range all {
+		if c.Name == name {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// AddContext defines a context, replacing one of the same name
+func AddContext(ctx Context) error {
+	var c contexts
+	if err := loadContexts(&c); err != nil {
+		return err
+	}
+	replaced := false
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == ctx.Name {
+			c.Contexts[i], replaced = ctx, true
+		}
+	}
+	if !replaced {
+		c.Contexts = append(c.Contexts, ctx)
+	}
+	return saveContexts(c)
+}
+
+// UseContext makes the named context current; an empty name clears it
+func UseContext(name string) error {
+	var c contexts
+	if err := loadContexts(&c); err != nil {
+		return err
+	}
+	if name != "" {
+		found := false
+		for _, ctx := range c.Contexts {
+			found = found || ctx.Name == name
+		}
+		if !found {
+			return fmt.Errorf("no context named %q", name)
+		}
+	}
+	c.Current = name
+	return saveContexts(c)
+}
+
+// RemoveContext deletes a context with its state files and, unless it shares
+// another's, its Azure CLI sign-in, reporting whether it existed
+func RemoveContext(name string) (bool, error) {
+	var c contexts
+	if err := loadContexts(&c); err != nil {
+		return false, err
+	}
+	kept := make([]Context, 0, len(c.Contexts))
+	var removed *Context
+	for _, ctx := range c.Contexts {
+		if ctx.Name == name {
+			removed = &ctx
+			continue
+		}
+		kept = append(kept, ctx)
+	}
+	if removed == nil {
+		return false, nil
+	}
+	c.Contexts = kept
+	if c.Current == name {
+		c.Current = ""
+	}
+	if err := saveContexts(c); err != nil {
+		return true, err
+	}
+
+	dir, err := removed.ownAzureDir()
+	if err != nil {
+		return true, err
+	}
+	if err := os.RemoveAll(filepath.Dir(dir)); err != nil {
+		return true, fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+const deactivationsFile = "deactivations.json"
+
+// ScheduledDeactivation is an active role to deactivate before it expires,
+// requested with --auto-deactivate
+type ScheduledDeactivation struct {
+	ID string `json:"id"`
+	RoleRef
+	RoleDefinitionID string    `json:"roleDefinitionId"`
+	At               time.Time `json:"at"`
+	Expires          time.Time `json:"expires"` // when the activation ends anyway
+	LastError        string    `json:"lastError,omitempty"`
+}
+
+// Role returns the role to deactivate
+func (d ScheduledDeactivation) Role() azure.RoleAssignment {
+	return azure.RoleAssignment{RoleName: d.RoleName, Scope: d.Scope, ScopeName: d.ScopeName, RoleDefinitionID: d.RoleDefinitionID}
+}
+
+// ScheduledDeactivations returns the scheduled deactivations
+func ScheduledDeactivations() ([]ScheduledDeactivation, error) {
+	var scheduled []ScheduledDeactivation
+	if err := load(deactivationsFile, &scheduled); err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// ScheduleDeactivation schedules deactivating role at the given time,
+// replacing an earlier schedule for it
+func ScheduleDeactivation(role azure.RoleAssignment, at, expires time.Time) (ScheduledDeactivation, error) {
+	entry := ScheduledDeactivation{
+		ID:               uuid.NewString()[:8],
+		RoleRef:          Ref(role),
+		RoleDefinitionID: role.RoleDefinitionID,
+		At:               at,
+		Expires:          expires,
+	}
+	err := UpdateScheduledDeactivations(LockWait, func(scheduled []ScheduledDeactivation) ([]ScheduledDeactivation, error) {
+		return append(withoutDeactivation(scheduled, role), entry), nil
+	})
+	return entry, err
+}
+
+// UnscheduleDeactivation drops the scheduled deactivation of role, if any
+func UnscheduleDeactivation(role azure.RoleAssignment) error {
+	return UpdateScheduledDeactivations(LockWait, func(scheduled []ScheduledDeactivation) ([]ScheduledDeactivation, error) {
+		return withoutDeactivation(scheduled, role), nil
+	})
+}
+
+func withoutDeactivation(scheduled []ScheduledDeactivation, role azure.RoleAssignment) []ScheduledDeactivation {
+	kept := scheduled[:0]
+	for _, d := range scheduled {
+		if !d.Matches(role) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// UpdateScheduledDeactivations replaces the scheduled deactivations with what
+// change makes of them. No other hacktivator process can change them
+// meanwhile; wait is how long to wait for one that is, before failing with
+// ErrLocked. The result is saved even if change returns an error.
+func UpdateScheduledDeactivations(wait time.Duration, change func([]ScheduledDeactivation) ([]ScheduledDeactivation, error)) error {
+	return update(deactivationsFile, wait, change)
+}
@@ -0,0 +1,37 @@
+package state
+
+import "github.com/ica-js/hacktivator/internal/azure"
+
+const eligibleFile = "eligible.json"
+
+// EligibleCache is the result of the last eligibility scan in a tenant
+type EligibleCache struct {
+	azure.EligibleScan
+	// ScanKey identifies the subscriptions that were scanned, so a cache
+	// from a scan of other subscriptions isn't used
+	ScanKey string `json:"scanKey"`
+}
+
+// CachedEligible returns the cached eligibility scans by tenant ID
+func CachedEligible() (map[string]EligibleCache, error) {
+	caches := make(map[string]EligibleCache)
+	if err := load(eligibleFile, &caches); err != nil {
+		return nil, err
+	}
+	return caches, nil
+}
+
+// SaveEligible replaces the cached eligibility scan of a tenant
+func SaveEligible(tenant, scanKey string, scan *azure.EligibleScan) error {
+	caches, err := CachedEligible()
+	if err != nil {
+		return err
+	}
+	caches[tenant] = EligibleCache{EligibleScan: *scan, ScanKey: scanKey}
+	return save(eligibleFile, caches)
+}
+
+// ClearEligible removes the cached eligibility scans
+func ClearEligible() error {
+	return remove(eligibleFile)
+}
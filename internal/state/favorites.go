@@ -0,0 +1,44 @@
+package state
+
+const favoritesFile = "favorites.json"
+
+// Favorites returns the starred roles in the order they were added
+func Favorites() ([]RoleRef, error) {
+	var favorites []RoleRef
+	if err := load(favoritesFile, &favorites); err != nil {
+		return nil, err
+	}
+	return favorites, nil
+}
+
+// AddFavorite stars a role. Adding an existing favorite is a no-op.
+func AddFavorite(ref RoleRef) error {
+	favorites, err := Favorites()
+	if err != nil {
+		return err
+	}
+	for _, f := range favorites {
+		if f.same(ref) {
+			return nil
+		}
+	}
+	return save(favoritesFile, append(favorites, ref))
+}
+
+// RemoveFavorite unstars a role, reporting whether it was a favorite
+func RemoveFavorite(ref RoleRef) (bool, error) {
+	favorites, err := Favorites()
+	if err != nil {
+		return false, err
+	}
+	kept := make([]RoleRef, 0, len(favorites))
+	for _, f := range favorites {
+		if !f.same(ref) {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == len(favorites) {
+		return false, nil
+	}
+	return true, save(favoritesFile, kept)
+}
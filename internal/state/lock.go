@@ -0,0 +1,84 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked means another hacktivator process is changing the state file
+var ErrLocked = errors.New("locked by another hacktivator process")
+
+// LockWait is how long commands wait for another process to finish changing
+// a state file
+const LockWait = 30 * time.Second
+
+// staleLockAge is when a lock is assumed to be left behind by a process that
+// crashed. Flushing the queue or deactivating roles under the lock takes a
+// few requests, well below it.
+const staleLockAge = 10 * time.Minute
+
+// lock takes an advisory lock on a state file, for changes that read it
+// first, and returns the function that releases it. The lock is a file
+// created next to it, which works the same on every platform. It waits up to
+// wait for another process to release it, then fails with ErrLocked.
+func lock(name string, wait time.Duration) (func(), error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, name+".lock")
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%s is %w; if none is running, remove %s", name, ErrLocked, path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// update loads a JSON list from a state file, replaces it with what change
+// makes of it and saves it, all under the file's lock. The result is saved
+// even when change returns an error, which is returned; an empty list
+// removes the file.
+func update[E any](name string, wait time.Duration, change func([]E) ([]E, error)) error {
+	unlock, err := lock(name, wait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var list []E
+	if err := load(name, &list); err != nil {
+		return err
+	}
+	list, changeErr := change(list)
+	if len(list) == 0 {
+		err = remove(name)
+	} else {
+		err = save(name, list)
+	}
+	if err != nil {
+		return err
+	}
+	return changeErr
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+func TestConcurrentEnqueueKeepsEveryEntry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HACKTIVATOR_CONFIG_DIR", dir)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			role := azure.RoleAssignment{RoleName: "Reader", Scope: fmt.Sprintf("/subscriptions/%d", i)}
+			if err := Enqueue(azure.ActivationRequest{Role: role, Duration: 60}, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	queue, err := Queue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 20 {
+		t.Errorf("got %d queued activations, want 20", len(queue))
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "queue.json.*"))
+	if len(leftovers) > 0 {
+		t.Errorf("left behind %v", leftovers)
+	}
+}
+
+func TestLockedQueue(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HACKTIVATOR_CONFIG_DIR", dir)
+
+	unlock, err := lock(queueFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = UpdateQueue(0, func(q []QueuedActivation) ([]QueuedActivation, error) {
+		t.Error("changed the queue while it was locked")
+		return q, nil
+	})
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("got %v, want ErrLocked", err)
+	}
+	unlock()
+
+	// A lock left behind by a crashed process is taken over
+	path := filepath.Join(dir, queueFile+".lock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-staleLockAge - time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateQueue(0, func(q []QueuedActivation) ([]QueuedActivation, error) { return q, nil }); err != nil {
+		t.Errorf("stale lock: %v", err)
+	}
+}
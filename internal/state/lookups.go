@@ -0,0 +1,19 @@
+package state
+
+import "github.com/ica-js/hacktivator/internal/azure"
+
+const lookupsFile = "lookups.json"
+
+// CachedLookups returns the remembered policy and role definition lookups
+func CachedLookups() (azure.Lookups, error) {
+	var l azure.Lookups
+	if err := load(lookupsFile, &l); err != nil {
+		return azure.Lookups{}, err
+	}
+	return l, nil
+}
+
+// SaveLookups replaces the remembered lookups
+func SaveLookups(l azure.Lookups) error {
+	return save(lookupsFile, l)
+}
@@ -0,0 +1,89 @@
+package state
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+const pendingFile = "pending.json"
+
+// PendingRequest is an activation waiting for approval, kept so a later run
+// can report when it is approved or denied
+type PendingRequest struct {
+	RoleRef
+	RoleDefinitionID string    `json:"roleDefinitionId,omitempty"`
+	RequestID        string    `json:"requestId"`
+	Duration         int       `json:"duration"`
+	Justification    string    `json:"justification,omitempty"`
+	SubmittedAt      time.Time `json:"submittedAt"`
+	RemindedAt       time.Time `json:"remindedAt,omitzero"` // when approvers were last reminded
+}
+
+// Role returns the role the request is for, as far as it is kept
+func (p PendingRequest) Role() azure.RoleAssignment {
+	return azure.RoleAssignment{RoleName: p.RoleName, Scope: p.Scope, ScopeName: p.ScopeName, RoleDefinitionID: p.RoleDefinitionID}
+}
+
+// PendingRequests returns the tracked requests
+func PendingRequests() ([]PendingRequest, error) {
+	var pending []PendingRequest
+	if err := load(pendingFile, &pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// AddPendingRequest starts tracking a request that awaits approval
+func AddPendingRequest(role azure.RoleAssignment, requestID string, duration int, justification string) error {
+	pending, err := PendingRequests()
+	if err != nil {
+		return err
+	}
+	pending = append(pending, PendingRequest{
+		RoleRef:          Ref(role),
+		RoleDefinitionID: role.RoleDefinitionID,
+		RequestID:        requestID,
+		Duration:         duration,
+		Justification:    justification,
+		SubmittedAt:      time.Now(),
+	})
+	return save(pendingFile, pending)
+}
+
+// MarkPendingReminded records that the approvers of the given request IDs
+// were reminded now
+func MarkPendingReminded(requestIDs ...string) error {
+	pending, err := PendingRequests()
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		for _, id := range requestIDs {
+			if strings.EqualFold(pending[i].RequestID, id) {
+				pending[i].RemindedAt = time.Now()
+			}
+		}
+	}
+	return save(pendingFile, pending)
+}
+
+// RemovePendingRequests stops tracking the given request IDs
+func RemovePendingRequests(requestIDs ...string) error {
+	pending, err := PendingRequests()
+	if err != nil {
+		return err
+	}
+	kept := pending[:0]
+	for _, p := range pending {
+		remove := false
+		for _, id := range requestIDs {
+			remove = remove || strings.EqualFold(p.RequestID, id)
+		}
+		if !remove {
+			kept = append(kept, p)
+		}
+	}
+	return save(pendingFile, kept)
+}
@@ -0,0 +1,83 @@
+package state
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+const queueFile = "queue.json"
+
+// QueuedActivation is an activation that could not be sent because Azure
+// was unreachable, kept to be retried once the network is back
+type QueuedActivation struct {
+	ID            string               `json:"id"`
+	Role          azure.RoleAssignment `json:"role"`
+	Duration      int                  `json:"duration"`
+	Justification string               `json:"justification,omitempty"`
+	TicketNumber  string               `json:"ticketNumber,omitempty"`
+	TicketSystem  string               `json:"ticketSystem,omitempty"`
+	RequestID     string               `json:"requestId,omitempty"` // the ID the failed attempt was sent under
+	QueuedAt      time.Time            `json:"queuedAt"`
+	Attempts      int                  `json:"attempts"`
+	LastError     string               `json:"lastError,omitempty"`
+}
+
+// Request returns the activation request to retry
+func (q QueuedActivation) Request() azure.ActivationRequest {
+	return azure.ActivationRequest{
+		Role:          q.Role,
+		Duration:      q.Duration,
+		Justification: q.Justification,
+		TicketNumber:  q.TicketNumber,
+		TicketSystem:  q.TicketSystem,
+		RequestID:     q.RequestID,
+	}
+}
+
+// Queue returns the queued activations, oldest first
+func Queue() ([]QueuedActivation, error) {
+	var queue []QueuedActivation
+	if err := load(queueFile, &queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// Enqueue adds an activation to retry later. A role that is already queued
+// is replaced, so retrying a command doesn't activate it twice.
+func Enqueue(req azure.ActivationRequest, lastErr error) error {
+	entry := QueuedActivation{
+		ID:            uuid.NewString()[:8],
+		Role:          req.Role,
+		Duration:      req.Duration,
+		Justification: req.Justification,
+		TicketNumber:  req.TicketNumber,
+		TicketSystem:  req.TicketSystem,
+		RequestID:     req.RequestID,
+		QueuedAt:      time.Now(),
+		Attempts:      1,
+	}
+	if lastErr != nil {
+		entry.LastError = lastErr.Error()
+	}
+	return UpdateQueue(LockWait, func(queue []QueuedActivation) ([]QueuedActivation, error) {
+		kept := queue[:0]
+		for _, q := range queue {
+			if !Ref(q.Role).Matches(req.Role) {
+				kept = append(kept, q)
+			}
+		}
+		return append(kept, entry), nil
+	})
+}
+
+// UpdateQueue replaces the queue with what change makes of it, e.g. after
+// retrying it. No other hacktivator process can change it meanwhile; wait is
+// how long to wait for one that is, before failing with ErrLocked. The result
+// is saved even if change returns an error.
+func UpdateQueue(wait time.Duration, change func([]QueuedActivation) ([]QueuedActivation, error)) error {
+	return update(queueFile, wait, change)
+}
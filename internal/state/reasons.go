@@ -0,0 +1,39 @@
+package state
+
+import "strings"
+
+const reasonsFile = "reasons.json"
+
+// RecentReasons returns previously used justifications, most recent first
+func RecentReasons() ([]string, error) {
+	var reasons []string
+	if err := load(reasonsFile, &reasons); err != nil {
+		return nil, err
+	}
+	return reasons, nil
+}
+
+// RecordReason moves reason to the front of the history, keeping at most limit entries
+func RecordReason(reason string, limit int) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || limit <= 0 {
+		return nil
+	}
+
+	reasons, err := RecentReasons()
+	if err != nil {
+		return err
+	}
+
+	updated := []string{reason}
+	for _, r := range reasons {
+		if r != reason {
+			updated = append(updated, r)
+		}
+	}
+	if len(updated) > limit {
+		updated = updated[:limit]
+	}
+
+	return save(reasonsFile, updated)
+}
@@ -0,0 +1,19 @@
+package state
+
+import "github.com/ica-js/hacktivator/internal/azure"
+
+const requestKeysFile = "request-keys.json"
+
+// RequestKeys returns the IDs of recent activation requests
+func RequestKeys() (map[string]azure.RequestKey, error) {
+	keys := make(map[string]azure.RequestKey)
+	if err := load(requestKeysFile, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SaveRequestKeys replaces the IDs of recent activation requests
+func SaveRequestKeys(keys map[string]azure.RequestKey) error {
+	return save(requestKeysFile, keys)
+}
@@ -0,0 +1,29 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// RoleRef identifies a role on a scope. Eligibility IDs change when
+// assignments are renewed, so roles are remembered by name and scope instead.
+type RoleRef struct {
+	RoleName  string `json:"roleName"`
+	Scope     string `json:"scope"`
+	ScopeName string `json:"scopeName,omitempty"`
+}
+
+// Ref returns the reference for a role assignment
+func Ref(role azure.RoleAssignment) RoleRef {
+	return RoleRef{RoleName: role.RoleName, Scope: role.Scope, ScopeName: role.ScopeName}
+}
+
+// Matches reports whether ref refers to the given role assignment
+func (r RoleRef) Matches(role azure.RoleAssignment) bool {
+	return r.same(Ref(role))
+}
+
+func (r RoleRef) same(other RoleRef) bool {
+	return strings.EqualFold(r.RoleName, other.RoleName) && strings.EqualFold(r.Scope, other.Scope)
+}
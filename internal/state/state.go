@@ -0,0 +1,108 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ica-js/hacktivator/internal/config"
+)
+
+// currentContext is the context whose state files are used; empty for the
+// files of the Azure CLI's own sign-in
+var currentContext string
+
+// SetContext keeps the state files of a context, such as its caches,
+// favorites and history, apart from those of every other context
+func SetContext(name string) {
+	currentContext = name
+}
+
+// Dir returns the directory of the state files: the config directory, or a
+// directory of the current context's own below it
+func Dir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil || currentContext == "" {
+		return dir, err
+	}
+	return filepath.Join(dir, "contexts", currentContext), nil
+}
+
+// load reads a JSON state file into v. A missing file leaves v untouched.
+func load(name string, v any) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	return loadFrom(dir, name, v)
+}
+
+// loadFrom reads a JSON state file in dir into v
+func loadFrom(dir, name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// save writes v as JSON to a state file, creating the directory if needed
+func save(name string, v any) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	return saveTo(dir, name, v)
+}
+
+// saveTo writes v as JSON to a state file in dir
+func saveTo(dir, name string, v any) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated
+	// file; the temp file is unique so concurrent saves can't mix
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// remove deletes a state file; a missing file is not an error
+func remove(name string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	return nil
+}
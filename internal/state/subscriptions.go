@@ -0,0 +1,39 @@
+package state
+
+import (
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+const subscriptionsFile = "subscriptions.json"
+
+// SubscriptionCache is the subscription list of one tenant
+type SubscriptionCache struct {
+	Subscriptions []azure.Subscription `json:"subscriptions"`
+	FetchedAt     time.Time            `json:"fetchedAt"`
+}
+
+// CachedSubscriptions returns the cached subscription lists by tenant ID
+func CachedSubscriptions() (map[string]SubscriptionCache, error) {
+	caches := make(map[string]SubscriptionCache)
+	if err := load(subscriptionsFile, &caches); err != nil {
+		return nil, err
+	}
+	return caches, nil
+}
+
+// SaveSubscriptions replaces the cached subscription list of a tenant
+func SaveSubscriptions(tenant string, subs []azure.Subscription) error {
+	caches, err := CachedSubscriptions()
+	if err != nil {
+		return err
+	}
+	caches[tenant] = SubscriptionCache{Subscriptions: subs, FetchedAt: time.Now()}
+	return save(subscriptionsFile, caches)
+}
+
+// ClearSubscriptions removes the cached subscription lists
+func ClearSubscriptions() error {
+	return remove(subscriptionsFile)
+}
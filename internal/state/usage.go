@@ -0,0 +1,45 @@
+package state
+
+import (
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+const usageFile = "usage.json"
+
+// Usage counts how often and how recently a role was activated
+type Usage struct {
+	RoleRef
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// RoleUsage returns the recorded activation history
+func RoleUsage() ([]Usage, error) {
+	var usage []Usage
+	if err := load(usageFile, &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// RecordActivation bumps the activation count and timestamp for a role
+func RecordActivation(role azure.RoleAssignment) error {
+	usage, err := RoleUsage()
+	if err != nil {
+		return err
+	}
+
+	ref := Ref(role)
+	now := time.Now().UTC()
+	for i := range usage {
+		if usage[i].same(ref) {
+			usage[i].Count++
+			usage[i].LastUsed = now
+			usage[i].ScopeName = ref.ScopeName
+			return save(usageFile, usage)
+		}
+	}
+	return save(usageFile, append(usage, Usage{RoleRef: ref, Count: 1, LastUsed: now}))
+}
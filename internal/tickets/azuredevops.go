@@ -0,0 +1,68 @@
+package tickets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// azureDevOps looks up work items through the Azure CLI azure-devops extension
+type azureDevOps struct {
+	organization string
+	project      string
+}
+
+// closedStates are the default terminal states across the built-in process templates
+var closedStates = map[string]bool{
+	"closed":   true,
+	"done":     true,
+	"removed":  true,
+	"resolved": true,
+}
+
+// workItemMissing is the error code of az boards for a work item that
+// doesn't exist, or that the user can't read, Azure DevOps' 404
+const workItemMissing = "TF401232"
+
+func workItemToTicket(w azure.WorkItem) Ticket {
+	return Ticket{
+		Number: strconv.Itoa(w.ID),
+		Title:  w.Title,
+		Status: w.State,
+		Open:   !closedStates[strings.ToLower(w.State)],
+	}
+}
+
+func (a *azureDevOps) System() string { return "Azure DevOps" }
+
+func (a *azureDevOps) Get(number string) (*Ticket, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(number, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid work item ID %q", number)
+	}
+
+	item, err := azure.GetWorkItem(a.organization, id)
+	if err != nil && strings.Contains(err.Error(), workItemMissing) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := workItemToTicket(*item)
+	return &t, nil
+}
+
+func (a *azureDevOps) ListAssigned() ([]Ticket, error) {
+	items, err := azure.GetMyActiveWorkItems(a.organization, a.project)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := make([]Ticket, 0, len(items))
+	for _, item := range items {
+		tickets = append(tickets, workItemToTicket(item))
+	}
+	return tickets, nil
+}
@@ -0,0 +1,63 @@
+package tickets
+
+import (
+	"net/url"
+)
+
+// jira talks to the Jira REST API v2
+type jira struct {
+	*client
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		Status  struct {
+			Name           string `json:"name"`
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"status"`
+	} `json:"fields"`
+}
+
+func (i jiraIssue) toTicket() Ticket {
+	return Ticket{
+		Number: i.Key,
+		Title:  i.Fields.Summary,
+		Status: i.Fields.Status.Name,
+		Open:   i.Fields.Status.StatusCategory.Key != "done",
+	}
+}
+
+func (j *jira) System() string { return "Jira" }
+
+func (j *jira) Get(number string) (*Ticket, error) {
+	var issue jiraIssue
+	if err := j.getJSON("/rest/api/2/issue/"+url.PathEscape(number)+"?fields=summary,status", &issue); err != nil {
+		return nil, err
+	}
+	t := issue.toTicket()
+	return &t, nil
+}
+
+func (j *jira) ListAssigned() ([]Ticket, error) {
+	query := url.Values{}
+	query.Set("jql", "assignee = currentUser() AND statusCategory != Done ORDER BY updated DESC")
+	query.Set("fields", "summary,status")
+	query.Set("maxResults", "50")
+
+	var response struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	if err := j.getJSON("/rest/api/2/search?"+query.Encode(), &response); err != nil {
+		return nil, err
+	}
+
+	tickets := make([]Ticket, 0, len(response.Issues))
+	for _, issue := range response.Issues {
+		tickets = append(tickets, issue.toTicket())
+	}
+	return tickets, nil
+}
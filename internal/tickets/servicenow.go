@@ -0,0 +1,79 @@
+package tickets
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// serviceNow talks to the ServiceNow Table API
+type serviceNow struct {
+	*client
+	table string
+}
+
+type serviceNowRecord struct {
+	Number           string `json:"number"`
+	ShortDescription string `json:"short_description"`
+	State            string `json:"state"`
+	Active           string `json:"active"`
+}
+
+func (r serviceNowRecord) toTicket() Ticket {
+	return Ticket{
+		Number: r.Number,
+		Title:  r.ShortDescription,
+		Status: r.State,
+		Open:   r.Active == "true",
+	}
+}
+
+func (s *serviceNow) System() string { return "ServiceNow" }
+
+func (s *serviceNow) query(sysparmQuery string, limit string) ([]serviceNowRecord, error) {
+	query := url.Values{}
+	query.Set("sysparm_query", sysparmQuery)
+	query.Set("sysparm_fields", "number,short_description,state,active")
+	query.Set("sysparm_display_value", "true")
+	query.Set("sysparm_limit", limit)
+
+	var response struct {
+		Result []serviceNowRecord `json:"result"`
+	}
+	if err := s.getJSON("/api/now/table/"+url.PathEscape(s.table)+"?"+query.Encode(), &response); err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+// serviceNowNumber is a record number like INC0012345. Anything else could
+// add conditions to the encoded query, e.g. X^ORnumber!= matching any record.
+var serviceNowNumber = regexp.MustCompile(`^[A-Z]+[0-9]+$`)
+
+func (s *serviceNow) Get(number string) (*Ticket, error) {
+	if !serviceNowNumber.MatchString(number) {
+		return nil, fmt.Errorf("invalid ServiceNow number %q, use one like INC0012345", number)
+	}
+	records, err := s.query("number="+number, "1")
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || records[0].Number != number {
+		return nil, ErrNotFound
+	}
+	t := records[0].toTicket()
+	return &t, nil
+}
+
+func (s *serviceNow) ListAssigned() ([]Ticket, error) {
+	records, err := s.query("assigned_to=javascript:gs.getUserID()^active=true^ORDERBYDESCsys_updated_on", "50")
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := make([]Ticket, 0, len(records))
+	for _, r := range records {
+		tickets = append(tickets, r.toTicket())
+	}
+	return tickets, nil
+}
@@ -0,0 +1,41 @@
+package tickets
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServiceNowGet(t *testing.T) {
+	// The instance returns its first record whatever is asked, like a query
+	// that an injected ^OR condition made match everything
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("sysparm_query"))
+		json.NewEncoder(w).Encode(map[string]any{"result": []serviceNowRecord{
+			{Number: "INC0000001", ShortDescription: "Outage", State: "In Progress", Active: "true"},
+		}})
+	}))
+	defer srv.Close()
+	s := &serviceNow{client: &client{baseURL: srv.URL, http: srv.Client()}, table: "incident"}
+
+	ticket, err := s.Get("INC0000001")
+	if err != nil || ticket.Number != "INC0000001" || !ticket.Open {
+		t.Errorf("Get(INC0000001) = %+v, %v", ticket, err)
+	}
+	if _, err := s.Get("INC0000002"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("another record was accepted: %v", err)
+	}
+
+	queries = nil
+	for _, number := range []string{"X^ORnumber!=", "INC1^active=true", "inc0000001", ""} {
+		if _, err := s.Get(number); err == nil {
+			t.Errorf("Get(%q) succeeded", number)
+		}
+	}
+	if len(queries) > 0 {
+		t.Errorf("sent queries %q for invalid numbers", queries)
+	}
+}
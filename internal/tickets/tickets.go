@@ -0,0 +1,117 @@
+package tickets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/secrets"
+)
+
+// Ticket is a work item from an external ticket system
+type Ticket struct {
+	Number string
+	Title  string
+	Status string
+	Open   bool
+}
+
+// Provider looks up tickets in an external ticket system
+type Provider interface {
+	// System returns the ticket system name sent with activation requests
+	System() string
+	// Get fetches a single ticket by number
+	Get(number string) (*Ticket, error)
+	// ListAssigned returns open tickets assigned to the configured user
+	ListAssigned() ([]Ticket, error)
+}
+
+// NewProvider builds the provider selected in config, or nil if none is configured
+func NewProvider(cfg config.Tickets) (Provider, error) {
+	if cfg.Provider == "" {
+		return nil, nil
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("tickets.url must be set for provider %q", cfg.Provider)
+	}
+
+	if strings.EqualFold(cfg.Provider, "azuredevops") {
+		return &azureDevOps{organization: cfg.URL, project: cfg.Project}, nil
+	}
+
+	token, err := secrets.Resolve(cfg.TokenSecret, os.Getenv(cfg.TokenEnv))
+	if err != nil {
+		return nil, fmt.Errorf("tickets.token_secret: %w", err)
+	}
+
+	c := &client{
+		baseURL:  strings.TrimRight(cfg.URL, "/"),
+		username: cfg.Username,
+		token:    token,
+		http:     &http.Client{Timeout: 15 * time.Second},
+	}
+
+	switch strings.ToLower(cfg.Provider) {
+	case "jira":
+		return &jira{client: c}, nil
+	case "servicenow":
+		table := cfg.Table
+		if table == "" {
+			table = "incident"
+		}
+		return &serviceNow{client: c, table: table}, nil
+	default:
+		return nil, fmt.Errorf("unknown ticket provider %q (supported: jira, servicenow, azuredevops)", cfg.Provider)
+	}
+}
+
+// ErrNotFound is returned when a ticket does not exist
+var ErrNotFound = fmt.Errorf("ticket not found")
+
+// client is a minimal authenticated JSON REST client
+type client struct {
+	baseURL  string
+	username string
+	token    string
+	http     *http.Client
+}
+
+func (c *client) getJSON(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.token)
+	} else if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("request to %s failed: %s: %s", c.baseURL, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
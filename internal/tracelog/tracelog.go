@@ -0,0 +1,197 @@
+// Package tracelog writes the exact az commands and REST requests made by a
+// run, with their responses, as JSON lines to a file or file descriptor, so
+// support teams can collect a full trace without touching stdout. Access
+// tokens, secrets and JWTs are redacted before anything is written.
+package tracelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one traced az command or HTTP request
+type Record struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"` // az, http, or run for the summary of a run
+	Args       []string  `json:"args,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Request    string    `json:"request,omitempty"` // request body
+	Status     int       `json:"status,omitempty"`
+	ExitCode   int       `json:"exitCode,omitempty"`
+	Version    string    `json:"version,omitempty"`  // hacktivator version, on run records
+	Response   string    `json:"response,omitempty"` // response body, or stdout of az
+	Stderr     string    `json:"stderr,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var (
+	mu      sync.Mutex
+	outputs []io.Writer
+	closers []io.Closer
+)
+
+// Open starts tracing to the file descriptor fd if it is positive, or else
+// to path, which is appended to. Tracing stays off if neither is set.
+func Open(fd int, path string) error {
+	switch {
+	case fd > 0:
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+		if f == nil {
+			return fmt.Errorf("invalid trace file descriptor %d", fd)
+		}
+		add(f, nil)
+	case path != "":
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open trace file: %w", err)
+		}
+		add(f, f)
+	}
+	return nil
+}
+
+// OpenLastRun also traces to path, replacing the trace of the previous run,
+// so a report can include the run that went wrong
+func OpenLastRun(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	add(f, f)
+	return nil
+}
+
+func add(w io.Writer, c io.Closer) {
+	mu.Lock()
+	defer mu.Unlock()
+	outputs = append(outputs, w)
+	if c != nil {
+		closers = append(closers, c)
+	}
+}
+
+// Close stops tracing and closes the trace files
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, c := range closers {
+		c.Close()
+	}
+	outputs, closers = nil, nil
+}
+
+// Enabled reports whether a trace is being written
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(outputs) > 0
+}
+
+// Run records how a run ended: its arguments, version, exit code and error
+func Run(args []string, version string, exitCode int, start time.Time, err error) {
+	if !Enabled() {
+		return
+	}
+	write(Record{Type: "run", Args: RedactArgs(args), Version: version, ExitCode: exitCode}, start, err)
+}
+
+// Az records an az invocation
+func Az(args []string, stdout, stderr string, exitCode int, start time.Time, err error) {
+	if !Enabled() {
+		return
+	}
+	write(Record{
+		Type:     "az",
+		Args:     RedactArgs(args),
+		ExitCode: exitCode,
+		Response: Redact(stdout),
+		Stderr:   Redact(stderr),
+	}, start, err)
+}
+
+// HTTP records a request and its response
+func HTTP(method, url string, body []byte, status int, response string, start time.Time, err error) {
+	if !Enabled() {
+		return
+	}
+	write(Record{
+		Type:     "http",
+		Method:   method,
+		URL:      url,
+		Request:  Redact(string(body)),
+		Status:   status,
+		Response: Redact(response),
+	}, start, err)
+}
+
+func write(r Record, start time.Time, err error) {
+	r.Time = start.UTC()
+	r.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		r.Error = Redact(err.Error())
+	}
+	line, jsonErr := json.Marshal(r)
+	if jsonErr != nil {
+		return
+	}
+
+	line = append(line, '\n')
+	mu.Lock()
+	defer mu.Unlock()
+	for _, out := range outputs {
+		out.Write(line)
+	}
+}
+
+// redacted replaces secrets in traces and reports
+const redacted = "REDACTED"
+
+var (
+	// JSON properties holding credentials, e.g. az account get-access-token output
+	secretFields = regexp.MustCompile(`(?i)("(?:access_?token|refresh_?token|id_?token|client_?secret|password|secret|federated_?token|assertion)"\s*:\s*)"[^"]*"`)
+	// Bearer tokens in headers and error messages
+	bearer = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+	// JWTs anywhere else
+	jwt = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// Redact removes access tokens and other credentials from s
+func Redact(s string) string {
+	s = secretFields.ReplaceAllString(s, `$1"`+redacted+`"`)
+	s = bearer.ReplaceAllString(s, "${1}"+redacted)
+	return jwt.ReplaceAllString(s, redacted)
+}
+
+// secretFlags are az arguments whose value is a credential
+var secretFlags = map[string]bool{
+	"-p": true, "--password": true, "--client-secret": true, "--federated-token": true,
+}
+
+// RedactArgs returns a copy of az arguments with credential values redacted
+func RedactArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case i > 0 && secretFlags[args[i-1]]:
+			out[i] = redacted
+		case strings.Contains(arg, "="):
+			name, _, _ := strings.Cut(arg, "=")
+			if secretFlags[name] {
+				out[i] = name + "=" + redacted
+				continue
+			}
+			out[i] = Redact(arg)
+		default:
+			out[i] = Redact(arg)
+		}
+	}
+	return out
+}
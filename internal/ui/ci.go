@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CIFormat is how progress and errors are logged in CI mode.
+type CIFormat int
+
+const (
+	// CINone is outside CI mode.
+	CINone CIFormat = iota
+	// CIPlain logs plain lines, for CI systems without log commands.
+	CIPlain
+	// CIGitHub uses GitHub Actions workflow commands.
+	CIGitHub
+	// CIAzurePipelines uses Azure Pipelines logging commands.
+	CIAzurePipelines
+)
+
+// CI is the CI log format; progress is logged to stderr, even in quiet mode,
+// so stdout stays machine-readable.
+var CI CIFormat
+
+// ciLog receives the CI progress log.
+var ciLog io.Writer = os.Stderr
+
+// DetectCI returns the log format of the CI system running the process.
+func DetectCI() CIFormat {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return CIGitHub
+	case strings.EqualFold(os.Getenv("TF_BUILD"), "true"):
+		return CIAzurePipelines
+	}
+	return CIPlain
+}
+
+// ciStep logs title as a collapsible group around fn.
+func ciStep[T any](title string, fn func() (T, error)) (T, error) {
+	switch CI {
+	case CIGitHub:
+		fmt.Fprintf(ciLog, "::group::%s\n", escapeWorkflowData(title))
+		defer fmt.Fprintln(ciLog, "::endgroup::")
+	case CIAzurePipelines:
+		fmt.Fprintf(ciLog, "##[group]%s\n", title)
+		defer fmt.Fprintln(ciLog, "##[endgroup]")
+	default:
+		fmt.Fprintf(ciLog, "%s...\n", title)
+	}
+	return fn()
+}
+
+// CIError formats an error so the CI system annotates the run with it.
+func CIError(msg string) string {
+	switch CI {
+	case CIGitHub:
+		return "::error title=hacktivator::" + escapeWorkflowData(msg)
+	case CIAzurePipelines:
+		return "##vso[task.logissue type=error]" + strings.ReplaceAll(msg, "\n", " ")
+	}
+	return "Error: " + msg
+}
+
+// CINotice formats an informational message the CI system highlights.
+func CINotice(msg string) string {
+	switch CI {
+	case CIGitHub:
+		return "::notice title=hacktivator::" + escapeWorkflowData(msg)
+	case CIAzurePipelines:
+		return "##[section]" + strings.ReplaceAll(msg, "\n", " ")
+	}
+	return msg
+}
+
+// escapeWorkflowData escapes a GitHub Actions workflow command's message so
+// that newlines don't end it.
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// Columns chooses and orders the columns of role tables by name, see
+// ColumnNames. JSON output keeps only the fields of these columns. Empty
+// means the default layout.
+var Columns []string
+
+// namedColumn is a role column that can be chosen with Columns
+type namedColumn struct {
+	column
+	// fields are the JSON fields the column is projected to
+	fields []string
+}
+
+// columnAliases are alternative names accepted for columns
+var columnAliases = map[string]string{
+	"expiry":       "expires",
+	"end":          "expires",
+	"start":        "started",
+	"sub":          "subscription",
+	"scope-type":   "type",
+	"max":          "max-duration",
+	"reason":       "justification",
+	"principal-id": "principal",
+}
+
+// ColumnNames returns the column names accepted by ParseColumns, sorted
+func ColumnNames() []string {
+	var names []string
+	for name := range namedColumns(false) {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseColumns parses a comma-separated list of column names, resolving
+// aliases. Names are case-insensitive.
+func ParseColumns(s string) ([]string, error) {
+	available := namedColumns(false)
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if alias, ok := columnAliases[name]; ok {
+			name = alias
+		}
+		if _, ok := available[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(ColumnNames(), ", "))
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no columns given (available: %s)", strings.Join(ColumnNames(), ", "))
+	}
+	return names, nil
+}
+
+// namedColumns returns every column that can be chosen. includeStatus tells
+// active roles from eligible ones, which changes the meaning of some.
+func namedColumns(includeStatus bool) map[string]namedColumn {
+	expires := "ELIGIBLE UNTIL"
+	status := "Eligible"
+	if includeStatus {
+		expires, status = "EXPIRES", "Active"
+	}
+	return map[string]namedColumn{
+		"role": {column{header: "ROLE", value: func(r azure.RoleAssignment) string { return r.RoleName }, flexible: true, minWidth: 12},
+			[]string{"roleName", "roleDefinitionId"}},
+		"scope": {column{header: "SCOPE", value: ScopeLabel, flexible: true, minWidth: 12},
+			[]string{"scopeName", "scope"}},
+		"scope-id": {column{header: "SCOPE ID", value: func(r azure.RoleAssignment) string { return r.Scope }, flexible: true, minWidth: 12},
+			[]string{"scope"}},
+		"type": {column{header: "TYPE", value: func(r azure.RoleAssignment) string { return r.ScopeType }},
+			[]string{"scopeType"}},
+		"subscription": {column{header: "SUBSCRIPTION", value: func(r azure.RoleAssignment) string {
+			if r.SubscriptionName != "" {
+				return r.SubscriptionName
+			}
+			return r.SubscriptionID
+		}, flexible: true, minWidth: 12},
+			[]string{"subscriptionName", "subscriptionId"}},
+		"member": {column{header: "MEMBER", value: memberLabel},
+			[]string{"memberType", "groupName"}},
+		"group": {column{header: "GROUP", value: func(r azure.RoleAssignment) string { return r.GroupName }, flexible: true, minWidth: 12},
+			[]string{"groupName"}},
+		"principal": {column{header: "PRINCIPAL", value: PrincipalName, flexible: true, minWidth: 12},
+			[]string{"principalId", "principalName", "principalType"}},
+		"status": {column{header: "STATUS", value: func(r azure.RoleAssignment) string {
+			if r.Status == "" {
+				return status
+			}
+			return r.Status
+		}},
+			[]string{"status"}},
+		"started": {column{header: "STARTED", value: func(r azure.RoleAssignment) string {
+			if r.StartDateTime.IsZero() {
+				return ""
+			}
+			return FormatTime(r.StartDateTime)
+		}},
+			[]string{"startDateTime", "startDateTimeLocal"}},
+		"expires": {column{header: expires, value: func(r azure.RoleAssignment) string {
+			if r.AutoDeactivateAt != nil {
+				return FormatTime(*r.AutoDeactivateAt) + " (auto-deactivate)"
+			}
+			if r.EndDateTime == nil {
+				return "permanent"
+			}
+			return FormatTime(*r.EndDateTime)
+		}},
+			[]string{"endDateTime", "endDateTimeLocal", "autoDeactivateAt"}},
+		"max-duration": {column{header: "MAX DURATION", value: func(r azure.RoleAssignment) string {
+			if r.MaxDuration <= 0 {
+				return ""
+			}
+			return FormatMinutes(r.MaxDuration)
+		}},
+			[]string{"maxDurationMinutes"}},
+		"justification": {column{header: "JUSTIFICATION", value: func(r azure.RoleAssignment) string {
+			if r.Activation == nil {
+				return ""
+			}
+			return r.Activation.Justification
+		}, flexible: true, minWidth: 12},
+			[]string{"activation"}},
+	}
+}
+
+// projectRolesJSON keeps only the identifying fields and those of Columns
+// in each rendered role
+func projectRolesJSON(out []roleJSON) ([]map[string]json.RawMessage, error) {
+	keep := map[string]bool{"schemaVersion": true, "id": true}
+	available := namedColumns(false)
+	for _, name := range Columns {
+		for _, f := range available[name].fields {
+			keep[f] = true
+		}
+	}
+
+	projected := make([]map[string]json.RawMessage, len(out))
+	for i, r := range out {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for k := range fields {
+			if !keep[k] {
+				delete(fields, k)
+			}
+		}
+		projected[i] = fields
+	}
+	return projected, nil
+}
@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// spreadsheetTime is how times are written to CSV and TSV, a format Excel
+// recognizes as a date without a time zone suffix
+const spreadsheetTime = "2006-01-02 15:04:05"
+
+// RenderRolesDelimited renders role assignments as CSV (comma ',') or TSV
+// (comma '\t') with a header row, for pasting into spreadsheets. Values are
+// never truncated and are quoted where needed. When includeStatus is true,
+// activation status columns are included.
+func RenderRolesDelimited(roles []azure.RoleAssignment, comma rune, includeStatus bool) (string, error) {
+	headers := []string{"Role", "Scope Name", "Scope", "Scope Type", "Subscription", "Subscription ID",
+		"Member Type", "Group"}
+	if includeStatus {
+		headers = append(headers, "Status", "Start", "End", "Justification")
+	} else {
+		headers = append(headers, "Eligible Until", "Max Duration (min)")
+	}
+	headers = append(headers, "Principal ID", "Role Definition ID")
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+	if err := w.Write(headers); err != nil {
+		return "", err
+	}
+	for _, r := range roles {
+		row := []string{r.RoleName, r.ScopeName, r.Scope, r.ScopeType, r.SubscriptionName, r.SubscriptionID,
+			r.MemberType, r.GroupName}
+		if includeStatus {
+			status := r.Status
+			if status == "" {
+				status = "Active"
+			}
+			justification := ""
+			if r.Activation != nil {
+				justification = r.Activation.Justification
+			}
+			row = append(row, status, spreadsheetTimeOf(r.StartDateTime), endTime(r.EndDateTime), justification)
+		} else {
+			maxDuration := ""
+			if r.MaxDuration > 0 {
+				maxDuration = strconv.Itoa(r.MaxDuration)
+			}
+			row = append(row, endTime(r.EndDateTime), maxDuration)
+		}
+		row = append(row, r.PrincipalID, r.RoleDefinitionID)
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return b.String(), w.Error()
+}
+
+func spreadsheetTimeOf(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return DisplayTime(t).Format(spreadsheetTime)
+}
+
+func endTime(t *time.Time) string {
+	if t == nil {
+		return "permanent"
+	}
+	return spreadsheetTimeOf(*t)
+}
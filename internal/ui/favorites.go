@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+)
+
+// loadFavorites returns the indexes of roles that are favorites
+func loadFavorites(roles []azure.RoleAssignment) map[int]bool {
+	refs, err := state.Favorites()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load favorites: %v\n", err)
+	}
+
+	favorites := make(map[int]bool)
+	for i, role := range roles {
+		for _, ref := range refs {
+			if ref.Matches(role) {
+				favorites[i] = true
+				break
+			}
+		}
+	}
+	return favorites
+}
+
+// toggleFavorite stars or unstars the item's role and returns a status message
+func toggleFavorite(item *roleItem) string {
+	ref := state.Ref(item.role)
+	if item.favorite {
+		if _, err := state.RemoveFavorite(ref); err != nil {
+			return ErrorStyle.Render("Failed to remove favorite: " + err.Error())
+		}
+		item.favorite = false
+		return SuccessStyle.Render("Removed " + item.role.RoleName + " from favorites")
+	}
+	if err := state.AddFavorite(ref); err != nil {
+		return ErrorStyle.Render("Failed to add favorite: " + err.Error())
+	}
+	item.favorite = true
+	return SuccessStyle.Render("Added " + item.role.RoleName + " to favorites")
+}
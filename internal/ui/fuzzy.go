@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/sahilm/fuzzy"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// Fields searched by the role filter, in the order they appear in
+// roleFilterValue. A match in an earlier field ranks higher.
+const (
+	fieldRoleName = iota
+	fieldScopeName
+	fieldSubscriptionID
+	fieldScopePath
+)
+
+var fieldBonus = []int{
+	fieldRoleName:       30,
+	fieldScopeName:      20,
+	fieldSubscriptionID: 10,
+	fieldScopePath:      0,
+}
+
+const fieldSeparator = "\n"
+
+// roleFilterValue packs the searchable fields of a role into one string
+func roleFilterValue(r azure.RoleAssignment) string {
+	return strings.Join([]string{
+		r.RoleName,
+		r.ScopeName,
+		azure.SubscriptionID(r.Scope),
+		r.Scope,
+	}, fieldSeparator)
+}
+
+// runeIndexes converts byte offsets reported by fuzzy into rune offsets
+func runeIndexes(s string, byteIndexes []int) []int {
+	out := make([]int, 0, len(byteIndexes))
+	for _, b := range byteIndexes {
+		out = append(out, utf8.RuneCountInString(s[:b]))
+	}
+	return out
+}
+
+// roleFilter ranks roles by their best fuzzy match across role name, scope
+// name, subscription ID and scope path. Matched indexes refer to the role
+// name, followed by a separator and the scope name, so roleDelegate can
+// highlight both lines.
+func roleFilter(term string, targets []string) []list.Rank {
+	type scored struct {
+		rank  list.Rank
+		score int
+	}
+
+	var results []scored
+	for i, target := range targets {
+		fields := strings.Split(target, fieldSeparator)
+
+		var best *scored
+		for f, field := range fields {
+			matches := fuzzy.Find(term, []string{field})
+			if len(matches) == 0 {
+				continue
+			}
+			score := matches[0].Score + fieldBonus[f]
+			if best != nil && score <= best.score {
+				continue
+			}
+
+			var highlight []int
+			switch f {
+			case fieldRoleName:
+				highlight = runeIndexes(field, matches[0].MatchedIndexes)
+			case fieldScopeName:
+				offset := utf8.RuneCountInString(fields[fieldRoleName]) + 1
+				for _, idx := range runeIndexes(field, matches[0].MatchedIndexes) {
+					highlight = append(highlight, idx+offset)
+				}
+			}
+			best = &scored{rank: list.Rank{Index: i, MatchedIndexes: highlight}, score: score}
+		}
+
+		if best != nil {
+			results = append(results, *best)
+		}
+	}
+
+	sort.SliceStable(results, func(a, b int) bool { return results[a].score > results[b].score })
+
+	ranks := make([]list.Rank, len(results))
+	for i, r := range results {
+		ranks[i] = r.rank
+	}
+	return ranks
+}
+
+// roleDelegate renders role items like list.DefaultDelegate, but highlights
+// filter matches in the scope name as well as the role name.
+type roleDelegate struct {
+	list.DefaultDelegate
+}
+
+func (d roleDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	i, ok := item.(roleItem)
+	if !ok || m.Width() <= 0 {
+		return
+	}
+	s := &d.Styles
+
+	title := i.Title()
+	desc := i.Description()
+
+	// Split matches between the two lines; the title may carry a marker prefix
+	var titleMatches, descMatches []int
+	filtering := m.FilterState() == list.Filtering || m.FilterState() == list.FilterApplied
+	if filtering && index < len(m.VisibleItems()) {
+		nameLen := utf8.RuneCountInString(i.role.RoleName)
+		prefix := utf8.RuneCountInString(title) - nameLen
+		for _, idx := range m.MatchesForItem(index) {
+			if idx < nameLen {
+				titleMatches = append(titleMatches, idx+prefix)
+			} else if idx > nameLen {
+				descMatches = append(descMatches, idx-nameLen-1)
+			}
+		}
+	}
+
+	textWidth := m.Width() - s.NormalTitle.GetPaddingLeft() - s.NormalTitle.GetPaddingRight()
+	title = ansi.Truncate(title, textWidth, "…")
+	desc = ansi.Truncate(desc, textWidth, "…")
+
+	highlight := func(text string, matches []int, base lipgloss.Style) string {
+		if len(matches) == 0 {
+			return text
+		}
+		unmatched := base.Inline(true)
+		return lipgloss.StyleRunes(text, matches, unmatched.Inherit(s.FilterMatch), unmatched)
+	}
+
+	isSelected := index == m.Index()
+	emptyFilter := m.FilterState() == list.Filtering && m.FilterValue() == ""
+
+	switch {
+	case emptyFilter:
+		title = s.DimmedTitle.Render(title)
+		desc = s.DimmedDesc.Render(desc)
+	case isSelected && m.FilterState() != list.Filtering:
+		title = s.SelectedTitle.Render(highlight(title, titleMatches, s.SelectedTitle))
+		desc = s.SelectedDesc.Render(highlight(desc, descMatches, s.SelectedDesc))
+	default:
+		title = s.NormalTitle.Render(highlight(title, titleMatches, s.NormalTitle))
+		desc = s.NormalDesc.Render(highlight(desc, descMatches, s.NormalDesc))
+	}
+
+	fmt.Fprintf(w, "%s\n%s", title, desc) //nolint: errcheck
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/schema"
+)
+
+// roleJSON is the machine-readable form of a role assignment. Values are
+// never truncated, unlike the table view.
+type roleJSON struct {
+	SchemaVersion    int        `json:"schemaVersion"`
+	ID               string     `json:"id"`
+	RoleDefinitionID string     `json:"roleDefinitionId"`
+	RoleName         string     `json:"roleName"`
+	Scope            string     `json:"scope"`
+	ScopeName        string     `json:"scopeName"`
+	ScopeType        string     `json:"scopeType"`
+	SubscriptionID   string     `json:"subscriptionId,omitempty"`
+	SubscriptionName string     `json:"subscriptionName,omitempty"`
+	PrincipalID      string     `json:"principalId"`
+	PrincipalName    string     `json:"principalName,omitempty"`
+	PrincipalType    string     `json:"principalType,omitempty"`
+	Status           string     `json:"status,omitempty"`
+	MemberType       string     `json:"memberType,omitempty"`
+	GroupName        string     `json:"groupName,omitempty"`
+	AssignmentType   string     `json:"assignmentType,omitempty"`
+	StartDateTime    *time.Time `json:"startDateTime,omitempty"`
+	EndDateTime      *time.Time `json:"endDateTime,omitempty"`
+	// The same times in the local time zone, or UTC with --utc
+	StartDateTimeLocal *time.Time      `json:"startDateTimeLocal,omitempty"`
+	EndDateTimeLocal   *time.Time      `json:"endDateTimeLocal,omitempty"`
+	AutoDeactivateAt   *time.Time      `json:"autoDeactivateAt,omitempty"`
+	MaxDuration        int             `json:"maxDurationMinutes,omitempty"`
+	EligibilityID      string          `json:"eligibilityId,omitempty"`
+	Activation         *activationJSON `json:"activation,omitempty"`
+}
+
+// activationJSON is the request that activated a role, see azure.AttachActivationDetails
+type activationJSON struct {
+	RequestID     string     `json:"requestId"`
+	RequestType   string     `json:"requestType,omitempty"`
+	Justification string     `json:"justification,omitempty"`
+	TicketNumber  string     `json:"ticketNumber,omitempty"`
+	TicketSystem  string     `json:"ticketSystem,omitempty"`
+	RequestedAt   *time.Time `json:"requestedAt,omitempty"`
+	Requester     string     `json:"requester,omitempty"`
+	Approver      string     `json:"approver,omitempty"`
+}
+
+func toRoleJSON(r azure.RoleAssignment) roleJSON {
+	out := roleJSON{
+		SchemaVersion:    schema.Version,
+		ID:               r.ID,
+		RoleDefinitionID: r.RoleDefinitionID,
+		RoleName:         r.RoleName,
+		Scope:            r.Scope,
+		ScopeName:        r.ScopeName,
+		ScopeType:        r.ScopeType,
+		SubscriptionID:   r.SubscriptionID,
+		SubscriptionName: r.SubscriptionName,
+		PrincipalID:      r.PrincipalID,
+		Status:           r.Status,
+		MemberType:       r.MemberType,
+		GroupName:        r.GroupName,
+		AssignmentType:   r.AssignmentType,
+		MaxDuration:      r.MaxDuration,
+		EligibilityID:    r.EligibilityID,
+	}
+	if r.ExpandedProperties != nil {
+		out.PrincipalName = r.ExpandedProperties.Principal.DisplayName
+		out.PrincipalType = r.ExpandedProperties.Principal.Type
+	}
+	if a := r.Activation; a != nil {
+		out.Activation = &activationJSON{
+			RequestID:     a.RequestID,
+			RequestType:   a.RequestType,
+			Justification: a.Justification,
+			TicketNumber:  a.TicketNumber,
+			TicketSystem:  a.TicketSystem,
+			Requester:     a.Requester,
+			Approver:      a.Approver,
+		}
+		if !a.RequestedAt.IsZero() {
+			requested := a.RequestedAt
+			out.Activation.RequestedAt = &requested
+		}
+	}
+	if !r.StartDateTime.IsZero() {
+		start, local := r.StartDateTime.UTC(), DisplayTime(r.StartDateTime)
+		out.StartDateTime, out.StartDateTimeLocal = &start, &local
+	}
+	if r.EndDateTime != nil {
+		end, local := r.EndDateTime.UTC(), DisplayTime(*r.EndDateTime)
+		out.EndDateTime, out.EndDateTimeLocal = &end, &local
+	}
+	if r.AutoDeactivateAt != nil {
+		at := r.AutoDeactivateAt.UTC()
+		out.AutoDeactivateAt = &at
+	}
+	return out
+}
+
+// RenderRolesJSON renders role assignments as an indented JSON array. With
+// Columns set, each role only has the fields of those columns besides its
+// schemaVersion and id.
+func RenderRolesJSON(roles []azure.RoleAssignment) (string, error) {
+	out := make([]roleJSON, len(roles))
+	for i, r := range roles {
+		out[i] = toRoleJSON(r)
+	}
+
+	var v any = out
+	if len(Columns) > 0 {
+		projected, err := projectRolesJSON(out)
+		if err != nil {
+			return "", err
+		}
+		v = projected
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/ica-js/hacktivator/internal/config"
+)
+
+// KeyMap holds the bindings shared by the interactive views.
+type KeyMap struct {
+	Select      key.Binding
+	Filter      key.Binding
+	Quit        key.Binding
+	MultiSelect key.Binding
+	Copy        key.Binding
+	OpenPortal  key.Binding
+	Favorite    key.Binding
+	Sort        key.Binding
+	Deactivate  key.Binding
+	Extend      key.Binding
+	Help        key.Binding
+}
+
+// Keys is the active key map, replaced by ApplyKeybindings.
+var Keys = DefaultKeyMap()
+
+// DefaultKeyMap returns the built-in bindings.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Select:      key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "select")),
+		Filter:      key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter")),
+		Quit:        key.NewBinding(key.WithKeys("esc", "q"), key.WithHelp("esc/q", "quit")),
+		MultiSelect: key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle multi-select")),
+		Copy:        key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy scope ID")),
+		OpenPortal:  key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open in portal")),
+		Favorite:    key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "toggle favorite")),
+		Sort:        key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "cycle sort order")),
+		Deactivate:  key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "deactivate")),
+		Extend:      key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "extend")),
+		Help:        key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "toggle help")),
+	}
+}
+
+// keyAliases maps friendly names used in config to bubbletea key strings
+var keyAliases = map[string]string{
+	"space":  " ",
+	"escape": "esc",
+	"return": "enter",
+}
+
+// rebind replaces the keys of b when keys is non-empty, keeping its description
+func rebind(b *key.Binding, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	normalized := make([]string, len(keys))
+	for i, k := range keys {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if alias, ok := keyAliases[k]; ok {
+			k = alias
+		}
+		normalized[i] = k
+	}
+	b.SetKeys(normalized...)
+	b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+}
+
+// ApplyKeybindings overrides the default bindings with those from config.
+func ApplyKeybindings(cfg config.Keybindings) {
+	k := DefaultKeyMap()
+	rebind(&k.Select, cfg.Select)
+	rebind(&k.Filter, cfg.Filter)
+	rebind(&k.Quit, cfg.Quit)
+	rebind(&k.MultiSelect, cfg.MultiSelect)
+	rebind(&k.Copy, cfg.Copy)
+	rebind(&k.OpenPortal, cfg.OpenPortal)
+	rebind(&k.Favorite, cfg.Favorite)
+	rebind(&k.Sort, cfg.Sort)
+	rebind(&k.Deactivate, cfg.Deactivate)
+	rebind(&k.Extend, cfg.Extend)
+	rebind(&k.Help, cfg.Help)
+	Keys = k
+}
+
+// renderHelpOverlay lists the given bindings in a bordered box.
+func renderHelpOverlay(bindings ...key.Binding) string {
+	keyWidth := 0
+	for _, b := range bindings {
+		keyWidth = max(keyWidth, len(b.Help().Key))
+	}
+
+	var b strings.Builder
+	b.WriteString(TitleStyle.Render("Key bindings") + "\n\n")
+	for _, binding := range bindings {
+		if !binding.Enabled() {
+			continue
+		}
+		help := binding.Help()
+		b.WriteString(PreviewLabelStyle.Render(help.Key+strings.Repeat(" ", keyWidth-len(help.Key))) +
+			"  " + PreviewValueStyle.Render(help.Desc) + "\n")
+	}
+	b.WriteString("\n" + SubtleStyle.Render("press "+Keys.Help.Help().Key+" to close"))
+
+	return lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(current.PreviewBorder).
+		Padding(1, 2).
+		Render(b.String())
+}
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// markdownEscaper keeps cell values from breaking a Markdown table row
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+
+// RenderRolesMarkdown renders role assignments as a Markdown table with the
+// same columns as the terminal table, for pasting into wikis and incident
+// documents. Values are never truncated.
+func RenderRolesMarkdown(roles []azure.RoleAssignment, includeStatus bool) string {
+	columns := roleColumns(includeStatus)
+	cells := tableCells(columns, roles)
+
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = markdownEscaper.Replace(col.header)
+	}
+
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(columns)) + "\n")
+	for _, row := range cells {
+		for i, v := range row {
+			row[i] = markdownEscaper.Replace(v)
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,28 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+// ReducedMotion replaces spinners with static progress messages and stops
+// cursors from blinking, for users sensitive to motion and for slow links
+// where every animation frame is redrawn over the network.
+var ReducedMotion bool
+
+// applyListMotion stops the filter cursor of a list from blinking in
+// reduced motion mode
+func applyListMotion(l *list.Model) {
+	if ReducedMotion {
+		l.FilterInput.Cursor.SetMode(cursor.CursorStatic)
+	}
+}
+
+// applyInputMotion stops a text input's cursor from blinking in reduced
+// motion mode
+func applyInputMotion(ti *textinput.Model) {
+	if ReducedMotion {
+		ti.Cursor.SetMode(cursor.CursorStatic)
+	}
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const doubleClickInterval = 400 * time.Millisecond
+
+// clickTracker detects double clicks on the same row
+type clickTracker struct {
+	at    time.Time
+	index int
+}
+
+// click records a click on index and reports whether it completes a double click
+func (c *clickTracker) click(index int) bool {
+	now := time.Now()
+	double := index == c.index && now.Sub(c.at) <= doubleClickInterval
+	if double {
+		*c = clickTracker{index: -1}
+	} else {
+		*c = clickTracker{at: now, index: index}
+	}
+	return double
+}
+
+// isLeftClick reports whether msg is a press of the left mouse button
+func isLeftClick(msg tea.MouseMsg) bool {
+	return msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft
+}
+
+// listItemAt returns the index into l.VisibleItems() of the item rendered at
+// row y of the list view, or -1 if y is not on an item.
+func listItemAt(l list.Model, itemHeight, itemSpacing, y int) int {
+	header := 0
+	if l.ShowTitle() || (l.ShowFilter() && l.FilteringEnabled()) {
+		header += 1 + l.Styles.TitleBar.GetVerticalFrameSize()
+	}
+	if l.ShowStatusBar() {
+		header += 1 + l.Styles.StatusBar.GetVerticalFrameSize()
+	}
+
+	rel := y - header
+	stride := itemHeight + itemSpacing
+	if rel < 0 || rel%stride >= itemHeight {
+		return -1
+	}
+
+	index := l.Paginator.Page*l.Paginator.PerPage + rel/stride
+	if index >= len(l.VisibleItems()) || rel/stride >= l.Paginator.PerPage {
+		return -1
+	}
+	return index
+}
+
+// cursorMarker is injected into a copy of a table to locate the cursor row on screen
+const cursorMarker = "§§"
+
+// tableRowAt returns the row rendered at line y of the table view, or -1.
+// The table keeps its scroll offset private, so the cursor row is located by
+// rendering a copy with a marker in it and counting lines from there.
+func tableRowAt(t table.Model, y int) int {
+	rows := t.Rows()
+	cursor := t.Cursor()
+	if cursor < 0 || cursor >= len(rows) {
+		return -1
+	}
+
+	marked := make([]table.Row, len(rows))
+	copy(marked, rows)
+	markedRow := append(table.Row{}, rows[cursor]...)
+	markedRow[0] = cursorMarker + markedRow[0]
+	marked[cursor] = markedRow
+
+	probe := t
+	probe.SetRows(marked)
+	lines := strings.Split(probe.View(), "\n")
+	header := len(lines) - t.Height()
+
+	for i, line := range lines {
+		if strings.Contains(ansi.Strip(line), cursorMarker) {
+			if y < header {
+				return -1
+			}
+			row := cursor + (y - i)
+			if row < 0 || row >= len(rows) {
+				return -1
+			}
+			return row
+		}
+	}
+	return -1
+}
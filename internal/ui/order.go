@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+)
+
+// SortMode is the order of roles in the selector. Favorites always come first.
+type SortMode int
+
+const (
+	SortRecent   SortMode = iota // most recently activated first
+	SortFrequent                 // most often activated first
+	SortDefault                  // the order returned by Azure
+)
+
+var sortModeNames = []string{
+	SortRecent:   "recent",
+	SortFrequent: "frequent",
+	SortDefault:  "default",
+}
+
+func (s SortMode) String() string { return sortModeNames[s] }
+
+// next returns the mode the sort key cycles to
+func (s SortMode) next() SortMode { return (s + 1) % SortMode(len(sortModeNames)) }
+
+// SelectorSort is the initial order of the role selector, set by ApplySort.
+var SelectorSort = SortRecent
+
+// ApplySort sets the initial selector order from config. An empty name keeps the default.
+func ApplySort(name string) error {
+	if name == "" {
+		return nil
+	}
+	for mode, n := range sortModeNames {
+		if strings.EqualFold(name, n) {
+			SelectorSort = SortMode(mode)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown selector_sort %q (available: %s)", name, strings.Join(sortModeNames, ", "))
+}
+
+// loadUsage returns the activation history, warning rather than failing if it can't be read
+func loadUsage() []state.Usage {
+	usage, err := state.RoleUsage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load activation history: %v\n", err)
+	}
+	return usage
+}
+
+// usageFor returns the recorded usage of an item's role, if any
+func usageFor(item roleItem, usage []state.Usage) state.Usage {
+	for _, u := range usage {
+		if u.Matches(item.role) {
+			return u
+		}
+	}
+	return state.Usage{}
+}
+
+// RankRoles returns roles in the order the selector lists them: favorites
+// first, then by SelectorSort.
+func RankRoles(roles []azure.RoleAssignment) []azure.RoleAssignment {
+	items := roleItems(roles, loadFavorites(roles), loadUsage())
+	ranked := make([]azure.RoleAssignment, len(items))
+	for i, item := range items {
+		ranked[i] = item.role
+	}
+	return ranked
+}
+
+// sortRoleItems orders items in place: favorites first, then by mode. Ties
+// keep the order returned by Azure.
+func sortRoleItems(items []roleItem, mode SortMode, usage []state.Usage) {
+	sort.SliceStable(items, func(a, b int) bool {
+		ia, ib := items[a], items[b]
+		if ia.favorite != ib.favorite {
+			return ia.favorite
+		}
+		ua, ub := usageFor(ia, usage), usageFor(ib, usage)
+		switch mode {
+		case SortRecent:
+			if !ua.LastUsed.Equal(ub.LastUsed) {
+				return ua.LastUsed.After(ub.LastUsed)
+			}
+		case SortFrequent:
+			if ua.Count != ub.Count {
+				return ua.Count > ub.Count
+			}
+		}
+		return ia.index < ib.index
+	})
+}
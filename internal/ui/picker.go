@@ -0,0 +1,248 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PickItem is a generic entry for Pick.
+type PickItem struct {
+	Title       string
+	Description string
+}
+
+func (i PickItem) FilterValue() string { return i.Title + " " + i.Description }
+
+// pickListItem adapts a PickItem to the list delegate, which reads the title
+// and description through methods.
+type pickListItem struct {
+	item   PickItem
+	index  int // position in the items passed to Pick
+	marked bool
+}
+
+func (i pickListItem) Title() string {
+	if i.marked {
+		return "✓ " + i.item.Title
+	}
+	return i.item.Title
+}
+func (i pickListItem) Description() string { return i.item.Description }
+func (i pickListItem) FilterValue() string { return i.item.FilterValue() }
+
+// ErrPickSkipped is returned by Pick when the user dismisses the list with esc.
+var ErrPickSkipped = fmt.Errorf("selection skipped")
+
+type pickerModel struct {
+	list      list.Model
+	selected  int
+	multi     bool         // PickMany: the multi-select key marks items
+	marked    map[int]bool // marked items by index, in multi mode
+	skipped   bool
+	cancelled bool
+	showHelp  bool
+	clicks    clickTracker
+	width     int
+	height    int
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.list.SetSize(msg.Width, msg.Height)
+		return m, nil
+
+	case tea.MouseMsg:
+		if m.showHelp {
+			return m, nil
+		}
+		switch {
+		case msg.Button == tea.MouseButtonWheelUp:
+			m.list.CursorUp()
+		case msg.Button == tea.MouseButtonWheelDown:
+			m.list.CursorDown()
+		case isLeftClick(msg):
+			delegate := newListDelegate()
+			index := listItemAt(m.list, delegate.Height(), delegate.Spacing(), msg.Y)
+			if index < 0 {
+				return m, nil
+			}
+			m.list.Select(index)
+			if m.clicks.click(index) {
+				m.selected = m.list.GlobalIndex()
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			m.cancelled = true
+			return m, tea.Quit
+		}
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		if m.showHelp {
+			if key.Matches(msg, Keys.Help, Keys.Quit) {
+				m.showHelp = false
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, Keys.Help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, Keys.Select):
+			m.selected = m.list.GlobalIndex()
+			return m, tea.Quit
+		case m.multi && key.Matches(msg, Keys.MultiSelect):
+			if item, ok := m.list.SelectedItem().(pickListItem); ok {
+				item.marked = !item.marked
+				m.marked[item.index] = item.marked
+				return m, m.list.SetItem(m.list.GlobalIndex(), item)
+			}
+			return m, nil
+		case key.Matches(msg, Keys.Quit):
+			if m.list.FilterState() == list.Unfiltered {
+				m.skipped = true
+				return m, tea.Quit
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m pickerModel) View() string {
+	if m.showHelp {
+		bindings := []key.Binding{Keys.Select, Keys.Filter, Keys.Quit, Keys.Help}
+		if m.multi {
+			bindings = append(bindings, Keys.MultiSelect)
+		}
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+			renderHelpOverlay(bindings...))
+	}
+	return m.list.View()
+}
+
+// Pick presents a fuzzy list of items and returns the index of the chosen one.
+// The quit key returns ErrPickSkipped so callers can treat the choice as optional.
+func Pick(title string, items []PickItem) (int, error) {
+	if len(items) == 0 {
+		return -1, fmt.Errorf("nothing to select")
+	}
+
+	if lineMode() {
+		labels := make([]string, len(items))
+		for i, item := range items {
+			labels[i] = item.Title
+			if item.Description != "" {
+				labels[i] += " (" + item.Description + ")"
+			}
+		}
+		return plainSelect(title, "item", labels, nil)
+	}
+
+	result, err := runPicker(title, items, false)
+	if err != nil {
+		return -1, err
+	}
+	if result.selected < 0 || result.selected >= len(items) {
+		return -1, fmt.Errorf("nothing selected")
+	}
+
+	return result.selected, nil
+}
+
+// PickMany presents a fuzzy list where several items can be marked with the
+// multi-select key, and returns the indexes of the marked items in order. If
+// none are marked, the item under the cursor is chosen. The quit key returns
+// ErrPickSkipped.
+func PickMany(title string, items []PickItem) ([]int, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("nothing to select")
+	}
+
+	if lineMode() {
+		labels := make([]string, len(items))
+		for i, item := range items {
+			labels[i] = item.Title
+			if item.Description != "" {
+				labels[i] += " (" + item.Description + ")"
+			}
+		}
+		return plainSelectMany(title, labels)
+	}
+
+	result, err := runPicker(title, items, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []int
+	for i := range items {
+		if result.marked[i] {
+			selected = append(selected, i)
+		}
+	}
+	if len(selected) == 0 {
+		if result.selected < 0 || result.selected >= len(items) {
+			return nil, fmt.Errorf("nothing selected")
+		}
+		selected = append(selected, result.selected)
+	}
+	return selected, nil
+}
+
+// runPicker runs the picker TUI and returns its final state. Cancellation
+// and the quit key are reported as errors.
+func runPicker(title string, items []PickItem, multi bool) (pickerModel, error) {
+	listItems := make([]list.Item, len(items))
+	for i, item := range items {
+		listItems[i] = pickListItem{item: item, index: i}
+	}
+
+	l := list.New(listItems, newListDelegate(), 0, 0)
+	l.Title = title
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = TitleStyle
+	applyListKeys(&l)
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		if multi {
+			return []key.Binding{Keys.MultiSelect, Keys.Help}
+		}
+		return []key.Binding{Keys.Help}
+	}
+
+	model := pickerModel{list: l, selected: -1, multi: multi, marked: make(map[int]bool)}
+	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	finalModel, err := runProgram(p)
+	if err != nil {
+		return pickerModel{}, fmt.Errorf("picker failed: %w", err)
+	}
+
+	result, ok := finalModel.(pickerModel)
+	if !ok {
+		return pickerModel{}, fmt.Errorf("unexpected model type")
+	}
+	if result.cancelled {
+		return pickerModel{}, fmt.Errorf("selection cancelled")
+	}
+	if result.skipped {
+		return pickerModel{}, ErrPickSkipped
+	}
+	return result, nil
+}
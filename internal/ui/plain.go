@@ -0,0 +1,207 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Plain replaces the full-screen TUIs with numbered lists and line-based
+// prompts that work with screen readers and dumb terminals.
+var Plain bool
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// lineMode reports whether prompts must be line-based: in plain mode, or when
+// stdin or stdout is not a terminal (IDE consoles, pipes) where the TUI misbehaves.
+func lineMode() bool {
+	return Plain || !isatty.IsTerminal(os.Stdout.Fd()) || !isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// readLine prints prompt and reads one line from stdin without the trailing newline
+func readLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := stdinReader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err == io.EOF {
+		return "", fmt.Errorf("no input available")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// plainSelect prints a numbered list and asks for a number until a valid one is entered.
+// If match is non-nil, answers that aren't numbers are resolved with it, allowing
+// selection by name. An empty answer returns ErrPickSkipped.
+func plainSelect(title, noun string, labels []string, match func(string) []int) (int, error) {
+	fmt.Println(title)
+	for i, label := range labels {
+		fmt.Printf("  %d) %s\n", i+1, label)
+	}
+
+	for {
+		answer, err := readLine(fmt.Sprintf("Select %s [1-%d]: ", noun, len(labels)))
+		if err != nil {
+			return -1, err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return -1, ErrPickSkipped
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(labels) {
+			return n - 1, nil
+		}
+		if match != nil && err != nil {
+			switch matches := match(answer); len(matches) {
+			case 1:
+				return matches[0], nil
+			case 0:
+				fmt.Printf("No %s matches %q.\n", noun, answer)
+			default:
+				fmt.Printf("%d entries match %q, enter a number instead.\n", len(matches), answer)
+			}
+			continue
+		}
+		fmt.Printf("Please enter a number between 1 and %d.\n", len(labels))
+	}
+}
+
+// plainSelectMany prints a numbered list and asks for one or more numbers,
+// separated by commas or spaces, with ranges like 3-5. An empty answer
+// returns ErrPickSkipped.
+func plainSelectMany(title string, labels []string) ([]int, error) {
+	fmt.Println(title)
+	for i, label := range labels {
+		fmt.Printf("  %d) %s\n", i+1, label)
+	}
+
+	for {
+		answer, err := readLine(fmt.Sprintf("Select one or more [1-%d], e.g. 1,3-5: ", len(labels)))
+		if err != nil {
+			return nil, err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return nil, ErrPickSkipped
+		}
+		if selected, ok := parseNumberList(answer, len(labels)); ok {
+			return selected, nil
+		}
+		fmt.Printf("Please enter numbers between 1 and %d.\n", len(labels))
+	}
+}
+
+// parseNumberList parses 1-based numbers and ranges into sorted, distinct
+// 0-based indexes below n
+func parseNumberList(answer string, n int) ([]int, bool) {
+	chosen := make([]bool, n)
+	fields := strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' })
+	for _, field := range fields {
+		from, to, isRange := strings.Cut(field, "-")
+		lo, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, false
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(to); err != nil {
+				return nil, false
+			}
+		}
+		if lo < 1 || hi > n || lo > hi {
+			return nil, false
+		}
+		for i := lo; i <= hi; i++ {
+			chosen[i-1] = true
+		}
+	}
+
+	var selected []int
+	for i, ok := range chosen {
+		if ok {
+			selected = append(selected, i)
+		}
+	}
+	return selected, len(selected) > 0
+}
+
+// plainPrompt asks for a line of text. Suggestions are listed by number and can
+// be reused by entering their number; an empty answer takes initial.
+func plainPrompt(prompt, initial string, suggestions []string, validate func(string) error) (string, error) {
+	if len(suggestions) > 0 {
+		fmt.Println("Recent reasons (enter a number to reuse):")
+		for i, s := range suggestions {
+			fmt.Printf("  %d) %s\n", i+1, s)
+		}
+	}
+	if initial != "" {
+		prompt = fmt.Sprintf("%s[%s] ", prompt, initial)
+	}
+
+	for {
+		answer, err := readLine(prompt)
+		if err != nil {
+			return "", err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = initial
+		} else if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(suggestions) {
+			answer = suggestions[n-1]
+		}
+
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Println(err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// Confirm asks a yes/no question on the terminal; anything but y or yes declines.
+func Confirm(question string) (bool, error) {
+	answer, err := readLine(question + " [y/N]: ")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ConfirmDefaultYes asks a yes/no question on the terminal; an empty answer,
+// y or yes accepts.
+func ConfirmDefaultYes(question string) (bool, error) {
+	answer, err := readLine(question + " [Y/n]: ")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ConfirmByTyping asks the user to type expected, in any case, to go ahead.
+func ConfirmByTyping(question, expected string) (bool, error) {
+	answer, err := readLine(question + ": ")
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(strings.TrimSpace(answer), expected), nil
+}
@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
+)
+
+// ActivationState is the progress of one request in TrackActivations.
+type ActivationState int
+
+const (
+	ActivationQueued ActivationState = iota
+	ActivationSubmitted
+	ActivationPending
+	ActivationProvisioned
+	ActivationFailed
+)
+
+var activationStateNames = []string{
+	ActivationQueued:      "queued",
+	ActivationSubmitted:   "submitted",
+	ActivationPending:     "pending approval",
+	ActivationProvisioned: "provisioned",
+	ActivationFailed:      "failed",
+}
+
+func (s ActivationState) String() string { return activationStateNames[s] }
+
+// ActivationUpdate reports that the request at Index moved to State.
+type ActivationUpdate struct {
+	Index  int
+	State  ActivationState
+	Detail string // e.g. the request ID or error message
+}
+
+type activationRow struct {
+	label  string
+	state  ActivationState
+	detail string
+}
+
+type trackerDoneMsg struct{}
+
+// trackerModel lists each request with its live status.
+type trackerModel struct {
+	spinner     spinner.Model
+	rows        []activationRow
+	done        bool
+	interrupted bool
+}
+
+func (m trackerModel) Init() tea.Cmd {
+	if ReducedMotion {
+		return nil
+	}
+	return m.spinner.Tick
+}
+
+func (m trackerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ActivationUpdate:
+		m.rows[msg.Index].state = msg.State
+		m.rows[msg.Index].detail = msg.Detail
+		return m, nil
+	case trackerDoneMsg:
+		m.done = true
+		return m, tea.Quit
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			m.interrupted = true
+			return m, tea.Quit
+		}
+	}
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+func (m trackerModel) View() string {
+	var b strings.Builder
+	for _, row := range m.rows {
+		b.WriteString(m.icon(row.state) + " " + row.label + "  " + stateStyle(row.state).Render(row.state.String()))
+		if row.detail != "" {
+			b.WriteString(SubtleStyle.Render(" (" + row.detail + ")"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (m trackerModel) icon(state ActivationState) string {
+	switch state {
+	case ActivationQueued:
+		return SubtleStyle.Render("·")
+	case ActivationSubmitted:
+		if ReducedMotion {
+			return SpinnerStyle.Render("›")
+		}
+		return m.spinner.View()
+	case ActivationPending:
+		return TitleStyle.Render("…")
+	case ActivationProvisioned:
+		return SuccessStyle.Render("✓")
+	default:
+		return ErrorStyle.Render("✗")
+	}
+}
+
+func stateStyle(state ActivationState) lipgloss.Style {
+	switch state {
+	case ActivationPending:
+		return TitleStyle
+	case ActivationProvisioned:
+		return SuccessStyle
+	case ActivationFailed:
+		return ErrorStyle
+	default:
+		return SubtleStyle
+	}
+}
+
+// TrackActivations shows a live status line per label while run submits the
+// requests. run reports progress through update, which is safe to call from
+// several goroutines. Without a terminal each update is printed as a line;
+// in quiet mode nothing is printed. In CI mode the lines go to stderr.
+func TrackActivations(labels []string, run func(update func(ActivationUpdate)), nonInteractive bool) error {
+	if Quiet && CI == CINone {
+		run(func(ActivationUpdate) {})
+		return nil
+	}
+
+	if CI != CINone || nonInteractive || Plain || !isatty.IsTerminal(os.Stdout.Fd()) {
+		out := io.Writer(os.Stdout)
+		if CI != CINone {
+			out = ciLog
+		}
+		var mu sync.Mutex
+		run(func(u ActivationUpdate) {
+			mu.Lock()
+			defer mu.Unlock()
+			line := fmt.Sprintf("%s: %s", labels[u.Index], u.State)
+			if u.Detail != "" {
+				line += " (" + u.Detail + ")"
+			}
+			fmt.Fprintln(out, line)
+		})
+		return nil
+	}
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = SpinnerStyle
+	rows := make([]activationRow, len(labels))
+	for i, label := range labels {
+		rows[i] = activationRow{label: label}
+	}
+
+	p := tea.NewProgram(trackerModel{spinner: s, rows: rows})
+	go func() {
+		run(func(u ActivationUpdate) { p.Send(u) })
+		p.Send(trackerDoneMsg{})
+	}()
+
+	finalModel, err := runProgram(p)
+	if err != nil {
+		return fmt.Errorf("status display failed: %w", err)
+	}
+	if m, ok := finalModel.(trackerModel); ok && m.interrupted {
+		return fmt.Errorf("interrupted, requests already submitted may still complete")
+	}
+	return nil
+}
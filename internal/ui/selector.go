@@ -1,77 +1,154 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/atotto/clipboard"
+
 	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/browser"
+	"github.com/ica-js/hacktivator/internal/state"
 )
 
 // --- Role selector (with preview pane) ---
 
 // roleItem implements list.Item for the role selector.
 type roleItem struct {
-	role azure.RoleAssignment
+	role     azure.RoleAssignment
+	index    int // position in the original roles slice
+	marked   bool
+	favorite bool
 }
 
-func (i roleItem) Title() string       { return i.role.RoleName }
-func (i roleItem) Description() string { return i.role.ScopeName }
-func (i roleItem) FilterValue() string {
-	return fmt.Sprintf("%s %s %s", i.role.RoleName, i.role.ScopeName, i.role.ScopeType)
+func (i roleItem) Title() string {
+	title := i.role.RoleName
+	if i.favorite {
+		title = "★ " + title
+	}
+	if i.marked {
+		title = "✓ " + title
+	}
+	return title
 }
+func (i roleItem) Description() string {
+	if via := ViaGroup(i.role); via != "" {
+		return ScopeLabel(i.role) + " · " + via
+	}
+	return ScopeLabel(i.role)
+}
+func (i roleItem) FilterValue() string { return roleFilterValue(i.role) }
 
 const minPreviewWidth = 60
 
 type selectorModel struct {
 	list        list.Model
 	viewport    viewport.Model
-	selected    *azure.RoleAssignment
+	roles       []azure.RoleAssignment
+	usage       []state.Usage
+	sort        SortMode
+	marked      map[int]bool
+	selected    []azure.RoleAssignment
 	cancelled   bool
+	showHelp    bool
+	clicks      clickTracker
+	itemHeight  int
+	itemSpacing int
 	width       int
 	height      int
 	showPreview bool
 }
 
-func newSelectorModel(roles []azure.RoleAssignment, title string) selectorModel {
-	items := make([]list.Item, len(roles))
-	for i, r := range roles {
-		items[i] = roleItem{role: r}
-	}
-
+// newListDelegate returns the list item delegate styled with the current theme
+func newListDelegate() list.DefaultDelegate {
 	delegate := list.NewDefaultDelegate()
 	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
-		Foreground(lipgloss.Color("5")).
-		BorderLeftForeground(lipgloss.Color("5"))
+		Foreground(current.Selection).
+		BorderLeftForeground(current.Selection)
 	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
-		Foreground(lipgloss.Color("8")).
-		BorderLeftForeground(lipgloss.Color("5"))
+		Foreground(current.Subtle).
+		BorderLeftForeground(current.Selection)
+	return delegate
+}
+
+// roleItems wraps roles as list items in the order given by SelectorSort
+func roleItems(roles []azure.RoleAssignment, favorites map[int]bool, usage []state.Usage) []roleItem {
+	items := make([]roleItem, len(roles))
+	for i, r := range roles {
+		items[i] = roleItem{role: r, index: i, favorite: favorites[i]}
+	}
+	sortRoleItems(items, SelectorSort, usage)
+	return items
+}
+
+func newSelectorModel(roles []azure.RoleAssignment, sorted []roleItem, usage []state.Usage, title string) selectorModel {
+	items := make([]list.Item, len(sorted))
+	for i, item := range sorted {
+		items[i] = item
+	}
 
+	delegate := roleDelegate{newListDelegate()}
 	l := list.New(items, delegate, 0, 0)
 	l.Title = title
+	l.Filter = roleFilter
 	l.SetShowStatusBar(true)
 	l.SetFilteringEnabled(true)
 	l.Styles.Title = TitleStyle
-	l.KeyMap.Quit.SetEnabled(false) // we handle quit ourselves
+	applyListKeys(&l)
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{Keys.MultiSelect, Keys.Favorite, Keys.Sort, Keys.Help}
+	}
 
 	vp := viewport.New(0, 0)
 
 	return selectorModel{
-		list:     l,
-		viewport: vp,
+		list:        l,
+		viewport:    vp,
+		roles:       roles,
+		usage:       usage,
+		sort:        SelectorSort,
+		marked:      make(map[int]bool),
+		itemHeight:  delegate.Height(),
+		itemSpacing: delegate.Spacing(),
 	}
 }
 
+// applyListKeys hands quit and help over to our own key map and applies the
+// configured filter key to a list.
+func applyListKeys(l *list.Model) {
+	l.KeyMap.Quit.SetEnabled(false) // we handle quit ourselves
+	l.KeyMap.ForceQuit.SetEnabled(false)
+	l.KeyMap.ShowFullHelp.SetEnabled(false)
+	l.KeyMap.CloseFullHelp.SetEnabled(false)
+	l.KeyMap.Filter = Keys.Filter
+	applyListMotion(l)
+}
+
+// hierarchyLoadedMsg reports that the management group tree was fetched
+type hierarchyLoadedMsg struct{}
+
 func (m selectorModel) Init() tea.Cmd {
-	return nil
+	// Resolve the management groups above each scope for the preview without
+	// holding up the list; a failure leaves the paths starting at the subscription
+	return func() tea.Msg {
+		_ = azure.LoadScopeHierarchy()
+		return hierarchyLoadedMsg{}
+	}
 }
 
 func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case hierarchyLoadedMsg:
+		m.updatePreview()
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -90,24 +167,85 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.updatePreview()
 		return m, nil
 
-	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
-			// Only select when not mid-filter-typing
-			if m.list.FilterState() != list.Filtering {
-				if item, ok := m.list.SelectedItem().(roleItem); ok {
-					m.selected = &item.role
-				}
+	case tea.MouseMsg:
+		if m.showHelp {
+			return m, nil
+		}
+		switch {
+		case msg.Button == tea.MouseButtonWheelUp:
+			m.list.CursorUp()
+		case msg.Button == tea.MouseButtonWheelDown:
+			m.list.CursorDown()
+		case isLeftClick(msg):
+			if m.showPreview && msg.X >= m.width*60/100 {
+				return m, nil
+			}
+			index := listItemAt(m.list, m.itemHeight, m.itemSpacing, msg.Y)
+			if index < 0 {
+				return m, nil
+			}
+			m.list.Select(index)
+			if m.clicks.click(index) {
+				m.selected = m.selection()
 				return m, tea.Quit
 			}
-		case tea.KeyCtrlC:
+		}
+		m.updatePreview()
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
 			m.cancelled = true
 			return m, tea.Quit
-		case tea.KeyEscape:
-			if m.list.FilterState() != list.Filtering {
-				m.cancelled = true
-				return m, tea.Quit
+		}
+		// While typing a filter every key belongs to the filter input
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		if m.showHelp {
+			if key.Matches(msg, Keys.Help, Keys.Quit) {
+				m.showHelp = false
 			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, Keys.Help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, Keys.Select):
+			m.selected = m.selection()
+			return m, tea.Quit
+		case key.Matches(msg, Keys.Quit):
+			m.cancelled = true
+			return m, tea.Quit
+		case key.Matches(msg, Keys.MultiSelect):
+			if item, ok := m.list.SelectedItem().(roleItem); ok {
+				item.marked = !item.marked
+				m.marked[item.index] = item.marked
+				cmd := m.list.SetItem(m.list.GlobalIndex(), item)
+				return m, cmd
+			}
+			return m, nil
+		case key.Matches(msg, Keys.Favorite):
+			if item, ok := m.list.SelectedItem().(roleItem); ok {
+				status := toggleFavorite(&item)
+				return m, tea.Batch(m.list.SetItem(m.list.GlobalIndex(), item), m.list.NewStatusMessage(status))
+			}
+			return m, nil
+		case key.Matches(msg, Keys.Sort):
+			m.sort = m.sort.next()
+			return m, tea.Batch(m.resort(), m.list.NewStatusMessage(SubtleStyle.Render("Sorted by "+m.sort.String())))
+		case key.Matches(msg, Keys.Copy):
+			if item, ok := m.list.SelectedItem().(roleItem); ok {
+				return m, m.list.NewStatusMessage(copyToClipboard(item.role.Scope))
+			}
+			return m, nil
+		case key.Matches(msg, Keys.OpenPortal):
+			if item, ok := m.list.SelectedItem().(roleItem); ok {
+				return m, m.list.NewStatusMessage(openPortal(item.role))
+			}
+			return m, nil
 		}
 	}
 
@@ -117,6 +255,42 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// resort reorders the list items by the current sort mode, keeping marks and favorites
+func (m *selectorModel) resort() tea.Cmd {
+	current := m.list.Items()
+	items := make([]roleItem, 0, len(current))
+	for _, it := range current {
+		if item, ok := it.(roleItem); ok {
+			items = append(items, item)
+		}
+	}
+	sortRoleItems(items, m.sort, m.usage)
+
+	sorted := make([]list.Item, len(items))
+	for i, item := range items {
+		sorted[i] = item
+	}
+	cmd := m.list.SetItems(sorted)
+	m.list.Select(0)
+	return cmd
+}
+
+// selection returns the marked roles in list order, or the role under the cursor if none are marked
+func (m selectorModel) selection() []azure.RoleAssignment {
+	var selected []azure.RoleAssignment
+	for i, r := range m.roles {
+		if m.marked[i] {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) == 0 {
+		if item, ok := m.list.SelectedItem().(roleItem); ok {
+			selected = append(selected, item.role)
+		}
+	}
+	return selected
+}
+
 func (m *selectorModel) updatePreview() {
 	if !m.showPreview {
 		return
@@ -139,10 +313,17 @@ func (m *selectorModel) updatePreview() {
 		{"Role ID", role.RoleDefinitionID},
 		{"Scope Type", role.ScopeType},
 		{"Scope Name", role.ScopeName},
+		{"Scope Path", azure.ScopeBreadcrumb(role)},
 		{"Scope ID", role.Scope},
 		{"Max Duration", fmt.Sprintf("%d minutes", role.MaxDuration)},
 		{"Assignment ID", role.EligibilityID},
 	}
+	if via := ViaGroup(role); via != "" {
+		fields = append(fields, struct{ label, value string }{"Granted", via})
+	}
+	if role.EndDateTime != nil {
+		fields = append(fields, struct{ label, value string }{"Eligible Until", FormatTime(*role.EndDateTime)})
+	}
 
 	labelWidth := 16 // 14 chars + 2 spaces
 	valueWidth := m.viewport.Width - labelWidth
@@ -160,10 +341,14 @@ func (m *selectorModel) updatePreview() {
 }
 
 func (m selectorModel) View() string {
+	if m.showHelp {
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+			renderHelpOverlay(Keys.Select, Keys.Filter, Keys.MultiSelect, Keys.Favorite, Keys.Sort, Keys.Copy, Keys.OpenPortal, Keys.Quit, Keys.Help))
+	}
 	if m.showPreview {
 		listView := m.list.View()
-		previewBox := lipgloss.NewStyle().
-			Width(m.width - m.width*60/100 - 2).
+		previewBox := PreviewBoxStyle.
+			Width(m.width - m.width*60/100 - 3).
 			Height(m.height).
 			Render(m.viewport.View())
 		return lipgloss.JoinHorizontal(lipgloss.Top, listView, previewBox)
@@ -171,26 +356,41 @@ func (m selectorModel) View() string {
 	return m.list.View()
 }
 
-// SelectRole presents an interactive fuzzy list for selecting an eligible role.
-func SelectRole(roles []azure.RoleAssignment, nonInteractive bool) (*azure.RoleAssignment, error) {
+// SelectRoles presents an interactive fuzzy list for selecting eligible roles.
+// Several roles can be marked with the multi-select key; otherwise the role
+// under the cursor is returned.
+func SelectRoles(roles []azure.RoleAssignment, title string, nonInteractive bool) ([]azure.RoleAssignment, error) {
 	if len(roles) == 0 {
 		return nil, fmt.Errorf("no eligible roles available")
 	}
 
 	if len(roles) == 1 {
-		fmt.Println(SuccessStyle.Render(
-			fmt.Sprintf("Auto-selecting the only eligible role: %s on %s", roles[0].RoleName, roles[0].ScopeName)))
-		return &roles[0], nil
+		if !Quiet {
+			fmt.Println(SuccessStyle.Render(
+				fmt.Sprintf("Auto-selecting the only eligible role: %s on %s", roles[0].RoleName, roles[0].ScopeName)))
+		}
+		return roles[:1], nil
 	}
 
 	if nonInteractive {
 		return nil, fmt.Errorf("multiple roles available but running in non-interactive mode")
 	}
 
-	m := newSelectorModel(roles, "Select role to activate")
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	usage := loadUsage()
+	items := roleItems(roles, loadFavorites(roles), usage)
+
+	if lineMode() {
+		role, err := selectRolePlain(items)
+		if err != nil {
+			return nil, err
+		}
+		return []azure.RoleAssignment{*role}, nil
+	}
+
+	m := newSelectorModel(roles, items, usage, title)
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
-	finalModel, err := p.Run()
+	finalModel, err := runProgram(p)
 	if err != nil {
 		return nil, fmt.Errorf("selector failed: %w", err)
 	}
@@ -202,10 +402,70 @@ func SelectRole(roles []azure.RoleAssignment, nonInteractive bool) (*azure.RoleA
 	if result.cancelled {
 		return nil, fmt.Errorf("selection cancelled")
 	}
-	if result.selected == nil {
+	if len(result.selected) == 0 {
 		return nil, fmt.Errorf("no role selected")
 	}
 
 	return result.selected, nil
 }
 
+// selectRolePlain asks for the role by number instead of running the TUI. The
+// answer may also be a role name, or "role on scope", which allows piping a
+// selection through stdin.
+func selectRolePlain(items []roleItem) (*azure.RoleAssignment, error) {
+	roles := make([]azure.RoleAssignment, len(items))
+	labels := make([]string, len(items))
+	for i, item := range items {
+		r := item.role
+		roles[i] = r
+		labels[i] = fmt.Sprintf("%s on %s (%s)", r.RoleName, r.ScopeName, r.ScopeType)
+		if via := ViaGroup(r); via != "" {
+			labels[i] += ", " + via
+		}
+	}
+
+	match := func(answer string) []int {
+		var matches []int
+		for i, r := range roles {
+			if strings.EqualFold(answer, r.RoleName) ||
+				strings.EqualFold(answer, r.RoleName+" on "+r.ScopeName) ||
+				strings.EqualFold(answer, labels[i]) {
+				matches = append(matches, i)
+			}
+		}
+		return matches
+	}
+
+	display := make([]string, len(labels))
+	for i, label := range labels {
+		if items[i].favorite {
+			label = "★ " + label
+		}
+		display[i] = label
+	}
+
+	idx, err := plainSelect("Eligible roles:", "role", display, match)
+	if errors.Is(err, ErrPickSkipped) {
+		return nil, fmt.Errorf("selection cancelled")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &roles[idx], nil
+}
+
+// copyToClipboard copies text and returns a status message describing the outcome
+func copyToClipboard(text string) string {
+	if err := clipboard.WriteAll(text); err != nil {
+		return ErrorStyle.Render("Copy failed: " + err.Error())
+	}
+	return SuccessStyle.Render("Copied " + text)
+}
+
+// openPortal opens the role's scope in the Azure portal and returns a status message
+func openPortal(role azure.RoleAssignment) string {
+	if err := browser.Open(azure.PortalURL(role)); err != nil {
+		return ErrorStyle.Render(err.Error())
+	}
+	return SuccessStyle.Render("Opened " + role.ScopeName + " in the portal")
+}
@@ -71,11 +71,22 @@ func (m spinnerModel) View() string {
 	return m.spinner.View() + " " + m.title + "\n"
 }
 
+// Quiet suppresses spinners, progress messages and other decorative output
+var Quiet bool
+
 // SpinWithResult runs fn in the background while showing a spinner with the
 // given title. If nonInteractive is true or stdout is not a TTY, it prints a
-// simple message and calls fn directly (no TUI).
+// simple message and calls fn directly (no TUI). The same happens in plain and
+// reduced motion modes. In quiet mode nothing is printed. In CI mode the
+// title is logged to stderr as a group.
 func SpinWithResult[T any](title string, fn func() (T, error), nonInteractive bool) (T, error) {
-	if nonInteractive || !isatty.IsTerminal(os.Stdout.Fd()) {
+	if CI != CINone {
+		return ciStep(title, fn)
+	}
+	if Quiet {
+		return fn()
+	}
+	if nonInteractive || Plain || ReducedMotion || !isatty.IsTerminal(os.Stdout.Fd()) {
 		fmt.Printf("%s...\n", title)
 		return fn()
 	}
@@ -86,7 +97,7 @@ func SpinWithResult[T any](title string, fn func() (T, error), nonInteractive bo
 
 	m := newSpinnerModel(title, wrapped)
 	p := tea.NewProgram(m)
-	finalModel, err := p.Run()
+	finalModel, err := runProgram(p)
 	if err != nil {
 		var zero T
 		return zero, fmt.Errorf("spinner program failed: %w", err)
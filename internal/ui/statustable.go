@@ -0,0 +1,263 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// StatusAction is an action chosen from the interactive status table.
+type StatusAction int
+
+const (
+	StatusActionNone StatusAction = iota
+	StatusActionDeactivate
+	StatusActionExtend
+)
+
+type statusTableModel struct {
+	table    table.Model
+	columns  []column
+	cells    [][]string
+	roles    []azure.RoleAssignment
+	action   StatusAction
+	showHelp bool
+	status   string
+	width    int
+	height   int
+}
+
+func newStatusTableModel(roles []azure.RoleAssignment) statusTableModel {
+	columns := roleColumns(true)
+	cells := tableCells(columns, roles)
+
+	rows := make([]table.Row, len(cells))
+	for i, c := range cells {
+		rows[i] = table.Row(c)
+	}
+
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.
+		Bold(true).
+		Foreground(current.Title).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderBottom(true).
+		BorderForeground(current.Subtle)
+	styles.Selected = styles.Selected.
+		Bold(true).
+		Foreground(current.Selection)
+
+	t := table.New(
+		table.WithRows(rows),
+		table.WithFocused(true),
+		table.WithStyles(styles),
+	)
+
+	m := statusTableModel{
+		table:   t,
+		columns: columns,
+		cells:   cells,
+		roles:   roles,
+	}
+	m.resize(terminalWidth(), len(rows)+2)
+	return m
+}
+
+// resize distributes the available width across columns. The table adds one
+// cell of padding on each side of every column.
+func (m *statusTableModel) resize(width, height int) {
+	available := 0
+	if width > 0 {
+		available = width - len(m.columns)*2 + tableIndent + columnGap*(len(m.columns)-1)
+	}
+	widths := columnWidths(m.columns, m.cells, available)
+
+	cols := make([]table.Column, len(m.columns))
+	for i, c := range m.columns {
+		cols[i] = table.Column{Title: c.header, Width: widths[i]}
+	}
+	m.table.SetColumns(cols)
+
+	// Leave room for the header, its border, the details line and the help line
+	m.table.SetHeight(max(3, min(height-4, len(m.roles)+1)))
+}
+
+func (m statusTableModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m statusTableModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize(msg.Width, msg.Height)
+		return m, nil
+
+	case tea.MouseMsg:
+		if m.showHelp {
+			return m, nil
+		}
+		switch {
+		case msg.Button == tea.MouseButtonWheelUp:
+			m.table.MoveUp(1)
+		case msg.Button == tea.MouseButtonWheelDown:
+			m.table.MoveDown(1)
+		case isLeftClick(msg):
+			row := tableRowAt(m.table, msg.Y)
+			if row < 0 {
+				return m, nil
+			}
+			m.table.SetCursor(row)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		if m.showHelp {
+			if key.Matches(msg, Keys.Help, Keys.Quit) {
+				m.showHelp = false
+			}
+			return m, nil
+		}
+
+		m.status = ""
+		switch {
+		case key.Matches(msg, Keys.Help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, Keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, Keys.Deactivate):
+			m.action = StatusActionDeactivate
+			return m, tea.Quit
+		case key.Matches(msg, Keys.Extend):
+			m.action = StatusActionExtend
+			return m, tea.Quit
+		case key.Matches(msg, Keys.Copy):
+			if role := m.current(); role != nil {
+				m.status = copyToClipboard(role.Scope)
+			}
+			return m, nil
+		case key.Matches(msg, Keys.OpenPortal):
+			if role := m.current(); role != nil {
+				m.status = openPortal(*role)
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+// current returns the role under the cursor
+func (m statusTableModel) current() *azure.RoleAssignment {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.roles) {
+		return nil
+	}
+	return &m.roles[cursor]
+}
+
+func (m statusTableModel) View() string {
+	if m.action != StatusActionNone {
+		return ""
+	}
+	if m.showHelp {
+		return renderHelpOverlay(Keys.Deactivate, Keys.Extend, Keys.Copy, Keys.OpenPortal, Keys.Quit, Keys.Help) + "\n"
+	}
+
+	footer := m.status
+	if footer == "" {
+		footer = SubtleStyle.Render(fmt.Sprintf("↑/↓ scroll • %s deactivate • %s extend • %s help • %s quit",
+			Keys.Deactivate.Help().Key, Keys.Extend.Help().Key, Keys.Help.Help().Key, Keys.Quit.Help().Key))
+	}
+	return m.table.View() + "\n" + m.details() + "\n" + footer + "\n"
+}
+
+// details summarises the request that activated the role under the cursor
+func (m statusTableModel) details() string {
+	role := m.current()
+	if role == nil {
+		return ""
+	}
+	granted := "granted at " + role.Scope
+	if role.Activation == nil {
+		return m.fit(SubtleStyle.Render(granted + " • no activation request found"))
+	}
+	a := role.Activation
+
+	parts := []string{granted}
+	if a.Requester != "" {
+		parts = append(parts, "requested by "+a.Requester)
+	}
+	if !a.RequestedAt.IsZero() {
+		parts = append(parts, "at "+FormatTime(a.RequestedAt))
+	}
+	if a.TicketNumber != "" {
+		ticket := a.TicketNumber
+		if a.TicketSystem != "" {
+			ticket = a.TicketSystem + " " + ticket
+		}
+		parts = append(parts, "ticket "+ticket)
+	}
+	if a.Approver != "" {
+		parts = append(parts, "approved by "+a.Approver)
+	}
+
+	line := strings.Join(parts, " • ")
+	if a.Justification != "" {
+		line = PreviewValueStyle.Render(a.Justification) + SubtleStyle.Render("  "+line)
+	} else {
+		line = SubtleStyle.Render(line)
+	}
+	return m.fit(line)
+}
+
+// fit truncates a line to the terminal width
+func (m statusTableModel) fit(line string) string {
+	if m.width > 0 {
+		line = ansi.Truncate(line, m.width, "…")
+	}
+	return line
+}
+
+// BrowseActiveRoles shows active assignments in a scrollable table. It returns
+// the action bound to the key pressed and the role under the cursor, or
+// StatusActionNone if the user just quit.
+func BrowseActiveRoles(roles []azure.RoleAssignment) (StatusAction, *azure.RoleAssignment, error) {
+	p := tea.NewProgram(newStatusTableModel(roles), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	finalModel, err := runProgram(p)
+	if err != nil {
+		return StatusActionNone, nil, fmt.Errorf("status table failed: %w", err)
+	}
+
+	result, ok := finalModel.(statusTableModel)
+	if !ok {
+		return StatusActionNone, nil, fmt.Errorf("unexpected model type")
+	}
+	if result.action == StatusActionNone {
+		return StatusActionNone, nil, nil
+	}
+
+	role := result.current()
+	if role == nil {
+		return StatusActionNone, nil, nil
+	}
+	return result.action, role, nil
+}
+
+// Interactive reports whether full-screen interfaces can be used
+func Interactive() bool {
+	return !lineMode()
+}
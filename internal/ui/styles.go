@@ -1,32 +1,76 @@
 package ui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
 
 var (
+	TitleStyle   lipgloss.Style
+	SuccessStyle lipgloss.Style
+	ErrorStyle   lipgloss.Style
+	SubtleStyle  lipgloss.Style
+	SpinnerStyle lipgloss.Style
+
+	// Preview pane styles
+	PreviewTitleStyle lipgloss.Style
+	PreviewLabelStyle lipgloss.Style
+	PreviewValueStyle lipgloss.Style
+	PreviewBoxStyle   lipgloss.Style
+)
+
+func init() {
+	buildStyles(current)
+}
+
+// buildStyles derives the shared styles from a theme
+func buildStyles(t Theme) {
 	TitleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("5"))
+		Bold(true).
+		Foreground(t.Title)
 
 	SuccessStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("2"))
+		Bold(true).
+		Foreground(t.Success)
+
+	ErrorStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(t.Error)
 
 	SubtleStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("8"))
+		Foreground(t.Subtle)
 
 	SpinnerStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("5"))
+		Foreground(t.Title)
 
-	// Preview pane styles
 	PreviewTitleStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("5"))
+		Bold(true).
+		Foreground(t.Title)
 
 	PreviewLabelStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("7"))
+		Bold(true).
+		Foreground(t.Text)
 
 	PreviewValueStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("7"))
+		Foreground(t.Text)
 
-)
+	PreviewBoxStyle = lipgloss.NewStyle().
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		BorderForeground(t.PreviewBorder).
+		PaddingLeft(1)
+}
+
+// DisableColor turns off all styling (colors, bold, reverse) for every
+// renderer in the process, including tables, the selector and spinners.
+func DisableColor() {
+	lipgloss.SetColorProfile(termenv.Ascii)
+}
+
+// NoColorRequested reports whether the NO_COLOR convention (https://no-color.org)
+// asks for colorless output.
+func NoColorRequested() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
@@ -1,50 +1,256 @@
 package ui
 
 import (
-	"fmt"
+	"os"
 	"strings"
 
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+
 	"github.com/ica-js/hacktivator/internal/azure"
 )
 
+// column describes one table column. Flexible columns share whatever width
+// is left after the fixed columns, but never shrink below minWidth.
+type column struct {
+	header   string
+	value    func(azure.RoleAssignment) string
+	flexible bool
+	minWidth int
+}
+
+// Wide extends table lines past the terminal width instead of truncating
+// values that don't fit.
+var Wide bool
+
+const (
+	tableIndent = 2
+	columnGap   = 1
+)
+
+// terminalWidth returns the width of stdout, or 0 if it is not a terminal
+// or Wide is set
+func terminalWidth() int {
+	if Wide || !term.IsTerminal(os.Stdout.Fd()) {
+		return 0
+	}
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
 func truncate(s string, max int) string {
-	if len(s) > max {
-		return s[:max-3] + "..."
+	if runewidth.StringWidth(s) > max {
+		return runewidth.Truncate(s, max, "...")
 	}
 	return s
 }
 
-// RenderRolesTable renders a styled table of role assignments.
-// When includeStatus is true, an extra STATUS column is appended.
-func RenderRolesTable(roles []azure.RoleAssignment, includeStatus bool) string {
-	var header, divider string
-	if includeStatus {
-		header = fmt.Sprintf("  %-30s %-40s %-15s %-10s", "ROLE", "SCOPE", "TYPE", "STATUS")
-		divider = "  " + strings.Repeat("─", 95)
-	} else {
-		header = fmt.Sprintf("  %-30s %-40s %-15s", "ROLE", "SCOPE", "TYPE")
-		divider = "  " + strings.Repeat("─", 85)
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-runewidth.StringWidth(s)))
+}
+
+// columnWidths sizes columns to their content, shrinking the flexible ones
+// proportionally when the total exceeds available. available <= 0 means
+// unlimited (e.g. output is piped), so nothing is truncated.
+func columnWidths(columns []column, cells [][]string, available int) []int {
+	widths := make([]int, len(columns))
+	for i, col := range columns {
+		widths[i] = runewidth.StringWidth(col.header)
+		for _, row := range cells {
+			widths[i] = max(widths[i], runewidth.StringWidth(row[i]))
+		}
+	}
+	if available <= 0 {
+		return widths
+	}
+
+	total := tableIndent + columnGap*(len(columns)-1)
+	flexTotal := 0
+	for i, col := range columns {
+		total += widths[i]
+		if col.flexible {
+			flexTotal += widths[i]
+		}
+	}
+	overflow := total - available
+	if overflow <= 0 || flexTotal == 0 {
+		return widths
+	}
+
+	// Take the overflow from flexible columns in proportion to their size
+	for i, col := range columns {
+		if !col.flexible {
+			continue
+		}
+		shrink := overflow * widths[i] / flexTotal
+		widths[i] = max(col.minWidth, widths[i]-shrink)
+	}
+	return widths
+}
+
+// tableCells evaluates every column for every role
+func tableCells(columns []column, roles []azure.RoleAssignment) [][]string {
+	cells := make([][]string, len(roles))
+	for r, role := range roles {
+		cells[r] = make([]string, len(columns))
+		for c, col := range columns {
+			cells[r][c] = col.value(role)
+		}
+	}
+	return cells
+}
+
+func renderTable(columns []column, roles []azure.RoleAssignment) string {
+	cells := tableCells(columns, roles)
+
+	widths := columnWidths(columns, cells, terminalWidth())
+
+	formatRow := func(values []string) string {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = pad(truncate(v, widths[i]), widths[i])
+		}
+		return strings.Repeat(" ", tableIndent) +
+			strings.TrimRight(strings.Join(parts, strings.Repeat(" ", columnGap)), " ")
+	}
+
+	headers := make([]string, len(columns))
+	lineWidth := columnGap * (len(columns) - 1)
+	for i, col := range columns {
+		headers[i] = col.header
+		lineWidth += widths[i]
 	}
 
 	var b strings.Builder
-	b.WriteString(TitleStyle.Render(header) + "\n")
-	b.WriteString(SubtleStyle.Render(divider) + "\n")
+	b.WriteString(TitleStyle.Render(formatRow(headers)) + "\n")
+	b.WriteString(SubtleStyle.Render(strings.Repeat(" ", tableIndent)+strings.Repeat("─", lineWidth)) + "\n")
+	for _, row := range cells {
+		b.WriteString(formatRow(row) + "\n")
+	}
+	return b.String()
+}
 
-	for _, role := range roles {
-		roleName := truncate(role.RoleName, 28)
-		scopeName := truncate(role.ScopeName, 38)
+// roleColumns returns the columns shown for role assignments: the ones
+// chosen with Columns, or by default ROLE, SCOPE, TYPE and MEMBER (Direct,
+// Group or Inherited). When includeStatus is true, STATUS, STARTED, EXPIRES
+// and JUSTIFICATION follow; otherwise the roles are eligible ones and
+// ELIGIBLE UNTIL does.
+func roleColumns(includeStatus bool) []column {
+	names := Columns
+	if len(names) == 0 {
+		names = []string{"role", "scope", "type", "member", "expires"}
 		if includeStatus {
-			status := role.Status
-			if status == "" {
-				status = "Active"
+			names = []string{"role", "scope", "type", "member", "status", "started", "expires", "justification"}
+		}
+	}
+	available := namedColumns(includeStatus)
+	columns := make([]column, len(names))
+	for i, name := range names {
+		columns[i] = available[name].column
+	}
+	return columns
+}
+
+// RenderAssignmentsTable renders every principal's assignments at a scope,
+// as listed by azure.GetAssignmentsAtScope.
+func RenderAssignmentsTable(roles []azure.RoleAssignment) string {
+	return renderTable([]column{
+		{header: "PRINCIPAL", value: PrincipalName, flexible: true, minWidth: 12},
+		{header: "KIND", value: func(r azure.RoleAssignment) string {
+			if r.ExpandedProperties == nil {
+				return ""
+			}
+			return r.ExpandedProperties.Principal.Type
+		}},
+		{header: "ROLE", value: func(r azure.RoleAssignment) string { return r.RoleName }, flexible: true, minWidth: 12},
+		{header: "SCOPE", value: ScopeLabel, flexible: true, minWidth: 12},
+		{header: "ASSIGNMENT", value: func(r azure.RoleAssignment) string {
+			if r.EligibilityID != "" {
+				return "Eligible"
+			}
+			return r.AssignmentType
+		}},
+		{header: "MEMBER", value: func(r azure.RoleAssignment) string { return r.MemberType }},
+		{header: "ENDS", value: func(r azure.RoleAssignment) string {
+			if r.EndDateTime == nil {
+				return "permanent"
+			}
+			return FormatTime(*r.EndDateTime)
+		}},
+	}, roles)
+}
+
+// RenderUsageTable renders eligibilities with when each was last activated,
+// as found by the audit unused command. showPrincipal adds a PRINCIPAL column
+// for reviews across principals.
+func RenderUsageTable(roles []azure.RoleAssignment, showPrincipal bool) string {
+	var columns []column
+	if showPrincipal {
+		columns = append(columns, column{header: "PRINCIPAL", value: PrincipalName, flexible: true, minWidth: 12})
+	}
+	columns = append(columns,
+		column{header: "ROLE", value: func(r azure.RoleAssignment) string { return r.RoleName }, flexible: true, minWidth: 12},
+		column{header: "SCOPE", value: ScopeLabel, flexible: true, minWidth: 12},
+		column{header: "MEMBER", value: func(r azure.RoleAssignment) string { return r.MemberType }},
+		column{header: "LAST ACTIVATED", value: func(r azure.RoleAssignment) string {
+			if r.Activation == nil || r.Activation.RequestedAt.IsZero() {
+				return "never"
 			}
-			row := fmt.Sprintf("  %-30s %-40s %-15s %-10s", roleName, scopeName, role.ScopeType, status)
-			b.WriteString(row + "\n")
-		} else {
-			row := fmt.Sprintf("  %-30s %-40s %-15s", roleName, scopeName, role.ScopeType)
-			b.WriteString(row + "\n")
+			return FormatTime(r.Activation.RequestedAt)
+		}},
+	)
+	return renderTable(columns, roles)
+}
+
+// ScopeLabel names a role's scope. Resource group and resource scopes are
+// shown with the levels above them, since their names are often reused
+// across subscriptions.
+func ScopeLabel(r azure.RoleAssignment) string {
+	if !strings.Contains(strings.ToLower(r.Scope), "/resourcegroups/") {
+		return r.ScopeName
+	}
+	return azure.ScopeBreadcrumb(r)
+}
+
+// memberLabel is the member type of an assignment, with the group for group-based ones
+func memberLabel(r azure.RoleAssignment) string {
+	if r.MemberType == "Group" && r.GroupName != "" {
+		return "Group: " + r.GroupName
+	}
+	return r.MemberType
+}
+
+// ViaGroup describes the group a role is granted through, like "via group:
+// SRE-Prod-Admins", or returns "" for roles granted directly.
+func ViaGroup(r azure.RoleAssignment) string {
+	if r.MemberType != "Group" {
+		return ""
+	}
+	if r.GroupName == "" {
+		return "via group"
+	}
+	return "via group: " + r.GroupName
+}
+
+// PrincipalName names the principal of an assignment, falling back to its ID.
+func PrincipalName(r azure.RoleAssignment) string {
+	if r.ExpandedProperties != nil {
+		p := r.ExpandedProperties.Principal
+		switch {
+		case p.Email != "" && p.DisplayName != "":
+			return p.DisplayName + " <" + p.Email + ">"
+		case p.DisplayName != "":
+			return p.DisplayName
 		}
 	}
+	return r.PrincipalID
+}
 
-	return b.String()
+// RenderRolesTable renders a styled table of role assignments sized to the
+// terminal width. When includeStatus is true, activation status columns are appended.
+func RenderRolesTable(roles []azure.RoleAssignment, includeStatus bool) string {
+	return renderTable(roleColumns(includeStatus), roles)
 }
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-isatty"
+)
+
+var (
+	programMu sync.Mutex
+	running   *tea.Program
+	savedTerm *term.State
+)
+
+// SaveTerminal records the terminal state at startup so Teardown can put it
+// back even if a full-screen interface is interrupted.
+func SaveTerminal() {
+	if term.IsTerminal(os.Stdin.Fd()) {
+		savedTerm, _ = term.GetState(os.Stdin.Fd())
+	}
+}
+
+// runProgram runs p, remembering it so Teardown can stop it
+func runProgram(p *tea.Program) (tea.Model, error) {
+	programMu.Lock()
+	running = p
+	programMu.Unlock()
+
+	defer func() {
+		programMu.Lock()
+		running = nil
+		programMu.Unlock()
+	}()
+	return p.Run()
+}
+
+// Suspend hands the terminal back from a running interface, such as a
+// spinner, while fn runs, so fn can prompt or run an interactive command.
+func Suspend(fn func() error) error {
+	programMu.Lock()
+	p := running
+	programMu.Unlock()
+	if p == nil {
+		return fn()
+	}
+	if err := p.ReleaseTerminal(); err != nil {
+		return err
+	}
+	defer p.RestoreTerminal()
+	return fn()
+}
+
+// Teardown stops any running full-screen interface and restores the terminal:
+// cooked mode, main screen, visible cursor and no mouse reporting. It is safe
+// to call from a panic handler or a signal handler.
+func Teardown() {
+	programMu.Lock()
+	p := running
+	programMu.Unlock()
+	if p != nil {
+		p.Kill()
+		p.Wait()
+	}
+
+	if savedTerm != nil {
+		_ = term.Restore(os.Stdin.Fd(), savedTerm)
+	}
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		fmt.Print("\x1b[?1000l\x1b[?1002l\x1b[?1006l\x1b[?1049l\x1b[?25h")
+	}
+}
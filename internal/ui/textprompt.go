@@ -11,19 +11,35 @@ type textPromptModel struct {
 	textInput textinput.Model
 	done      bool
 	cancelled bool
+
+	// suggestions are cycled with up/down; index -1 means the user's own input
+	suggestions []string
+	index       int
+	draft       string
+
+	// validate rejects a submitted value, keeping the prompt open
+	validate func(string) error
+	err      error
 }
 
-func newTextPromptModel(prompt string, placeholder string) textPromptModel {
+func newTextPromptModel(prompt string, placeholder string, suggestions []string, validate func(string) error) textPromptModel {
 	ti := textinput.New()
 	ti.Placeholder = placeholder
 	ti.Prompt = prompt
 	ti.Focus()
+	applyInputMotion(&ti)
 	return textPromptModel{
-		textInput: ti,
+		textInput:   ti,
+		suggestions: suggestions,
+		index:       -1,
+		validate:    validate,
 	}
 }
 
 func (m textPromptModel) Init() tea.Cmd {
+	if ReducedMotion {
+		return nil
+	}
 	return textinput.Blink
 }
 
@@ -32,11 +48,37 @@ func (m textPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.Type {
 		case tea.KeyEnter:
+			if m.validate != nil {
+				if m.err = m.validate(m.textInput.Value()); m.err != nil {
+					return m, nil
+				}
+			}
 			m.done = true
 			return m, tea.Quit
 		case tea.KeyCtrlC, tea.KeyEscape:
 			m.cancelled = true
 			return m, tea.Quit
+		case tea.KeyUp:
+			if m.index+1 < len(m.suggestions) {
+				if m.index == -1 {
+					m.draft = m.textInput.Value()
+				}
+				m.index++
+				m.textInput.SetValue(m.suggestions[m.index])
+				m.textInput.CursorEnd()
+			}
+			return m, nil
+		case tea.KeyDown:
+			if m.index >= 0 {
+				m.index--
+				if m.index == -1 {
+					m.textInput.SetValue(m.draft)
+				} else {
+					m.textInput.SetValue(m.suggestions[m.index])
+				}
+				m.textInput.CursorEnd()
+			}
+			return m, nil
 		}
 	}
 	var cmd tea.Cmd
@@ -45,16 +87,56 @@ func (m textPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m textPromptModel) View() string {
-	return m.textInput.View() + "\n"
+	view := m.textInput.View() + "\n"
+	if m.err != nil && !m.done {
+		view += ErrorStyle.Render(m.err.Error()) + "\n"
+	}
+	if len(m.suggestions) > 0 && !m.done {
+		hint := "↑/↓ recent reasons"
+		if m.index >= 0 {
+			hint = fmt.Sprintf("↑/↓ recent reasons (%d/%d)", m.index+1, len(m.suggestions))
+		}
+		view += SubtleStyle.Render(hint) + "\n"
+	}
+	return view
 }
 
 // PromptForJustification prompts the user to enter a justification reason.
 // Inline (no alt screen). Press Enter to submit (empty = skip), ctrl+c/esc to cancel.
-func PromptForJustification() (string, error) {
-	m := newTextPromptModel("Justification (Enter to skip): ", "optional reason for activation")
+// Suggestions (expanded templates and recent reasons) can be recalled with up/down.
+// If validate is non-nil the prompt re-asks until it returns nil. A non-empty
+// initial value pre-fills the input.
+func PromptForJustification(initial string, suggestions []string, validate func(string) error) (string, error) {
+	if validate != nil {
+		return promptText("Justification: ", "reason for activation", initial, suggestions, validate)
+	}
+	return promptText("Justification (Enter to skip): ", "optional reason for activation", initial, suggestions, nil)
+}
+
+// PromptForTicketNumber prompts the user to enter a ticket number.
+func PromptForTicketNumber(validate func(string) error) (string, error) {
+	return promptText("Ticket number: ", "e.g. INC001234", "", nil, validate)
+}
+
+// PromptForDuration prompts the user to enter an activation duration.
+func PromptForDuration(validate func(string) error) (string, error) {
+	return promptText("Duration: ", "e.g. 90m or 3h", "", nil, validate)
+}
+
+func promptText(prompt, placeholder, initial string, suggestions []string, validate func(string) error) (string, error) {
+	if lineMode() {
+		return plainPrompt(prompt, initial, suggestions, validate)
+	}
+
+	m := newTextPromptModel(prompt, placeholder, suggestions, validate)
+	if initial != "" {
+		m.textInput.SetValue(initial)
+		m.textInput.CursorEnd()
+	}
+
 	p := tea.NewProgram(m)
 
-	finalModel, err := p.Run()
+	finalModel, err := runProgram(p)
 	if err != nil {
 		return "", fmt.Errorf("text prompt failed: %w", err)
 	}
@@ -69,3 +151,13 @@ func PromptForJustification() (string, error) {
 
 	return result.textInput.Value(), nil
 }
+
+// PromptForPrincipalSearch prompts for the start of a user or group name.
+func PromptForPrincipalSearch() (string, error) {
+	return promptText("Search users and groups: ", "start of a name or UPN", "", nil, func(s string) error {
+		if s == "" {
+			return fmt.Errorf("enter part of a name to search for")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/ica-js/hacktivator/internal/config"
+)
+
+// Theme is the set of colors used across tables, lists and prompts.
+type Theme struct {
+	Title         lipgloss.TerminalColor
+	Success       lipgloss.TerminalColor
+	Error         lipgloss.TerminalColor
+	Subtle        lipgloss.TerminalColor
+	Selection     lipgloss.TerminalColor
+	PreviewBorder lipgloss.TerminalColor
+	Text          lipgloss.TerminalColor
+}
+
+var themes = map[string]Theme{
+	"default": {
+		Title:         lipgloss.Color("5"),
+		Success:       lipgloss.Color("2"),
+		Error:         lipgloss.Color("1"),
+		Subtle:        lipgloss.Color("8"),
+		Selection:     lipgloss.Color("5"),
+		PreviewBorder: lipgloss.Color("8"),
+		Text:          lipgloss.Color("7"),
+	},
+	"solarized": {
+		Title:         lipgloss.Color("#268bd2"),
+		Success:       lipgloss.Color("#859900"),
+		Error:         lipgloss.Color("#dc322f"),
+		Subtle:        lipgloss.AdaptiveColor{Light: "#93a1a1", Dark: "#586e75"},
+		Selection:     lipgloss.Color("#d33682"),
+		PreviewBorder: lipgloss.AdaptiveColor{Light: "#eee8d5", Dark: "#073642"},
+		Text:          lipgloss.AdaptiveColor{Light: "#657b83", Dark: "#839496"},
+	},
+	"high-contrast": {
+		Title:         lipgloss.AdaptiveColor{Light: "#0000af", Dark: "#ffff00"},
+		Success:       lipgloss.AdaptiveColor{Light: "#005f00", Dark: "#00ff00"},
+		Error:         lipgloss.AdaptiveColor{Light: "#af0000", Dark: "#ff5f5f"},
+		Subtle:        lipgloss.AdaptiveColor{Light: "#303030", Dark: "#d0d0d0"},
+		Selection:     lipgloss.AdaptiveColor{Light: "#000000", Dark: "#00ffff"},
+		PreviewBorder: lipgloss.AdaptiveColor{Light: "#000000", Dark: "#ffffff"},
+		Text:          lipgloss.AdaptiveColor{Light: "#000000", Dark: "#ffffff"},
+	},
+}
+
+// current is the active theme, used by views that build styles on demand
+var current = themes["default"]
+
+// ThemeNames returns the built-in theme names.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func toTerminalColor(c config.Color) lipgloss.TerminalColor {
+	if c.Light == c.Dark {
+		return lipgloss.Color(c.Light)
+	}
+	return lipgloss.AdaptiveColor{Light: c.Light, Dark: c.Dark}
+}
+
+// ApplyTheme selects a built-in theme, applies color overrides from config
+// and rebuilds the shared styles.
+func ApplyTheme(cfg config.Theme) error {
+	name := cfg.Name
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %v)", name, ThemeNames())
+	}
+
+	overrides := []struct {
+		color config.Color
+		dst   *lipgloss.TerminalColor
+	}{
+		{cfg.Title, &theme.Title},
+		{cfg.Success, &theme.Success},
+		{cfg.Error, &theme.Error},
+		{cfg.Subtle, &theme.Subtle},
+		{cfg.Selection, &theme.Selection},
+		{cfg.PreviewBorder, &theme.PreviewBorder},
+	}
+	for _, o := range overrides {
+		if o.color.IsSet() {
+			*o.dst = toTerminalColor(o.color)
+		}
+	}
+
+	current = theme
+	buildStyles(theme)
+	return nil
+}
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+)
+
+// UTC shows times in UTC instead of the local time zone.
+var UTC bool
+
+// DisplayTime converts t to the zone times are shown in.
+func DisplayTime(t time.Time) time.Time {
+	if UTC {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+// FormatTime renders t for tables and messages, with the zone so it is
+// unambiguous when shared.
+func FormatTime(t time.Time) string {
+	return DisplayTime(t).Format("Jan 02 15:04 MST")
+}
+
+// FormatMinutes renders a duration in minutes like 45m, 2h or 1h30m.
+func FormatMinutes(m int) string {
+	switch {
+	case m < 60:
+		return fmt.Sprintf("%dm", m)
+	case m%60 == 0:
+		return fmt.Sprintf("%dh", m/60)
+	}
+	return fmt.Sprintf("%dh%dm", m/60, m%60)
+}
@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"github.com/ica-js/hacktivator/internal/azure"
+)
+
+// timelineMarkers mark the state of each assignment in the history timeline
+var timelineMarkers = map[string]string{
+	"Active":      "●",
+	"Scheduled":   "◌",
+	"Ended":       "○",
+	"Deactivated": "○",
+}
+
+// timelineState labels an assignment's state, with a marker unless in plain
+// mode. Pending and failed requests keep their request status.
+func timelineState(r azure.RoleAssignment) string {
+	marker, ok := timelineMarkers[r.Status]
+	switch {
+	case Plain:
+		return r.Status
+	case ok:
+	case (&azure.ActivationResult{Status: r.Status}).IsPendingApproval():
+		marker = "…"
+	default:
+		marker = "✗"
+	}
+	return marker + " " + r.Status
+}
+
+// RenderTimelineTable renders assignments built from activation history,
+// oldest first, with when each started and ended.
+func RenderTimelineTable(roles []azure.RoleAssignment) string {
+	return renderTable([]column{
+		{header: "START", value: func(r azure.RoleAssignment) string {
+			if r.StartDateTime.IsZero() {
+				return ""
+			}
+			return FormatTime(r.StartDateTime)
+		}},
+		{header: "END", value: func(r azure.RoleAssignment) string {
+			if r.EndDateTime == nil {
+				return "permanent"
+			}
+			return FormatTime(*r.EndDateTime)
+		}},
+		{header: "ROLE", value: func(r azure.RoleAssignment) string { return r.RoleName }, flexible: true, minWidth: 12},
+		{header: "SCOPE", value: ScopeLabel, flexible: true, minWidth: 12},
+		{header: "STATE", value: timelineState},
+		{header: "JUSTIFICATION", value: func(r azure.RoleAssignment) string {
+			if r.Activation == nil {
+				return ""
+			}
+			return r.Activation.Justification
+		}, flexible: true, minWidth: 12},
+	}, roles)
+}
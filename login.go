@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/browser"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	loginTenant     string
+	loginDeviceCode bool
+	loginCheckOnly  bool
+	// signingIn skips the signed-in check before the login command runs
+	signingIn bool
+)
+
+func loginCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Sign in to Azure and check that hacktivator can use the sign-in",
+		Long: `Runs az login the way hacktivator needs it, then checks that the sign-in can
+call the PIM APIs and read your user from Microsoft Graph, which some
+features need.
+
+The tenant is --tenant, the current context's, or the one the Azure CLI last
+used. Without a browser, e.g. over SSH, or with --use-device-code, az prints
+a code to enter at microsoft.com/devicelogin instead. With a context, the
+sign-in is kept in the context's Azure CLI config directory.`,
+		Args: cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			signingIn = true
+			return cmd.Root().PersistentPreRunE(cmd, args)
+		},
+		RunE: runLogin,
+	}
+	cmd.Flags().StringVar(&loginTenant, "tenant", "", "Tenant ID or domain to sign in to, e.g. contoso.onmicrosoft.com")
+	cmd.Flags().BoolVar(&loginDeviceCode, "use-device-code", false, "Sign in with a code on another device instead of a browser")
+	cmd.Flags().BoolVar(&loginCheckOnly, "check", false, "Only check the current sign-in")
+	return cmd
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	if !loginCheckOnly {
+		if nonInteractive || ciMode {
+			return fmt.Errorf("signing in needs a terminal; use --oidc in pipelines")
+		}
+		tenant := loginTenant
+		if tenant == "" {
+			tenant = signInTenant()
+		}
+		if activeContext != nil {
+			dir, err := activeContext.AzureDir()
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+		}
+		if tenant != "" {
+			infof("Signing in to %s...\n", tenant)
+		}
+		if err := azure.Login(tenant, loginDeviceCode || !browser.Available()); err != nil {
+			return withExitCode(exitAuthError, err)
+		}
+	}
+	return verifySignIn()
+}
+
+// verifySignIn checks that the Azure CLI's sign-in can do what hacktivator
+// needs, printing a line per check
+func verifySignIn() error {
+	if !azure.IsAuthenticated() {
+		return withExitCode(exitAuthError, fmt.Errorf("not signed in, run 'hacktivator login'"))
+	}
+
+	user, err := ui.SpinWithResult("Reading your user from Microsoft Graph", azure.SignedInUser, nonInteractive)
+	if err != nil {
+		// Activation reads the principal from the access token instead
+		fmt.Println(ui.TitleStyle.Render("! Microsoft Graph: " + firstLine(err.Error())))
+		fmt.Println(ui.SubtleStyle.Render("  Activation works without it, but group names, approvers and email notifications may not"))
+	} else {
+		name := user.DisplayName
+		if user.UPN != "" {
+			name += " (" + user.UPN + ")"
+		}
+		fmt.Println(ui.SuccessStyle.Render("✓ Microsoft Graph: signed in as " + name))
+	}
+
+	roles, err := ui.SpinWithResult("Listing eligible roles through the PIM API", func() ([]azure.RoleAssignment, error) {
+		return azure.GetEligibleRolesAtScope("")
+	}, nonInteractive)
+	if err != nil {
+		fmt.Println(ui.ErrorStyle.Render("✗ PIM API: " + firstLine(err.Error())))
+		return withExitCode(exitAuthError, fmt.Errorf("failed to call the PIM API: %w", err))
+	}
+	fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("✓ PIM API: listed %d eligible role(s) tenant-wide", len(roles))))
+
+	if tenant, err := azure.DefaultTenant(); err == nil {
+		infof("Ready to activate roles in tenant %s.\n", tenant)
+	}
+	return nil
+}
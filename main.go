@@ -3,13 +3,24 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/cassette"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/telemetry"
+	"github.com/ica-js/hacktivator/internal/tracelog"
 	"github.com/ica-js/hacktivator/internal/ui"
 )
 
+// version is set at release time by goreleaser
+var version = "dev"
+
 var (
 	duration       int
 	reason         string
@@ -17,9 +28,30 @@ var (
 	ticketSys      string
 	nonInteractive bool
 	verbose        bool
+	reasonTmpl     string
+	ticketTitle    string
+	quiet          bool
+	noColor        bool
+	plain          bool
+	wide           bool
+	reducedMotion  bool
+	utc            bool
+	outputFormat   string
+	columns        string
+	extendDuration int
+	dryRun         bool
+	otelEndpoint   string
+	traceFD        int
+	traceFile      string
+
+	cfg *config.Config
 )
 
 func main() {
+	defer handlePanic()
+	ui.SaveTerminal()
+	handleTermination()
+
 	rootCmd := &cobra.Command{
 		Use:   "hacktivator",
 		Short: "Activate Azure PIM eligible roles from the command line",
@@ -28,55 +60,336 @@ eligible Azure PIM (Privileged Identity Management) roles.
 
 It uses the Azure CLI for authentication and provides an interactive
 fuzzy-finder interface for selecting subscriptions and roles.`,
+		SilenceErrors: true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyEnvDefaults(cmd); err != nil {
+				return err
+			}
+			// Flags parsed fine; don't bury runtime errors under usage text
+			cmd.SilenceUsage = true
+			if showSchema {
+				return replaceWithSchema(cmd)
+			}
+			if ciMode {
+				applyCI(cmd)
+			}
+			if f := cmd.Flags().Lookup("duration"); f != nil {
+				durationSet = f.Changed
+			}
+			if err := applyContext(); err != nil {
+				return err
+			}
+			if traceFD > 0 && traceFile != "" {
+				return fmt.Errorf("use only one of --trace-fd and --trace-file")
+			}
+			if err := tracelog.Open(traceFD, traceFile); err != nil {
+				return err
+			}
+			if err := openCassette(); err != nil {
+				return err
+			}
+			traceLastRun(cmd)
 			azure.Verbose = verbose
-			return checkPrerequisites()
+			azure.DryRun = dryRun
+			switch outputFormat {
+			case "table":
+			case "md":
+				outputFormat = "markdown"
+				quiet = true
+			case "json", "csv", "tsv", "markdown":
+				// Keep stdout parseable
+				quiet = true
+			default:
+				return fmt.Errorf("unsupported output format %q (supported: table, json, csv, tsv, markdown)", outputFormat)
+			}
+			if err := validatePatterns(); err != nil {
+				return err
+			}
+			if columns != "" {
+				names, err := ui.ParseColumns(columns)
+				if err != nil {
+					return fmt.Errorf("invalid --columns: %w", err)
+				}
+				ui.Columns = names
+			}
+			ui.Quiet = quiet
+			ui.Plain = plain
+			ui.Wide = wide
+			ui.UTC = utc
+			if dryRun {
+				// Keep spinners from interleaving with the printed requests
+				ui.Quiet = true
+			}
+			if noColor || plain || ui.NoColorRequested() {
+				ui.DisableColor()
+			}
+
+			var err error
+			if cfg, err = config.Load(); err != nil {
+				return err
+			}
+			if err := configureNetwork(cfg.Network); err != nil {
+				return err
+			}
+			if err := configureAuditSinks(cfg.AuditSinks); err != nil {
+				return err
+			}
+			if err := configureNotifications(cfg.Notifications); err != nil {
+				return err
+			}
+			azure.OnScheduleRequest = onScheduleRequest
+			azure.OnSessionExpired = reauthenticate
+			configureSubscriptionScan(cfg.ScanSubscriptions)
+			if err := azure.SetDiscoveryMode(cfg.DiscoveryMode); err != nil {
+				return err
+			}
+			if err := setEligibleCacheTTL(cfg.EligibleCacheTTL); err != nil {
+				return err
+			}
+			primeCaches()
+			primeRequestKeys()
+			if otelEndpoint == "" {
+				otelEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+			}
+			telemetry.Init(otelEndpoint, cmd.CommandPath())
+			if err := applyUntil(); err != nil {
+				return err
+			}
+			if err := ui.ApplyTheme(cfg.Theme); err != nil {
+				return err
+			}
+			ui.ApplyKeybindings(cfg.Keybindings)
+			ui.ReducedMotion = reducedMotion || cfg.ReducedMotion
+			if err := ui.ApplySort(cfg.SelectorSort); err != nil {
+				return err
+			}
+
+			if err := checkPrerequisites(); err != nil {
+				return err
+			}
+			// Only commands that activate roles send queued activations and
+			// carry out overdue deactivations, so reading roles never
+			// submits a request as a side effect
+			if !dryRun && !signingIn && activatesRoles(cmd) {
+				retryQueuedActivations()
+				runOverdueDeactivations()
+			}
+			return nil
 		},
 		RunE: runActivate,
 	}
 
 	// Activate command flags (also on root for convenience)
-	rootCmd.Flags().IntVarP(&duration, "duration", "d", 480, "Activation duration in minutes (default 480 = 8 hours)")
-	rootCmd.Flags().StringVarP(&reason, "reason", "r", "", "Justification reason for activation")
-	rootCmd.Flags().StringVar(&ticketNum, "ticket-number", "", "Ticket number for activation request")
-	rootCmd.Flags().StringVar(&ticketSys, "ticket-system", "", "Ticket system name (e.g., ServiceNow, Jira)")
-	rootCmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Fail if user input is required")
+	addActivationFlags(rootCmd)
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose/debug output")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress decorative output (errors are still printed)")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colors and text styling (also honors NO_COLOR)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table, json, csv, tsv or markdown")
+	rootCmd.PersistentFlags().BoolVar(&utc, "utc", false, "Show times in UTC instead of the local time zone")
+	rootCmd.PersistentFlags().BoolVar(&plain, "plain", false, "Use numbered lists and line prompts instead of full-screen interfaces")
+	rootCmd.PersistentFlags().BoolVar(&wide, "wide", false, "Never truncate table values, extending lines past the terminal width (alias --no-trunc)")
+	rootCmd.PersistentFlags().BoolVar(&ciMode, "ci", false, "Run in a CI pipeline: no prompts or full-screen interfaces, JSON output, progress and errors logged for GitHub Actions or Azure Pipelines")
+	rootCmd.PersistentFlags().BoolVar(&oidcLogin, "oidc", false, "Sign in as the federated service principal in AZURE_CLIENT_ID and AZURE_TENANT_ID with the pipeline's OIDC token")
+	rootCmd.PersistentFlags().BoolVar(&reducedMotion, "reduced-motion", false, "Show static progress messages instead of spinners and blinking cursors")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Disable TLS certificate verification for direct HTTP requests (unsafe, for debugging)")
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "Sign in with this context instead of the current one, see 'hacktivator context'")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the activate, extend and deactivate requests instead of sending them")
+	rootCmd.PersistentFlags().StringVar(&subscriptionPattern, "subscription", "", "Only use roles in subscriptions whose name or ID matches this glob pattern, e.g. 'prod-*', or regular expression with re:; an exact name or ID queries only that subscription")
+	rootCmd.PersistentFlags().BoolVar(&fullRefresh, "full-refresh", false, "Query every subscription again instead of reusing cached scan results")
+	rootCmd.PersistentFlags().BoolVar(&rescanAll, "rescan-all", false, "Scan every subscription, ignoring scan_subscriptions from the config file")
+	rootCmd.PersistentFlags().StringVar(&otelEndpoint, "otel-endpoint", "", "Export trace spans to this OTLP/HTTP endpoint, e.g. http://localhost:4318")
+	rootCmd.PersistentFlags().IntVar(&traceFD, "trace-fd", 0, "Write az commands and REST requests with responses as JSON lines to this file descriptor, tokens redacted")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "Append az commands and REST requests with responses as JSON lines to this file, tokens redacted")
+
+	// Bulk activation flags
+	rootCmd.Flags().BoolVar(&activateAll, "all", false, "Activate every eligible role matching --role, --subscription and --scope without selecting")
+	rootCmd.Flags().StringVar(&rolePattern, "role", "", "Only offer roles whose name matches this glob pattern, e.g. 'Reader', or regular expression with re:, e.g. 're:^Contrib'")
+	rootCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation before bulk activation")
+	rootCmd.Flags().StringVar(&directScope, "scope", "", "Activate at exactly this scope without scanning, with --role-definition-id; with glob: or re: only offer roles whose scope matches")
+	// --role-name reads better next to --role-definition-id in scripts, and
+	// --json-schema is the name other tools use
+	rootCmd.SetGlobalNormalizationFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		switch name {
+		case "role-name":
+			name = "role"
+		case "json-schema":
+			name = "schema"
+		case "no-trunc":
+			name = "wide"
+		}
+		return pflag.NormalizedName(name)
+	})
+	addSchemaFlag(rootCmd)
+	rootCmd.Flags().BoolVar(&takeFirst, "first", false, "When several roles match in non-interactive mode, activate the first instead of failing")
+	rootCmd.Flags().StringVar(&roleDefinitionID, "role-definition-id", "", "Role definition ID or GUID to activate at --scope")
 
 	// Add subcommands
 	rootCmd.AddCommand(listCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(favoriteCmd())
+	rootCmd.AddCommand(favoritesCmd())
+	rootCmd.AddCommand(aliasCmd())
+	rootCmd.AddCommand(auditCmd())
+	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(applyCmd())
+	rootCmd.AddCommand(secretCmd())
+	rootCmd.AddCommand(promptCmd())
+	rootCmd.AddCommand(daemonCmd())
+	rootCmd.AddCommand(serveCmd())
+	rootCmd.AddCommand(mcpCmd())
+	rootCmd.AddCommand(renewEligibilityCmd())
+	rootCmd.AddCommand(adminCmd())
+	rootCmd.AddCommand(cacheCmd())
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(queueCmd())
+	rootCmd.AddCommand(summaryCmd())
+	rootCmd.AddCommand(approversCmd())
+	rootCmd.AddCommand(pendingCmd())
+	rootCmd.AddCommand(contextCmd())
+	rootCmd.AddCommand(loginCmd())
+	rootCmd.AddCommand(benchCmd())
+	rootCmd.AddCommand(deactivateScheduledCmd())
+
+	err := rootCmd.Execute()
+	if verbose {
+		if m := azure.RunHTTPMetrics(); m.Requests > 0 {
+			fmt.Fprintf(os.Stderr, "[DEBUG] HTTP: %s\n", m)
+		}
+	}
+	telemetry.Shutdown(err)
+	tracelog.Run(os.Args[1:], version, exitCode(err), runStart, err)
+	tracelog.Close()
+	if err := cassette.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if err != nil {
+		if msg := err.Error(); msg != "" && ui.CI != ui.CINone {
+			fmt.Fprintln(os.Stderr, ui.CIError(msg))
+		} else if msg != "" {
+			fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render("Error: "+msg))
+		}
+		os.Exit(exitCode(err))
+	}
+}
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+// addActivationFlags registers the flags shared by commands that activate roles
+func addActivationFlags(cmd *cobra.Command) {
+	flags := pflag.NewFlagSet("activation", pflag.ContinueOnError)
+	flags.VarP(newMinutesValue(480, &duration), "duration", "d", "Activation duration in minutes or as a duration like 2h or 90m (default 480 = 8 hours)")
+	flags.StringVar(&until, "until", "", "Activate until a local time like 18:00, or eod for the end of the workday")
+	flags.StringVarP(&reason, "reason", "r", "", "Justification reason for activation")
+	flags.StringVar(&ticketNum, "ticket-number", "", "Ticket number for activation request")
+	flags.StringVar(&ticketSys, "ticket-system", "", "Ticket system name (e.g., ServiceNow, Jira)")
+	flags.StringVarP(&reasonTmpl, "template", "t", "", "Name of a reason template from the config file")
+	flags.BoolVar(&nonInteractive, "non-interactive", false, "Fail if user input is required")
+	flags.BoolVar(&force, "force", false, "Submit the activation even if the role is already active or was requested in the last 10 minutes")
+	flags.VarP(newMinutesValue(0, &autoDeactivate), "auto-deactivate", "", "Deactivate the role again after this long, e.g. 20m, though the duration is longer")
+	flags.BoolVar(&overrideGuardrail, "override-guardrail", false, "Activate even if it breaks a guardrail from the config file; recorded in the audit log")
+	flags.StringVar(&breakGlass, "break-glass", "", "Confirm activating this break-glass role without typing its name")
+	// Set from the environment on every command that has them, see envBound
+	flags.VisitAll(func(f *pflag.Flag) {
+		f.Annotations = map[string][]string{envAnnotation: {"true"}}
+	})
+	cmd.Flags().AddFlagSet(flags)
+
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[activatesAnnotation] = "true"
+}
+
+// activatesAnnotation marks the commands that activate roles, see
+// addActivationFlags
+const activatesAnnotation = "hacktivator_activates"
+
+// activatesRoles reports whether cmd activates roles
+func activatesRoles(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[activatesAnnotation]
+	return ok
+}
+
+// infof prints informational output that --quiet suppresses
+func infof(format string, args ...any) {
+	if !quiet {
+		fmt.Printf(format, args...)
 	}
 }
 
 func listCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all eligible PIM role assignments",
-		Long:  `Lists all eligible PIM role assignments that you can activate.`,
-		RunE:  runList,
+		Long: `Lists all eligible PIM role assignments that you can activate.
+
+With --expiring only eligibilities that end within the given number of days
+are listed, soonest first, so renewals can be requested in time.`,
+		RunE: runList,
 	}
+	cmd.Flags().StringVar(&expiring, "expiring", "", "Only list eligibilities ending within this many days, e.g. 30d")
+	cmd.Flags().StringSliceVar(&memberTypes, "member-type", nil, "Only list direct, group or inherited assignments (comma-separated)")
+	addColumnsFlag(cmd)
+	addSchemaFlag(cmd)
+	return cmd
 }
 
 func statusCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show currently active PIM role assignments",
-		Long:  `Shows all currently active PIM role assignments.`,
-		RunE:  runStatus,
+		Long: `Shows all currently active PIM role assignments.
+
+On a terminal the assignments are shown in a scrollable table where the
+selected role can be deactivated (d) or extended (e).
+
+With --format the summary is printed as a starship, tmux or waybar segment
+from the local cache, like the prompt command.
+
+With --history past, active and scheduled assignments are shown as one
+timeline, oldest first, from your activation requests since --since. Use
+--role to answer questions like when you last had Owner.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if statusFormat != "" {
+				// Segments are redrawn constantly; skip the az checks
+				return localOnly(cmd, args)
+			}
+			return cmd.Root().PersistentPreRunE(cmd, args)
+		},
+		RunE: runStatus,
 	}
+	cmd.Flags().StringVar(&statusFormat, "format", "", "Print a status bar segment instead: starship, tmux or waybar")
+	cmd.Flags().VarP(newMinutesValue(60, &extendDuration), "duration", "d", "Duration in minutes (or like 1h) when extending a role from the table")
+	cmd.Flags().StringVarP(&reason, "reason", "r", "", "Justification when extending a role from the table")
+	addSchemaFlag(cmd)
+	cmd.Flags().StringSliceVar(&memberTypes, "member-type", nil, "Only show direct, group or inherited assignments (comma-separated)")
+	addColumnsFlag(cmd)
+	cmd.Flags().BoolVar(&statusHistory, "history", false, "Show past, active and scheduled assignments as a timeline")
+	cmd.Flags().StringVar(&historySince, "since", "90d", "With --history, start the timeline here, e.g. 30d, 12w or 2024-01-31")
+	cmd.Flags().StringVar(&rolePattern, "role", "", "Only show roles whose name matches this glob pattern, or regular expression with re:")
+	return cmd
 }
 
 func checkPrerequisites() error {
 	if !azure.IsAzCliInstalled() {
 		return fmt.Errorf("azure CLI (az) is not installed, see https://docs.microsoft.com/en-us/cli/azure/install-azure-cli")
 	}
+	if err := checkAzVersion(); err != nil {
+		return err
+	}
+	if oidcLogin {
+		if err := loginFederated(); err != nil {
+			return err
+		}
+	}
 
+	if signingIn {
+		return nil
+	}
 	if !azure.IsAuthenticated() {
-		return fmt.Errorf("not logged in to Azure CLI, run 'az login' first")
+		if activeContext != nil {
+			return withExitCode(exitAuthError, fmt.Errorf("not signed in to context %s, run 'hacktivator context login %s' first",
+				activeContext.Name, activeContext.Name))
+		}
+		return withExitCode(exitAuthError, fmt.Errorf("not logged in to Azure CLI, run 'hacktivator login' first"))
 	}
 
 	return nil
@@ -89,106 +402,582 @@ func fetchCurrentUser(nonInteractive bool) (*azure.UserInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
-	fmt.Printf("Logged in as: %s\n\n", ui.TitleStyle.Render(user.DisplayName))
+	infof("Logged in as: %s\n\n", ui.TitleStyle.Render(user.DisplayName))
 	return user, nil
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	if err := validateMemberTypes(); err != nil {
+		return err
+	}
+	var within time.Duration
+	if expiring != "" {
+		var err error
+		if within, err = parseDays(expiring); err != nil {
+			return err
+		}
+	}
+
 	if _, err := fetchCurrentUser(false); err != nil {
 		return err
 	}
 
-	eligibleRoles, err := ui.SpinWithResult("Fetching eligible roles", func() ([]azure.RoleAssignment, error) {
-		return azure.GetEligibleRoleAssignments()
-	}, false)
-	if err != nil {
-		return fmt.Errorf("failed to get eligible roles: %w", err)
+	eligibleRoles, ok := daemonEligibleRoles()
+	if !ok {
+		var err error
+		eligibleRoles, _, err = scanEligibleRoles(false)
+		if err != nil {
+			return fmt.Errorf("failed to get eligible roles: %w", err)
+		}
 	}
 
-	if len(eligibleRoles) == 0 {
-		fmt.Println("No eligible role assignments found.")
+	eligibleRoles = filterMemberTypes(filterSubscription(eligibleRoles))
+	if len(eligibleRoles) == 0 && outputFormat == "table" {
+		infof("No eligible role assignments found.\n")
 		return nil
 	}
 
-	fmt.Printf("Found %d eligible role(s):\n\n", len(eligibleRoles))
-	fmt.Print(ui.RenderRolesTable(eligibleRoles, false))
+	if expiring != "" {
+		eligibleRoles = expiringWithin(eligibleRoles, within)
+		if len(eligibleRoles) == 0 && outputFormat == "table" {
+			infof("No eligibilities end within %s.\n", expiring)
+			return nil
+		}
+		infof("Found %d eligible role(s) ending within %s:\n\n", len(eligibleRoles), expiring)
+		return printRoles(eligibleRoles, false)
+	}
+
+	warnExpiringEligibility(eligibleRoles)
+	infof("Found %d eligible role(s):\n\n", len(eligibleRoles))
+	return printRoles(eligibleRoles, false)
+}
 
+// addColumnsFlag registers --columns on a command that prints roles with printRoles
+func addColumnsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&columns, "columns", "", "Columns to show, in order, e.g. role,scope,expiry,member (also limits -o json fields)")
+}
+
+// delimiter returns the field separator for --output csv or tsv
+func delimiter() (rune, bool) {
+	switch outputFormat {
+	case "csv":
+		return ',', true
+	case "tsv":
+		return '\t', true
+	}
+	return 0, false
+}
+
+// printRoles writes roles to stdout in the selected --output format
+func printRoles(roles []azure.RoleAssignment, includeStatus bool) error {
+	if outputFormat == "json" {
+		out, err := ui.RenderRolesJSON(roles)
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Print(out)
+		return nil
+	}
+	if comma, ok := delimiter(); ok {
+		out, err := ui.RenderRolesDelimited(roles, comma, includeStatus)
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", strings.ToUpper(outputFormat), err)
+		}
+		fmt.Print(out)
+		return nil
+	}
+	if outputFormat == "markdown" {
+		fmt.Print(ui.RenderRolesMarkdown(roles, includeStatus))
+		return nil
+	}
+
+	fmt.Print(ui.RenderRolesTable(roles, includeStatus))
 	return nil
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
+	if statusFormat != "" {
+		return printStatusSegment(statusFormat)
+	}
+
+	if err := validateMemberTypes(); err != nil {
+		return err
+	}
 	if _, err := fetchCurrentUser(false); err != nil {
 		return err
 	}
+	if statusHistory {
+		return runStatusHistory()
+	}
 
+	var detailsErr error
 	activeRoles, err := ui.SpinWithResult("Fetching active roles", func() ([]azure.RoleAssignment, error) {
-		return azure.GetActiveRoleAssignments()
+		roles, err := azure.GetActiveRoleAssignments()
+		if err != nil {
+			return nil, err
+		}
+		detailsErr = azure.AttachActivationDetails(roles)
+		return roles, nil
 	}, false)
 	if err != nil {
 		return fmt.Errorf("failed to get active roles: %w", err)
 	}
+	if detailsErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load activation details: %v\n", detailsErr)
+	}
+	saveActiveCache(activeRoles)
+	attachScheduledDeactivations(activeRoles)
+	activeRoles = filterMemberTypes(filterRoles(activeRoles))
 
-	if len(activeRoles) == 0 {
-		fmt.Println("No active PIM role assignments found.")
+	if len(activeRoles) == 0 && outputFormat == "table" {
+		infof("No active PIM role assignments found.\n")
 		return nil
 	}
 
-	fmt.Printf("Found %d active role(s):\n\n", len(activeRoles))
-	fmt.Print(ui.RenderRolesTable(activeRoles, true))
+	// The browser can't scroll sideways, so --wide prints the whole table
+	if outputFormat == "table" && !quiet && !wide && ui.Interactive() {
+		return browseActiveRoles(activeRoles)
+	}
+
+	infof("Found %d active role(s):\n\n", len(activeRoles))
+	return printRoles(activeRoles, true)
+}
+
+// browseActiveRoles runs the interactive status table and performs the chosen action
+func browseActiveRoles(activeRoles []azure.RoleAssignment) error {
+	action, role, err := ui.BrowseActiveRoles(activeRoles)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case ui.StatusActionDeactivate:
+		result, err := ui.SpinWithResult(
+			fmt.Sprintf("Deactivating %s on %s", role.RoleName, role.ScopeName),
+			func() (*azure.ActivationResult, error) { return azure.DeactivateRole(*role) },
+			false,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to deactivate role: %w", err)
+		}
+		if result.IsDryRun() {
+			return nil
+		}
+		invalidateActiveCache()
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Deactivated %s on %s", role.RoleName, role.ScopeName)))
+
+	case ui.StatusActionExtend:
+		// The same checks as extending from the activation flow
+		user, err := fetchCurrentUser(nonInteractive)
+		if err != nil {
+			return err
+		}
+		roles := []azure.RoleAssignment{*role}
+		justification, err := authorizeRequests(nil, roles, extendDuration, user)
+		if err != nil {
+			return err
+		}
+		err = extendRoles(roles, extendDuration, justification)
+		invalidateActiveCache()
+		recordReason(justification, err)
+		return err
+	}
 
 	return nil
 }
 
 func runActivate(cmd *cobra.Command, args []string) error {
-	if _, err := fetchCurrentUser(nonInteractive); err != nil {
-		return err
+	if roleDefinitionID != "" || (directScope != "" && !isPattern(directScope)) {
+		return activateDirect()
 	}
+	return activate(func() ([]azure.RoleAssignment, error) {
+		roles, err := fetchEligibleRoles()
+		if err != nil {
+			return nil, err
+		}
+		matched := filterRoles(roles)
+		if len(matched) == 0 && len(roles) > 0 {
+			return nil, withExitCode(exitNothingEligible, fmt.Errorf("no eligible roles match --role, --subscription and --scope"))
+		}
+		return matched, nil
+	})
+}
 
-	eligibleRoles, err := ui.SpinWithResult("Fetching eligible roles", func() ([]azure.RoleAssignment, error) {
-		return azure.GetEligibleRoleAssignments()
-	}, nonInteractive)
+// activateDirect activates the role named by --scope and --role-definition-id,
+// e.g. copied from earlier JSON output, looking up only its eligibility
+// instead of scanning every subscription
+func activateDirect() error {
+	if directScope == "" || roleDefinitionID == "" {
+		return fmt.Errorf("--scope and --role-definition-id must be used together")
+	}
+	if isPattern(directScope) {
+		return fmt.Errorf("--role-definition-id needs an exact --scope, not a pattern")
+	}
+	if activateAll || rolePattern != "" || subscriptionPattern != "" {
+		return fmt.Errorf("--scope can't be combined with --all, --role or --subscription")
+	}
+	return activate(func() ([]azure.RoleAssignment, error) {
+		role, err := ui.SpinWithResult("Looking up the eligibility", func() (azure.RoleAssignment, error) {
+			return azure.FindEligibility(directScope, roleDefinitionID)
+		}, nonInteractive)
+		if err != nil {
+			return nil, err
+		}
+		return []azure.RoleAssignment{role}, nil
+	})
+}
+
+// fetchEligibleRoles discovers every role the user is eligible for
+func fetchEligibleRoles() ([]azure.RoleAssignment, error) {
+	if roles, ok := daemonEligibleRoles(); ok {
+		roles = filterSubscription(roles)
+		infof("Found %d eligible role(s) (from daemon)\n", len(roles))
+		warnExpiringEligibility(roles)
+		return roles, nil
+	}
+
+	roles, cached, err := scanEligibleRoles(nonInteractive)
 	if err != nil {
-		return fmt.Errorf("failed to get eligible roles: %w", err)
+		return nil, fmt.Errorf("failed to get eligible roles: %w", err)
+	}
+	roles = filterSubscription(roles)
+	if cached {
+		infof("Found %d eligible role(s) (cached)\n", len(roles))
+	} else {
+		infof("Found %d eligible role(s)\n", len(roles))
 	}
+	warnExpiringEligibility(roles)
+	return roles, nil
+}
 
-	fmt.Printf("Found %d eligible role(s)\n", len(eligibleRoles))
+// activate runs the activation flow on the candidate roles returned by load
+func activate(load func() ([]azure.RoleAssignment, error)) error {
+	if nonInteractive {
+		if err := checkRequirementsNonInteractive(); err != nil {
+			return err
+		}
+	}
 
+	user, err := fetchCurrentUser(nonInteractive)
+	if err != nil {
+		return err
+	}
+
+	eligibleRoles, err := load()
+	if err != nil {
+		return err
+	}
 	if len(eligibleRoles) == 0 {
-		fmt.Println("No eligible role assignments found.")
+		return withExitCode(exitNothingEligible, fmt.Errorf("no eligible role assignments found"))
+	}
+
+	var selectedRoles []azure.RoleAssignment
+	if activateAll {
+		if err := chooseDuration(eligibleRoles); err != nil {
+			return err
+		}
+		if err := confirmBulk(eligibleRoles); err != nil {
+			return err
+		}
+		selectedRoles = eligibleRoles
+	} else {
+		if nonInteractive && len(eligibleRoles) > 1 {
+			selectedRoles, err = pickNonInteractive(eligibleRoles)
+		} else {
+			selectedRoles, err = ui.SelectRoles(eligibleRoles, "Select role to activate", nonInteractive)
+		}
+		if err != nil {
+			return fmt.Errorf("role selection failed: %w", err)
+		}
+		if err := chooseDuration(selectedRoles); err != nil {
+			return err
+		}
+	}
+
+	selectedRoles, extendedRoles, err := skipAlreadyActive(selectedRoles)
+	if err != nil {
+		return err
+	}
+	if len(selectedRoles) == 0 && len(extendedRoles) == 0 {
+		infof("Nothing to activate.\n")
 		return nil
 	}
 
-	selectedRole, err := ui.SelectRole(eligibleRoles, nonInteractive)
+	if err := checkAutoDeactivate(); err != nil {
+		return err
+	}
+	justification, err := authorizeRequests(selectedRoles, extendedRoles, duration, user)
 	if err != nil {
-		return fmt.Errorf("role selection failed: %w", err)
+		return err
 	}
 
-	justification := reason
-	if justification == "" && !nonInteractive {
-		justification, err = ui.PromptForJustification()
-		if err != nil {
-			return fmt.Errorf("failed to get justification: %w", err)
+	if len(selectedRoles) > 0 {
+		err = activateRoles(selectedRoles, justification)
+	}
+	if len(extendedRoles) > 0 {
+		if extendErr := extendRoles(extendedRoles, duration, justification); extendErr != nil && (err == nil || exitCode(err) == exitPendingApproval) {
+			err = extendErr
+		}
+	}
+	invalidateActiveCache()
+	recordReason(justification, err)
+	return err
+}
+
+// authorizeRequests runs the checks every activation and extension goes
+// through, wherever it was started: the guardrails, the ticket, the
+// justification and its requirements, and the confirmation of high-risk
+// roles. It returns the justification.
+func authorizeRequests(activating, extending []azure.RoleAssignment, minutes int, user *azure.UserInfo) (string, error) {
+	var guarded []guardedRequest
+	for _, role := range activating {
+		guarded = append(guarded, guardedRequest{role: role, minutes: minutes})
+	}
+	for _, role := range extending {
+		guarded = append(guarded, guardedRequest{role: role, minutes: minutes, extend: true})
+	}
+	if err := checkGuardrails(guarded, nil); err != nil {
+		return "", err
+	}
+
+	if err := resolveTicket(); err != nil {
+		return "", err
+	}
+
+	roles := append(append([]azure.RoleAssignment(nil), activating...), extending...)
+	justification, err := resolveJustification(roles, user)
+	if err != nil {
+		return "", err
+	}
+
+	if err := cfg.Requirements.CheckTicket(ticketNum); err != nil {
+		return "", err
+	}
+	if err := cfg.Requirements.CheckReason(justification); err != nil {
+		return "", err
+	}
+	if err := confirmHighRisk(roles, justification); err != nil {
+		return "", err
+	}
+	return justification, nil
+}
+
+// recordReason adds the justification to the reason history unless the
+// requests it was given for failed
+func recordReason(justification string, err error) {
+	if (err == nil || exitCode(err) == exitPendingApproval) && !dryRun {
+		if recordErr := state.RecordReason(justification, cfg.ReasonHistorySize); recordErr != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save reason history: %v\n", recordErr)
 		}
 	}
+}
 
-	activationRequest := azure.ActivationRequest{
-		Role:          *selectedRole,
+// activationRequest builds the request for a role from the activation flags
+func activationRequest(role azure.RoleAssignment, justification string) azure.ActivationRequest {
+	return azure.ActivationRequest{
+		Role:          role,
 		Duration:      duration,
 		Justification: justification,
 		TicketNumber:  ticketNum,
 		TicketSystem:  ticketSys,
+		Fresh:         force,
+	}
+}
+
+// activateRoles submits an activation request for each role, concurrently
+// with a live status list when there are several. Failures don't stop the
+// remaining roles; the first error is returned at the end.
+func activateRoles(roles []azure.RoleAssignment, justification string) error {
+	var outcomes []activationOutcome
+	if len(roles) == 1 {
+		result, err := activateRole(roles[0], justification)
+		outcomes = []activationOutcome{{role: roles[0], result: result, err: err}}
+	} else {
+		var err error
+		if outcomes, err = submitActivations(roles, justification); err != nil {
+			return err
+		}
+	}
+	retryWithMFA(outcomes, justification)
+
+	if outputFormat == "json" {
+		if err := printActivationResults(outcomes); err != nil {
+			return err
+		}
+	}
+
+	var firstErr error
+	var queued []string
+	var activated []azure.RoleAssignment
+	failed, pending := 0, false
+	for _, o := range outcomes {
+		if o.err == nil && o.result.IsFailed() {
+			o.err = fmt.Errorf("request %s ended with status %s", o.result.RequestID, o.result.Status)
+		}
+		if queueActivation(activationRequest(o.role, justification), o.err) {
+			queued = append(queued, o.role.RoleName+" on "+o.role.ScopeName)
+			continue
+		}
+		if o.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to activate %s on %s: %w", o.role.RoleName, o.role.ScopeName, o.err)
+			}
+			continue
+		}
+		if o.result.IsDryRun() {
+			continue
+		}
+		if !o.result.Existing {
+			recordActivation(o.role)
+		}
+		if o.result.IsPendingApproval() {
+			pending = true
+			if autoDeactivate > 0 {
+				fmt.Fprintf(os.Stderr, "warning: %s on %s is pending approval, so its deactivation isn't scheduled\n", o.role.RoleName, o.role.ScopeName)
+			}
+			continue
+		}
+		activated = append(activated, o.role)
 	}
+	scheduleDeactivations(activated)
+
+	if firstErr != nil {
+		if failed > 1 {
+			return fmt.Errorf("%d of %d activations failed, first error: %w", failed, len(roles), firstErr)
+		}
+		return firstErr
+	}
+	if len(queued) > 0 {
+		return fmt.Errorf("Azure is unreachable, queued the activation of %s to retry on the next run or by the daemon (see 'hacktivator queue list')",
+			strings.Join(queued, ", "))
+	}
+	if pending {
+		return withExitCode(exitPendingApproval, nil)
+	}
+	return nil
+}
 
-	err = ui.SpinWithAction(
-		fmt.Sprintf("Activating %s on %s", selectedRole.RoleName, selectedRole.ScopeName),
-		func() error { return azure.ActivateRole(activationRequest) },
+// activateRole submits a single activation request behind a spinner
+func activateRole(role azure.RoleAssignment, justification string) (*azure.ActivationResult, error) {
+	result, err := ui.SpinWithResult(
+		fmt.Sprintf("Activating %s on %s", role.RoleName, role.ScopeName),
+		func() (*azure.ActivationResult, error) {
+			return azure.ActivateRole(activationRequest(role, justification))
+		},
 		nonInteractive,
 	)
-	if err != nil {
-		return fmt.Errorf("failed to activate role: %w", err)
+	if err != nil || result.IsDryRun() || result.IsFailed() {
+		return result, err
+	}
+
+	if result.Existing {
+		infof("%s\n", ui.TitleStyle.Render(fmt.Sprintf(
+			"Activation of %s was already requested (request %s, %s); not submitting it again, use --force for a new request",
+			role.RoleName, result.RequestID, result.Status)))
+		return result, nil
 	}
 
-	fmt.Println(ui.SuccessStyle.Render(
-		fmt.Sprintf("Successfully activated %s for %d minutes", selectedRole.RoleName, duration)))
+	if result.IsPendingApproval() {
+		infof("%s\n", ui.TitleStyle.Render(
+			fmt.Sprintf("Activation of %s submitted and pending approval (request %s)", role.RoleName, result.RequestID)))
+		return result, nil
+	}
+
+	infof("%s\n", ui.SuccessStyle.Render(
+		fmt.Sprintf("Successfully activated %s for %d minutes", role.RoleName, duration)))
+	return result, nil
+}
+
+// recordActivation remembers the role for most-recently-used ordering
+func recordActivation(role azure.RoleAssignment) {
+	if err := state.RecordActivation(role); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to save activation history: %v\n", err)
+	}
+}
+
+// checkRequirementsNonInteractive fails fast when config requirements cannot be
+// met because prompting is disabled
+func checkRequirementsNonInteractive() error {
+	if err := cfg.Requirements.CheckTicket(ticketNum); err != nil {
+		return fmt.Errorf("%w by config in non-interactive mode, pass --ticket-number", err)
+	}
+	if reasonTmpl == "" {
+		if err := cfg.Requirements.CheckReason(reason); err != nil {
+			return fmt.Errorf("%w, pass a longer --reason or use --template", err)
+		}
+	}
 	return nil
 }
+
+// reasonVars returns the values available to {{name}} placeholders in reasons.
+// With several roles selected, role and scope list each distinct value.
+func reasonVars(roles []azure.RoleAssignment, user *azure.UserInfo) map[string]string {
+	var names, scopes, scopeTypes []string
+	for _, r := range roles {
+		names = appendUnique(names, r.RoleName)
+		scopes = appendUnique(scopes, r.ScopeName)
+		scopeTypes = appendUnique(scopeTypes, r.ScopeType)
+	}
+
+	return map[string]string{
+		"ticket":        ticketNum,
+		"ticket_system": ticketSys,
+		"ticket_title":  ticketTitle,
+		"role":          strings.Join(names, ", "),
+		"scope":         strings.Join(scopes, ", "),
+		"scope_type":    strings.Join(scopeTypes, ", "),
+		"user":          user.DisplayName,
+		"duration":      fmt.Sprintf("%dm", duration),
+		"date":          time.Now().Format("2006-01-02"),
+	}
+}
+
+func appendUnique(values []string, v string) []string {
+	for _, existing := range values {
+		if existing == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
+
+// resolveJustification determines the justification from the --template and
+// --reason flags, falling back to an interactive prompt offering expanded
+// templates and recently used reasons.
+func resolveJustification(roles []azure.RoleAssignment, user *azure.UserInfo) (string, error) {
+	vars := reasonVars(roles, user)
+
+	if reasonTmpl != "" {
+		tmpl, ok := cfg.ReasonTemplates[reasonTmpl]
+		if !ok {
+			return "", fmt.Errorf("reason template %q not found in config", reasonTmpl)
+		}
+		return config.ExpandReason(tmpl, vars), nil
+	}
+
+	if reason != "" || nonInteractive {
+		return config.ExpandReason(reason, vars), nil
+	}
+
+	var suggestions []string
+	for _, name := range cfg.TemplateNames() {
+		suggestions = append(suggestions, config.ExpandReason(cfg.ReasonTemplates[name], vars))
+	}
+	recent, err := state.RecentReasons()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load reason history: %v\n", err)
+	}
+	suggestions = append(suggestions, recent...)
+
+	var validate func(string) error
+	if cfg.Requirements.ReasonRequired() {
+		validate = cfg.Requirements.CheckReason
+	}
+
+	justification, err := ui.PromptForJustification(ticketTitle, suggestions, validate)
+	if err != nil {
+		return "", fmt.Errorf("failed to get justification: %w", err)
+	}
+	return justification, nil
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// mcpProtocolVersion is the newest Model Context Protocol revision we speak
+const mcpProtocolVersion = "2025-06-18"
+
+var mcpAssumeYes bool
+
+func mcpCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve PIM operations to AI assistants over the Model Context Protocol",
+		Long: `Runs a Model Context Protocol server on stdin and stdout so assistants can list
+roles and request activations. Register it in the assistant's MCP settings with
+the command "hacktivator mcp".
+
+Every activation and deactivation needs human confirmation. The assistant's
+client is asked to confirm with you when it supports elicitation; otherwise the
+question is asked on this machine's terminal. Without either, the request is
+refused unless --yes is set.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// stdout carries the protocol; nothing else may be printed there
+			ui.Quiet = true
+			azure.DryRunOutput = os.Stderr
+			return newMCPServer(os.Stdin, os.Stdout).serve()
+		},
+	}
+	cmd.Flags().BoolVarP(&mcpAssumeYes, "yes", "y", false, "Skip confirmation when neither the client nor a terminal can ask")
+	return cmd
+}
+
+// JSON-RPC 2.0 messages as used by MCP over stdio, one per line
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type mcpServer struct {
+	in io.Reader
+
+	mu  sync.Mutex
+	out *json.Encoder
+
+	// Requests we sent to the client, keyed by ID, awaiting a response
+	pendingMu sync.Mutex
+	pending   map[string]chan rpcMessage
+	nextID    int
+	closed    bool
+
+	canElicit bool
+}
+
+func newMCPServer(in io.Reader, out io.Writer) *mcpServer {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	return &mcpServer{in: in, out: enc, pending: map[string]chan rpcMessage{}}
+}
+
+func (s *mcpServer) send(msg rpcMessage) {
+	msg.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Encode(msg); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to write MCP message: %v\n", err)
+	}
+}
+
+func (s *mcpServer) reply(id json.RawMessage, result any, rerr *rpcError) {
+	msg := rpcMessage{ID: id, Error: rerr}
+	if rerr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &rpcError{Code: -32603, Message: err.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	s.send(msg)
+}
+
+// serve reads messages until the client closes stdin. Tool calls run in their
+// own goroutine so they can wait on confirmation requests sent to the client.
+func (s *mcpServer) serve() error {
+	scanner := bufio.NewScanner(s.in)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+
+		switch {
+		case msg.Method == "" && msg.ID != nil:
+			s.deliver(msg)
+		case msg.ID == nil:
+			// Notifications such as notifications/initialized need no answer
+		case msg.Method == "tools/call":
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer handlePanic()
+				result, rerr := s.callTool(msg.Params)
+				s.reply(msg.ID, result, rerr)
+			}()
+		default:
+			result, rerr := s.handle(msg)
+			s.reply(msg.ID, result, rerr)
+		}
+	}
+
+	// Release tool calls still waiting on the client
+	s.pendingMu.Lock()
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+	s.closed = true
+	s.pendingMu.Unlock()
+	return scanner.Err()
+}
+
+func (s *mcpServer) handle(msg rpcMessage) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			Capabilities    struct {
+				Elicitation *struct{} `json:"elicitation"`
+			} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		s.canElicit = params.Capabilities.Elicitation != nil
+
+		protocol := mcpProtocolVersion
+		switch params.ProtocolVersion {
+		case "2024-11-05", "2025-03-26":
+			protocol = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": protocol,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "hacktivator", "version": version},
+			"instructions":    "Activate Azure PIM roles only when the user's task needs them, with a specific justification. The user confirms every activation.",
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+// deliver hands a client's response to the request waiting for it
+func (s *mcpServer) deliver(msg rpcMessage) {
+	s.pendingMu.Lock()
+	ch, ok := s.pending[string(msg.ID)]
+	delete(s.pending, string(msg.ID))
+	s.pendingMu.Unlock()
+	if ok {
+		ch <- msg
+	}
+}
+
+// request sends a request to the client and waits for its response
+func (s *mcpServer) request(method string, params any) (rpcMessage, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return rpcMessage{}, err
+	}
+	s.pendingMu.Lock()
+	if s.closed {
+		s.pendingMu.Unlock()
+		return rpcMessage{}, errors.New("the client disconnected")
+	}
+	s.nextID++
+	id := json.RawMessage(fmt.Sprintf("%d", s.nextID))
+	ch := make(chan rpcMessage, 1)
+	s.pending[string(id)] = ch
+	s.pendingMu.Unlock()
+
+	s.send(rpcMessage{ID: id, Method: method, Params: data})
+	resp, ok := <-ch
+	if !ok {
+		return resp, errors.New("the client disconnected")
+	}
+	if resp.Error != nil {
+		return resp, fmt.Errorf("%s failed: %s", method, resp.Error.Message)
+	}
+	return resp, nil
+}
+
+// mcpTool describes one tool in tools/list
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+var mcpRoleProperties = map[string]any{
+	"roleName": map[string]any{"type": "string", "description": "Role name, e.g. Contributor"},
+	"scope":    map[string]any{"type": "string", "description": "Full scope ID, e.g. /subscriptions/<id>"},
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "list_eligible_roles",
+		Description: "List the Azure PIM roles the user is eligible to activate",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+	},
+	{
+		Name:        "list_active_roles",
+		Description: "List the user's currently active Azure role assignments",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+	},
+	{
+		Name:        "activate_role",
+		Description: "Request activation of an eligible Azure PIM role. The user is asked to confirm.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"roleName":      mcpRoleProperties["roleName"],
+				"scope":         mcpRoleProperties["scope"],
+				"justification": map[string]any{"type": "string", "description": "Why the role is needed"},
+				"duration":      map[string]any{"type": "integer", "description": "Minutes to activate for"},
+				"ticketNumber":  map[string]any{"type": "string", "description": "Validated with the configured ticket provider, if any"},
+				"ticketSystem":  map[string]any{"type": "string", "description": "Defaults to the configured ticket provider"},
+			},
+			"required": []string{"roleName", "scope", "justification"},
+		},
+	},
+	{
+		Name:        "deactivate_role",
+		Description: "Deactivate an active Azure PIM role. The user is asked to confirm.",
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": mcpRoleProperties,
+			"required":   []string{"roleName", "scope"},
+		},
+	},
+}
+
+// toolResult builds a tools/call result with a single text block
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func toolError(err error) (any, *rpcError) {
+	return toolResult(err.Error(), true), nil
+}
+
+func (s *mcpServer) callTool(raw json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+
+	switch params.Name {
+	case "list_eligible_roles", "list_active_roles":
+		var roles []azure.RoleAssignment
+		var err error
+		if params.Name == "list_eligible_roles" {
+			roles, err = serveEligibleRoles()
+		} else if roles, err = azure.GetActiveRoleAssignments(); err == nil {
+			saveActiveCache(roles)
+		}
+		if err != nil {
+			return toolError(err)
+		}
+		data, err := ui.RenderRolesJSON(roles)
+		if err != nil {
+			return toolError(err)
+		}
+		return toolResult(data, false), nil
+
+	case "activate_role", "deactivate_role":
+		var req apiRoleRequest
+		if len(params.Arguments) > 0 {
+			if err := json.Unmarshal(params.Arguments, &req); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+		}
+		if req.RoleName == "" || req.Scope == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "roleName and scope are required"}
+		}
+		if params.Name == "activate_role" {
+			return s.activate(&req)
+		}
+		return s.deactivate(&req)
+	}
+	return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name}
+}
+
+func (s *mcpServer) activate(req *apiRoleRequest) (any, *rpcError) {
+	if err := checkActivationRequest(req); err != nil {
+		return toolError(err)
+	}
+	role, err := findEligibleRole(req)
+	if err != nil {
+		return toolError(err)
+	}
+	if err := refuseHighRisk(*role); err != nil {
+		return toolError(err)
+	}
+	if err := checkGuardrails([]guardedRequest{{role: *role, minutes: req.Duration}}, nil); err != nil {
+		return toolError(err)
+	}
+	question := fmt.Sprintf("Activate %s on %s for %d minutes?\nJustification: %s",
+		role.RoleName, scopeLabel(*role), req.Duration, req.Justification)
+	if err := s.confirm(question); err != nil {
+		return toolError(err)
+	}
+
+	result, err := activateRequested(*role, req)
+	return roleToolResult(*role, result, err)
+}
+
+func (s *mcpServer) deactivate(req *apiRoleRequest) (any, *rpcError) {
+	role, err := findActiveRole(req)
+	if err != nil {
+		return toolError(err)
+	}
+	if err := s.confirm(fmt.Sprintf("Deactivate %s on %s?", role.RoleName, scopeLabel(*role))); err != nil {
+		return toolError(err)
+	}
+
+	result, err := deactivateRequested(*role)
+	return roleToolResult(*role, result, err)
+}
+
+func roleToolResult(role azure.RoleAssignment, result *azure.ActivationResult, err error) (any, *rpcError) {
+	out := toActivationResultJSON(activationOutcome{role: role, result: result, err: err})
+	data, merr := json.MarshalIndent(out, "", "  ")
+	if merr != nil {
+		return toolError(merr)
+	}
+	return toolResult(string(data), err != nil), nil
+}
+
+func scopeLabel(role azure.RoleAssignment) string {
+	if role.ScopeName != "" {
+		return role.ScopeName
+	}
+	return role.Scope
+}
+
+// errDeclined is returned when the user turns down a tool call
+var errDeclined = errors.New("the user declined the request")
+
+// confirm asks the user through the client if it supports elicitation, and
+// on the local terminal otherwise
+func (s *mcpServer) confirm(question string) error {
+	if s.canElicit {
+		resp, err := s.request("elicitation/create", map[string]any{
+			"message": question,
+			"requestedSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"confirm": map[string]any{"type": "boolean", "title": "Approve"},
+				},
+				"required": []string{"confirm"},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Action  string `json:"action"`
+			Content struct {
+				Confirm bool `json:"confirm"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return fmt.Errorf("invalid confirmation response: %w", err)
+		}
+		if result.Action != "accept" || !result.Content.Confirm {
+			return errDeclined
+		}
+		return nil
+	}
+
+	ok, err := confirmOnTerminal(question)
+	switch {
+	case err == nil && ok:
+		return nil
+	case err == nil:
+		return errDeclined
+	case mcpAssumeYes:
+		return nil
+	}
+	return fmt.Errorf("cannot ask for confirmation (%v); run hacktivator mcp with --yes to skip it", err)
+}
+
+// terminalMu keeps concurrent tool calls from interleaving their prompts
+var terminalMu sync.Mutex
+
+// confirmOnTerminal asks on the controlling terminal, since stdin and stdout
+// belong to the protocol
+func confirmOnTerminal(question string) (bool, error) {
+	inPath, outPath := "/dev/tty", "/dev/tty"
+	if runtime.GOOS == "windows" {
+		inPath, outPath = "CONIN$", "CONOUT$"
+	}
+	terminalMu.Lock()
+	defer terminalMu.Unlock()
+
+	in, err := os.Open(inPath)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(outPath, os.O_WRONLY, 0)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	fmt.Fprintf(out, "\n%s\n%s [y/N]: ", ui.TitleStyle.Render("An assistant is requesting:"), question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/browser"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// retryWithMFA handles activations that were rejected because the session
+// wasn't signed in with MFA. On a terminal it offers to sign in again with
+// MFA and retries them; otherwise, or if the user declines, it adds the
+// command to run to their errors.
+func retryWithMFA(outcomes []activationOutcome, justification string) {
+	var rejected []int
+	for i, o := range outcomes {
+		if errors.Is(o.err, azure.ErrMFARequired) {
+			rejected = append(rejected, i)
+		}
+	}
+	if len(rejected) == 0 {
+		return
+	}
+
+	tenant := signInTenant()
+	if stepUpMFA(tenant) {
+		for _, i := range rejected {
+			result, err := activateRole(outcomes[i].role, justification)
+			outcomes[i].result, outcomes[i].err = result, err
+		}
+	}
+
+	for _, i := range rejected {
+		if errors.Is(outcomes[i].err, azure.ErrMFARequired) {
+			outcomes[i].err = fmt.Errorf("the role requires MFA, but your Azure CLI session wasn't signed in with it; "+
+				"run '%s' and try again: %w", azure.StepUpCommand(tenant, !browser.Available()), outcomes[i].err)
+		}
+	}
+}
+
+// stepUpMFA asks whether to sign in again with MFA and does so, reporting
+// whether the activations should be retried
+func stepUpMFA(tenant string) bool {
+	if nonInteractive || !term.IsTerminal(os.Stdin.Fd()) {
+		return false
+	}
+	fmt.Fprintln(os.Stderr, "The role requires MFA, but your Azure CLI session wasn't signed in with it.")
+	ok, err := ui.Confirm("Sign in again with MFA and retry?")
+	if err != nil || !ok {
+		return false
+	}
+	if err := azure.StepUpLogin(tenant, !browser.Available()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to sign in again: %v\n", err)
+		return false
+	}
+	return true
+}
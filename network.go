@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var insecureSkipVerify bool
+
+// configureNetwork applies the proxy and TLS settings to every HTTP client in
+// the process: direct ARM calls and the ticket and notification integrations.
+// Without configuration, HTTPS_PROXY and NO_PROXY are honored as usual.
+func configureNetwork(n config.Network) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if n.Proxy != "" {
+		proxy, err := url.Parse(n.Proxy)
+		if err != nil || proxy.Host == "" {
+			return fmt.Errorf("invalid network.proxy %q", n.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+		// The Azure CLI reads the environment, so send it through the same proxy
+		os.Setenv("HTTPS_PROXY", n.Proxy)
+	}
+
+	if n.CABundle != "" {
+		pem, err := os.ReadFile(n.CABundle)
+		if err != nil {
+			return fmt.Errorf("failed to read network.ca_bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("network.ca_bundle %s contains no PEM certificates", n.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	if insecureSkipVerify || n.InsecureSkipVerify {
+		fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render(
+			"WARNING: TLS certificate verification is disabled. Requests and your access token can be intercepted. Use network.ca_bundle instead."))
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	http.DefaultTransport = transport
+	return nil
+}
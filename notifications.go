@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/notify"
+	"github.com/ica-js/hacktivator/internal/secrets"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// pendingRequestTTL is how long a request awaiting approval is tracked. PIM
+// approval requests expire after 24 hours.
+const pendingRequestTTL = 25 * time.Hour
+
+// scopedNotifier is a notification channel limited to matching scopes
+type scopedNotifier struct {
+	name string
+	notify.Notifier
+	scopes []string
+	// pingApprovers names the role's approvers in pending request notices
+	pingApprovers bool
+}
+
+var (
+	notifiers []scopedNotifier
+	// emailApprovers sends pending requests to the approvers' mailboxes
+	emailApprovers bool
+	// remindAfter is how long a request may stay pending before the daemon
+	// announces it again, 0 for never
+	remindAfter time.Duration
+)
+
+// configureNotifications sets up the channels in the config
+func configureNotifications(n config.Notifications) error {
+	if n.Teams != nil {
+		url, err := webhookURL("teams", *n.Teams)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, scopedNotifier{name: "teams", Notifier: &notify.Teams{URL: url}, scopes: n.Teams.Scopes})
+	}
+	if n.Slack != nil {
+		url, err := webhookURL("slack", *n.Slack)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, scopedNotifier{name: "slack", Notifier: &notify.Slack{URL: url}, scopes: n.Slack.Scopes})
+	}
+	if n.Email != nil {
+		if len(n.Email.To) == 0 {
+			return fmt.Errorf("notifications.email needs at least one address in to")
+		}
+		notifiers = append(notifiers, scopedNotifier{name: "email", Notifier: &notify.Email{To: n.Email.To}, scopes: n.Email.Scopes})
+	}
+
+	for _, channel := range n.Approvers {
+		switch channel {
+		case "email":
+			emailApprovers = true
+			continue
+		case "teams", "slack":
+		default:
+			return fmt.Errorf("unsupported notifications.approvers channel %q (supported: email, teams, slack)", channel)
+		}
+		found := false
+		for i := range notifiers {
+			if notifiers[i].name == channel {
+				notifiers[i].pingApprovers, found = true, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("notifications.approvers includes %s, but notifications.%s is not configured", channel, channel)
+		}
+	}
+
+	if n.RemindAfter != "" {
+		d, err := time.ParseDuration(n.RemindAfter)
+		if err != nil || d < time.Minute {
+			return fmt.Errorf("invalid notifications.remind_after %q, use a duration of at least a minute like 30m or 2h", n.RemindAfter)
+		}
+		remindAfter = d
+	}
+	return nil
+}
+
+// webhookURL resolves a webhook URL from the keyring or the config
+func webhookURL(name string, w config.Webhook) (string, error) {
+	url, err := secrets.Resolve(w.URLSecret, os.ExpandEnv(w.URL))
+	if err != nil {
+		return "", fmt.Errorf("notifications.%s: %w", name, err)
+	}
+	if url == "" {
+		return "", fmt.Errorf("notifications.%s needs a url or url_secret", name)
+	}
+	return url, nil
+}
+
+// onScheduleRequest is called by the azure package after every activate,
+// extend and deactivate request, and every admin request
+func onScheduleRequest(e azure.RequestEvent) {
+	if e.Result != nil && e.Result.IsDryRun() {
+		return
+	}
+	writeAuditEvent(e)
+	if e.RequestType == "SelfActivate" && e.Err == nil && !e.Result.IsFailed() {
+		if e.Result.IsPendingApproval() {
+			// Tracked for the pending command, and so the daemon can
+			// announce the decision
+			if err := state.AddPendingRequest(e.Role, e.Result.RequestID, e.Duration, e.Justification); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to track pending request: %v\n", err)
+			}
+		}
+		notifyActivation(e)
+	}
+	// Granting someone a break-glass role is announced like activating it
+	if e.RequestType == "AdminAssign" && !e.Eligibility && isHighRisk(e.Role) && e.Err == nil && !e.Result.IsFailed() {
+		notifyActivation(e)
+	}
+}
+
+// notifyActivation announces a submitted activation, or an active role
+// granted to another principal
+func notifyActivation(e azure.RequestEvent) {
+	if len(notifiers) == 0 && !emailApprovers {
+		return
+	}
+	event := notify.Event{
+		Kind:          notify.Activated,
+		Requester:     signedInUser(),
+		Grantee:       e.Principal,
+		RoleName:      e.Role.RoleName,
+		Scope:         e.Role.Scope,
+		ScopeName:     e.Role.ScopeName,
+		Duration:      e.Duration,
+		Justification: e.Justification,
+		TicketNumber:  e.TicketNumber,
+		TicketSystem:  e.TicketSystem,
+		RequestID:     e.Result.RequestID,
+		PortalURL:     azure.PortalURL(e.Role),
+		HighRisk:      isHighRisk(e.Role),
+	}
+	if e.Result.IsPendingApproval() {
+		event.Kind = notify.PendingApproval
+		event.ApprovalURL = azure.ApprovalsURL
+		event.Approvers = pingApprovers(event, e.Role)
+	} else if e.Duration > 0 {
+		event.Expires = ui.DisplayTime(time.Now().Add(time.Duration(e.Duration) * time.Minute))
+	}
+	sendNotification(event, e.Role)
+}
+
+// pingApprovers looks up the role's approvers if any channel pings them, and
+// emails them directly when configured
+func pingApprovers(event notify.Event, role azure.RoleAssignment) []azure.Approver {
+	wanted := emailApprovers
+	for _, n := range notifiers {
+		wanted = wanted || n.pingApprovers
+	}
+	if !wanted {
+		return nil
+	}
+
+	approvers, err := azure.GetApprovers(role)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to look up approvers: %v\n", err)
+		return nil
+	}
+	event.Approvers = approvers
+	if emailApprovers {
+		if err := (notify.ApproverEmail{}).Notify(event); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to email approvers: %v\n", err)
+		}
+	}
+	return approvers
+}
+
+// sendNotification delivers an event to every channel whose scopes match the
+// role, and to every channel for break-glass roles
+func sendNotification(event notify.Event, role azure.RoleAssignment) {
+	for _, n := range notifiers {
+		if !event.HighRisk && !notifierMatches(n, role) {
+			continue
+		}
+		e := event
+		if !n.pingApprovers {
+			e.Approvers = nil
+		}
+		if err := n.Notify(e); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to send %s notification: %v\n", n.name, err)
+		}
+	}
+}
+
+func notifierMatches(n scopedNotifier, role azure.RoleAssignment) bool {
+	if len(n.scopes) == 0 {
+		return true
+	}
+	for _, pattern := range n.scopes {
+		if globMatch(pattern, role.ScopeName) || globMatch(pattern, role.SubscriptionName) || globMatch(pattern, role.Scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPendingApprovals announces tracked requests that were approved or
+// denied since the last check, and reminds approvers of the ones that have
+// been pending for longer than remindAfter
+func checkPendingApprovals() {
+	pending, err := state.PendingRequests()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to load pending requests: %v\n", err)
+		return
+	}
+
+	var done, reminded []string
+	for _, p := range pending {
+		if time.Since(p.SubmittedAt) > pendingRequestTTL {
+			done = append(done, p.RequestID)
+			continue
+		}
+		result, err := azure.GetScheduleRequest(p.Scope, p.RequestID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to check request %s: %v\n", p.RequestID, err)
+			continue
+		}
+
+		var kind notify.Kind
+		switch {
+		case result.IsProvisioned():
+			kind = notify.Approved
+		case result.IsFailed():
+			kind = notify.Denied
+		default:
+			if remindAfter > 0 && time.Since(lastNotice(p)) >= remindAfter {
+				remindApprovers(p)
+				reminded = append(reminded, p.RequestID)
+			}
+			continue
+		}
+		role := p.Role()
+		event := notify.Event{
+			Kind:          kind,
+			Requester:     signedInUser(),
+			RoleName:      p.RoleName,
+			Scope:         p.Scope,
+			ScopeName:     p.ScopeName,
+			Duration:      p.Duration,
+			Justification: p.Justification,
+			RequestID:     p.RequestID,
+			PortalURL:     azure.PortalURL(role),
+			HighRisk:      isHighRisk(role),
+		}
+		if kind == notify.Approved && p.Duration > 0 {
+			// Approval starts the activation, at most one daemon refresh ago
+			event.Expires = ui.DisplayTime(time.Now().Add(time.Duration(p.Duration) * time.Minute))
+		}
+		sendNotification(event, role)
+		done = append(done, p.RequestID)
+	}
+
+	if len(done) > 0 {
+		if err := state.RemovePendingRequests(done...); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update pending requests: %v\n", err)
+		}
+	}
+	if len(reminded) > 0 {
+		if err := state.MarkPendingReminded(reminded...); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update pending requests: %v\n", err)
+		}
+	}
+}
+
+// lastNotice is when a pending request was last announced
+func lastNotice(p state.PendingRequest) time.Time {
+	if p.RemindedAt.After(p.SubmittedAt) {
+		return p.RemindedAt
+	}
+	return p.SubmittedAt
+}
+
+// remindApprovers announces a request that is still pending again, pinging
+// the approvers on the channels that do
+func remindApprovers(p state.PendingRequest) {
+	role := p.Role()
+	event := notify.Event{
+		Kind:          notify.PendingApproval,
+		Requester:     signedInUser(),
+		RoleName:      p.RoleName,
+		Scope:         p.Scope,
+		ScopeName:     p.ScopeName,
+		Duration:      p.Duration,
+		Justification: p.Justification,
+		RequestID:     p.RequestID,
+		PortalURL:     azure.PortalURL(role),
+		ApprovalURL:   azure.ApprovalsURL,
+		Waiting:       time.Since(p.SubmittedAt),
+		HighRisk:      isHighRisk(role),
+	}
+	event.Approvers = pingApprovers(event, role)
+	sendNotification(event, role)
+}
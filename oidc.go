@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// oidcLogin signs in as a federated service principal before the run
+var oidcLogin bool
+
+// loginFederated signs the Azure CLI in as the service principal named by
+// the environment with the pipeline's OIDC token, so a pipeline can activate
+// the roles its service principal is eligible for without a stored secret
+func loginFederated() error {
+	id, err := azure.FederatedIdentityFromEnv()
+	if err != nil {
+		return withExitCode(exitAuthError, err)
+	}
+	token, source, err := azure.FederatedToken()
+	if err != nil {
+		return withExitCode(exitAuthError, err)
+	}
+	err = ui.SpinWithAction(fmt.Sprintf("Signing in as %s with the %s OIDC token", id.ClientID, source), func() error {
+		return azure.LoginFederated(id, token)
+	}, nonInteractive)
+	if err != nil {
+		return withExitCode(exitAuthError, err)
+	}
+	return nil
+}
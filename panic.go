@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// handlePanic restores the terminal and exits when the calling goroutine
+// panics, so a crash inside a full-screen interface doesn't leave the shell
+// unusable. Defer it first in main and in every goroutine started while an
+// interface may be running.
+func handlePanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	ui.Teardown()
+	fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render(fmt.Sprintf("Error: internal error: %v", r)))
+	fmt.Fprintf(os.Stderr, "%s\n", debug.Stack())
+	os.Exit(exitFailure)
+}
+
+// handleTermination restores the terminal and exits when the process is
+// asked to stop with SIGTERM or SIGHUP
+func handleTermination() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		s := <-sig
+		ui.Teardown()
+		fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render(fmt.Sprintf("Error: terminated by %v", s)))
+		os.Exit(128 + int(s.(syscall.Signal)))
+	}()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+func pendingCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pending",
+		Short: "List your requests waiting for approval and how long they have waited",
+		Long: `Lists the activation requests you made with hacktivator that are still
+waiting for approval, with how long each has waited. PIM cancels requests
+that aren't approved within 24 hours.
+
+Run 'hacktivator approvers --pending' to see whom to ask. With
+notifications.remind_after in the config, the daemon reminds the approvers
+of requests that keep waiting.`,
+		Args: cobra.NoArgs,
+		RunE: runPending,
+	}
+}
+
+// pendingJSON is a request in the output of pending
+type pendingJSON struct {
+	RequestID      string    `json:"requestId"`
+	RoleName       string    `json:"roleName"`
+	ScopeName      string    `json:"scopeName,omitempty"`
+	Scope          string    `json:"scope"`
+	Status         string    `json:"status"`
+	SubmittedAt    time.Time `json:"submittedAt"`
+	WaitingMinutes int       `json:"waitingMinutes"`
+	RemindedAt     time.Time `json:"remindedAt,omitzero"`
+}
+
+func runPending(cmd *cobra.Command, args []string) error {
+	tracked, err := state.PendingRequests()
+	if err != nil {
+		return err
+	}
+
+	type checked struct {
+		state.PendingRequest
+		status string
+	}
+	results, err := ui.SpinWithResult("Checking pending requests", func() ([]checked, error) {
+		var results []checked
+		for _, p := range tracked {
+			if time.Since(p.SubmittedAt) > pendingRequestTTL {
+				continue
+			}
+			result, err := azure.GetScheduleRequest(p.Scope, p.RequestID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check request %s: %w", p.RequestID, err)
+			}
+			results = append(results, checked{PendingRequest: p, status: result.Status})
+		}
+		return results, nil
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	var waiting []checked
+	var resolved []string
+	for _, r := range results {
+		if (&azure.ActivationResult{Status: r.status}).IsPendingApproval() {
+			waiting = append(waiting, r)
+		} else {
+			resolved = append(resolved, r.RequestID)
+		}
+	}
+	// With notifications the daemon announces the decision before it stops
+	// tracking a request
+	if len(notifiers) == 0 && len(resolved) > 0 {
+		if err := state.RemovePendingRequests(resolved...); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update pending requests: %v\n", err)
+		}
+	}
+
+	if outputFormat == "json" {
+		out := []pendingJSON{}
+		for _, r := range waiting {
+			out = append(out, pendingJSON{
+				RequestID: r.RequestID, RoleName: r.RoleName, ScopeName: r.ScopeName, Scope: r.Scope, Status: r.status,
+				SubmittedAt: r.SubmittedAt, WaitingMinutes: int(time.Since(r.SubmittedAt) / time.Minute), RemindedAt: r.RemindedAt,
+			})
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(waiting) == 0 {
+		infof("No requests are waiting for approval.\n")
+		return nil
+	}
+
+	infof("%d request(s) waiting for approval:\n\n", len(waiting))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  ROLE\tSCOPE\tREQUESTED\tWAITING\tREMINDED\tREQUEST")
+	for _, r := range waiting {
+		reminded := "-"
+		if !r.RemindedAt.IsZero() {
+			reminded = formatAge(r.RemindedAt) + " ago"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", r.RoleName, r.ScopeName, ui.FormatTime(r.SubmittedAt),
+			formatAge(r.SubmittedAt), reminded, r.RequestID)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	infof("\n%s\n", ui.SubtleStyle.Render("Run 'hacktivator approvers --pending' to see whom to ask"))
+	return nil
+}
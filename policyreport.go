@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// longestActivation is the maximum activation duration, in minutes, at
+// which the policy report flags a policy
+const longestActivation = 24 * 60
+
+// privilegedRoles can grant themselves or others access, so their policies
+// are held to a stricter standard in the policy report
+var privilegedRoles = map[string]bool{
+	"owner":                     true,
+	"user access administrator": true,
+	"role based access control administrator": true,
+}
+
+var policyReportAll bool
+
+func policyReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy-report",
+		Short: "Report role management policies that deviate from good practice",
+		Long: `Reads the role management policy of every role at --scope and, for a
+management group, at the management groups and subscriptions below it, and
+reports the outliers:
+
+  - no approval or MFA on Owner, User Access Administrator or
+    Role Based Access Control Administrator
+  - permanent eligibility allowed for those roles
+  - activations of 24 hours or more, or without a maximum duration
+  - no justification required
+
+Only policies with findings are listed unless --all is given. Reading
+policies requires permission to read role management policies at the scope.`,
+		Args: cobra.NoArgs,
+		RunE: runPolicyReport,
+	}
+	cmd.Flags().StringVar(&adminScope, "scope", "", "Scope, e.g. /providers/Microsoft.Management/managementGroups/<id>")
+	cmd.Flags().StringVar(&adminRole, "role", "", "Only report roles whose name matches this glob pattern")
+	cmd.Flags().BoolVar(&policyReportAll, "all", false, "List every policy, not only those with findings")
+	return cmd
+}
+
+// policyFinding is a policy in the policy report with what's wrong with it
+type policyFinding struct {
+	RoleName              string   `json:"roleName"`
+	RoleDefinitionID      string   `json:"roleDefinitionId"`
+	ScopeName             string   `json:"scopeName"`
+	Scope                 string   `json:"scope"`
+	MaxActivationMinutes  int      `json:"maxActivationMinutes"` // 0 if unlimited
+	ApprovalRequired      bool     `json:"approvalRequired"`
+	Approvers             int      `json:"approvers"`
+	MFARequired           bool     `json:"mfaRequired"`
+	JustificationRequired bool     `json:"justificationRequired"`
+	TicketRequired        bool     `json:"ticketRequired"`
+	PermanentEligibility  bool     `json:"permanentEligibility"`
+	Findings              []string `json:"findings"`
+}
+
+func runPolicyReport(cmd *cobra.Command, args []string) error {
+	if adminScope == "" || !strings.HasPrefix(adminScope, "/") {
+		return fmt.Errorf("--scope is required, e.g. /providers/Microsoft.Management/managementGroups/<id>")
+	}
+	if _, err := path.Match(adminRole, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", adminRole, err)
+	}
+
+	policies, err := ui.SpinWithResult("Reading role management policies under "+adminScope, func() ([]azure.PolicySettings, error) {
+		return azure.ListPolicySettings(adminScope)
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	report := []policyFinding{}
+	for _, p := range policies {
+		if adminRole != "" && !globMatch(adminRole, p.RoleName) {
+			continue
+		}
+		f := policyFinding{
+			RoleName: p.RoleName, RoleDefinitionID: p.RoleDefinitionID, ScopeName: p.ScopeName, Scope: p.Scope,
+			MaxActivationMinutes: p.MaxActivationMinutes, ApprovalRequired: p.ApprovalRequired, Approvers: p.Approvers,
+			MFARequired: p.MFARequired, JustificationRequired: p.JustificationRequired, TicketRequired: p.TicketRequired,
+			PermanentEligibility: p.PermanentEligibility,
+			Findings:             policyFindings(p),
+		}
+		if len(f.Findings) > 0 || policyReportAll {
+			report = append(report, f)
+		}
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		if len(report[i].Findings) != len(report[j].Findings) {
+			return len(report[i].Findings) > len(report[j].Findings)
+		}
+		return strings.ToLower(report[i].RoleName) < strings.ToLower(report[j].RoleName)
+	})
+
+	if outputFormat == "json" {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	if len(report) == 0 {
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("No findings in %d policies under %s", len(policies), adminScope)))
+		return nil
+	}
+
+	infof("%d of %d policies under %s have findings:\n\n", len(report), len(policies), adminScope)
+	yesNo := map[bool]string{true: "yes", false: "no"}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  ROLE\tSCOPE\tMAX\tAPPROVAL\tMFA\tJUSTIFICATION\tFINDINGS")
+	for _, f := range report {
+		maxDuration := "unlimited"
+		if f.MaxActivationMinutes > 0 {
+			maxDuration = ui.FormatMinutes(f.MaxActivationMinutes)
+		}
+		approval := yesNo[f.ApprovalRequired]
+		if f.ApprovalRequired {
+			approval = fmt.Sprintf("yes (%d)", f.Approvers)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.RoleName, f.ScopeName, maxDuration, approval,
+			yesNo[f.MFARequired], yesNo[f.JustificationRequired], strings.Join(f.Findings, "; "))
+	}
+	return w.Flush()
+}
+
+// policyFindings lists how a policy deviates from good practice
+func policyFindings(p azure.PolicySettings) []string {
+	var findings []string
+	if privilegedRoles[strings.ToLower(p.RoleName)] {
+		if !p.ApprovalRequired {
+			findings = append(findings, "no approval")
+		} else if p.Approvers == 0 {
+			findings = append(findings, "approval without approvers")
+		}
+		if !p.MFARequired {
+			findings = append(findings, "no MFA")
+		}
+		if p.PermanentEligibility {
+			findings = append(findings, "permanent eligibility")
+		}
+	}
+	switch {
+	case p.MaxActivationMinutes == 0:
+		findings = append(findings, "no maximum duration")
+	case p.MaxActivationMinutes >= longestActivation:
+		findings = append(findings, fmt.Sprintf("%s maximum duration", ui.FormatMinutes(p.MaxActivationMinutes)))
+	}
+	if !p.JustificationRequired {
+		findings = append(findings, "no justification")
+	}
+	return findings
+}
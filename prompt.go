@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/daemon"
+	"github.com/ica-js/hacktivator/internal/state"
+)
+
+var (
+	promptMaxAge  time.Duration
+	promptRefresh bool
+)
+
+// promptRefreshInterval is the minimum time between background refreshes
+// started by prompts, however many shells are open
+const promptRefreshInterval = 30 * time.Second
+
+func promptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Print a compact summary of active roles for shell prompts",
+		Long: `Prints a short summary of your active roles, e.g. "⚡2 roles (1h5m)", with
+the time until the first one expires. Nothing is printed when no role is active.
+
+Only the local cache is read, so it returns in milliseconds. When the cache is
+older than --max-age a refresh is started in the background and the next
+prompt shows the result. The cache is also updated by 'status' and invalidated
+by activations.
+
+  # zsh
+  RPROMPT='$(hacktivator prompt)'`,
+		Args: cobra.NoArgs,
+		// The root checks call az, which would slow down every prompt
+		PersistentPreRunE: localOnly,
+		RunE:              runPrompt,
+	}
+	cmd.Flags().DurationVar(&promptMaxAge, "max-age", time.Minute, "Refresh the cache in the background when it is older than this")
+	cmd.Flags().BoolVar(&promptRefresh, "refresh", false, "Refresh the cache now and print nothing")
+	return cmd
+}
+
+func runPrompt(cmd *cobra.Command, args []string) error {
+	if promptRefresh {
+		return refreshActiveCache()
+	}
+
+	fmt.Print(activeSummary(cachedActiveRoles(promptMaxAge)))
+	return nil
+}
+
+// cachedActiveRoles returns the unexpired roles from the local cache and
+// starts a background refresh when the cache is older than maxAge. Errors are
+// swallowed: a broken cache must never break a shell prompt or status bar.
+func cachedActiveRoles(maxAge time.Duration) []state.ActiveRole {
+	cache, err := state.CachedActive()
+	if err != nil {
+		return nil
+	}
+	if time.Since(cache.FetchedAt) > maxAge {
+		refreshInBackground()
+	}
+	return cache.Current()
+}
+
+// activeSummary renders roles as e.g. "⚡2 roles (1h5m)", with the time until
+// the first expiry, or an empty string when nothing is active
+func activeSummary(roles []state.ActiveRole) string {
+	if len(roles) == 0 {
+		return ""
+	}
+
+	noun := "roles"
+	if len(roles) == 1 {
+		noun = "role"
+	}
+	summary := fmt.Sprintf("⚡%d %s", len(roles), noun)
+	if next := nextExpiry(roles); next != nil {
+		summary += fmt.Sprintf(" (%s)", formatRemaining(*next))
+	}
+	return summary
+}
+
+// nextExpiry returns the earliest end time, or nil if every role is permanent
+func nextExpiry(roles []state.ActiveRole) *time.Time {
+	var next *time.Time
+	for _, r := range roles {
+		if r.EndDateTime != nil && (next == nil || r.EndDateTime.Before(*next)) {
+			next = r.EndDateTime
+		}
+	}
+	return next
+}
+
+// refreshActiveCache fetches the active assignments and saves them
+func refreshActiveCache() error {
+	roles, err := azure.GetActiveRoleAssignments()
+	if err != nil {
+		return fmt.Errorf("failed to get active roles: %w", err)
+	}
+	return state.SaveActive(roles)
+}
+
+// refreshInBackground starts a detached 'prompt --refresh' unless another
+// process started one recently
+func refreshInBackground() {
+	if !state.ClaimRefresh(promptRefreshInterval) {
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	args := []string{"prompt", "--refresh"}
+	if activeContext != nil {
+		args = append(args, "--context", activeContext.Name)
+	}
+	refresh := exec.Command(exe, args...)
+	if refresh.Start() == nil {
+		_ = refresh.Process.Release()
+	}
+}
+
+// saveActiveCache stores freshly fetched active roles for the prompt helper
+func saveActiveCache(roles []azure.RoleAssignment) {
+	if err := state.SaveActive(roles); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to cache active roles: %v\n", err)
+	}
+}
+
+// invalidateActiveCache marks the cached active roles stale after a change
+func invalidateActiveCache() {
+	if dryRun {
+		return
+	}
+	daemon.RequestRefresh()
+	if err := state.InvalidateActive(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to update active role cache: %v\n", err)
+	}
+}
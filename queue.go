@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// queueMaxAge is how long a queued activation is retried. An activation
+// still unsent after a workday is more likely unwanted than late.
+const queueMaxAge = 8 * time.Hour
+
+var dropAll bool
+
+func queueCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Manage activations queued while Azure was unreachable",
+		Long: `Activations that fail because Azure can't be reached, e.g. when the network
+drops or the VPN reconnects, are queued instead of lost. The queue is retried
+at the start of the next command that activates roles and on every refresh of
+a running daemon, checking the guardrails again. Queued activations are
+dropped after 8 hours.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:               "list",
+		Short:             "List queued activations",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: localOnly,
+		RunE:              runQueueList,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "flush",
+		Short: "Send the queued activations now",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flushQueue(state.LockWait)
+		},
+	})
+	drop := &cobra.Command{
+		Use:               "drop [id...]",
+		Short:             "Remove activations from the queue without sending them",
+		PersistentPreRunE: localOnly,
+		RunE:              runQueueDrop,
+	}
+	drop.Flags().BoolVar(&dropAll, "all", false, "Drop every queued activation")
+	cmd.AddCommand(drop)
+
+	return cmd
+}
+
+func runQueueList(cmd *cobra.Command, args []string) error {
+	queue, err := state.Queue()
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		if queue == nil {
+			queue = []state.QueuedActivation{}
+		}
+		out, err := json.MarshalIndent(queue, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if len(queue) == 0 {
+		infof("No activations are queued.\n")
+		return nil
+	}
+	for _, q := range queue {
+		fmt.Printf("%s  %s on %s for %d minutes, queued %s ago, %d attempts\n",
+			q.ID, q.Role.RoleName, q.Role.ScopeName, q.Duration, formatAge(q.QueuedAt), q.Attempts)
+		if !quiet && q.LastError != "" {
+			fmt.Println(ui.SubtleStyle.Render("          " + firstLine(q.LastError)))
+		}
+	}
+	return nil
+}
+
+func runQueueDrop(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !dropAll {
+		return fmt.Errorf("pass the IDs from 'hacktivator queue list', or --all")
+	}
+	return state.UpdateQueue(state.LockWait, func(queue []state.QueuedActivation) ([]state.QueuedActivation, error) {
+		var kept, dropped []state.QueuedActivation
+		for _, q := range queue {
+			drop := dropAll
+			for _, id := range args {
+				drop = drop || strings.EqualFold(q.ID, id)
+			}
+			if drop {
+				dropped = append(dropped, q)
+			} else {
+				kept = append(kept, q)
+			}
+		}
+		for _, id := range args {
+			if !slices.ContainsFunc(dropped, func(q state.QueuedActivation) bool { return strings.EqualFold(q.ID, id) }) {
+				return queue, fmt.Errorf("no queued activation %q", id)
+			}
+		}
+		for _, q := range dropped {
+			infof("Dropped %s on %s\n", q.Role.RoleName, q.Role.ScopeName)
+		}
+		return kept, nil
+	})
+}
+
+// queueActivation keeps an activation that failed to reach Azure for a
+// later retry, and reports whether it was queued
+func queueActivation(req azure.ActivationRequest, err error) bool {
+	if dryRun || !azure.IsNetworkError(err) {
+		return false
+	}
+	// The request may have reached Azure before the connection dropped;
+	// the retry reuses it if so
+	if req.RequestID == "" {
+		req.RequestID = azure.RecentRequestID(req.Role)
+	}
+	if err := state.Enqueue(req, err); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to queue activation: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// retryQueuedActivations sends queued activations in the background of
+// another command, reporting problems as warnings
+func retryQueuedActivations() {
+	// Another process sending them leaves nothing to do
+	if err := flushQueue(0); err != nil && !errors.Is(err, state.ErrLocked) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// flushQueue sends the queued activations in the order they were queued.
+// If Azure is still unreachable, the rest of the queue is kept for later.
+// The queue stays locked meanwhile, so concurrent runs can't send an
+// activation twice or lose a newly queued one; wait is how long to wait for
+// another run to finish.
+func flushQueue(wait time.Duration) error {
+	return state.UpdateQueue(wait, sendQueued)
+}
+
+// sendQueued sends the queued activations and returns the ones to keep
+func sendQueued(queue []state.QueuedActivation) ([]state.QueuedActivation, error) {
+	var kept []state.QueuedActivation
+	var firstErr error
+	for i, q := range queue {
+		label := fmt.Sprintf("%s on %s", q.Role.RoleName, q.Role.ScopeName)
+		if time.Since(q.QueuedAt) > queueMaxAge {
+			fmt.Fprintf(os.Stderr, "warning: dropped queued activation of %s, queued %s ago\n", label, formatAge(q.QueuedAt))
+			continue
+		}
+
+		// The guardrails, or the roles active, may have changed since it
+		// was queued
+		var result *azure.ActivationResult
+		err := checkGuardrails([]guardedRequest{{role: q.Role, minutes: q.Duration}}, nil)
+		if err == nil {
+			result, err = azure.ActivateRole(q.Request())
+		}
+		switch {
+		case azure.IsNetworkError(err):
+			q.Attempts++
+			q.LastError = err.Error()
+			kept = append(kept, q)
+			kept = append(kept, queue[i+1:]...)
+			return kept, fmt.Errorf("Azure is still unreachable, %d queued activations kept for later", len(kept))
+		case errors.Is(err, azure.ErrRoleAssignmentExists):
+			// The earlier attempt reached Azure before the connection dropped
+			infof("%s\n", ui.SuccessStyle.Render("Queued activation of "+label+" is already active"))
+		case err != nil:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("queued activation of %s failed: %w", label, err)
+			}
+		case result.IsFailed():
+			if firstErr == nil {
+				firstErr = fmt.Errorf("queued activation of %s ended with status %s", label, result.Status)
+			}
+		case result.IsPendingApproval():
+			recordActivation(q.Role)
+			infof("%s\n", ui.TitleStyle.Render(fmt.Sprintf("Queued activation of %s submitted and pending approval (request %s)", label, result.RequestID)))
+		default:
+			recordActivation(q.Role)
+			infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Activated queued %s for %d minutes", label, q.Duration)))
+		}
+	}
+	return kept, firstErr
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
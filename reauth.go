@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/browser"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// reauthenticate is called when the Azure CLI session expires mid-run. On a
+// terminal it offers to run az login, pausing any spinner meanwhile, and
+// reports whether the user signed in again so the failed call is retried.
+func reauthenticate() bool {
+	if nonInteractive || !term.IsTerminal(os.Stdin.Fd()) {
+		return false
+	}
+	signedIn := false
+	err := ui.Suspend(func() error {
+		ok, err := ui.ConfirmDefaultYes("Your session expired — re-authenticate now?")
+		if err != nil || !ok {
+			return err
+		}
+		if err := azure.Login(signInTenant(), !browser.Available()); err != nil {
+			return err
+		}
+		signedIn = true
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to re-authenticate: %v\n", err)
+	}
+	return signedIn
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	renewDays int
+	renewAll  bool
+)
+
+func renewEligibilityCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "renew-eligibility",
+		Short: "Request renewal of eligibilities that are about to end",
+		Long: `Submits a SelfRenew request for each selected eligibility that ends soon,
+so access isn't lost silently. Renewals must be allowed by the role management
+policy and usually wait for an administrator to approve them.
+
+Eligibilities ending within the warning window (eligibility_warning_days,
+default 14) are offered; use --expiring to look further ahead. Eligibilities
+granted through a group can only be renewed by the group's administrators.`,
+		Args: cobra.NoArgs,
+		RunE: runRenewEligibility,
+	}
+	cmd.Flags().StringVar(&expiring, "expiring", "", "Offer eligibilities ending within this many days, e.g. 30d")
+	cmd.Flags().IntVar(&renewDays, "days", 365, "Days to extend each eligibility by")
+	cmd.Flags().StringVarP(&reason, "reason", "r", "", "Justification for the renewal")
+	cmd.Flags().StringVarP(&reasonTmpl, "template", "t", "", "Name of a reason template from the config file")
+	cmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Fail if user input is required")
+	cmd.Flags().BoolVar(&renewAll, "all", false, "Renew every eligibility ending in the window without selecting")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip the confirmation")
+	return cmd
+}
+
+func runRenewEligibility(cmd *cobra.Command, args []string) error {
+	if renewDays <= 0 {
+		return fmt.Errorf("--days must be positive")
+	}
+	window := time.Duration(cfg.EligibilityWarningDays) * 24 * time.Hour
+	if cfg.EligibilityWarningDays <= 0 {
+		window = defaultEligibilityWarningDays * 24 * time.Hour
+	}
+	if expiring != "" {
+		var err error
+		if window, err = parseDays(expiring); err != nil {
+			return err
+		}
+	}
+
+	user, err := fetchCurrentUser(nonInteractive)
+	if err != nil {
+		return err
+	}
+
+	roles, ok := daemonEligibleRoles()
+	if !ok {
+		roles, err = ui.SpinWithResult("Fetching eligible roles", func() ([]azure.RoleAssignment, error) {
+			return azure.GetEligibleRoleAssignments()
+		}, nonInteractive)
+		if err != nil {
+			return fmt.Errorf("failed to get eligible roles: %w", err)
+		}
+	}
+
+	var candidates []azure.RoleAssignment
+	for _, r := range expiringWithin(roles, window) {
+		if r.MemberType == "Group" {
+			infof("%s\n", ui.SubtleStyle.Render(fmt.Sprintf("%s on %s is granted through a group, ask its administrators to renew it",
+				r.RoleName, r.ScopeName)))
+			continue
+		}
+		candidates = append(candidates, r)
+	}
+	if len(candidates) == 0 {
+		infof("No eligibilities to renew end within %s.\n", formatWindow(window))
+		return nil
+	}
+
+	selected := candidates
+	if !renewAll && !nonInteractive && len(candidates) > 1 {
+		if selected, err = ui.SelectRoles(candidates, "Select eligibilities to renew", nonInteractive); err != nil {
+			return err
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	justification, err := resolveJustification(selected, user)
+	if err != nil {
+		return err
+	}
+
+	if !assumeYes && !nonInteractive {
+		fmt.Print(ui.RenderRolesTable(selected, false))
+		ok, err := ui.Confirm(fmt.Sprintf("Request renewal of %d eligibility(ies) for %d days?", len(selected), renewDays))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	return renewEligibilities(selected, justification)
+}
+
+// renewEligibilities submits a renewal request for each role
+func renewEligibilities(roles []azure.RoleAssignment, justification string) error {
+	pending, renewed := false, false
+	for _, role := range roles {
+		result, err := ui.SpinWithResult(
+			fmt.Sprintf("Requesting renewal of %s on %s", role.RoleName, role.ScopeName),
+			func() (*azure.ActivationResult, error) { return azure.RenewEligibility(role, renewDays, justification) },
+			nonInteractive,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to renew %s on %s: %w", role.RoleName, role.ScopeName, err)
+		}
+		if result.IsDryRun() {
+			continue
+		}
+		if result.IsPendingApproval() {
+			pending = true
+			infof("%s\n", ui.TitleStyle.Render(fmt.Sprintf("Renewal of %s on %s submitted and pending approval", role.RoleName, role.ScopeName)))
+			continue
+		}
+		infof("%s\n", ui.SuccessStyle.Render(fmt.Sprintf("Renewed %s on %s for %d days", role.RoleName, role.ScopeName, renewDays)))
+		renewed = true
+	}
+
+	if renewed {
+		// The cached scan still has the old end dates
+		if err := state.ClearEligible(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to clear cached eligible roles: %v\n", err)
+		}
+	}
+
+	if pending {
+		return withExitCode(exitPendingApproval, nil)
+	}
+	return nil
+}
+
+// formatWindow renders a --expiring window in days
+func formatWindow(d time.Duration) string {
+	if days := int(d.Hours() / 24); days >= 1 {
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/state"
+	"github.com/ica-js/hacktivator/internal/tracelog"
+)
+
+// lastRunFile keeps the trace of the most recent run for reports
+const lastRunFile = "last-run.jsonl"
+
+var (
+	reportFile       string
+	keepPrincipalIDs bool
+	runStart         = time.Now()
+	untracedCommands = map[string]bool{"report": true, "daemon": true, "serve": true, "mcp": true}
+)
+
+// traceLastRun records this run's requests for a later report. The report
+// itself and the long-running servers are not recorded, so they don't
+// replace the run being reported or grow the file without bound.
+func traceLastRun(cmd *cobra.Command) {
+	if untracedCommands[cmd.Name()] {
+		return
+	}
+	dir, err := state.Dir()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return
+	}
+	if err := tracelog.OpenLastRun(filepath.Join(dir, lastRunFile)); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record this run: %v\n", err)
+	}
+}
+
+func reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Bundle diagnostics and the last run's trace for a bug report",
+		Long: `Writes a zip to attach to a bug report with:
+
+  diagnostics.txt  versions, platform, Azure CLI, config and cache status
+  last-run.jsonl   the az commands and REST requests of the previous run,
+                   with their responses and how the run ended
+
+Access tokens, secrets and JWTs are never recorded. Principal IDs of users,
+groups and service principals are replaced with placeholders like
+principal-1 unless --keep-principal-ids is given. Review the files before
+sharing them.`,
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: localOnly,
+		RunE:              runReport,
+	}
+	cmd.Flags().StringVar(&reportFile, "file", "", "Where to write the zip (default hacktivator-report-<time>.zip)")
+	cmd.Flags().BoolVar(&keepPrincipalIDs, "keep-principal-ids", false, "Keep principal IDs instead of replacing them with placeholders")
+	return cmd
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if reportFile == "" {
+		reportFile = "hacktivator-report-" + time.Now().Format("20060102-150405") + ".zip"
+	}
+
+	dir, err := state.Dir()
+	if err != nil {
+		return err
+	}
+	lastRun, err := os.ReadFile(filepath.Join(dir, lastRunFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read the last run: %w", err)
+	}
+
+	diagnostics := []byte(collectDiagnostics())
+	if !keepPrincipalIDs {
+		scrub := newPrincipalScrubber(lastRun)
+		lastRun, diagnostics = scrub.apply(lastRun), scrub.apply(diagnostics)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"diagnostics.txt", diagnostics},
+		{lastRunFile, lastRun},
+	}
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.WriteFile(reportFile, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if len(lastRun) == 0 {
+		fmt.Fprintln(os.Stderr, "note: no previous run was recorded; reproduce the problem and run report again for a trace")
+	}
+	fmt.Println(reportFile)
+	return nil
+}
+
+// collectDiagnostics describes the environment hacktivator runs in. It
+// never fails: anything that can't be determined is reported as such.
+func collectDiagnostics() string {
+	var b strings.Builder
+	line := func(label, format string, args ...any) {
+		fmt.Fprintf(&b, "%-18s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	line("hacktivator", "%s", version)
+	line("Go", "%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	line("Time", "%s", time.Now().Format(time.RFC3339))
+
+	if path, err := exec.LookPath("az"); err != nil {
+		line("Azure CLI", "not found: %v", err)
+	} else if v, err := azure.GetCLIVersion(); err != nil {
+		line("Azure CLI", "%s, version unknown: %v", path, err)
+	} else {
+		line("Azure CLI", "%s %s", path, v)
+		line("Signed in", "%t", azure.IsAuthenticated())
+	}
+	if tenant, err := azure.DefaultTenant(); err != nil {
+		line("Tenant", "unknown: %v", err)
+	} else {
+		line("Tenant", "%s", tenant)
+	}
+	if activeContext != nil {
+		line("Context", "%s (tenant %s)", activeContext.Name, activeContext.Tenant)
+	}
+
+	line("Terminal", "stdin %t, stdout %t, TERM=%s", term.IsTerminal(os.Stdin.Fd()), term.IsTerminal(os.Stdout.Fd()), os.Getenv("TERM"))
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		switch {
+		case strings.HasPrefix(name, envPrefix), name == "NO_COLOR", name == "AZURE_CONFIG_DIR",
+			strings.EqualFold(name, "HTTPS_PROXY"), strings.EqualFold(name, "NO_PROXY"):
+			env = append(env, name)
+		}
+	}
+	sort.Strings(env)
+	line("Environment", "%s (values omitted)", strings.Join(env, " "))
+
+	path, _ := config.Path()
+	c, err := config.Load()
+	switch {
+	case err != nil:
+		line("Config", "%s: %v", path, err)
+	default:
+		if _, statErr := os.Stat(path); statErr != nil {
+			line("Config", "%s (not found, using defaults)", path)
+		} else {
+			line("Config", "%s", path)
+		}
+		line("Tickets", "%s", orNone(c.Tickets.Provider))
+		var sinks []string
+		for _, s := range c.AuditSinks {
+			sinks = append(sinks, s.Type)
+		}
+		line("Audit sinks", "%s", orNone(strings.Join(sinks, ", ")))
+		var channels []string
+		if c.Notifications.Teams != nil {
+			channels = append(channels, "teams")
+		}
+		if c.Notifications.Slack != nil {
+			channels = append(channels, "slack")
+		}
+		if c.Notifications.Email != nil {
+			channels = append(channels, "email")
+		}
+		line("Notifications", "%s", orNone(strings.Join(channels, ", ")))
+		line("Network", "proxy %t, CA bundle %t, insecure %t", c.Network.Proxy != "", c.Network.CABundle != "", c.Network.InsecureSkipVerify)
+		line("Scan", "%s", orNone(strings.Join(c.ScanSubscriptions, ", ")))
+	}
+
+	b.WriteString("\nCaches:\n")
+	if entries, err := cacheEntries(); err != nil {
+		fmt.Fprintf(&b, "  %v\n", err)
+	} else if len(entries) == 0 {
+		b.WriteString("  none\n")
+	} else {
+		writeCacheEntries(&b, entries)
+	}
+	return b.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+var (
+	// Properties and URL segments that hold the object ID of a user, group
+	// or service principal
+	principalFields = regexp.MustCompile(`(?i)"(?:principalId|requestorId|approverId|objectId|oid)"\s*:\s*"([0-9a-f-]{36})"`)
+	principalObject = regexp.MustCompile(`(?i)"principal"\s*:\s*\{\s*"id"\s*:\s*"([0-9a-f-]{36})"`)
+	principalPaths  = regexp.MustCompile(`(?i)/(?:users|groups|directoryObjects|servicePrincipals)/([0-9a-f-]{36})`)
+	// az ad signed-in-user show prints the user's own object ID as id
+	signedInUserID = regexp.MustCompile(`(?i)\\?"id\\?"\s*:\s*\\?"([0-9a-f-]{36})\\?"`)
+)
+
+// principalScrubber replaces principal IDs with stable placeholders, so a
+// trace still shows which requests concern the same principal
+type principalScrubber struct {
+	replacer *strings.Replacer
+}
+
+func newPrincipalScrubber(trace []byte) principalScrubber {
+	seen := make(map[string]bool)
+	var ids []string
+	collect := func(re *regexp.Regexp, s string) {
+		for _, m := range re.FindAllStringSubmatch(s, -1) {
+			id := strings.ToLower(m[1])
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trace))
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var r tracelog.Record
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		text := r.URL + r.Request + r.Response
+		for _, re := range []*regexp.Regexp{principalFields, principalObject, principalPaths} {
+			collect(re, text)
+		}
+		if strings.Contains(strings.Join(r.Args, " "), "signed-in-user") {
+			collect(signedInUserID, r.Response)
+		}
+	}
+
+	var pairs []string
+	for i, id := range ids {
+		placeholder := fmt.Sprintf("principal-%d", i+1)
+		pairs = append(pairs, id, placeholder, strings.ToUpper(id), placeholder)
+	}
+	return principalScrubber{replacer: strings.NewReplacer(pairs...)}
+}
+
+func (s principalScrubber) apply(data []byte) []byte {
+	var out bytes.Buffer
+	s.replacer.WriteString(&out, string(data))
+	return out.Bytes()
+}
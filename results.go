@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/schema"
+)
+
+// activationResultJSON is the machine-readable outcome of one activation
+// request. The request ID and resource ID let callers poll or cancel it later.
+type activationResultJSON struct {
+	SchemaVersion     int    `json:"schemaVersion"`
+	RoleName          string `json:"roleName"`
+	RoleDefinitionID  string `json:"roleDefinitionId"`
+	Scope             string `json:"scope"`
+	ScopeName         string `json:"scopeName"`
+	RequestID         string `json:"requestId,omitempty"`
+	RequestResourceID string `json:"requestResourceId,omitempty"`
+	Status            string `json:"status,omitempty"`
+	ScheduleID        string `json:"scheduleId,omitempty"`
+	PortalURL         string `json:"portalUrl"`
+	Error             string `json:"error,omitempty"`
+}
+
+func toActivationResultJSON(o activationOutcome) activationResultJSON {
+	r := activationResultJSON{
+		SchemaVersion:    schema.Version,
+		RoleName:         o.role.RoleName,
+		RoleDefinitionID: o.role.RoleDefinitionID,
+		Scope:            o.role.Scope,
+		ScopeName:        o.role.ScopeName,
+		PortalURL:        azure.PortalURL(o.role),
+	}
+	if o.result != nil {
+		r.RequestID = o.result.RequestID
+		r.RequestResourceID = o.result.ID
+		r.Status = o.result.Status
+		r.ScheduleID = o.result.ScheduleID
+	}
+	if o.err != nil {
+		r.Error = o.err.Error()
+	}
+	return r
+}
+
+// printActivationResults writes the outcome of each activation request to
+// stdout as a JSON array, in the order the roles were selected
+func printActivationResults(outcomes []activationOutcome) error {
+	out := make([]activationResultJSON, len(outcomes))
+	for i, o := range outcomes {
+		out[i] = toActivationResultJSON(o)
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to render activation results: %w", err)
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
+
+// showSchema prints the JSON schema of a command's -o json output instead
+// of running it
+var showSchema bool
+
+// outputSchemas names the schema of each command with JSON output
+var outputSchemas = map[string]string{
+	"hacktivator": "activation-results",
+	"favorites":   "activation-results",
+	"list":        "roles",
+	"status":      "roles",
+}
+
+// addSchemaFlag registers --schema on a command listed in outputSchemas
+func addSchemaFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&showSchema, "schema", false, fmt.Sprintf("Print the JSON schema (version %d) of the -o json output and exit", schema.Version))
+}
+
+// replaceWithSchema makes cmd print its output schema instead of running,
+// so it needs neither az nor a sign-in
+func replaceWithSchema(cmd *cobra.Command) error {
+	name, ok := outputSchemas[cmd.Name()]
+	if !ok {
+		return fmt.Errorf("%s has no JSON output schema", cmd.CommandPath())
+	}
+	data, err := schema.Get(name)
+	if err != nil {
+		return err
+	}
+	cmd.RunE = func(*cobra.Command, []string) error {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/secrets"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+func secretCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Manage API tokens and other secrets in the system keyring",
+		Long: `Stores secrets such as ticket system API tokens in the system keyring
+(macOS Keychain, Windows Credential Manager or the Secret Service on Linux)
+so they don't have to live in the config file or the environment.
+
+Refer to a stored secret by name from the config file, e.g. tickets.token_secret.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set <name>",
+		Short: "Store a secret, read from a hidden prompt or stdin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := readSecret(args[0])
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				return fmt.Errorf("refusing to store an empty secret")
+			}
+			if err := secrets.Default.Set(args[0], value); err != nil {
+				return err
+			}
+			infof("%s\n", ui.SuccessStyle.Render("Stored "+args[0]+" in the keyring"))
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete <name>",
+		Short: "Remove a secret from the keyring",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := secrets.Default.Delete(args[0]); err != nil {
+				if errors.Is(err, secrets.ErrNotFound) {
+					infof("%s is not stored.\n", args[0])
+					return nil
+				}
+				return err
+			}
+			infof("%s\n", ui.SuccessStyle.Render("Deleted "+args[0]+" from the keyring"))
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// readSecret prompts for a secret without echoing it, or reads it from stdin
+// when stdin is not a terminal
+func readSecret(name string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+	data, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ica-js/hacktivator/internal/state"
+)
+
+var statusFormat string
+
+// segmentMaxAge is how stale the cache may be before a status bar segment
+// triggers a background refresh
+const segmentMaxAge = time.Minute
+
+// expiringSoon highlights segments when the first role expires within this window
+const expiringSoon = 15 * time.Minute
+
+// printStatusSegment prints the cached active roles for a status bar
+func printStatusSegment(format string) error {
+	switch format {
+	case "starship", "tmux", "waybar":
+	default:
+		return fmt.Errorf("unsupported status format %q (supported: starship, tmux, waybar)", format)
+	}
+
+	roles := cachedActiveRoles(segmentMaxAge)
+	summary := activeSummary(roles)
+	expiring := false
+	if next := nextExpiry(roles); next != nil {
+		expiring = time.Until(*next) < expiringSoon
+	}
+
+	switch format {
+	case "tmux":
+		if summary == "" {
+			return nil
+		}
+		color := "yellow"
+		if expiring {
+			color = "red"
+		}
+		fmt.Printf("#[fg=%s]%s#[default]\n", color, summary)
+
+	case "waybar":
+		out := struct {
+			Text    string `json:"text"`
+			Tooltip string `json:"tooltip"`
+			Class   string `json:"class"`
+		}{Text: summary, Tooltip: "No active PIM roles", Class: "inactive"}
+		if len(roles) > 0 {
+			out.Tooltip = segmentTooltip(roles)
+			out.Class = "active"
+			if expiring {
+				out.Class = "expiring"
+			}
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+
+	default:
+		// Starship styles custom modules itself; print plain text
+		if summary != "" {
+			fmt.Println(summary)
+		}
+	}
+	return nil
+}
+
+// segmentTooltip lists each active role with its remaining time
+func segmentTooltip(roles []state.ActiveRole) string {
+	lines := make([]string, len(roles))
+	for i, r := range roles {
+		scope := r.ScopeName
+		if scope == "" {
+			scope = r.Scope
+		}
+		remaining := "permanent"
+		if r.EndDateTime != nil {
+			remaining = formatRemaining(*r.EndDateTime)
+		}
+		lines[i] = fmt.Sprintf("%s on %s (%s)", r.RoleName, scope, remaining)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/tickets"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+var (
+	serveListen string
+	serveToken  string
+)
+
+// serveTokenFile holds the API token so local tools can read it
+const serveTokenFile = "serve.token"
+
+func serveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a local REST API for dashboards and editor extensions",
+		Long: `Serves a small REST API so local tools can list roles and submit activations
+without shelling out to the CLI. Every request needs the header
+"Authorization: Bearer <token>". The token is taken from --token or generated,
+and written to serve.token in the config directory (readable only by you).
+
+  GET  /v1/eligible     eligible role assignments
+  GET  /v1/active       active role assignments
+  POST /v1/activate     {"roleName", "scope", "duration", "justification",
+                         "ticketNumber", "ticketSystem"}; duration in minutes
+  POST /v1/deactivate   {"roleName", "scope"}
+
+Configured requirements such as minimum justification length apply as usual,
+and tickets are validated with the configured ticket provider like the CLI does.`,
+		Args: cobra.NoArgs,
+		RunE: runServe,
+	}
+	cmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8999", "Address to listen on")
+	cmd.Flags().StringVar(&serveToken, "token", "", "API token clients must send (generated if empty)")
+	return cmd
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	host, _, err := net.SplitHostPort(serveListen)
+	if err != nil {
+		return fmt.Errorf("invalid --listen %q: %w", serveListen, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "warning: listening on %s exposes role activation beyond this machine\n", serveListen)
+	}
+
+	token := serveToken
+	if token == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = hex.EncodeToString(buf)
+	}
+	tokenPath, err := writeServeToken(token)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tokenPath)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/eligible", func(w http.ResponseWriter, r *http.Request) {
+		roles, err := serveEligibleRoles()
+		writeAPI(w, roles, err)
+	})
+	mux.HandleFunc("GET /v1/active", func(w http.ResponseWriter, r *http.Request) {
+		roles, err := azure.GetActiveRoleAssignments()
+		if err == nil {
+			saveActiveCache(roles)
+		}
+		writeAPI(w, roles, err)
+	})
+	mux.HandleFunc("POST /v1/activate", serveActivate)
+	mux.HandleFunc("POST /v1/deactivate", serveDeactivate)
+
+	server := &http.Server{
+		Addr:              serveListen,
+		Handler:           requireToken(token, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	infof("Serving the API on http://%s/v1/ (token in %s)\n", serveListen, tokenPath)
+	return server.ListenAndServe()
+}
+
+// writeServeToken saves the token where local tools can find it
+func writeServeToken(token string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, serveTokenFile)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write token: %w", err)
+	}
+	return path, nil
+}
+
+// requireToken rejects requests without the bearer token
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiError is the body of every failed API request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(apiError{Error: err.Error()})
+}
+
+// writeAPI writes roles as JSON, or err as a 502 since failures come from Azure
+func writeAPI(w http.ResponseWriter, roles []azure.RoleAssignment, err error) {
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err)
+		return
+	}
+	data, err := ui.RenderRolesJSON(roles)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(data))
+}
+
+func serveEligibleRoles() ([]azure.RoleAssignment, error) {
+	if roles, ok := daemonEligibleRoles(); ok {
+		return roles, nil
+	}
+	return azure.GetEligibleRoleAssignments()
+}
+
+// apiRoleRequest is the body of activate and deactivate requests
+type apiRoleRequest struct {
+	RoleName      string `json:"roleName"`
+	Scope         string `json:"scope"`
+	Duration      int    `json:"duration"`
+	Justification string `json:"justification"`
+	TicketNumber  string `json:"ticketNumber"`
+	TicketSystem  string `json:"ticketSystem"`
+}
+
+func decodeRoleRequest(w http.ResponseWriter, r *http.Request) (*apiRoleRequest, bool) {
+	var req apiRoleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return nil, false
+	}
+	if req.RoleName == "" || req.Scope == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("roleName and scope are required"))
+		return nil, false
+	}
+	return &req, true
+}
+
+// errRoleNotFound means the role named in a request is not eligible or active
+var errRoleNotFound = errors.New("role not found")
+
+// matchRole finds the role named in a request among roles
+func matchRole(roles []azure.RoleAssignment, req *apiRoleRequest) *azure.RoleAssignment {
+	for _, r := range findRoles(roles, req.RoleName, "") {
+		if sameScope(r.Scope, req.Scope) {
+			return &r
+		}
+	}
+	return nil
+}
+
+// findEligibleRole looks up the eligible role a request names
+func findEligibleRole(req *apiRoleRequest) (*azure.RoleAssignment, error) {
+	roles, err := azure.GetEligibleRolesAtScope(req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	role := matchRole(roles, req)
+	if role == nil {
+		return nil, fmt.Errorf("%w: not eligible for %s at %s", errRoleNotFound, req.RoleName, req.Scope)
+	}
+	return role, nil
+}
+
+// findActiveRole looks up the active role a request names
+func findActiveRole(req *apiRoleRequest) (*azure.RoleAssignment, error) {
+	active, err := azure.GetActiveRoleAssignments()
+	if err != nil {
+		return nil, err
+	}
+	role := matchRole(active, req)
+	if role == nil {
+		return nil, fmt.Errorf("%w: %s is not active at %s", errRoleNotFound, req.RoleName, req.Scope)
+	}
+	return role, nil
+}
+
+// checkActivationRequest applies defaults and the configured requirements,
+// and validates the ticket with the configured provider like the CLI does
+func checkActivationRequest(req *apiRoleRequest) error {
+	if req.Duration <= 0 {
+		req.Duration = duration
+	}
+	if err := cfg.Requirements.CheckReason(req.Justification); err != nil {
+		return err
+	}
+	if err := cfg.Requirements.CheckTicket(req.TicketNumber); err != nil {
+		return err
+	}
+	provider, err := tickets.NewProvider(cfg.Tickets)
+	if err != nil {
+		return err
+	}
+	req.TicketSystem, _, err = validateTicket(provider, req.TicketNumber, req.TicketSystem, false)
+	return err
+}
+
+// activateRequested submits the activation a request describes
+func activateRequested(role azure.RoleAssignment, req *apiRoleRequest) (*azure.ActivationResult, error) {
+	result, err := azure.ActivateRole(azure.ActivationRequest{
+		Role:          role,
+		Duration:      req.Duration,
+		Justification: req.Justification,
+		TicketNumber:  req.TicketNumber,
+		TicketSystem:  req.TicketSystem,
+	})
+	if err == nil && !result.IsDryRun() && !result.Existing {
+		recordActivation(role)
+		invalidateActiveCache()
+	}
+	return result, err
+}
+
+// deactivateRequested deactivates an active role
+func deactivateRequested(role azure.RoleAssignment) (*azure.ActivationResult, error) {
+	result, err := azure.DeactivateRole(role)
+	if err == nil && !result.IsDryRun() {
+		invalidateActiveCache()
+	}
+	return result, err
+}
+
+// writeLookupError reports a failed role lookup with a fitting status
+func writeLookupError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, errRoleNotFound) {
+		status = http.StatusNotFound
+	}
+	writeAPIError(w, status, err)
+}
+
+func serveActivate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRoleRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := checkActivationRequest(req); err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	role, err := findEligibleRole(req)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if err := refuseHighRisk(*role); err != nil {
+		writeAPIError(w, http.StatusForbidden, err)
+		return
+	}
+	if err := checkGuardrails([]guardedRequest{{role: *role, minutes: req.Duration}}, nil); err != nil {
+		writeAPIError(w, http.StatusForbidden, err)
+		return
+	}
+
+	result, err := activateRequested(*role, req)
+	writeRoleResult(w, *role, result, err)
+}
+
+func serveDeactivate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRoleRequest(w, r)
+	if !ok {
+		return
+	}
+	role, err := findActiveRole(req)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+
+	result, err := deactivateRequested(*role)
+	writeRoleResult(w, *role, result, err)
+}
+
+// writeRoleResult reports the outcome of a schedule request like -o json does
+func writeRoleResult(w http.ResponseWriter, role azure.RoleAssignment, result *azure.ActivationResult, err error) {
+	out := toActivationResultJSON(activationOutcome{role: role, result: result, err: err})
+	status := http.StatusOK
+	switch {
+	case err != nil:
+		status = http.StatusBadGateway
+		switch {
+		case exitCode(err) == exitPolicyViolation:
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, azure.ErrThrottled):
+			status = http.StatusTooManyRequests
+		}
+	case result.IsPendingApproval():
+		status = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
+}
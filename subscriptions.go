@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/google/uuid"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/config"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// largeTenantSubscriptions is the number of subscriptions above which the
+// first run offers to scan only some of them
+const largeTenantSubscriptions = 20
+
+// rescanAll ignores scan_subscriptions for this run
+var rescanAll bool
+
+// configureSubscriptionScan limits the role scans to the subscriptions in
+// the config file, matched by name or ID
+func configureSubscriptionScan(patterns []string) {
+	if rescanAll || len(patterns) == 0 {
+		azure.ScanFilter = nil
+		return
+	}
+	azure.ScanFilter = func(sub azure.Subscription) bool {
+		for _, pattern := range patterns {
+			if globMatch(pattern, sub.Name) || globMatch(pattern, sub.ID) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// singleSubscription returns the subscription --subscription names exactly,
+// by ID or name, so discovery can skip every other subscription. A glob
+// pattern only filters the results of the full scan.
+func singleSubscription() (azure.Subscription, bool, error) {
+	if subscriptionPattern == "" || isPattern(subscriptionPattern) || strings.ContainsAny(subscriptionPattern, "*?[") {
+		return azure.Subscription{}, false, nil
+	}
+
+	// The list comes from the cache or the local az profile, so this is cheap
+	subs, err := azure.ListSubscriptions()
+	if err != nil {
+		return azure.Subscription{}, false, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		if strings.EqualFold(sub.ID, subscriptionPattern) || strings.EqualFold(sub.Name, subscriptionPattern) {
+			return sub, true, nil
+		}
+	}
+	if _, err := uuid.Parse(subscriptionPattern); err == nil {
+		return azure.Subscription{ID: subscriptionPattern}, true, nil
+	}
+	return azure.Subscription{}, false, fmt.Errorf("no subscription named %q, check 'az account list'", subscriptionPattern)
+}
+
+// offerSubscriptionScan asks which subscriptions to scan on the first run
+// in a large tenant, and saves the answer as scan_subscriptions. Scanning
+// every subscription is slow when there are hundreds of them and the user
+// is eligible in only a few.
+func offerSubscriptionScan(nonInteractive bool) error {
+	if cfg.ScanSubscriptions != nil || rescanAll || nonInteractive || outputFormat != "table" ||
+		azure.DiscoveryMode == azure.DiscoverAggregate || !term.IsTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+
+	subs, err := ui.SpinWithResult("Listing subscriptions", azure.ListSubscriptions, nonInteractive)
+	if err != nil {
+		// The scan lists them again and reports the failure
+		return nil
+	}
+	if len(subs) <= largeTenantSubscriptions {
+		return nil
+	}
+
+	sort.Slice(subs, func(i, j int) bool { return strings.ToLower(subs[i].Name) < strings.ToLower(subs[j].Name) })
+	items := make([]ui.PickItem, len(subs))
+	for i, sub := range subs {
+		items[i] = ui.PickItem{Title: sub.Name, Description: sub.ID}
+	}
+
+	infof("This tenant has %d subscriptions. Choose the ones to scan for eligible roles (esc scans all of them).\n", len(subs))
+	chosen, err := ui.PickMany("Subscriptions to scan", items)
+	patterns := []string{"*"}
+	switch {
+	case errors.Is(err, ui.ErrPickSkipped):
+	case err != nil:
+		return err
+	default:
+		patterns = make([]string, len(chosen))
+		for i, index := range chosen {
+			patterns[i] = subs[index].ID
+		}
+	}
+
+	cfg.ScanSubscriptions = patterns
+	configureSubscriptionScan(patterns)
+	if err := config.SetValue("scan_subscriptions", patterns); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to save scan_subscriptions: %v\n", err)
+		return nil
+	}
+	path, _ := config.Path()
+	infof("Saved to %s as scan_subscriptions; use --rescan-all to scan every subscription once.\n", path)
+	return nil
+}
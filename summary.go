@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ica-js/hacktivator/internal/azure"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+const (
+	// longActivation is how long a single activation can last before the
+	// summary suggests shorter ones
+	longActivation = 8 * time.Hour
+	// frequentActivations is how many activations of one role a week the
+	// summary considers frequent enough to question
+	frequentActivations = 10
+)
+
+var (
+	summaryWeek  bool
+	summaryMonth bool
+	summarySince string
+)
+
+func summaryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "summary",
+		Short: "Summarize how long you held each role",
+		Long: `Totals your activations per role and scope over the last week (--week, the
+default), the last 30 days (--month) or since --since: how often each role was
+activated and for how many hours in all.
+
+Roles held for 8 hours or more at a time, or activated 10 or more times a
+week, are flagged. Shorter activations of narrower roles keep standing access
+to a minimum.`,
+		Args: cobra.NoArgs,
+		RunE: runSummary,
+	}
+	cmd.Flags().BoolVar(&summaryWeek, "week", false, "Summarize the last 7 days (default)")
+	cmd.Flags().BoolVar(&summaryMonth, "month", false, "Summarize the last 30 days")
+	cmd.Flags().StringVar(&summarySince, "since", "", "Summarize since this, e.g. 14d or 2024-01-31")
+	cmd.MarkFlagsMutuallyExclusive("week", "month", "since")
+	return cmd
+}
+
+// roleUsage is the activation total of one role at one scope for summary
+type roleUsage struct {
+	RoleName         string    `json:"roleName"`
+	ScopeName        string    `json:"scopeName"`
+	Scope            string    `json:"scope"`
+	RoleDefinitionID string    `json:"roleDefinitionId"`
+	Activations      int       `json:"activations"`
+	Hours            float64   `json:"hours"`
+	LongestMinutes   int       `json:"longestMinutes"`
+	LastActivated    time.Time `json:"lastActivated"`
+	Flags            []string  `json:"flags,omitempty"`
+}
+
+func runSummary(cmd *cobra.Command, args []string) error {
+	window := "the last 7 days"
+	since := time.Now().AddDate(0, 0, -7)
+	switch {
+	case summaryMonth:
+		window, since = "the last 30 days", time.Now().AddDate(0, 0, -30)
+	case summarySince != "":
+		var err error
+		if since, err = parseSince(summarySince); err != nil {
+			return err
+		}
+		window = "since " + since.Format("2006-01-02")
+	}
+
+	var requests []azure.ScheduleRequest
+	active, err := ui.SpinWithResult("Fetching activation history", func() ([]azure.RoleAssignment, error) {
+		var err error
+		if requests, err = azure.ListScheduleRequests(); err != nil {
+			return nil, err
+		}
+		return azure.GetActiveRoleAssignments()
+	}, false)
+	if err != nil {
+		return fmt.Errorf("failed to get activation history: %w", err)
+	}
+
+	now := time.Now()
+	usage := summarizeUsage(historyTimeline(requests, active, now), since, now)
+
+	if outputFormat == "json" {
+		out, err := json.MarshalIndent(usage, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render JSON: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	if len(usage) == 0 {
+		infof("No activations in %s.\n", window)
+		return nil
+	}
+
+	total, count := 0.0, 0
+	for _, u := range usage {
+		total += u.Hours
+		count += u.Activations
+	}
+	infof("%d activation(s), %.1f elevated hour(s) in %s:\n\n", count, total, window)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  ROLE\tSCOPE\tACTIVATIONS\tHOURS\tLONGEST\tLAST\tNOTE")
+	for _, u := range usage {
+		fmt.Fprintf(w, "  %s\t%s\t%d\t%.1f\t%s\t%s\t%s\n", u.RoleName, u.ScopeName, u.Activations, u.Hours,
+			ui.FormatMinutes(u.LongestMinutes), ui.FormatTime(u.LastActivated), strings.Join(u.Flags, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var nudges []string
+	for _, u := range usage {
+		for _, flag := range u.Flags {
+			switch flag {
+			case "long":
+				nudges = append(nudges, fmt.Sprintf("%s on %s was held for %s at once; request only as long as the task needs",
+					u.RoleName, u.ScopeName, ui.FormatMinutes(u.LongestMinutes)))
+			case "frequent":
+				nudges = append(nudges, fmt.Sprintf("%s on %s was activated %d times; if it's part of daily work, a narrower role may do",
+					u.RoleName, u.ScopeName, u.Activations))
+			}
+		}
+	}
+	if len(nudges) > 0 {
+		infof("\n")
+	}
+	for _, n := range nudges {
+		infof("%s\n", ui.SubtleStyle.Render(n))
+	}
+	return nil
+}
+
+// summarizeUsage totals the granted activations of a timeline from
+// historyTimeline between since and now, most hours first. Only the part of
+// each activation within the window counts toward its hours.
+func summarizeUsage(timeline []azure.RoleAssignment, since, now time.Time) []roleUsage {
+	byRole := make(map[string]*roleUsage)
+	var order []string
+	for _, a := range timeline {
+		switch {
+		case a.AssignmentType == "Assigned":
+			// Standing assignments aren't activations
+			continue
+		case a.Status != timelineActive && a.Status != timelineEnded && a.Status != timelineDeactivated:
+			continue
+		}
+		start, end := a.StartDateTime, now
+		if a.EndDateTime != nil && a.EndDateTime.Before(now) {
+			end = *a.EndDateTime
+		}
+		if !end.After(since) {
+			continue
+		}
+
+		key := strings.ToLower(path.Base(a.RoleDefinitionID) + "|" + a.Scope)
+		u, ok := byRole[key]
+		if !ok {
+			u = &roleUsage{RoleName: a.RoleName, ScopeName: ui.ScopeLabel(a), Scope: a.Scope, RoleDefinitionID: a.RoleDefinitionID}
+			byRole[key] = u
+			order = append(order, key)
+		}
+		u.Activations++
+		u.Hours += end.Sub(maxTime(start, since)).Hours()
+		u.LongestMinutes = max(u.LongestMinutes, int(end.Sub(start).Minutes()))
+		if start.After(u.LastActivated) {
+			u.LastActivated = start
+		}
+	}
+
+	weeks := max(now.Sub(since).Hours()/(7*24), 1)
+	usage := make([]roleUsage, 0, len(order))
+	for _, key := range order {
+		u := byRole[key]
+		u.Hours = math.Round(u.Hours*10) / 10
+		if time.Duration(u.LongestMinutes)*time.Minute >= longActivation {
+			u.Flags = append(u.Flags, "long")
+		}
+		if float64(u.Activations)/weeks >= frequentActivations {
+			u.Flags = append(u.Flags, "frequent")
+		}
+		usage = append(usage, *u)
+	}
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Hours > usage[j].Hours })
+	return usage
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
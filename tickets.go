@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ica-js/hacktivator/internal/tickets"
+	"github.com/ica-js/hacktivator/internal/ui"
+)
+
+// resolveTicket fills in the ticket number and system before activation. It
+// offers a picker of assigned tickets from the configured provider, prompts
+// when config requires a ticket, and validates the result against the provider.
+func resolveTicket() error {
+	provider, err := tickets.NewProvider(cfg.Tickets)
+	if err != nil {
+		return err
+	}
+
+	if ticketNum == "" && provider != nil && cfg.Tickets.Pick && !nonInteractive {
+		if err := pickTicket(provider); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.Requirements.CheckTicket(ticketNum); err != nil && !nonInteractive {
+		ticketNum, err = ui.PromptForTicketNumber(cfg.Requirements.CheckTicket)
+		if err != nil {
+			return fmt.Errorf("failed to get ticket number: %w", err)
+		}
+	}
+
+	system, ticket, err := validateTicket(provider, ticketNum, ticketSys, true)
+	if err != nil {
+		return err
+	}
+	ticketSys = system
+	if ticket != nil {
+		ticketTitle = ticket.Title
+	}
+	return nil
+}
+
+// validateTicket checks a ticket number against the configured provider, as
+// every activation does, from the CLI, the REST API or the MCP server. It
+// returns the ticket system to send with it, the provider's unless system
+// names one, and the ticket if it was looked up: with tickets.validate it
+// must exist and be open, and system must be the provider's. spin shows a
+// spinner while looking it up.
+func validateTicket(provider tickets.Provider, number, system string, spin bool) (string, *tickets.Ticket, error) {
+	if number == "" || provider == nil {
+		return system, nil, nil
+	}
+	if system == "" {
+		system = provider.System()
+	}
+	if !cfg.Tickets.Validate {
+		return system, nil, nil
+	}
+	if !strings.EqualFold(system, provider.System()) {
+		return "", nil, fmt.Errorf("tickets are validated in %s, not %s", provider.System(), system)
+	}
+
+	get := func() (*tickets.Ticket, error) { return provider.Get(number) }
+	var ticket *tickets.Ticket
+	var err error
+	if spin {
+		ticket, err = ui.SpinWithResult("Validating ticket "+number, get, nonInteractive)
+	} else {
+		ticket, err = get()
+	}
+	if errors.Is(err, tickets.ErrNotFound) {
+		return "", nil, fmt.Errorf("ticket %s not found in %s", number, provider.System())
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to validate ticket %s: %w", number, err)
+	}
+	if !ticket.Open {
+		return "", nil, fmt.Errorf("ticket %s is not open (status: %s)", number, ticket.Status)
+	}
+	return system, ticket, nil
+}
+
+// pickTicket lets the user attach one of their open assigned tickets
+func pickTicket(provider tickets.Provider) error {
+	assigned, err := ui.SpinWithResult("Fetching your open tickets", provider.ListAssigned, nonInteractive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to list %s tickets: %v\n", provider.System(), err)
+		return nil
+	}
+	if len(assigned) == 0 {
+		return nil
+	}
+
+	items := make([]ui.PickItem, len(assigned))
+	for i, t := range assigned {
+		items[i] = ui.PickItem{
+			Title:       fmt.Sprintf("%s  %s", t.Number, t.Title),
+			Description: t.Status,
+		}
+	}
+
+	idx, err := ui.Pick("Select ticket to attach (esc to skip)", items)
+	if errors.Is(err, ui.ErrPickSkipped) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ticket selection failed: %w", err)
+	}
+
+	ticketNum = assigned[idx].Number
+	ticketTitle = assigned[idx].Title
+	return nil
+}